@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The article's `authorize()` takes no context. A call that hangs, for example, because the authorization server does not respond, cannot be canceled, and `Close()` would wait forever. Authorization functions that accept a context receive the refresher's context, which gets canceled by `Close()` or by the parent context.
+
+// `NewTokenContext` works like `NewToken` but receives an authorization function that takes a context. The context is canceled when the token is closed and, with `WithAuthorizeTimeout`, when the attempt takes too long.
+func NewTokenContext(ctx context.Context, auth func(ctx context.Context) (string, time.Duration, error), opts ...Option) *Token {
+	a := newToken(opts)
+	a.authorize = auth
+	a.start(ctx)
+	return a
+}
+
+// `WithAuthorizeTimeout` bounds each call to the authorization function. When the timeout expires, the call's context gets canceled, and the attempt counts as failed. Only authorization functions that take a context can honor the timeout. By default, there is no timeout.
+func WithAuthorizeTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.authorizeTimeout = d
+	}
+}
+
+// Method `callAuthorize` calls `authorize` with a context that carries the per-attempt timeout, if one is configured.
+func (a *Token) callAuthorize(ctx context.Context, authorize func(context.Context) (string, []byte, time.Duration, error)) (string, []byte, time.Duration, error) {
+	if a.opts.authorizeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.authorizeTimeout)
+		defer cancel()
+	}
+	return authorize(ctx)
+}
+
+// `ignoreContext` adapts an authorization function without context to the signature of one with a context.
+func ignoreContext(auth func() (string, time.Duration, error)) func(context.Context) (string, time.Duration, error) {
+	return func(context.Context) (string, time.Duration, error) {
+		return auth()
+	}
+}
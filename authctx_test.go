@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAuthorizeTimeout(t *testing.T) {
+	var calls atomic.Int32
+	auth := func(ctx context.Context) (string, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			// The first call hangs until its context gets canceled.
+			<-ctx.Done()
+			return "", 0, ctx.Err()
+		}
+		return "tok", time.Hour, nil
+	}
+	tok := NewTokenContext(context.Background(), auth, WithAuthorizeTimeout(20*time.Millisecond))
+	defer tok.Close()
+
+	if _, err := tok.Get(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+	waitFor(t, time.Second, func() bool {
+		got, _ := tok.Get()
+		return got == "tok"
+	})
+}
+
+func TestCloseCancelsAuthorize(t *testing.T) {
+	started := make(chan struct{})
+	auth := func(ctx context.Context) (string, time.Duration, error) {
+		close(started)
+		<-ctx.Done()
+		return "", 0, ctx.Err()
+	}
+	tok := NewTokenContext(context.Background(), auth)
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		tok.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on a hanging authorize call")
+	}
+}
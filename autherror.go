@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Clients that call `Get()` want to know why there is no token: Is the authorization server down, or were the credentials rejected? Matching error strings breaks as soon as a message changes. The sentinel errors, such as `ErrClosed`, `ErrNotReady`, or `ErrExpired`, work with `errors.Is`, and an authorization function that returns an `AuthError` lets clients inspect the failure with `errors.As`.
+
+// `maxAuthErrorBody` limits how much of the response body an `AuthError` keeps.
+const maxAuthErrorBody = 4096
+
+// An `AuthError` describes a failed call to the authorization endpoint. Authorization functions may return it, and `Get()` passes it on to the clients.
+// The refresh loop retries an `AuthError` only if `Retryable` is true. `Permanent()` and `Transient()` override this, as they do for any error.
+type AuthError struct {
+	// `StatusCode` is the HTTP status code of the response, or zero if there was no response.
+	StatusCode int
+	// `Body` is the response body, truncated to 4 KiB.
+	Body string
+	// `Retryable` reports whether the failure may go away by retrying.
+	Retryable bool
+	// `Err` is the underlying error, if any.
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	msg := "authorization failed"
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// Method `Temporary` returns `Retryable`, for classifiers such as `ClassifyTemporary`.
+func (e *AuthError) Temporary() bool { return e.Retryable }
+
+// `NewAuthError` returns an `AuthError` for a response of the authorization endpoint that did not succeed. It reads and closes the response body. Server errors (5xx) and rate limiting (429) are retryable.
+func NewAuthError(resp *http.Response) *AuthError {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAuthErrorBody))
+	return &AuthError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		Retryable:  resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+		Err:        err,
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Clients find the `AuthError` of the authorization function in the error of `Get`.
+func TestAuthErrorAs(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, &AuthError{StatusCode: http.StatusServiceUnavailable, Retryable: true}
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	_, err := tok.Get()
+	var ae *AuthError
+	if !errors.As(err, &ae) || ae.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want AuthError with status 503, got %v", err)
+	}
+}
+
+// A non-retryable `AuthError` stops the retries, and a retryable one does not.
+func TestAuthErrorRetryable(t *testing.T) {
+	for _, retryable := range []bool{false, true} {
+		var calls atomic.Int32
+		tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+			calls.Add(1)
+			return "", 0, &AuthError{StatusCode: http.StatusUnauthorized, Retryable: retryable}
+		}, WithLogger(NopLogger))
+		tok.Get()
+		time.Sleep(5 * retryDelay)
+		tok.Close()
+		if n := calls.Load(); (n > 1) != retryable {
+			t.Errorf("retryable %v: got %d calls", retryable, n)
+		}
+	}
+}
+
+func TestNewAuthError(t *testing.T) {
+	tests := []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusUnauthorized, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadGateway, true},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(strings.Repeat("x", 2*maxAuthErrorBody)))
+		}))
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ae := NewAuthError(resp)
+		srv.Close()
+		if ae.StatusCode != tt.status || ae.Retryable != tt.retryable || len(ae.Body) != maxAuthErrorBody || ae.Err != nil {
+			t.Errorf("status %d: got %+v", tt.status, ae)
+		}
+		if !strings.Contains(ae.Error(), http.StatusText(tt.status)) {
+			t.Errorf("status %d: got message %q", tt.status, ae.Error())
+		}
+	}
+}
+
+// Sentinel errors identify the package, like the errors of the subpackages do.
+func TestSentinelErrorPrefix(t *testing.T) {
+	for _, err := range []error{
+		ErrClosed, ErrGetTimeout, ErrExpired, ErrMaxServeAge, ErrRefreshDeadline, ErrWarmupFailed,
+		ErrPaused, ErrNotReady, ErrInvalidToken, ErrRetriesExhausted,
+	} {
+		if !strings.HasPrefix(err.Error(), "refresh: ") {
+			t.Errorf("%q lacks the refresh: prefix", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package awscreds adapts a refreshing token to the credentials provider contract of AWS-style SDKs, where a provider has a method `Retrieve(ctx) (Credentials, error)`.
+//
+// The package does not depend on any SDK. Its `Credentials` type mirrors the fields of `aws.Credentials` from the AWS SDK for Go v2, so that a thin wrapper can convert one into the other.
+//
+// For temporary credentials from AWS STS, `AssumeRole` and `AssumeRoleWithWebIdentity` return fetch functions for a refresher of `Credentials`, and `CredentialsProvider` serves the refreshed credentials.
+package awscreds
+
+import (
+	"context"
+	"time"
+)
+
+// TokenSource is the part of a refreshing token that the provider needs. `*Token` from the parent package satisfies it.
+type TokenSource interface {
+	GetContext(ctx context.Context) (string, error)
+	ExpiresAt() (time.Time, bool)
+}
+
+// Credentials mirrors the fields of `aws.Credentials`.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Source          string
+	CanExpire       bool
+	Expires         time.Time
+}
+
+// Provider retrieves credentials from a TokenSource.
+type Provider struct {
+	Source TokenSource
+	// Map turns a token into credentials. If nil, the token becomes the SessionToken.
+	Map func(token string) Credentials
+}
+
+// New returns a Provider that serves the tokens of src as session tokens.
+func New(src TokenSource) *Provider {
+	return &Provider{Source: src}
+}
+
+// Retrieve returns credentials built from the current token. Expires is set to the token's expiry time. Retrieve stops waiting for the token when ctx is done.
+func (p *Provider) Retrieve(ctx context.Context) (Credentials, error) {
+	// Read the expiry before and after the token. On a cold start, only the second read knows the expiry. If a refresh happens in between, the reported expiry is the older, earlier one, which errs on the safe side.
+	before, known := p.Source.ExpiresAt()
+	token, err := p.Source.GetContext(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	expires, ok := p.Source.ExpiresAt()
+	if known && (!ok || before.Before(expires)) {
+		expires, ok = before, true
+	}
+	var c Credentials
+	if p.Map != nil {
+		c = p.Map(token)
+	} else {
+		c = Credentials{SessionToken: token}
+	}
+	if c.Source == "" {
+		c.Source = "refresh"
+	}
+	c.CanExpire = ok
+	c.Expires = expires
+	return c, nil
+}
@@ -0,0 +1,81 @@
+package awscreds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeSource struct {
+	token   string
+	expires time.Time
+	err     error
+}
+
+func (f *fakeSource) GetContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return f.token, f.err
+}
+
+func (f *fakeSource) ExpiresAt() (time.Time, bool) { return f.expires, !f.expires.IsZero() }
+
+func TestRetrieve(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	p := New(&fakeSource{token: "tok", expires: exp})
+
+	c, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SessionToken != "tok" || !c.CanExpire || !c.Expires.Equal(exp) {
+		t.Fatalf("unexpected credentials: %+v", c)
+	}
+
+	p.Map = func(token string) Credentials {
+		return Credentials{AccessKeyID: "AKID", SecretAccessKey: token, Source: "custom"}
+	}
+	c, _ = p.Retrieve(context.Background())
+	if c.SecretAccessKey != "tok" || c.Source != "custom" || !c.Expires.Equal(exp) {
+		t.Fatalf("custom mapping not applied: %+v", c)
+	}
+}
+
+func TestRetrieveError(t *testing.T) {
+	p := New(&fakeSource{err: errors.New("down")})
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Fatal("want error")
+	}
+}
+
+// coldSource has no expiry before the first token is fetched, like a freshly started token.
+type coldSource struct {
+	fakeSource
+	fetched bool
+}
+
+func (c *coldSource) GetContext(ctx context.Context) (string, error) {
+	c.fetched = true
+	return c.fakeSource.GetContext(ctx)
+}
+
+func (c *coldSource) ExpiresAt() (time.Time, bool) {
+	if !c.fetched {
+		return time.Time{}, false
+	}
+	return c.fakeSource.ExpiresAt()
+}
+
+func TestRetrieveColdStart(t *testing.T) {
+	exp := time.Now().Add(time.Hour)
+	p := New(&coldSource{fakeSource: fakeSource{token: "tok", expires: exp}})
+	c, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.CanExpire || !c.Expires.Equal(exp) {
+		t.Fatalf("want expiry %v, got %+v", exp, c)
+	}
+}
@@ -0,0 +1,307 @@
+package awscreds
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The functions in this file fetch temporary credentials from AWS STS. They return fetch functions for `NewRefresher` of the parent package, so that the credentials get refreshed before they expire, and `CredentialsProvider` serves them to an SDK.
+
+// STSOption configures an STS fetch function.
+type STSOption func(*stsConfig)
+
+type stsConfig struct {
+	client   *http.Client
+	endpoint string
+	region   string
+	duration time.Duration
+	now      func() time.Time
+}
+
+// WithHTTPClient sets the client for STS requests. The default is `http.DefaultClient`.
+func WithHTTPClient(c *http.Client) STSOption {
+	return func(cfg *stsConfig) {
+		cfg.client = c
+	}
+}
+
+// WithRegion sends requests to the regional STS endpoint of region, and signs them for that region. The default is the global endpoint in "us-east-1".
+func WithRegion(region string) STSOption {
+	return func(cfg *stsConfig) {
+		cfg.region = region
+		cfg.endpoint = "https://sts." + region + ".amazonaws.com"
+	}
+}
+
+// WithEndpoint overrides the STS endpoint URL, for example, for a VPC endpoint or for tests.
+func WithEndpoint(u string) STSOption {
+	return func(cfg *stsConfig) {
+		cfg.endpoint = u
+	}
+}
+
+// WithDuration requests credentials that are valid for d. The default is STS's default of one hour.
+func WithDuration(d time.Duration) STSOption {
+	return func(cfg *stsConfig) {
+		cfg.duration = d
+	}
+}
+
+func newSTSConfig(opts []STSOption) *stsConfig {
+	cfg := &stsConfig{
+		client:   http.DefaultClient,
+		endpoint: "https://sts.amazonaws.com",
+		region:   "us-east-1",
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ErrInvalidResponse is returned if STS responds with success but the response cannot be used.
+var ErrInvalidResponse = errors.New("awscreds: invalid STS response")
+
+// ResponseError is returned if STS responds with an error status.
+type ResponseError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("awscreds: STS returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Temporary reports whether the error is likely to go away by retrying. This is the case for server errors (5xx) and throttling. Client errors, such as a denied role, are not temporary.
+func (e *ResponseError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests || e.Code == "Throttling"
+}
+
+// AssumeRoleWithWebIdentity returns a fetch function that exchanges the OIDC token in tokenFile for credentials of roleARN. The file is read on each call, so that rotated tokens, like the projected service account tokens of EKS, get picked up. The request needs no AWS credentials.
+func AssumeRoleWithWebIdentity(roleARN, sessionName, tokenFile string, opts ...STSOption) func(ctx context.Context) (Credentials, time.Duration, error) {
+	cfg := newSTSConfig(opts)
+	return func(ctx context.Context) (Credentials, time.Duration, error) {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return Credentials{}, 0, fmt.Errorf("awscreds: reading web identity token: %w", err)
+		}
+		form := url.Values{
+			"Action":           {"AssumeRoleWithWebIdentity"},
+			"RoleArn":          {roleARN},
+			"RoleSessionName":  {sessionName},
+			"WebIdentityToken": {strings.TrimSpace(string(token))},
+		}
+		return cfg.call(ctx, form, nil)
+	}
+}
+
+// AssumeRole returns a fetch function that assumes roleARN. base returns the credentials that sign the request, for example, static credentials of an IAM user.
+func AssumeRole(roleARN, sessionName string, base func(ctx context.Context) (Credentials, error), opts ...STSOption) func(ctx context.Context) (Credentials, time.Duration, error) {
+	cfg := newSTSConfig(opts)
+	return func(ctx context.Context) (Credentials, time.Duration, error) {
+		creds, err := base(ctx)
+		if err != nil {
+			return Credentials{}, 0, err
+		}
+		form := url.Values{
+			"Action":          {"AssumeRole"},
+			"RoleArn":         {roleARN},
+			"RoleSessionName": {sessionName},
+		}
+		return cfg.call(ctx, form, &creds)
+	}
+}
+
+// stsResponse matches the results of all AssumeRole variants.
+type stsResponse struct {
+	Result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"Credentials"`
+	} `xml:",any"`
+	Metadata struct{} `xml:"ResponseMetadata"`
+}
+
+// maxResponseSize limits how much of a response gets read.
+const maxResponseSize = 1 << 20
+
+// call posts form to STS, signed with creds if not nil, and parses the credentials in the response.
+func (cfg *stsConfig) call(ctx context.Context, form url.Values, creds *Credentials) (Credentials, time.Duration, error) {
+	form.Set("Version", "2011-06-15")
+	if cfg.duration > 0 {
+		form.Set("DurationSeconds", strconv.Itoa(int(cfg.duration/time.Second)))
+	}
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.endpoint, strings.NewReader(body))
+	if err != nil {
+		return Credentials{}, 0, fmt.Errorf("awscreds: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if creds != nil {
+		signV4(req, []byte(body), *creds, cfg.region, "sts", cfg.now())
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return Credentials{}, 0, fmt.Errorf("awscreds: STS request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return Credentials{}, 0, fmt.Errorf("awscreds: reading STS response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &ResponseError{StatusCode: resp.StatusCode}
+		var er struct {
+			Error struct {
+				Code    string `xml:"Code"`
+				Message string `xml:"Message"`
+			} `xml:"Error"`
+		}
+		if xml.Unmarshal(b, &er) == nil {
+			e.Code, e.Message = er.Error.Code, er.Error.Message
+		}
+		return Credentials{}, 0, e
+	}
+
+	var sr stsResponse
+	if err := xml.Unmarshal(b, &sr); err != nil {
+		return Credentials{}, 0, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	c := sr.Result.Credentials
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" || c.Expiration.IsZero() {
+		return Credentials{}, 0, fmt.Errorf("%w: incomplete credentials", ErrInvalidResponse)
+	}
+	return Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Source:          "sts",
+		CanExpire:       true,
+		Expires:         c.Expiration,
+	}, c.Expiration.Sub(cfg.now()), nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to req. body is the request body.
+func signV4(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	// Canonical headers: host and all headers set so far, lowercase and sorted.
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(req.Header.Get(k))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var canonQuery []string
+	for _, k := range keys {
+		vs := query[k]
+		sort.Strings(vs)
+		for _, v := range vs {
+			canonQuery = append(canonQuery, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+
+	payloadHash := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(canonQuery, "&"),
+		canonHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape escapes s as required by Signature Version 4: all bytes except unreserved characters, with spaces as "%20".
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// CredentialsSource is the part of a refresher of credentials that `CredentialsProvider` needs. `*Refresher[Credentials]` from the parent package satisfies it.
+type CredentialsSource interface {
+	GetContext(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsProvider serves the credentials of a CredentialsSource through the `Retrieve` method of `aws.CredentialsProvider`.
+type CredentialsProvider struct {
+	Source CredentialsSource
+}
+
+// NewCredentialsProvider returns a CredentialsProvider for src.
+func NewCredentialsProvider(src CredentialsSource) *CredentialsProvider {
+	return &CredentialsProvider{Source: src}
+}
+
+// Retrieve returns the current credentials. It stops waiting for them when ctx is done.
+func (p *CredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	return p.Source.GetContext(ctx)
+}
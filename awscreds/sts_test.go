@@ -0,0 +1,111 @@
+package awscreds
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The "get-vanilla" case of the AWS Signature Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signV4(req, nil, Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}, "us-east-1", "service", now)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("want\n%s\ngot\n%s", want, got)
+	}
+}
+
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleResult>
+    <Credentials>
+      <AccessKeyId>ASIATEST</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>2030-01-01T01:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/demo/app</Arn></AssumedRoleUser>
+  </AssumeRoleResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</AssumeRoleResponse>`
+
+func TestAssumeRole(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDBASE/20300101/eu-west-1/sts/aws4_request") {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		r.ParseForm()
+		if r.PostForm.Get("Action") != "AssumeRole" || r.PostForm.Get("RoleArn") != "arn:aws:iam::123456789012:role/demo" || r.PostForm.Get("DurationSeconds") != "900" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.Write([]byte(assumeRoleResponse))
+	}))
+	defer srv.Close()
+
+	base := func(ctx context.Context) (Credentials, error) {
+		return Credentials{AccessKeyID: "AKIDBASE", SecretAccessKey: "basesecret"}, nil
+	}
+	fetch := AssumeRole("arn:aws:iam::123456789012:role/demo", "app", base,
+		WithRegion("eu-west-1"), WithEndpoint(srv.URL), WithDuration(15*time.Minute),
+		func(cfg *stsConfig) {
+			cfg.now = func() time.Time { return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC) }
+		})
+	c, lifespan, err := fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.AccessKeyID != "ASIATEST" || c.SessionToken != "session" || !c.CanExpire || lifespan != time.Hour {
+		t.Fatalf("unexpected credentials %+v, lifespan %v", c, lifespan)
+	}
+
+	p := NewCredentialsProvider(sourceFunc(func(ctx context.Context) (Credentials, error) { return c, nil }))
+	if got, _ := p.Retrieve(context.Background()); got != c {
+		t.Fatalf("provider returned %+v", got)
+	}
+}
+
+type sourceFunc func(ctx context.Context) (Credentials, error)
+
+func (f sourceFunc) GetContext(ctx context.Context) (Credentials, error) { return f(ctx) }
+
+func TestAssumeRoleWithWebIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("oidc-token\n"), 0o600)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Header.Get("Authorization") != "" || r.PostForm.Get("WebIdentityToken") != "oidc-token" {
+			t.Errorf("unexpected request %v", r.PostForm)
+		}
+		w.Write([]byte(strings.ReplaceAll(assumeRoleResponse, "AssumeRole", "AssumeRoleWithWebIdentity")))
+	}))
+	defer srv.Close()
+
+	c, _, err := AssumeRoleWithWebIdentity("arn:aws:iam::123456789012:role/demo", "app", tokenFile, WithEndpoint(srv.URL))(context.Background())
+	if err != nil || c.AccessKeyID != "ASIATEST" {
+		t.Fatalf("unexpected result (%+v, %v)", c, err)
+	}
+}
+
+func TestSTSError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`))
+	}))
+	defer srv.Close()
+
+	base := func(ctx context.Context) (Credentials, error) {
+		return Credentials{AccessKeyID: "a", SecretAccessKey: "s"}, nil
+	}
+	_, _, err := AssumeRole("arn", "app", base, WithEndpoint(srv.URL))(context.Background())
+	var re *ResponseError
+	if !errors.As(err, &re) || re.Code != "AccessDenied" || re.Temporary() {
+		t.Fatalf("want permanent AccessDenied error, got %v", err)
+	}
+}
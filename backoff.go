@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// The article's backoff "strategy" retries after a constant delay. A `BackoffPolicy` makes this pluggable.
+
+// A `BackoffPolicy` decides how long the refresh loop waits before retrying a failed refresh.
+type BackoffPolicy interface {
+	// NextDelay returns the delay before retry number `attempt` (starting at 1), given the time elapsed since the first failed attempt of the current series. If ok is false, the loop stops retrying.
+	NextDelay(attempt int, elapsed time.Duration) (delay time.Duration, ok bool)
+}
+
+// `ErrRetriesExhausted` is returned by `Get()` after the backoff policy gave up on the current refresh window.
+var ErrRetriesExhausted = errors.New("refresh: retries exhausted")
+
+// `WithBackoff` sets the backoff policy for failed refreshes. For exponential backoff with full jitter, combine `ExponentialBackoff` with `WithBackoffJitter(1)`.
+func WithBackoff(p BackoffPolicy) Option {
+	return func(o *options) {
+		o.backoff = p
+	}
+}
+
+// `DefaultGiveUpInterval` is the least time the refresh loop waits after it gave up on a refresh window.
+const DefaultGiveUpInterval = time.Minute
+
+// `WithGiveUpInterval` sets the least time the refresh loop waits after the backoff policy or the refresh deadline gave up on a refresh window. If the last good token is still valid, or the failed call reported a lifespan, the loop waits until that lifespan's refresh time if this is later. It panics if `d` is not positive.
+func WithGiveUpInterval(d time.Duration) Option {
+	if d <= 0 {
+		panic("refresh: WithGiveUpInterval interval must be positive")
+	}
+	return func(o *options) {
+		o.giveUpInterval = d
+	}
+}
+
+// `ExponentialBackoff` doubles (or multiplies by `Multiplier`) the delay after each failed attempt, up to `Max`.
+type ExponentialBackoff struct {
+	// Initial is the delay before the first retry. Zero means `retryDelay`.
+	Initial time.Duration
+	// Max caps the delay. Zero means no cap.
+	Max time.Duration
+	// Multiplier is the growth factor of the delay. Zero means 2.
+	Multiplier float64
+	// MaxAttempts limits the number of retries. Zero means no limit.
+	MaxAttempts int
+	// MaxElapsed limits the time spent retrying. Zero means no limit.
+	MaxElapsed time.Duration
+}
+
+// Method `NextDelay` implements `BackoffPolicy`.
+func (b ExponentialBackoff) NextDelay(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+	if b.MaxElapsed > 0 && elapsed >= b.MaxElapsed {
+		return 0, false
+	}
+	initial, mult := b.Initial, b.Multiplier
+	if initial == 0 {
+		initial = retryDelay
+	}
+	if mult == 0 {
+		mult = 2
+	}
+	d := float64(initial) * math.Pow(mult, float64(attempt-1))
+	if b.Max > 0 && d > float64(b.Max) {
+		return b.Max, true
+	}
+	// Guard against overflow after many attempts without a cap.
+	if d > math.MaxInt64 {
+		return time.Duration(math.MaxInt64), true
+	}
+	return time.Duration(d), true
+}
+
+// `constantBackoff` is the article's original strategy: always wait `retryDelay`.
+type constantBackoff struct{}
+
+func (constantBackoff) NextDelay(int, time.Duration) (time.Duration, bool) {
+	return retryDelay, true
+}
+
+// Method `retryAfterError` decides when to try again after a failed refresh. The backoff policy provides the delay. If the policy gives up, or if the current window of failed attempts would exceed the refresh deadline, the loop gives up on the window and waits for `giveUpDelay`.
+func (a *Token) retryAfterError(lifespan time.Duration, err error) (time.Duration, error) {
+	now := a.now()
+	if a.windowStart.IsZero() {
+		a.windowStart = now
+		a.attempts = 0
+	}
+	a.attempts++
+	elapsed := now.Sub(a.windowStart)
+
+	var policy BackoffPolicy = constantBackoff{}
+	if a.opts.backoff != nil {
+		policy = a.opts.backoff
+	}
+	delay, ok := policy.NextDelay(a.attempts, elapsed)
+	var giveUp error
+	switch {
+	case !ok:
+		giveUp = ErrRetriesExhausted
+	case a.opts.refreshDeadline > 0 && elapsed+delay > a.opts.refreshDeadline:
+		giveUp = ErrRefreshDeadline
+	}
+	if giveUp != nil {
+		a.windowStart = time.Time{}
+		return a.jitter(a.giveUpDelay(lifespan), a.opts.refreshJitter), fmt.Errorf("%w: %w", giveUp, err)
+	}
+	return a.jitter(delay, a.opts.backoffJitter), err
+}
+
+// Method `giveUpDelay` returns the time until the next refresh window opens. Authorization functions usually report a lifespan of zero along with an error, so the lifespan alone would open the next window right away. The window opens when the lifespan reported by the failed call, or else the remaining lifespan of the last good token, is due for a refresh, but not before the give-up interval has passed.
+func (a *Token) giveUpDelay(lifespan time.Duration) time.Duration {
+	d := a.opts.giveUpInterval
+	if d <= 0 {
+		d = DefaultGiveUpInterval
+	}
+	if lifespan <= 0 {
+		if exp, ok := a.ExpiresAt(); ok {
+			lifespan = exp.Sub(a.now())
+		}
+	}
+	if lifespan > 0 {
+		d = max(d, a.refreshAfter(lifespan))
+	}
+	return d
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, MaxAttempts: 5}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		d, ok := b.NextDelay(i+1, 0)
+		if !ok || d != w*time.Millisecond {
+			t.Errorf("attempt %d: want %v, got (%v, %v)", i+1, w*time.Millisecond, d, ok)
+		}
+	}
+	if _, ok := b.NextDelay(6, 0); ok {
+		t.Error("want give-up after MaxAttempts")
+	}
+	if _, ok := (ExponentialBackoff{MaxElapsed: time.Second}).NextDelay(1, time.Second); ok {
+		t.Error("want give-up after MaxElapsed")
+	}
+	if d, _ := (ExponentialBackoff{}).NextDelay(100, 0); d <= 0 {
+		t.Errorf("uncapped delay overflowed: %v", d)
+	}
+}
+
+func TestBackoffMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	authErr := errors.New("down")
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "", 0, authErr
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithBackoff(ExponentialBackoff{Initial: time.Millisecond, MaxAttempts: 3}))
+
+	// The initial attempt, plus three retries.
+	waitFor(t, time.Second, func() bool { return calls.Load() == 4 })
+	time.Sleep(30 * time.Millisecond)
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("want 4 calls, got %d", n)
+	}
+	if _, err := tok.Get(); !errors.Is(err, ErrRetriesExhausted) || !errors.Is(err, authErr) {
+		t.Fatalf("want ErrRetriesExhausted, got %v", err)
+	}
+}
+
+// After giving up, the next window opens after the give-up interval, even though the failed call reported no lifespan.
+func TestGiveUpInterval(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "", 0, errors.New("down")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewToken(ctx, auth, WithBackoff(ExponentialBackoff{Initial: time.Millisecond, MaxAttempts: 1}), WithGiveUpInterval(50*time.Millisecond), WithLogger(NopLogger))
+
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("want 2 calls before the give-up interval passed, got %d", n)
+	}
+	waitFor(t, time.Second, func() bool { return calls.Load() >= 3 })
+}
+
+// `recordingBackoff` records the attempts it was asked about.
+type recordingBackoff struct {
+	attempts chan int
+}
+
+func (r recordingBackoff) NextDelay(attempt int, _ time.Duration) (time.Duration, bool) {
+	r.attempts <- attempt
+	return time.Millisecond, true
+}
+
+func TestBackoffPolicyPluggable(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) <= 3 {
+			return "", time.Hour, errors.New("down")
+		}
+		return "tok", time.Hour, nil
+	}
+	rec := recordingBackoff{attempts: make(chan int, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithBackoff(rec))
+
+	waitFor(t, time.Second, func() bool {
+		got, err := tok.Get()
+		return err == nil && got == "tok"
+	})
+	close(rec.attempts)
+	var got []int
+	for a := range rec.attempts {
+		got = append(got, a)
+	}
+	if len(got) != 3 || got[0] != 1 || got[2] != 3 {
+		t.Fatalf("want attempts [1 2 3], got %v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TLS certificates expire just like tokens. Servers that pick up a renewed certificate only on restart either have to restart regularly or risk serving an expired certificate. A `CertRefresher` keeps a certificate fresh in the background and hands it to `crypto/tls` on each handshake, so that the new certificate takes effect with the next connection.
+
+// `ErrNoCertificate` is returned when no valid certificate is available.
+var ErrNoCertificate = errors.New("refresh: no valid certificate")
+
+// `ErrCertificateExpired` is returned for a certificate whose `NotAfter` time has passed. It wraps `ErrNoCertificate`.
+var ErrCertificateExpired = fmt.Errorf("%w: certificate expired", ErrNoCertificate)
+
+// A `CertRefresher` is a `Refresher` for TLS certificates. If a renewal fails, it keeps serving the previous certificate until that certificate expires.
+type CertRefresher struct {
+	r    *Refresher[*tls.Certificate]
+	last atomic.Pointer[tls.Certificate]
+}
+
+// `NewCertRefresher` spawns a goroutine that calls `load` to get the initial certificate and then again shortly before the certificate's lifespan ends. `load` may read files, ask a SPIFFE workload API, or run an ACME client. If `load` returns a lifespan of zero, the lifespan ends when the certificate expires. The goroutine stops when `ctx` is canceled.
+func NewCertRefresher(ctx context.Context, load func(ctx context.Context) (*tls.Certificate, time.Duration, error)) *CertRefresher {
+	return &CertRefresher{
+		r: NewRefresher(ctx, func(ctx context.Context) (*tls.Certificate, time.Duration, error) {
+			cert, lifespan, err := load(ctx)
+			if err != nil {
+				return nil, lifespan, err
+			}
+			if cert == nil {
+				return nil, 0, ErrNoCertificate
+			}
+			// Parse the leaf before the certificate gets published, so that readers never need to write to it.
+			if cert.Leaf == nil {
+				leaf, err := parseLeaf(cert)
+				if err != nil {
+					return nil, 0, err
+				}
+				withLeaf := *cert
+				withLeaf.Leaf = leaf
+				cert = &withLeaf
+			}
+			if lifespan == 0 {
+				lifespan, err = certLifespan(cert)
+			}
+			return cert, lifespan, err
+		}),
+	}
+}
+
+// `CertFromFiles` returns a load function for `NewCertRefresher` that reads a PEM encoded certificate and key from disk, and reads them again every `interval`, or when the certificate expires, whatever comes first. An `interval` that is not positive means reading them again only when the certificate expires.
+func CertFromFiles(certFile, keyFile string, interval time.Duration) func(ctx context.Context) (*tls.Certificate, time.Duration, error) {
+	return func(ctx context.Context) (*tls.Certificate, time.Duration, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, 0, err
+		}
+		lifespan, err := certLifespan(&cert)
+		if err != nil {
+			return nil, 0, err
+		}
+		if interval > 0 {
+			lifespan = min(lifespan, interval)
+		}
+		return &cert, lifespan, nil
+	}
+}
+
+// `parseLeaf` parses the leaf certificate of `cert`.
+func parseLeaf(cert *tls.Certificate) (*x509.Certificate, error) {
+	if len(cert.Certificate) == 0 {
+		return nil, ErrNoCertificate
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// `certLifespan` returns the time until the leaf certificate of `cert` expires. An expired certificate is an error, as a lifespan that is not positive would make the refresher fetch it again right away. `certLifespan` does not modify `cert`, as it may be in use by concurrent handshakes.
+func certLifespan(cert *tls.Certificate) (time.Duration, error) {
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = parseLeaf(cert); err != nil {
+			return 0, err
+		}
+	}
+	d := time.Until(leaf.NotAfter)
+	if d <= 0 {
+		return 0, fmt.Errorf("%w: %s", ErrCertificateExpired, leaf.NotAfter)
+	}
+	return d, nil
+}
+
+// Method `Certificate` returns the current certificate. After a failed renewal, it returns the previous certificate as long as that one has not expired. It never returns an expired certificate.
+func (c *CertRefresher) Certificate() (*tls.Certificate, error) {
+	cert, err := c.r.Get()
+	if err == nil {
+		if _, err = certLifespan(cert); err == nil {
+			c.last.Store(cert)
+			return cert, nil
+		}
+	}
+	if last := c.last.Load(); last != nil {
+		if _, lerr := certLifespan(last); lerr == nil {
+			return last, nil
+		}
+	}
+	return nil, err
+}
+
+// Method `GetCertificate` can be used as `tls.Config.GetCertificate` for servers.
+func (c *CertRefresher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.Certificate()
+}
+
+// Method `GetClientCertificate` can be used as `tls.Config.GetClientCertificate` for clients that authenticate with mTLS.
+func (c *CertRefresher) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return c.Certificate()
+}
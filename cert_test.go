@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// `writeCert` writes a self-signed certificate with the given common name to `dir` and returns the file names.
+func writeCert(t *testing.T, dir, name string, notAfter time.Time) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// A rotated certificate file is picked up without restarting.
+func TestCertFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "one", time.Now().Add(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewCertRefresher(ctx, CertFromFiles(certFile, keyFile, 30*time.Millisecond))
+	cfg := &tls.Config{GetCertificate: c.GetCertificate}
+
+	cert, err := cfg.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(t, cert); cn != "one" {
+		t.Fatalf("want certificate one, got %q", cn)
+	}
+
+	writeCert(t, dir, "two", time.Now().Add(time.Hour))
+	waitFor(t, time.Second, func() bool {
+		cert, err := c.GetClientCertificate(nil)
+		return err == nil && commonName(t, cert) == "two"
+	})
+}
+
+// After a failed renewal, the previous certificate is served until it expires.
+func TestCertRefresherKeepsPrevious(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "one", time.Now().Add(time.Hour))
+	load := CertFromFiles(certFile, keyFile, 20*time.Millisecond)
+	var fail atomic.Bool
+	var failed atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewCertRefresher(ctx, func(ctx context.Context) (*tls.Certificate, time.Duration, error) {
+		if fail.Load() {
+			failed.Add(1)
+			return nil, 0, errors.New("CA unavailable")
+		}
+		return load(ctx)
+	})
+	if _, err := c.Certificate(); err != nil {
+		t.Fatal(err)
+	}
+	fail.Store(true)
+	waitFor(t, time.Second, func() bool { return failed.Load() > 0 })
+	cert, err := c.Certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := commonName(t, cert); cn != "one" {
+		t.Fatalf("want certificate one, got %q", cn)
+	}
+}
+
+// Without an explicit lifespan, the certificate's expiry decides.
+func TestCertLifespan(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "one", time.Now().Add(time.Hour))
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := certLifespan(&cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d <= 59*time.Minute || d > time.Hour {
+		t.Fatalf("want lifespan of about an hour, got %v", d)
+	}
+	if _, err := certLifespan(&tls.Certificate{}); !errors.Is(err, ErrNoCertificate) {
+		t.Fatalf("want ErrNoCertificate, got %v", err)
+	}
+}
+
+// An expired certificate is an error, not a lifespan that makes the refresher spin.
+func TestCertExpired(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "old", time.Now().Add(-time.Minute))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewCertRefresher(ctx, CertFromFiles(certFile, keyFile, time.Hour))
+	if _, err := c.Certificate(); !errors.Is(err, ErrCertificateExpired) || !errors.Is(err, ErrNoCertificate) {
+		t.Fatalf("want ErrCertificateExpired, got %v", err)
+	}
+	// A load function that reports its own lifespan does not get an expired certificate served either.
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewCertRefresher(ctx, func(context.Context) (*tls.Certificate, time.Duration, error) {
+		return &cert, time.Hour, nil
+	})
+	if _, err := c.Certificate(); !errors.Is(err, ErrCertificateExpired) {
+		t.Fatalf("want ErrCertificateExpired, got %v", err)
+	}
+}
+
+// Without a polling interval, the certificate gets read again when it expires.
+func TestCertFromFilesNoInterval(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "one", time.Now().Add(time.Hour))
+	_, d, err := CertFromFiles(certFile, keyFile, 0)(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d <= 59*time.Minute {
+		t.Fatalf("want lifespan of about an hour, got %v", d)
+	}
+}
+
+// A certificate without a parsed leaf gets one before it is published, so that concurrent handshakes only read it.
+func TestCertRefresherParsesLeaf(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "one", time.Now().Add(time.Hour))
+	loaded, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Leaf = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewCertRefresher(ctx, func(context.Context) (*tls.Certificate, time.Duration, error) {
+		return &loaded, 0, nil
+	})
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			if _, err := c.GetClientCertificate(nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	cert, _ := c.Certificate()
+	if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "one" {
+		t.Fatalf("want parsed leaf, got %v", cert.Leaf)
+	}
+	if loaded.Leaf != nil {
+		t.Error("the loader's certificate was modified")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// During an outage of the authorization server, every retry adds to its load, and backoff only thins out the retries. A circuit breaker stops them. After a number of consecutive failures, the circuit opens, and the token makes no authorization calls for a while. Then the circuit becomes half-open and lets a single probe call through. If the probe succeeds, the circuit closes again. If it fails, the circuit opens for another round.
+
+// `CircuitState` is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// `CircuitClosed` lets all authorization calls through.
+	CircuitClosed CircuitState = iota
+	// `CircuitOpen` blocks all authorization calls.
+	CircuitOpen
+	// `CircuitHalfOpen` lets a probe call through.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// `CircuitBreaker` configures the circuit breaker of a token.
+type CircuitBreaker struct {
+	// `Threshold` is the number of consecutive failures that open the circuit. It must be positive.
+	Threshold int
+	// `OpenFor` is the time the circuit stays open before a probe call. It must be positive.
+	OpenFor time.Duration
+	// `OnStateChange` is called on each state transition, outside the refresh loop like the hooks in `hooks.go`. It may be nil.
+	OnStateChange func(from, to CircuitState)
+}
+
+// `ErrCircuitOpen` is returned while the circuit is open, and while the probe call of a half-open circuit is in flight. It wraps the error of the last failed call.
+var ErrCircuitOpen = errors.New("refresh: circuit open")
+
+// `WithCircuitBreaker` adds a circuit breaker around the authorization function. While the circuit is open, clients receive `ErrCircuitOpen`, or the previous token if `WithStaleOnError` is set. `ForceRefresh()` does not bypass an open circuit.
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	if cb.Threshold <= 0 || cb.OpenFor <= 0 {
+		panic(fmt.Sprintf("refresh: invalid circuit breaker threshold %d or duration %v", cb.Threshold, cb.OpenFor))
+	}
+	return func(o *options) {
+		o.circuit = &cb
+	}
+}
+
+// `circuitBreaker` holds the state of the breaker. Authorization calls may come from the refresh goroutine and from callers of `SwapAuthorizer()` or on-demand tokens, so a mutex protects the state.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     CircuitState
+	failures  int
+	openUntil time.Time
+	lastErr   error
+	// `probing` is true while the probe call of the half-open circuit is in flight. Other calls are blocked meanwhile.
+	probing bool
+}
+
+// Method `CircuitState` returns the current state of the token's circuit breaker. Without a circuit breaker, it is always `CircuitClosed`.
+func (a *Token) CircuitState() CircuitState {
+	a.breaker.mu.Lock()
+	defer a.breaker.mu.Unlock()
+	return a.breaker.state
+}
+
+// Method `circuitAllow` returns an error if the circuit is open. Once the open period is over, it switches to half-open and lets the call through as the probe. Until the probe call has ended, it blocks all other calls. The caller must call `circuitEndProbe` when the call is over.
+func (a *Token) circuitAllow() error {
+	if a.opts.circuit == nil {
+		return nil
+	}
+	b := &a.breaker
+	var change circuitChange
+	defer a.logCircuit(&change)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitClosed:
+		return nil
+	case CircuitOpen:
+		if a.now().Before(b.openUntil) {
+			return fmt.Errorf("%w: %w", ErrCircuitOpen, b.lastErr)
+		}
+		change = a.setCircuit(CircuitHalfOpen)
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: %w", ErrCircuitOpen, b.lastErr)
+		}
+	}
+	b.probing = true
+	return nil
+}
+
+// Method `circuitEndProbe` ends the probe call, if any. If the call ended without an outcome for `circuitRecord`, for example, because the token came from a store, the circuit stays half-open and the next call becomes the probe.
+func (a *Token) circuitEndProbe() {
+	if a.opts.circuit == nil {
+		return
+	}
+	a.breaker.mu.Lock()
+	a.breaker.probing = false
+	a.breaker.mu.Unlock()
+}
+
+// Method `circuitRecord` updates the breaker with the outcome of a call. If the call opened the circuit, it returns the error wrapped in `ErrCircuitOpen`.
+func (a *Token) circuitRecord(err error) error {
+	if a.opts.circuit == nil {
+		return err
+	}
+	b := &a.breaker
+	var change circuitChange
+	defer a.logCircuit(&change)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		change = a.setCircuit(CircuitClosed)
+		return nil
+	}
+	b.failures++
+	b.lastErr = err
+	if b.state == CircuitHalfOpen || b.failures >= a.opts.circuit.Threshold {
+		b.openUntil = a.now().Add(a.opts.circuit.OpenFor)
+		change = a.setCircuit(CircuitOpen)
+		return fmt.Errorf("%w: %w", ErrCircuitOpen, err)
+	}
+	return err
+}
+
+// Method `circuitWait` returns the time until the circuit may let a call through again.
+func (a *Token) circuitWait() time.Duration {
+	if a.opts.circuit == nil {
+		return 0
+	}
+	a.breaker.mu.Lock()
+	defer a.breaker.mu.Unlock()
+	if a.breaker.state != CircuitOpen {
+		return 0
+	}
+	return a.breaker.openUntil.Sub(a.now())
+}
+
+// A `circuitChange` is a state transition of the circuit breaker. Equal states mean no transition.
+type circuitChange struct {
+	from, to CircuitState
+}
+
+// Method `setCircuit` changes the state and queues the `OnStateChange` hook, so that the hook sees the transitions in order. It returns the transition for `logCircuit`. The caller holds the lock.
+func (a *Token) setCircuit(to CircuitState) circuitChange {
+	from := a.breaker.state
+	if from == to {
+		return circuitChange{}
+	}
+	a.breaker.state = to
+	if f := a.opts.circuit.OnStateChange; f != nil {
+		a.hooks.push(func() { f(from, to) })
+	}
+	return circuitChange{from, to}
+}
+
+// Method `logCircuit` logs a transition. The caller must not hold the lock, as the logger may be slow or call back into the token.
+func (a *Token) logCircuit(c *circuitChange) {
+	if c.from != c.to {
+		a.logEvent(a.runCtx, EventCircuit, "Circuit breaker state changed", "from", c.from, "to", c.to)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	authErr := errors.New("down")
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) <= 3 {
+			return "", 0, authErr
+		}
+		return "tok", time.Hour, nil
+	}
+	var (
+		mu          sync.Mutex
+		transitions []string
+	)
+	cb := CircuitBreaker{
+		Threshold: 2,
+		OpenFor:   time.Minute,
+		OnStateChange: func(from, to CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, from.String()+">"+to.String())
+		},
+	}
+	tok := NewToken(context.Background(), auth, WithCircuitBreaker(cb), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	step := func(d time.Duration) {
+		waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+		clock.Advance(d)
+	}
+
+	// Two failures open the circuit.
+	step(retryDelay)
+	waitFor(t, time.Second, func() bool { return tok.CircuitState() == CircuitOpen })
+	if _, err := tok.Get(); !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, authErr) {
+		t.Fatalf("want ErrCircuitOpen wrapping %v, got %v", authErr, err)
+	}
+	// An open circuit blocks forced refreshes, too.
+	if err := tok.ForceRefresh(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("want ErrCircuitOpen, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("want 2 calls, got %d", n)
+	}
+
+	// The failed probe opens the circuit again, and the next probe closes it.
+	step(time.Minute)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 3 })
+	step(time.Minute)
+	waitFor(t, time.Second, func() bool {
+		got, _ := tok.Get()
+		return got == "tok"
+	})
+	if s := tok.CircuitState(); s != CircuitClosed {
+		t.Fatalf("want closed circuit, got %v", s)
+	}
+
+	want := []string{"closed>open", "open>half-open", "half-open>open", "open>half-open", "half-open>closed"}
+	waitFor(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(transitions) == len(want)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("want transitions %v, got %v", want, transitions)
+		}
+	}
+}
+
+// A half-open circuit lets exactly one of many concurrent calls through.
+func TestCircuitHalfOpenSingleProbe(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	a := newToken([]Option{WithCircuitBreaker(CircuitBreaker{Threshold: 1, OpenFor: time.Minute}), WithClock(clock), WithLogger(NopLogger)})
+	a.runCtx = context.Background()
+	a.circuitAllow()
+	a.circuitRecord(errors.New("down"))
+	clock.Advance(time.Minute)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.circuitAllow(); err == nil {
+				allowed.Add(1)
+			} else if !errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("want ErrCircuitOpen, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := allowed.Load(); n != 1 {
+		t.Fatalf("want 1 probe, got %d", n)
+	}
+	// A probe that ends without an outcome hands the probe over to the next call.
+	a.circuitEndProbe()
+	if err := a.circuitAllow(); err != nil {
+		t.Fatalf("want the next probe to pass, got %v", err)
+	}
+	a.circuitRecord(nil)
+	if s := a.CircuitState(); s != CircuitClosed {
+		t.Fatalf("want closed circuit, got %v", s)
+	}
+}
+
+// `stateLogger` reads the circuit state of its token in each log call, like a logger that adds the token's state to its entries.
+type stateLogger struct {
+	tok    atomic.Pointer[Token]
+	states chan CircuitState
+}
+
+func (l *stateLogger) Log(_ context.Context, _ slog.Level, msg string, _ ...any) {
+	if t := l.tok.Load(); t != nil && msg == "Circuit breaker state changed" {
+		l.states <- t.CircuitState()
+	}
+}
+
+// A logger that calls back into the token does not deadlock the circuit breaker.
+func TestCircuitLoggerCallback(t *testing.T) {
+	l := &stateLogger{states: make(chan CircuitState, 10)}
+	a := newToken([]Option{WithCircuitBreaker(CircuitBreaker{Threshold: 1, OpenFor: time.Minute}), WithLogger(l)})
+	a.runCtx = context.Background()
+	l.tok.Store(a)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.circuitAllow()
+		a.circuitRecord(errors.New("down"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deadlock in logger callback")
+	}
+	if s := <-l.states; s != CircuitOpen {
+		t.Fatalf("want open circuit, got %v", s)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// A `Clock` tells the time and sets timers. By default, a `Token` uses the system clock. Tests can pass a fake clock through `WithClock` to control time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// `systemClock` is the real thing.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// `WithClock` sets the clock that the token uses for timers, expiry times, and statistics.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// Method `clock` returns the token's clock.
+func (a *Token) clock() Clock {
+	if a.opts.clock == nil {
+		return systemClock{}
+	}
+	return a.opts.clock
+}
+
+// Method `now` returns the current time of the token's clock.
+func (a *Token) now() time.Time {
+	return a.clock().Now()
+}
+
+// Method `after` starts a timer on the token's clock.
+func (a *Token) after(d time.Duration) <-chan time.Time {
+	return a.clock().After(d)
+}
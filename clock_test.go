@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+var _ Clock = (*refreshtest.Clock)(nil)
+
+func TestWithClock(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	clock := refreshtest.NewClock(start)
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithClock(clock))
+
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want token 1, got %s", got)
+	}
+	if exp, _ := tok.ExpiresAt(); !exp.Equal(start.Add(time.Hour)) {
+		t.Fatalf("want expiry on the fake clock, got %v", exp)
+	}
+
+	// An hour of fake time passes in no time.
+	clock.Advance(time.Hour - lifeSpanSafetyMargin)
+	waitFor(t, time.Second, func() bool {
+		got, _ := tok.Get()
+		return got == "2"
+	})
+	if s := tok.Stats(); !s.LastRefresh.Equal(start.Add(time.Hour - lifeSpanSafetyMargin)) {
+		t.Fatalf("want last refresh on the fake clock, got %v", s.LastRefresh)
+	}
+}
+
+func TestRefreshIntervalWithFakes(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	auth := refreshtest.NewFakeAuthorizer(refreshtest.Response{LifeSpan: time.Minute})
+	auth.Now = clock.Now
+	tok := NewToken(context.Background(), auth.Authorize, WithClock(clock), WithSafetyMargin(10*time.Second))
+	defer tok.Close()
+
+	auth.AssertEventually(t, 1, time.Second)
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(50 * time.Second)
+	auth.AssertEventually(t, 2, time.Second)
+	auth.AssertInterval(t, 2, 50*time.Second, 50*time.Second)
+}
@@ -0,0 +1,54 @@
+package main
+
+import "errors"
+
+// The article points out that the refresh goroutine lives until its context gets canceled, which puts the burden of canceling on the `Token` consumer. `Close()` lets the `Token` manage its goroutine's lifetime itself.
+// Shutting down happens in two steps. Canceling the context ends the token's life right away: every waiting `Get()` call returns `ErrClosed`, and an authorization function that takes a context sees it canceled. Cleaning up takes a moment longer: an authorization call in flight must return, queued hooks still run, and a leader releases its lease. `Done()` signals the end of the second step.
+
+// `ErrClosed` is returned by `Get()` and its variants after the token has been closed or its context has been canceled.
+var ErrClosed = errors.New("refresh: token closed")
+
+// Method `Close` cancels the token and waits until all of its goroutines have exited, including an authorization call in flight. Afterwards, `Get()` returns `ErrClosed`. Calling `Close` more than once is safe.
+// An authorization function without a context cannot be interrupted, so `Close` waits for it to return.
+func (a *Token) Close() error {
+	a.cancel()
+	// A lazy token that was never used, or an idle token, has no goroutine to wait for. Make sure that it never starts one.
+	a.runMu.Lock()
+	if !a.running.Load() && !a.closed {
+		a.finish()
+	}
+	a.runMu.Unlock()
+	<-a.done
+	return nil
+}
+
+// Method `Done` returns a channel that is closed when the token has been closed or its context canceled, and all cleanup has finished: the refresh goroutine has stopped, no authorization call is in flight, and all queued hooks have run.
+func (a *Token) Done() <-chan struct{} {
+	return a.done
+}
+
+// Method `closing` returns a channel that is closed as soon as the token starts shutting down. Readers select on it, so that they do not wait for the cleanup.
+func (a *Token) closing() <-chan struct{} {
+	return a.runCtx.Done()
+}
+
+// Method `track` adds a unit of work to the cleanup that `Done()` waits for. It returns false if the token is already shutting down. The caller must call `a.cleanup.Done()` when the work is finished.
+func (a *Token) track() bool {
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+	if a.closed {
+		return false
+	}
+	a.cleanup.Add(1)
+	return true
+}
+
+// Method `finish` marks the token as closed and closes `a.done` once the cleanup has finished. The hooks run last, as the other goroutines may queue calls until they stop. The caller must hold `a.runMu`.
+func (a *Token) finish() {
+	a.closed = true
+	go func() {
+		a.cleanup.Wait()
+		a.hooks.close()
+		close(a.done)
+	}()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil })
+	if got, err := tok.Get(); err != nil || got != "tok" {
+		t.Fatalf("want tok, got (%q, %v)", got, err)
+	}
+	if err := tok.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tok.Done():
+	default:
+		t.Fatal("refresh goroutine still running after Close")
+	}
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+	if _, err := tok.GetContext(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("GetContext: want ErrClosed, got %v", err)
+	}
+	if err := tok.ForceRefresh(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("ForceRefresh: want ErrClosed, got %v", err)
+	}
+	if _, ok := tok.TryGet(); ok {
+		t.Fatal("TryGet: want no token after Close")
+	}
+	if err := tok.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestCloseOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tok := NewToken(ctx, func() (string, time.Duration, error) { return "tok", time.Hour, nil })
+	cancel()
+	<-tok.Done()
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed after context cancellation, got %v", err)
+	}
+}
+
+// Waiting readers return as soon as the token gets closed, but `Done()` waits for the authorization call in flight. In on-demand mode, the reader that performs the refresh returns when the call does.
+func TestCloseDrainsInflight(t *testing.T) {
+	for _, mode := range []string{"goroutine", "on-demand"} {
+		t.Run(mode, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			auth := func() (string, time.Duration, error) {
+				close(started)
+				<-release
+				return "tok", time.Hour, nil
+			}
+			var opts []Option
+			if mode == "on-demand" {
+				opts = append(opts, WithOnDemandRefresh())
+			}
+			tok := NewToken(context.Background(), auth, opts...)
+			errs := make(chan error, 2)
+			for i := 0; i < 2; i++ {
+				go func() {
+					_, err := tok.Get()
+					errs <- err
+				}()
+			}
+			<-started
+
+			closed := make(chan struct{})
+			go func() {
+				tok.Close()
+				close(closed)
+			}()
+			waiting := 2
+			if mode == "on-demand" {
+				waiting = 1
+			}
+			for i := 0; i < waiting; i++ {
+				select {
+				case err := <-errs:
+					if !errors.Is(err, ErrClosed) {
+						t.Fatalf("want ErrClosed, got %v", err)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Get still blocked after Close")
+				}
+			}
+			select {
+			case <-tok.Done():
+				t.Fatal("Done closed while authorization is in flight")
+			case <-closed:
+				t.Fatal("Close returned while authorization is in flight")
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			close(release)
+			select {
+			case <-tok.Done():
+			case <-time.After(time.Second):
+				t.Fatal("Done not closed after authorization returned")
+			}
+			<-closed
+			if mode == "on-demand" {
+				if err := <-errs; !errors.Is(err, ErrClosed) {
+					t.Fatalf("refreshing reader: want ErrClosed, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+// `Done()` waits for queued hooks.
+func TestCloseRunsQueuedHooks(t *testing.T) {
+	var ran atomic.Bool
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithOnRefresh(func(string, time.Time) {
+		time.Sleep(20 * time.Millisecond)
+		ran.Store(true)
+	}))
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+	tok.Close()
+	if !ran.Load() {
+		t.Fatal("Close returned before the hook ran")
+	}
+}
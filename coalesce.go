@@ -0,0 +1,16 @@
+package main
+
+import "errors"
+
+// Refresh triggers can arrive close together: the timer fires while clients force a refresh because the API rejected their token, and an operator swaps the authorization function at the same time. The refresh goroutine handles one trigger at a time, so two authorization calls never race within the loop. Still, a trigger that queues behind a refresh should not cause another authorization call, and a result that was overtaken by a newer one must not replace it.
+// To tell old from new, the `Token` counts its generations. Each completed refresh attempt, and each applied swap, starts a new generation.
+// - A `ForceRefresh()` call remembers the generation at the time of the call. If a refresh has completed when the request reaches the refresh goroutine, the token that the client wanted to replace is already gone. The request gets the result of that refresh instead of triggering another one.
+// - Concurrent `SwapAuthorizer()` calls are numbered. A swap that reaches the refresh goroutine after a later one is discarded.
+
+// `ErrSwapSuperseded` is returned by `SwapAuthorizer` if a later call to `SwapAuthorizer` took effect first.
+var ErrSwapSuperseded = errors.New("refresh: authorizer swap superseded by a later swap")
+
+// Method `Generation` returns the number of refresh attempts completed so far, including applied swaps. It increases whenever the token may have changed.
+func (a *Token) Generation() uint64 {
+	return a.generation.Load()
+}
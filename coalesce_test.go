@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// A forced refresh that queues behind a timer refresh is answered by that refresh.
+func TestForceRefreshCoalescesWithTimer(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	release := make(chan struct{})
+	auth := func() (string, time.Duration, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			<-release
+		}
+		return strconv.Itoa(int(n)), time.Minute, nil
+	}
+	tok := NewToken(context.Background(), auth, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+
+	clock.Advance(time.Minute)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+	forced := make(chan error)
+	go func() { forced <- tok.ForceRefresh(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if err := <-forced; err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("want 2 authorization calls, got %d", n)
+	}
+	if got, _ := tok.Get(); got != "2" {
+		t.Fatalf("want token 2, got %q", got)
+	}
+	if g := tok.Generation(); g != 2 {
+		t.Fatalf("want generation 2, got %d", g)
+	}
+}
+
+func TestSwapSuperseded(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "old", time.Hour, nil }, WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+
+	release := make(chan struct{})
+	slow := func() (string, time.Duration, error) {
+		<-release
+		return "slow", time.Hour, nil
+	}
+	fast := func() (string, time.Duration, error) { return "fast", time.Hour, nil }
+
+	slowErr := make(chan error)
+	go func() {
+		_, err := tok.SwapAuthorizer(context.Background(), slow)
+		slowErr <- err
+	}()
+	// Let the slow swap take its number first.
+	waitFor(t, time.Second, func() bool { return tok.swapSeq.Load() == 1 })
+	if _, err := tok.SwapAuthorizer(context.Background(), fast); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-slowErr; !errors.Is(err, ErrSwapSuperseded) {
+		t.Fatalf("want ErrSwapSuperseded, got %v", err)
+	}
+	if got, _ := tok.Get(); got != "fast" {
+		t.Fatalf("want token from the later swap, got %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"time"
+)
+
+// A `Store` keeps a token, which is a string. The values of a `Refresher` can be anything: structs, credential bundles, certificates. To keep them in a `Store` as well, a `Codec` turns them into bytes and back. The bytes travel in the `Token` field of a `StoredToken`, so every `Store` implementation works for values, too, including shared stores such as Redis that distribute them to other processes.
+
+// A `Codec` converts values to bytes and back. `Unmarshal` receives a pointer to the value to fill in.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// `JSONCodec` encodes values with package `encoding/json`. Only exported fields survive the round trip. It is the default codec.
+type JSONCodec struct{}
+
+// Method `Marshal` implements `Codec`.
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Method `Unmarshal` implements `Codec`.
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// `GobCodec` encodes values with package `encoding/gob`. Interface values inside `T` require registering their concrete types with `gob.Register`.
+type GobCodec struct{}
+
+// Method `Marshal` implements `Codec`.
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Method `Unmarshal` implements `Codec`.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// `EncodeValue` encodes `v` with `c` into a `StoredToken` that expires at `expiresAt`. The `Token` field holds the encoded bytes in base64, so that stores that expect text can keep them. A nil codec means `JSONCodec`.
+func EncodeValue[T any](c Codec, v T, expiresAt time.Time) (StoredToken, error) {
+	if c == nil {
+		c = JSONCodec{}
+	}
+	data, err := c.Marshal(v)
+	if err != nil {
+		return StoredToken{}, err
+	}
+	return StoredToken{Token: base64.StdEncoding.EncodeToString(data), ExpiresAt: expiresAt}, nil
+}
+
+// `DecodeValue` decodes the value of a `StoredToken` that `EncodeValue` created with the same codec.
+func DecodeValue[T any](c Codec, st StoredToken) (T, error) {
+	var v T
+	if c == nil {
+		c = JSONCodec{}
+	}
+	data, err := base64.StdEncoding.DecodeString(st.Token)
+	if err != nil {
+		return v, err
+	}
+	err = c.Unmarshal(data, &v)
+	return v, err
+}
+
+// `WithValueStore` saves each new value of the refresher to `s`, encoded with `c`, and restores the value from `s` on the first fetch, if it has not expired yet. A nil codec means `JSONCodec`. Errors of the store and the codec do not fail a fetch: a value that cannot be restored is fetched, and a value that cannot be saved is served anyway.
+func WithValueStore(s Store, c Codec) RefresherOption {
+	return func(o *refresherOptions) {
+		o.store = s
+		o.codec = c
+	}
+}
+
+// `storedFetch` wraps `fetch` to restore the first value from `s` and to save each new value to `s`. Expiry times follow `clock`.
+func storedFetch[T any](fetch func(ctx context.Context) (T, time.Duration, error), s Store, c Codec, clock Clock) func(ctx context.Context) (T, time.Duration, error) {
+	// Only the refresher's goroutine calls the fetch function, so `restored` needs no lock.
+	restored := false
+	return func(ctx context.Context) (T, time.Duration, error) {
+		if !restored {
+			restored = true
+			if st, ok, err := s.Load(ctx); err == nil && ok {
+				if remaining := st.ExpiresAt.Sub(clock.Now()); remaining > lifeSpanSafetyMargin {
+					if v, err := DecodeValue[T](c, st); err == nil {
+						return v, remaining, nil
+					}
+				}
+			}
+		}
+		v, lifespan, err := fetch(ctx)
+		if err != nil {
+			return v, lifespan, err
+		}
+		if st, err := EncodeValue(c, v, clock.Now().Add(lifespan)); err == nil {
+			s.Save(ctx, st)
+		}
+		return v, lifespan, nil
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type bundle struct {
+	AccessKey string
+	Secret    string
+	Scopes    []string
+	Issued    time.Time
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	want := bundle{AccessKey: "AKID", Secret: "s3cr3t", Scopes: []string{"read", "write"}, Issued: time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)}
+	expiresAt := time.Date(2023, 10, 18, 1, 0, 0, 0, time.UTC)
+	for _, c := range []Codec{nil, JSONCodec{}, GobCodec{}} {
+		st, err := EncodeValue(c, want, expiresAt)
+		if err != nil {
+			t.Fatalf("%T: %v", c, err)
+		}
+		if !st.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("%T: want expiry %v, got %v", c, expiresAt, st.ExpiresAt)
+		}
+		got, err := DecodeValue[bundle](c, st)
+		if err != nil {
+			t.Fatalf("%T: %v", c, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: want %+v, got %+v", c, want, got)
+		}
+	}
+}
+
+// A second refresher on the same store starts with the value of the first one, and fetches only once it has expired.
+func TestRefresherValueStore(t *testing.T) {
+	store := &MemoryStore{}
+	var calls atomic.Int32
+	fetch := func(context.Context) (bundle, time.Duration, error) {
+		calls.Add(1)
+		return bundle{AccessKey: "AKID", Scopes: []string{"read"}}, time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, c := range []Codec{JSONCodec{}, GobCodec{}} {
+		*store = MemoryStore{}
+		calls.Store(0)
+		first := NewRefresher(ctx, fetch, WithValueStore(store, c))
+		if _, err := first.Get(); err != nil {
+			t.Fatal(err)
+		}
+		second := NewRefresher(ctx, fetch, WithValueStore(store, c))
+		got, err := second.Get()
+		if err != nil || got.AccessKey != "AKID" || len(got.Scopes) != 1 {
+			t.Fatalf("%T: want restored value, got (%+v, %v)", c, got, err)
+		}
+		if n := calls.Load(); n != 1 {
+			t.Fatalf("%T: want 1 fetch, got %d", c, n)
+		}
+
+		st, _, _ := store.Load(ctx)
+		st.ExpiresAt = time.Now()
+		store.Save(ctx, st)
+		third := NewRefresher(ctx, fetch, WithValueStore(store, c))
+		third.Get()
+		if n := calls.Load(); n != 2 {
+			t.Fatalf("%T: want a fetch for the expired value, got %d fetches", c, n)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/appliedgo/refresh/oauth2"
+	"github.com/appliedgo/refresh/vault"
+)
+
+// Adding a token source in code means a code change, a review, and a release. For operations teams, a new API credential is configuration, not code. A config file describes the tokens of a `Manager`: which source each one comes from, with which endpoint and scopes, and how it refreshes. A new token source then only needs a restart.
+// The loader reads JSON. YAML works with any YAML package that can unmarshal into the config types; pass its `Unmarshal` function to `WithConfigUnmarshal`. The package itself depends on the standard library only.
+
+// A `Duration` is a `time.Duration` that reads and writes as a string such as "30s" or "5m".
+type Duration time.Duration
+
+// Method `UnmarshalText` parses a duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Method `MarshalText` formats the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// A `ManagerConfig` describes the named tokens of a `Manager`.
+type ManagerConfig struct {
+	Tokens map[string]TokenConfig `json:"tokens" yaml:"tokens"`
+}
+
+// A `TokenConfig` describes one token. `Type` selects the source and thus which of the source fields apply:
+//   - "oauth2": the client credentials grant at `TokenURL`, with `ClientID`, `ClientSecret`, `Scopes`, `Params`, and `AuthInParams`. `Lifespan` applies to responses without `expires_in`.
+//   - "vault": a Vault login at `Address`, with `Namespace` and `AuthMethod` "approle" (`RoleID`, `SecretID`) or "kubernetes" (`Role`, `JWTPath`). `Lifespan` applies to leases without a duration.
+//   - "file": the contents of the file at `Path`, read again every `Lifespan`, for tokens that a sidecar keeps up to date.
+//   - "custom": the factory registered under the name `Factory` with `WithConfigFactory`, which receives the whole `TokenConfig`, including `Params`.
+//
+// `ClientID`, `ClientSecret`, `RoleID`, and `SecretID` may reference environment variables as `${NAME}`, to keep secrets out of the config file.
+// The remaining fields configure the refresh behavior of all types. Zero values keep the defaults.
+type TokenConfig struct {
+	Type string `json:"type" yaml:"type"`
+
+	TokenURL     string            `json:"token_url,omitempty" yaml:"token_url,omitempty"`
+	ClientID     string            `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+	ClientSecret string            `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	Scopes       []string          `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	Params       map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+	AuthInParams bool              `json:"auth_in_params,omitempty" yaml:"auth_in_params,omitempty"`
+
+	Address    string `json:"address,omitempty" yaml:"address,omitempty"`
+	Namespace  string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	AuthMethod string `json:"auth_method,omitempty" yaml:"auth_method,omitempty"`
+	RoleID     string `json:"role_id,omitempty" yaml:"role_id,omitempty"`
+	SecretID   string `json:"secret_id,omitempty" yaml:"secret_id,omitempty"`
+	Role       string `json:"role,omitempty" yaml:"role,omitempty"`
+	JWTPath    string `json:"jwt_path,omitempty" yaml:"jwt_path,omitempty"`
+
+	Path     string   `json:"path,omitempty" yaml:"path,omitempty"`
+	Lifespan Duration `json:"lifespan,omitempty" yaml:"lifespan,omitempty"`
+
+	Factory string `json:"factory,omitempty" yaml:"factory,omitempty"`
+
+	SafetyMargin   Duration       `json:"safety_margin,omitempty" yaml:"safety_margin,omitempty"`
+	SafetyFraction float64        `json:"safety_fraction,omitempty" yaml:"safety_fraction,omitempty"`
+	AdaptiveMargin bool           `json:"adaptive_margin,omitempty" yaml:"adaptive_margin,omitempty"`
+	JWTExpiry      bool           `json:"jwt_expiry,omitempty" yaml:"jwt_expiry,omitempty"`
+	Backoff        *BackoffConfig `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	BackoffJitter  float64        `json:"backoff_jitter,omitempty" yaml:"backoff_jitter,omitempty"`
+	RefreshJitter  float64        `json:"refresh_jitter,omitempty" yaml:"refresh_jitter,omitempty"`
+}
+
+// A `BackoffConfig` describes an `ExponentialBackoff`.
+type BackoffConfig struct {
+	Initial     Duration `json:"initial,omitempty" yaml:"initial,omitempty"`
+	Max         Duration `json:"max,omitempty" yaml:"max,omitempty"`
+	Multiplier  float64  `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	MaxAttempts int      `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	MaxElapsed  Duration `json:"max_elapsed,omitempty" yaml:"max_elapsed,omitempty"`
+}
+
+// A `ConfigFactory` creates the authorization function for a token of type "custom".
+type ConfigFactory func(ctx context.Context, cfg TokenConfig) (func(ctx context.Context) (string, time.Duration, error), error)
+
+// `ConfigOption` configures how a `Manager` gets built from a config.
+type ConfigOption func(*configLoader)
+
+type configLoader struct {
+	factories  map[string]ConfigFactory
+	unmarshal  func(data []byte, v any) error
+	tokenOpts  []Option
+	managerOps []ManagerOption
+	// `onReload` is called after each reload of a `ConfigReloader`. See `reload.go`.
+	onReload func(err error)
+}
+
+// `WithConfigFactory` registers `f` for tokens of type "custom" whose `Factory` field is `name`.
+func WithConfigFactory(name string, f ConfigFactory) ConfigOption {
+	return func(l *configLoader) {
+		l.factories[name] = f
+	}
+}
+
+// `WithConfigUnmarshal` sets the function that decodes the config file, for example, `yaml.Unmarshal` of a YAML package. By default, the file is decoded as JSON, and unknown fields are an error.
+func WithConfigUnmarshal(unmarshal func(data []byte, v any) error) ConfigOption {
+	return func(l *configLoader) {
+		l.unmarshal = unmarshal
+	}
+}
+
+// `WithConfigTokenOptions` adds options to every token, for example, `WithLogger`. The options of the config come after them and take precedence.
+func WithConfigTokenOptions(opts ...Option) ConfigOption {
+	return func(l *configLoader) {
+		l.tokenOpts = append(l.tokenOpts, opts...)
+	}
+}
+
+// `WithConfigManagerOptions` sets the options of the `Manager` itself.
+func WithConfigManagerOptions(opts ...ManagerOption) ConfigOption {
+	return func(l *configLoader) {
+		l.managerOps = append(l.managerOps, opts...)
+	}
+}
+
+func newConfigLoader(opts []ConfigOption) *configLoader {
+	l := &configLoader{factories: make(map[string]ConfigFactory)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// `LoadManagerConfig` reads the config file at `path` and returns a `Manager` with a token for each entry. Files ending in ".yaml" or ".yml" require `WithConfigUnmarshal`.
+func LoadManagerConfig(path string, opts ...ConfigOption) (*Manager, error) {
+	l := newConfigLoader(opts)
+	cfg, err := l.read(path)
+	if err != nil {
+		return nil, err
+	}
+	return l.build(cfg)
+}
+
+// `NewManagerFromConfig` returns a `Manager` with a token for each entry of `cfg`. If any entry is invalid, it returns an error and creates no tokens at all.
+func NewManagerFromConfig(cfg ManagerConfig, opts ...ConfigOption) (*Manager, error) {
+	return newConfigLoader(opts).build(cfg)
+}
+
+// Method `read` reads and decodes the config file at `path`.
+func (l *configLoader) read(path string) (ManagerConfig, error) {
+	var cfg ManagerConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("refresh: reading config: %w", err)
+	}
+	switch {
+	case l.unmarshal != nil:
+		err = l.unmarshal(data, &cfg)
+	case strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml"):
+		return cfg, fmt.Errorf("refresh: config %s: YAML requires WithConfigUnmarshal", filepath.Base(path))
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("refresh: parsing config %s: %w", filepath.Base(path), err)
+	}
+	return cfg, nil
+}
+
+// Method `build` creates the `Manager`. It validates all entries before it creates any token.
+func (l *configLoader) build(cfg ManagerConfig) (*Manager, error) {
+	type entry struct {
+		auth func(context.Context) (string, time.Duration, error)
+		opts []Option
+	}
+	m := NewManager(l.managerOps...)
+	entries := make(map[string]entry, len(cfg.Tokens))
+	var errs []error
+	for name, tc := range cfg.Tokens {
+		auth, opts, err := l.prepare(m.ctx, name, tc)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entries[name] = entry{auth, opts}
+	}
+	if err := errors.Join(errs...); err != nil {
+		m.Close()
+		return nil, err
+	}
+	for name, e := range entries {
+		m.Register(name, NewTokenContext(m.ctx, e.auth, e.opts...))
+	}
+	return m, nil
+}
+
+// Method `prepare` returns the authorization function and the options for the token `name`.
+func (l *configLoader) prepare(ctx context.Context, name string, tc TokenConfig) (auth func(context.Context) (string, time.Duration, error), opts []Option, err error) {
+	// The option constructors panic on invalid values. In a config file, these are input errors.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("refresh: config for token %q: %v", name, r)
+		}
+	}()
+	auth, err = l.authorizer(ctx, tc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("refresh: config for token %q: %w", name, err)
+	}
+	return auth, append(slices.Clone(l.tokenOpts), tc.options()...), nil
+}
+
+// Method `authorizer` creates the authorization function for the type of `tc`.
+func (l *configLoader) authorizer(ctx context.Context, tc TokenConfig) (func(context.Context) (string, time.Duration, error), error) {
+	switch tc.Type {
+	case "oauth2":
+		if tc.TokenURL == "" || tc.ClientID == "" {
+			return nil, errors.New("oauth2 requires token_url and client_id")
+		}
+		var opts []oauth2.Option
+		for k, v := range tc.Params {
+			opts = append(opts, oauth2.WithParam(k, v))
+		}
+		if tc.AuthInParams {
+			opts = append(opts, oauth2.WithAuthInParams())
+		}
+		if tc.Lifespan > 0 {
+			opts = append(opts, oauth2.WithDefaultExpiry(time.Duration(tc.Lifespan)))
+		}
+		return oauth2.NewClientCredentials(tc.TokenURL, os.ExpandEnv(tc.ClientID), os.ExpandEnv(tc.ClientSecret), tc.Scopes, opts...), nil
+	case "vault":
+		if tc.Address == "" {
+			return nil, errors.New("vault requires address")
+		}
+		var method vault.LoginMethod
+		switch tc.AuthMethod {
+		case "approle":
+			method = vault.AppRole(os.ExpandEnv(tc.RoleID), os.ExpandEnv(tc.SecretID))
+		case "kubernetes":
+			method = vault.Kubernetes(tc.Role, tc.JWTPath)
+		default:
+			return nil, fmt.Errorf("unknown vault auth_method %q", tc.AuthMethod)
+		}
+		var opts []vault.Option
+		if tc.Namespace != "" {
+			opts = append(opts, vault.WithNamespace(tc.Namespace))
+		}
+		if tc.Lifespan > 0 {
+			opts = append(opts, vault.WithDefaultExpiry(time.Duration(tc.Lifespan)))
+		}
+		return vault.NewLogin(tc.Address, method, opts...), nil
+	case "file":
+		if tc.Path == "" || tc.Lifespan <= 0 {
+			return nil, errors.New("file requires path and lifespan")
+		}
+		return fileAuthorizer(tc.Path, time.Duration(tc.Lifespan)), nil
+	case "custom":
+		f, ok := l.factories[tc.Factory]
+		if !ok {
+			return nil, fmt.Errorf("no factory %q", tc.Factory)
+		}
+		return f(ctx, tc)
+	default:
+		return nil, fmt.Errorf("unknown type %q", tc.Type)
+	}
+}
+
+// Method `options` returns the token options for the refresh settings of `tc`.
+func (tc TokenConfig) options() []Option {
+	var opts []Option
+	if tc.SafetyMargin > 0 {
+		opts = append(opts, WithSafetyMargin(time.Duration(tc.SafetyMargin)))
+	}
+	if tc.SafetyFraction != 0 {
+		opts = append(opts, WithSafetyFraction(tc.SafetyFraction))
+	}
+	if tc.AdaptiveMargin {
+		opts = append(opts, WithAdaptiveMargin())
+	}
+	if tc.JWTExpiry {
+		opts = append(opts, WithJWTExpiry())
+	}
+	if b := tc.Backoff; b != nil {
+		opts = append(opts, WithBackoff(ExponentialBackoff{
+			Initial:     time.Duration(b.Initial),
+			Max:         time.Duration(b.Max),
+			Multiplier:  b.Multiplier,
+			MaxAttempts: b.MaxAttempts,
+			MaxElapsed:  time.Duration(b.MaxElapsed),
+		}))
+	}
+	if tc.BackoffJitter != 0 {
+		opts = append(opts, WithBackoffJitter(tc.BackoffJitter))
+	}
+	if tc.RefreshJitter != 0 {
+		opts = append(opts, WithRefreshJitter(tc.RefreshJitter))
+	}
+	return opts
+}
+
+// `fileAuthorizer` returns an authorization function that reads the token from the file at `path`. Each token counts as valid for `lifespan`.
+func fileAuthorizer(path string, lifespan time.Duration) func(context.Context) (string, time.Duration, error) {
+	return func(context.Context) (string, time.Duration, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", 0, err
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", 0, fmt.Errorf("refresh: token file %s is empty", path)
+		}
+		return token, lifespan, nil
+	}
+}
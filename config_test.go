@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadManagerConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "app" || secret != "from-env" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":"oauth-%s","expires_in":3600}`, r.FormValue("scope"))
+	}))
+	defer srv.Close()
+	t.Setenv("TEST_CLIENT_SECRET", "from-env")
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	os.WriteFile(tokenFile, []byte("file-token\n"), 0o600)
+	config := fmt.Sprintf(`{
+		"tokens": {
+			"api": {
+				"type": "oauth2",
+				"token_url": %q,
+				"client_id": "app",
+				"client_secret": "${TEST_CLIENT_SECRET}",
+				"scopes": ["read"],
+				"safety_fraction": 0.8,
+				"backoff": {"initial": "1s", "max": "1m", "max_attempts": 5}
+			},
+			"sidecar": {"type": "file", "path": %q, "lifespan": "5m"},
+			"legacy": {"type": "custom", "factory": "static", "params": {"value": "custom-token"}}
+		}
+	}`, srv.URL, tokenFile)
+	path := filepath.Join(dir, "tokens.json")
+	os.WriteFile(path, []byte(config), 0o600)
+
+	static := func(_ context.Context, tc TokenConfig) (func(context.Context) (string, time.Duration, error), error) {
+		return func(context.Context) (string, time.Duration, error) {
+			return tc.Params["value"], time.Hour, nil
+		}, nil
+	}
+	m, err := LoadManagerConfig(path, WithConfigFactory("static", static), WithConfigTokenOptions(WithLogger(NopLogger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	for name, want := range map[string]string{"api": "oauth-read", "sidecar": "file-token", "legacy": "custom-token"} {
+		if got, err := m.Get(context.Background(), name); got != want || err != nil {
+			t.Errorf("%s: want %q, got (%q, %v)", name, want, got, err)
+		}
+	}
+}
+
+// Invalid entries are reported together, and no token gets created.
+func TestManagerConfigInvalid(t *testing.T) {
+	cfg := ManagerConfig{Tokens: map[string]TokenConfig{
+		"unknown":  {Type: "smoke-signals"},
+		"nourl":    {Type: "oauth2", ClientID: "app"},
+		"fraction": {Type: "file", Path: "/tmp/token", Lifespan: Duration(time.Minute), SafetyFraction: 2},
+		"factory":  {Type: "custom", Factory: "missing"},
+		"vault":    {Type: "vault", Address: "http://vault", AuthMethod: "ldap"},
+	}}
+	_, err := NewManagerFromConfig(cfg)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	for name := range cfg.Tokens {
+		if !strings.Contains(err.Error(), fmt.Sprintf("%q", name)) {
+			t.Errorf("error does not mention %q: %v", name, err)
+		}
+	}
+}
+
+func TestManagerConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o600)
+		return path
+	}
+	if _, err := LoadManagerConfig(write("typo.json", `{"tokens": {"a": {"type": "file", "pth": "/x"}}}`)); err == nil || !strings.Contains(err.Error(), "pth") {
+		t.Errorf("want error for unknown field, got %v", err)
+	}
+	yamlPath := write("tokens.yaml", "tokens: {}")
+	if _, err := LoadManagerConfig(yamlPath); err == nil || !strings.Contains(err.Error(), "WithConfigUnmarshal") {
+		t.Errorf("want error for YAML without unmarshal function, got %v", err)
+	}
+	// A stand-in for a YAML package: any function that fills in the config types works.
+	unmarshal := func(data []byte, v any) error {
+		return json.Unmarshal([]byte(`{"tokens": {}}`), v)
+	}
+	m, err := LoadManagerConfig(yamlPath, WithConfigUnmarshal(unmarshal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+}
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1m30s"`), &d); err != nil || time.Duration(d) != 90*time.Second {
+		t.Fatalf("want 1m30s, got (%v, %v)", time.Duration(d), err)
+	}
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Fatal("want error for invalid duration")
+	}
+	if b, _ := json.Marshal(Duration(time.Minute)); string(b) != `"1m0s"` {
+		t.Fatalf(`want "1m0s", got %s`, b)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/appliedgo/refresh/redisstore"
+)
+
+// `WithSharedStore` takes a lock for each refresh, and any replica may end up fetching. Some setups rather want a single, stable leader that does all the fetching, for example, because only one replica has the credentials, or because the platform already offers leader election (etcd, Kubernetes Lease objects). A `Coordinator` abstracts the election. The leader fetches tokens and saves them to the store. All other replicas poll the store. If the leader goes away, its lease lapses, and the next replica that needs a token takes over.
+
+// A `Coordinator` elects a leader among replicas through a lease. `TryAcquire` tries to become leader for `ttl` and reports whether it succeeded. `Renew` extends the lease of the current leader and reports false if the lease was lost. `Release` gives up the lease.
+type Coordinator interface {
+	TryAcquire(ctx context.Context, ttl time.Duration) (bool, error)
+	Renew(ctx context.Context, ttl time.Duration) (bool, error)
+	Release(ctx context.Context) error
+}
+
+// `WithCoordinator` lets only the leader elected by `c` fetch tokens. The leader saves each token to `store`, and the other replicas load it from there. The leader renews its lease every third of `leaseTTL` and releases it when the token gets closed. A follower waits for at most `leaseTTL` for a new token before it tries to take over. Zero means 10 seconds. If the coordinator fails, the replica fetches on its own.
+// `WithCoordinator` implies `WithStore(store)`.
+func WithCoordinator(c Coordinator, store Store, leaseTTL time.Duration) Option {
+	return func(o *options) {
+		o.store = store
+		o.coordinator = c
+		o.leaseTTL = leaseTTL
+		if o.leaseTTL <= 0 {
+			o.leaseTTL = defaultLockTTL
+		}
+	}
+}
+
+// Method `IsLeader` reports whether this token currently holds the lease of its coordinator.
+func (a *Token) IsLeader() bool {
+	return a.leader.Load()
+}
+
+// Method `follow` returns a token that the leader stored. If this replica is or becomes the leader, `follow` returns false, and the caller fetches the token.
+func (a *Token) follow(ctx context.Context) (tokenResponse, time.Duration, bool) {
+	ttl := a.opts.leaseTTL
+	poll := max(ttl/20, 10*time.Millisecond)
+	for {
+		if a.leader.Load() {
+			return tokenResponse{}, 0, false
+		}
+		won, err := a.opts.coordinator.TryAcquire(ctx, ttl)
+		if err != nil {
+			a.logEvent(ctx, EventRefreshError, "Cannot acquire leadership", "err", err)
+			return tokenResponse{}, 0, false
+		}
+		if won {
+			a.lead(ctx)
+			return tokenResponse{}, 0, false
+		}
+		deadline := a.now().Add(ttl)
+		for a.now().Before(deadline) {
+			if resp, lifespan, ok := a.loadShared(ctx); ok {
+				return resp, lifespan, true
+			}
+			select {
+			case <-a.after(poll):
+			case <-ctx.Done():
+				return tokenResponse{Err: ctx.Err()}, 0, true
+			}
+		}
+		a.logEvent(ctx, EventRefreshError, "Leader did not deliver a token; trying to take over")
+	}
+}
+
+// Method `lead` marks this replica as leader and keeps the lease alive until it is lost or `ctx` is done.
+func (a *Token) lead(ctx context.Context) {
+	a.leader.Store(true)
+	a.logEvent(ctx, EventRefresh, "Acquired leadership")
+	// `lead` runs inside `fetch()`, either in the refresh goroutine or in a tracked on-demand refresh, so `a.done` cannot be closed yet. `Close()` waits until the lease is released.
+	a.cleanup.Add(1)
+	go func() {
+		defer a.cleanup.Done()
+		ttl := a.opts.leaseTTL
+		defer a.leader.Store(false)
+		for {
+			select {
+			case <-a.after(ttl / 3):
+				ok, err := a.opts.coordinator.Renew(ctx, ttl)
+				if err != nil || !ok {
+					a.logEvent(ctx, EventRefreshError, "Lost leadership", "err", err)
+					return
+				}
+			case <-ctx.Done():
+				// `ctx` is done, so release the lease with a fresh context.
+				rctx, cancel := context.WithTimeout(context.Background(), ttl)
+				defer cancel()
+				if err := a.opts.coordinator.Release(rctx); err != nil {
+					a.logEvent(ctx, EventRefreshError, "Cannot release leadership", "err", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// `RedisCoordinator` is a `Coordinator` on a Redis server. The lease is the key `Key`, set with NX and a TTL to a random value that identifies this replica.
+type RedisCoordinator struct {
+	Client *redisstore.Client
+	Key    string
+	owner  string
+}
+
+// `NewRedisCoordinator` returns a `RedisCoordinator` with a random replica ID.
+func NewRedisCoordinator(client *redisstore.Client, key string) *RedisCoordinator {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return &RedisCoordinator{Client: client, Key: key, owner: hex.EncodeToString(b)}
+}
+
+// Method `TryAcquire` implements `Coordinator`.
+func (c *RedisCoordinator) TryAcquire(ctx context.Context, ttl time.Duration) (bool, error) {
+	return c.Client.SetNX(ctx, c.Key, c.owner, ttl)
+}
+
+// Method `Renew` implements `Coordinator`.
+func (c *RedisCoordinator) Renew(ctx context.Context, ttl time.Duration) (bool, error) {
+	return c.Client.ExpireIfEqual(ctx, c.Key, c.owner, ttl)
+}
+
+// Method `Release` implements `Coordinator`.
+func (c *RedisCoordinator) Release(ctx context.Context) error {
+	_, err := c.Client.DeleteIfEqual(ctx, c.Key, c.owner)
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/redisstore"
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// Only the leader fetches. When the leader goes away, a follower takes over.
+func TestCoordinatorFailover(t *testing.T) {
+	srv, err := refreshtest.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return "token-" + strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}
+	replica := func() *Token {
+		client := &redisstore.Client{Addr: srv.Addr()}
+		t.Cleanup(func() { client.Close() })
+		store := RedisStore{Client: client, Key: "api-token"}
+		return NewToken(context.Background(), auth,
+			WithCoordinator(NewRedisCoordinator(client, "api-token:leader"), store, 300*time.Millisecond),
+			WithLogger(NopLogger))
+	}
+
+	leader := replica()
+	if got, _ := leader.Get(); got != "token-1" || !leader.IsLeader() {
+		t.Fatalf("want leader with token-1, got %q, leader %v", got, leader.IsLeader())
+	}
+	follower := replica()
+	defer follower.Close()
+	if got, _ := follower.Get(); got != "token-1" || follower.IsLeader() {
+		t.Fatalf("want follower with token-1, got %q, leader %v", got, follower.IsLeader())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 authorization call, got %d", n)
+	}
+
+	// The lease outlives several renewals.
+	time.Sleep(400 * time.Millisecond)
+	if !leader.IsLeader() {
+		t.Fatal("leader lost its lease")
+	}
+
+	leader.Close()
+	waitFor(t, time.Second, func() bool { return !leader.IsLeader() })
+	if err := follower.ForceRefresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := follower.Get(); got != "token-2" || !follower.IsLeader() {
+		t.Fatalf("want new leader with token-2, got %q, leader %v", got, follower.IsLeader())
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshhttp"
+)
+
+// Shell scripts, cron jobs, and legacy processes cannot import a Go package, but they need credentials, too. If each of them fetched its own tokens, the host would hammer the authorization server and spread client secrets across many scripts. A daemon that runs a `Manager` from a config file and hands out the current tokens over a local socket keeps the secrets in one place:
+//
+//	curl -s --unix-socket /run/refreshd.sock -H "Authorization: Bearer $(cat secret)" http://refreshd/tokens/api
+//
+// `RunDaemon` is the whole daemon. The `main` function in `refreshd.go`, built with the `refreshd` tag, registers the flags of `DaemonConfig`, parses them, and calls `RunDaemon` with a context that ends on SIGTERM.
+
+// `DaemonConfig` configures `RunDaemon`.
+type DaemonConfig struct {
+	// `ConfigPath` is the token config file, as read by `LoadManagerConfig`.
+	ConfigPath string
+	// `Socket` is the path of a Unix domain socket to serve tokens on.
+	Socket string
+	// `Addr` is a loopback address to serve tokens on via HTTP, such as "127.0.0.1:8181".
+	Addr string
+	// `SecretFile` holds the secret that clients send as a bearer token.
+	SecretFile string
+	// `PollInterval` is how often the daemon checks the config file for changes. Zero means only SIGHUP reloads the config.
+	PollInterval time.Duration
+}
+
+// Method `RegisterFlags` registers command-line flags for the fields of `c` in `fs`.
+func (c *DaemonConfig) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.ConfigPath, "config", "", "token config `file` (JSON)")
+	fs.StringVar(&c.Socket, "socket", "", "serve tokens on this Unix domain socket `path`")
+	fs.StringVar(&c.Addr, "addr", "", "serve tokens via HTTP on this loopback `address`")
+	fs.StringVar(&c.SecretFile, "secret-file", "", "`file` with the bearer secret that clients must send")
+	fs.DurationVar(&c.PollInterval, "poll", 0, "check the config file for changes at this `interval` (0: reload on SIGHUP only)")
+}
+
+// `RunDaemon` builds a `Manager` from the config file and serves its tokens under `/tokens/<name>` on the socket, the loopback address, or both, as `refreshhttp.NewMultiHandler` describes. The config reloads on SIGHUP and, with a `PollInterval`, when the file changes. `RunDaemon` returns when `ctx` is done or a listener fails, and closes all tokens before it returns.
+func RunDaemon(ctx context.Context, c DaemonConfig, opts ...ConfigOption) error {
+	if c.ConfigPath == "" || c.SecretFile == "" {
+		return errors.New("refresh: daemon needs a config file and a secret file")
+	}
+	if c.Socket == "" && c.Addr == "" {
+		return errors.New("refresh: daemon needs a socket or an address to listen on")
+	}
+	secret, err := os.ReadFile(c.SecretFile)
+	if err != nil {
+		return fmt.Errorf("refresh: reading daemon secret: %w", err)
+	}
+	if len(strings.TrimSpace(string(secret))) == 0 {
+		return fmt.Errorf("refresh: daemon secret file %s is empty", c.SecretFile)
+	}
+	r, err := NewConfigReloader(c.ConfigPath, opts...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	h := refreshhttp.NewMultiHandler(func(name string) (refreshhttp.Source, bool) {
+		t, ok := r.Manager().Token(name)
+		if !ok {
+			return nil, false
+		}
+		return t, true
+	}, strings.TrimSpace(string(secret)))
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var listeners []func() error
+	if c.Socket != "" {
+		listeners = append(listeners, func() error { return refreshhttp.ListenAndServeUnix(ctx, c.Socket, h) })
+	}
+	if c.Addr != "" {
+		listeners = append(listeners, func() error { return refreshhttp.ListenAndServe(ctx, c.Addr, h) })
+	}
+	errc := make(chan error, len(listeners))
+	for _, listen := range listeners {
+		go func(listen func() error) { errc <- listen() }(listen)
+	}
+	go r.Watch(ctx, c.PollInterval)
+
+	// The first listener to return ends the daemon. If `ctx` is done, that is no failure.
+	err = <-errc
+	cancel()
+	for range listeners[1:] {
+		<-errc
+	}
+	if parent.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshhttp"
+)
+
+func TestRunDaemon(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	os.WriteFile(tokenFile, []byte("file-token"), 0o600)
+	configPath := filepath.Join(dir, "tokens.json")
+	os.WriteFile(configPath, []byte(`{"tokens": {"api": {"type": "file", "path": "`+tokenFile+`", "lifespan": "1h"}}}`), 0o600)
+	secretFile := filepath.Join(dir, "secret")
+	os.WriteFile(secretFile, []byte("s3cret\n"), 0o600)
+
+	var c DaemonConfig
+	fs := flag.NewFlagSet("refreshd", flag.ContinueOnError)
+	c.RegisterFlags(fs)
+	socket := filepath.Join(dir, "refreshd.sock")
+	if err := fs.Parse([]string{"-config", configPath, "-secret-file", secretFile, "-socket", socket}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- RunDaemon(ctx, c, WithConfigTokenOptions(WithLogger(NopLogger))) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	var body refreshhttp.Response
+	waitFor(t, 2*time.Second, func() bool {
+		req, _ := http.NewRequest(http.MethodGet, "http://refreshd/tokens/api", nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, err := client.Do(req)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&body) == nil
+	})
+	if body.Token != "file-token" {
+		t.Fatalf("want file-token, got %q", body.Token)
+	}
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("want clean shutdown, got %v", err)
+	}
+}
+
+func TestRunDaemonInvalid(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	os.WriteFile(empty, nil, 0o600)
+	for name, c := range map[string]DaemonConfig{
+		"no config":      {SecretFile: empty, Socket: "x"},
+		"no listener":    {ConfigPath: "x", SecretFile: empty},
+		"empty secret":   {ConfigPath: "x", SecretFile: empty, Socket: "x"},
+		"missing secret": {ConfigPath: "x", SecretFile: filepath.Join(dir, "missing"), Addr: "127.0.0.1:0"},
+	} {
+		if err := RunDaemon(context.Background(), c); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// Database credentials rotate, too: Vault's database secrets engine or AWS IAM authentication hand out passwords that expire after an hour or less. `database/sql` opens connections whenever its pool needs one, long after the app has started, so the DSN that `sql.Open` received would eventually carry a dead password. A connector created by `NewDBConnector` builds the DSN anew for each connection, from the credentials that a `Refresher` keeps fresh:
+//
+//	creds := NewRefresher(ctx, fetchFromVault)
+//	db := sql.OpenDB(NewDBConnector(creds, &pq.Driver{}, func(c DBCredentials) string {
+//		return fmt.Sprintf("postgres://%s:%s@db:5432/app", url.PathEscape(c.Username), url.PathEscape(c.Password))
+//	}))
+//	db.SetConnMaxLifetime(30 * time.Minute)
+//
+// Connections that are already open keep working with most databases after their credentials expire. To move all connections over to new credentials, limit their lifetime with `SetConnMaxLifetime`.
+//
+// Drivers with a hook that runs before each connection, such as pgx's `OptionBeforeConnect`, can fetch the credentials in that hook with `creds.GetContext(ctx)` instead.
+
+// `DBCredentials` are a username and password pair for a database.
+type DBCredentials struct {
+	Username string
+	Password string
+}
+
+// Method `String` keeps the password out of logs. See `secret.go`.
+func (c DBCredentials) String() string {
+	return fmt.Sprintf("{%s %v}", c.Username, Secret(c.Password))
+}
+
+// `dbConnector` implements `driver.Connector`.
+type dbConnector struct {
+	creds *Refresher[DBCredentials]
+	drv   driver.Driver
+	dsn   func(DBCredentials) string
+}
+
+// `NewDBConnector` returns a connector for `sql.OpenDB` that opens each new connection through `drv` with the DSN that `dsn` builds from the current credentials of `creds`.
+func NewDBConnector(creds *Refresher[DBCredentials], drv driver.Driver, dsn func(DBCredentials) string) driver.Connector {
+	return &dbConnector{creds: creds, drv: drv, dsn: dsn}
+}
+
+// Method `Connect` implements `driver.Connector`. It fails if no valid credentials are available.
+func (c *dbConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	creds, err := c.creds.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("refresh: database credentials: %w", err)
+	}
+	dsn := c.dsn(creds)
+	if dc, ok := c.drv.(driver.DriverContext); ok {
+		conn, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return conn.Connect(ctx)
+	}
+	return c.drv.Open(dsn)
+}
+
+// Method `Driver` implements `driver.Connector`.
+func (c *dbConnector) Driver() driver.Driver {
+	return c.drv
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// `dsnDriver` records the DSN of each connection and fails all calls on the connection.
+type dsnDriver struct {
+	mu   sync.Mutex
+	dsns []string
+}
+
+func (d *dsnDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dsns = append(d.dsns, dsn)
+	return dsnConn{}, nil
+}
+
+type dsnConn struct{}
+
+func (dsnConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (dsnConn) Close() error                        { return nil }
+func (dsnConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func TestDBConnector(t *testing.T) {
+	var n atomic.Int32
+	creds := NewRefresher(context.Background(), func(context.Context) (DBCredentials, time.Duration, error) {
+		return DBCredentials{Username: "app", Password: fmt.Sprintf("pw%d", n.Add(1))}, time.Hour, nil
+	})
+	drv := &dsnDriver{}
+	db := sql.OpenDB(NewDBConnector(creds, drv, func(c DBCredentials) string {
+		return c.Username + ":" + c.Password + "@db"
+	}))
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(drv.dsns) != 1 || drv.dsns[0] != "app:pw1@db" {
+		t.Fatalf("want DSN with current credentials, got %v", drv.dsns)
+	}
+}
+
+func TestDBConnectorNoCredentials(t *testing.T) {
+	creds := NewRefresher(context.Background(), func(context.Context) (DBCredentials, time.Duration, error) {
+		return DBCredentials{}, 0, errors.New("vault sealed")
+	})
+	db := sql.OpenDB(NewDBConnector(creds, &dsnDriver{}, func(DBCredentials) string { return "" }))
+	defer db.Close()
+	if err := db.PingContext(context.Background()); err == nil || !strings.Contains(err.Error(), "vault sealed") {
+		t.Fatalf("want credentials error, got %v", err)
+	}
+}
+
+func TestDBCredentialsString(t *testing.T) {
+	if s := fmt.Sprint(DBCredentials{Username: "app", Password: "hunter2"}); strings.Contains(s, "hunter2") || !strings.Contains(s, "app") {
+		t.Fatalf("password not redacted: %s", s)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Values can depend on each other. A pre-signed URL or a session cookie is derived from an OAuth token and has a lifespan of its own. Such a value must be derived again when it expires, but also when the token it was derived from gets refreshed.
+
+// `closedChan` is a channel that is always ready to receive from.
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// `Derive` returns a `Refresher` whose value is derived from the value of `parent`. `derive` receives the current parent value and returns the derived value and its lifespan. The derived value is refreshed when it is about to expire and whenever `parent` fetches a new value. If `parent` serves an error, the derived refresher serves that error until `parent` recovers. The goroutine stops when `ctx` is canceled.
+// Derived refreshers can be derived from in turn, to build pipelines of any length.
+func Derive[P, T any](ctx context.Context, parent *Refresher[P], derive func(ctx context.Context, parentValue P) (T, time.Duration, error), opts ...RefresherOption) *Refresher[T] {
+	// `seen` is the parent version that the current value was derived from. Only the goroutine of the derived refresher accesses it.
+	var seen uint64
+	var o refresherOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r := newRefresher[T](o)
+	r.fetch = func(ctx context.Context) (T, time.Duration, error) {
+		var zero T
+		var p result[P]
+		select {
+		case p = <-parent.value:
+		case <-parent.done:
+			return zero, 0, ErrClosed
+		case <-ctx.Done():
+			return zero, 0, ctx.Err()
+		}
+		seen = p.version
+		if p.Err != nil {
+			return zero, 0, p.Err
+		}
+		return derive(ctx, p.Value)
+	}
+	r.parentChanged = func() <-chan struct{} {
+		changed, version := parent.watch()
+		if version > seen {
+			// The parent was refreshed after this fetch read its value.
+			return closedChan
+		}
+		return changed
+	}
+	r.start(ctx)
+	return r
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A derived value follows each refresh of its parent.
+func TestDeriveFollowsParent(t *testing.T) {
+	var version atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parent := NewRefresher(ctx, func(ctx context.Context) (int, time.Duration, error) {
+		return int(version.Add(1)), 20*time.Millisecond + lifeSpanSafetyMargin, nil
+	})
+	child := Derive(ctx, parent, func(ctx context.Context, token int) (string, time.Duration, error) {
+		return fmt.Sprintf("https://example.com/?token=%d", token), time.Hour, nil
+	})
+
+	waitFor(t, time.Second, func() bool {
+		u, err := child.Get()
+		return err == nil && u == "https://example.com/?token=3"
+	})
+}
+
+// A derived value is refreshed when it expires, even if the parent does not change.
+func TestDeriveExpires(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parent := NewRefresher(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		return "token", time.Hour, nil
+	})
+	var derived atomic.Int32
+	child := Derive(ctx, parent, func(ctx context.Context, token string) (int, time.Duration, error) {
+		return int(derived.Add(1)), 20*time.Millisecond + lifeSpanSafetyMargin, nil
+	})
+
+	waitFor(t, time.Second, func() bool {
+		n, _ := child.Get()
+		return n >= 3
+	})
+}
+
+// Parent errors propagate, and derived values recover with the parent.
+func TestDeriveParentError(t *testing.T) {
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parent := NewRefresher(ctx, func(ctx context.Context) (string, time.Duration, error) {
+		if calls.Add(1) <= 3 {
+			return "", 0, errors.New("down")
+		}
+		return "token", time.Hour, nil
+	})
+	var derived atomic.Int32
+	child := Derive(ctx, parent, func(ctx context.Context, token string) (string, time.Duration, error) {
+		derived.Add(1)
+		return token + "-cookie", time.Hour, nil
+	})
+
+	if _, err := child.Get(); err == nil {
+		t.Fatal("want parent error")
+	}
+	waitFor(t, time.Second, func() bool {
+		v, err := child.Get()
+		return err == nil && v == "token-cookie"
+	})
+	if n := derived.Load(); n != 1 {
+		t.Fatalf("want 1 derivation, got %d", n)
+	}
+}
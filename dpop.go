@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A bearer token works for anyone who has it. A leaked token therefore gives an attacker access until the token expires. DPoP (Demonstrating Proof of Possession, RFC 9449) binds the token to a key pair: the authorization server issues the token for the client's public key, and each request carries a proof, a small JWT that the client signs with the private key for this very request. A stolen token without the key is useless.
+//
+// So a DPoP client manages two things: the token and its proof key. `NewDPoPToken` keeps both in the same `Token`, using the signing key mechanism of `signingkey.go`, so that a token never meets a key of another generation. `DPoPTransport()` signs a proof for each request.
+
+// `ErrNoDPoPKey` is returned by the transport of `DPoPTransport()` if the token has no DPoP key, because it was not created by `NewDPoPToken`.
+var ErrNoDPoPKey = errors.New("refresh: token has no DPoP key")
+
+// A `DPoPKey` is an ECDSA P-256 key pair that signs DPoP proofs with ES256.
+type DPoPKey struct {
+	priv *ecdsa.PrivateKey
+	// `jwk` is the public key in JWK format, as it appears in the header of each proof.
+	jwk map[string]string
+}
+
+// `NewDPoPKey` generates a new key.
+func NewDPoPKey() (*DPoPKey, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return DPoPKeyFrom(priv)
+}
+
+// `DPoPKeyFrom` wraps an existing private key, for example, one loaded from a key store. The key must use the P-256 curve.
+func DPoPKeyFrom(priv *ecdsa.PrivateKey) (*DPoPKey, error) {
+	pub, err := priv.PublicKey.ECDH()
+	if err != nil || priv.Curve != elliptic.P256() {
+		return nil, errors.New("refresh: DPoP key must be a P-256 key")
+	}
+	// The uncompressed point is 0x04, X, and Y, 32 bytes each.
+	point := pub.Bytes()
+	return &DPoPKey{priv: priv, jwk: map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(point[1:33]),
+		"y":   base64.RawURLEncoding.EncodeToString(point[33:]),
+	}}, nil
+}
+
+// Method `Thumbprint` returns the JWK thumbprint of the public key (RFC 7638), which authorization servers put into the `cnf.jkt` claim of bound tokens.
+func (k *DPoPKey) Thumbprint() string {
+	// RFC 7638 requires the required members in lexicographic order and no whitespace, which is what `json.Marshal` produces for a map.
+	b, _ := json.Marshal(k.jwk)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Method `Proof` returns a DPoP proof for a request with the given method and URL. Query and fragment of the URL are not part of the proof. For requests to a resource server, `accessToken` is the token that the request carries; for requests to the token endpoint, it is empty. `nonce` is the latest nonce that the server sent in its `DPoP-Nonce` header, or empty.
+func (k *DPoPKey) Proof(method, url, accessToken, nonce string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	header := map[string]any{"typ": "dpop+jwt", "alg": "ES256", "jwk": k.jwk}
+	claims := map[string]any{
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"htm": method,
+		"htu": url,
+		"iat": time.Now().Unix(),
+	}
+	if accessToken != "" {
+		ath := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(ath[:])
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return k.sign(header, claims)
+}
+
+// Method `sign` returns the compact JWS of `claims`, signed with ES256.
+func (k *DPoPKey) sign(header, claims map[string]any) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, k.priv, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants R and S as fixed-size big-endian integers, not in ASN.1.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// Method `Public` returns the public key.
+func (k *DPoPKey) Public() crypto.PublicKey {
+	return k.priv.Public()
+}
+
+// Method `Transport` returns an `http.RoundTripper` that adds a DPoP proof without access token to each request. Use it in the HTTP client of the authorization function, so that token requests get bound to the key, for example, with `oauth2.WithHTTPClient(&http.Client{Transport: key.Transport(nil)})`.
+func (k *DPoPKey) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &dpopTransport{base: base, key: func(*http.Request) (string, *DPoPKey, error) { return "", k, nil }}
+}
+
+// `NewDPoPToken` works like `NewSigningToken`, but `auth` returns the DPoP key that the token is bound to. `auth` may return the same key each time or rotate it, as long as it requests each token for the key that it returns.
+func NewDPoPToken(ctx context.Context, auth func(ctx context.Context) (token string, key *DPoPKey, lifespan time.Duration, err error), opts ...Option) *Token {
+	a := newToken(opts)
+	a.authorizeWithKey = func(ctx context.Context) (string, []byte, time.Duration, error) {
+		token, key, lifespan, err := auth(ctx)
+		if err != nil {
+			return token, nil, lifespan, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key.priv)
+		return token, der, lifespan, err
+	}
+	a.start(ctx)
+	return a
+}
+
+// Method `DPoPTransport` returns an `http.RoundTripper` that sends each request with the current token as `Authorization: DPoP <token>` and a proof signed with the token's key, through `base`. If `base` is nil, `http.DefaultTransport` is used. The token must come from `NewDPoPToken`.
+// If the server asks for a nonce, the transport retries the request once with the nonce, and uses the server's latest nonce for later requests.
+func (a *Token) DPoPTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	var mu sync.Mutex
+	var der []byte
+	var key *DPoPKey
+	return &dpopTransport{base: base, key: func(req *http.Request) (string, *DPoPKey, error) {
+		token, k, err := a.GetSignedContext(req.Context())
+		if err != nil {
+			return "", nil, err
+		}
+		if len(k) == 0 {
+			return "", nil, ErrNoDPoPKey
+		}
+		// Parse the key only when it has changed.
+		mu.Lock()
+		defer mu.Unlock()
+		if !bytes.Equal(k, der) {
+			priv, err := x509.ParsePKCS8PrivateKey(k)
+			ec, ok := priv.(*ecdsa.PrivateKey)
+			if err != nil || !ok {
+				return "", nil, ErrNoDPoPKey
+			}
+			if key, err = DPoPKeyFrom(ec); err != nil {
+				return "", nil, err
+			}
+			der = k
+		}
+		return token, key, nil
+	}}
+}
+
+// `dpopTransport` adds DPoP proofs to requests. `key` returns the token, if any, and the key for a request.
+type dpopTransport struct {
+	base http.RoundTripper
+	key  func(req *http.Request) (string, *DPoPKey, error)
+	// `nonces` holds the latest nonce per host.
+	mu     sync.Mutex
+	nonces map[string]string
+}
+
+func (t *dpopTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, key, err := t.key(req)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	nonce := t.nonce(req.URL.Host)
+	resp, err := t.send(req, token, key, nonce, false)
+	if err != nil {
+		return nil, err
+	}
+	newNonce := resp.Header.Get("DPoP-Nonce")
+	if newNonce == "" || newNonce == nonce {
+		return resp, nil
+	}
+	t.mu.Lock()
+	if t.nonces == nil {
+		t.nonces = make(map[string]string)
+	}
+	t.nonces[req.URL.Host] = newNonce
+	t.mu.Unlock()
+	// A server that requires a nonce rejects the first request with `use_dpop_nonce`: the authorization server with 400, a resource server with 401.
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return t.send(req, token, key, newNonce, true)
+}
+
+// Method `nonce` returns the latest nonce of `host`.
+func (t *dpopTransport) nonce(host string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.nonces[host]
+}
+
+// Method `send` sends a copy of `req` with a fresh proof and, if `token` is set, the token.
+func (t *dpopTransport) send(req *http.Request, token string, key *DPoPKey, nonce string, retry bool) (*http.Response, error) {
+	proof, err := key.Proof(req.Method, req.URL.String(), token, nonce)
+	if err != nil {
+		closeBody(req)
+		return nil, fmt.Errorf("refresh: signing DPoP proof: %w", err)
+	}
+	r := req.Clone(req.Context())
+	if retry && req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	r.Header.Set("DPoP", proof)
+	if token != "" {
+		r.Header.Set("Authorization", "DPoP "+token)
+	}
+	return t.base.RoundTrip(r)
+}
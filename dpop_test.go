@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// `verifyDPoP` checks the signature of a proof against the key in its header and returns the header and claims.
+func verifyDPoP(t *testing.T, proof string, pub *ecdsa.PublicKey) (header map[string]any, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(proof, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed proof %q", proof)
+	}
+	for i, v := range []*map[string]any{&header, &claims} {
+		b, _ := base64.RawURLEncoding.DecodeString(parts[i])
+		if err := json.Unmarshal(b, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Fatal("invalid proof signature")
+	}
+	return header, claims
+}
+
+func TestDPoPTransport(t *testing.T) {
+	key, err := NewDPoPKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(*ecdsa.PublicKey)
+	var nonces []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "DPoP bound-token" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		header, claims := verifyDPoP(t, r.Header.Get("DPoP"), pub)
+		ath := sha256.Sum256([]byte("bound-token"))
+		if header["typ"] != "dpop+jwt" || claims["htm"] != http.MethodGet || claims["htu"] != "http://"+r.Host+"/api" || claims["ath"] != base64.RawURLEncoding.EncodeToString(ath[:]) {
+			t.Errorf("unexpected proof %v %v", header, claims)
+		}
+		nonce, _ := claims["nonce"].(string)
+		nonces = append(nonces, nonce)
+		w.Header().Set("DPoP-Nonce", "n1")
+		if nonce != "n1" {
+			w.Header().Set("WWW-Authenticate", `DPoP error="use_dpop_nonce"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	tok := NewDPoPToken(context.Background(), func(context.Context) (string, *DPoPKey, time.Duration, error) {
+		return "bound-token", key, time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	client := &http.Client{Transport: tok.DPoPTransport(nil)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL + "/api?q=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("want 200, got %d", resp.StatusCode)
+		}
+	}
+	// The first request learns the nonce, the second one knows it already.
+	if strings.Join(nonces, ",") != ",n1,n1" {
+		t.Fatalf("unexpected nonces %q", nonces)
+	}
+}
+
+func TestDPoPKeyTransport(t *testing.T) {
+	key, _ := NewDPoPKey()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, claims := verifyDPoP(t, r.Header.Get("DPoP"), key.Public().(*ecdsa.PublicKey))
+		if _, ok := claims["ath"]; ok || r.Header.Get("Authorization") != "" {
+			t.Error("token request carries a token")
+		}
+		jwk, _ := json.Marshal(header["jwk"])
+		sum := sha256.Sum256(jwk)
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != key.Thumbprint() {
+			t.Error("thumbprint does not match the proof's key")
+		}
+	}))
+	defer srv.Close()
+	resp, err := (&http.Client{Transport: key.Transport(nil)}).Post(srv.URL+"/token", "application/x-www-form-urlencoded", strings.NewReader("grant_type=client_credentials"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestDPoPTransportWithoutKey(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "bearer", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	_, err := (&http.Client{Transport: tok.DPoPTransport(nil)}).Get("http://127.0.0.1:1/")
+	if !errors.Is(err, ErrNoDPoPKey) {
+		t.Fatalf("want ErrNoDPoPKey, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// A `FileStore` writes the token in plain text. The file is only readable by its owner, but backups, container layers, and debug dumps may not respect this. `EncryptedFileStore` encrypts the token with AES-GCM before it touches the disk. The key comes from a callback, so that it can live in an environment variable, a secrets manager, or a KMS.
+
+// `ErrDecrypt` is returned by `EncryptedFileStore.Load` if the file cannot be decrypted, for example, because the key has changed.
+var ErrDecrypt = errors.New("refresh: cannot decrypt stored token")
+
+// `EncryptedFileStore` keeps the token in a file, encrypted with AES-GCM. `Key` returns the AES key, which must be 16, 24, or 32 bytes long. `Key` is called for every load and save, so that rotated keys take effect. After a key rotation, `Load` fails with `ErrDecrypt` until the next save.
+type EncryptedFileStore struct {
+	Path string
+	Key  func(ctx context.Context) ([]byte, error)
+}
+
+// `KeyFromEnv` returns a key function for `EncryptedFileStore` that reads a base64 encoded key from the environment variable `name`.
+func KeyFromEnv(name string) func(ctx context.Context) ([]byte, error) {
+	return func(context.Context) ([]byte, error) {
+		v := os.Getenv(name)
+		if v == "" {
+			return nil, fmt.Errorf("refresh: environment variable %s not set", name)
+		}
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("refresh: decoding key from %s: %w", name, err)
+		}
+		return key, nil
+	}
+}
+
+// `storeAAD` binds the ciphertext to its purpose, so that a ciphertext made for something else with the same key does not decrypt as a token.
+var storeAAD = []byte("refresh.StoredToken.v1")
+
+// Method `aead` creates the cipher from the current key.
+func (s EncryptedFileStore) aead(ctx context.Context) (cipher.AEAD, error) {
+	key, err := s.Key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("refresh: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Method `Load` implements `Store`. A missing file means that there is no token.
+func (s EncryptedFileStore) Load(ctx context.Context) (StoredToken, bool, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return StoredToken{}, false, nil
+	}
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	gcm, err := s.aead(ctx)
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	if len(b) < gcm.NonceSize() {
+		return StoredToken{}, false, ErrDecrypt
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], storeAAD)
+	if err != nil {
+		return StoredToken{}, false, ErrDecrypt
+	}
+	var t StoredToken
+	if err := json.Unmarshal(plain, &t); err != nil {
+		return StoredToken{}, false, err
+	}
+	return t, true, nil
+}
+
+// Method `Save` implements `Store`. The file holds a random nonce followed by the ciphertext.
+func (s EncryptedFileStore) Save(ctx context.Context, t StoredToken) error {
+	plain, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	gcm, err := s.aead(ctx)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, gcm.Seal(nonce, nonce, plain, storeAAD), 0o600)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptedFileStore(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	t.Setenv("REFRESH_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+	s := EncryptedFileStore{Path: filepath.Join(t.TempDir(), "token.enc"), Key: KeyFromEnv("REFRESH_TEST_KEY")}
+	ctx := context.Background()
+
+	if _, ok, err := s.Load(ctx); ok || err != nil {
+		t.Fatalf("want empty store, got (%v, %v)", ok, err)
+	}
+	want := StoredToken{Token: "secret-token", ExpiresAt: time.Now().Add(time.Hour).Truncate(time.Second)}
+	if err := s.Save(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(s.Path)
+	if bytes.Contains(raw, []byte("secret-token")) {
+		t.Fatal("token stored in plain text")
+	}
+	got, ok, err := s.Load(ctx)
+	if err != nil || !ok || got.Token != want.Token || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Fatalf("want %+v, got (%+v, %v, %v)", want, got, ok, err)
+	}
+
+	// After a key rotation, the old file cannot be decrypted.
+	rand.Read(key)
+	t.Setenv("REFRESH_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+	if _, _, err := s.Load(ctx); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("want ErrDecrypt, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Not every error goes away by retrying. A revoked credential or an unknown client ID fails every time, and retrying it forever only adds load to the authorization server and noise to the logs. The refresh loop therefore distinguishes transient errors, which it retries with backoff, from permanent ones, which stop the retries until a client forces a refresh or swaps the authorization function.
+
+// `PermanentError` marks an authorization error that retrying cannot fix.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return "permanent: " + e.Err.Error() }
+
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// `TransientError` marks an authorization error that may go away by retrying. It overrides the error classifier.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// `Permanent` wraps `err` in a `PermanentError`. It returns nil for a nil error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// `Transient` wraps `err` in a `TransientError`. It returns nil for a nil error.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+// `WithErrorClassifier` sets a function that decides whether an authorization error is permanent. It applies to errors that are neither wrapped in `PermanentError` or `TransientError` nor an `AuthError`. By default, all such errors are transient.
+func WithErrorClassifier(isPermanent func(error) bool) Option {
+	return func(o *options) {
+		o.isPermanent = isPermanent
+	}
+}
+
+// `ClassifyTemporary` is a classifier for `WithErrorClassifier` that treats errors with a method `Temporary() bool` returning false as permanent, such as `*oauth2.ResponseError` for client errors like invalid_client.
+func ClassifyTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && !t.Temporary()
+}
+
+// `never` is the delay that the loop waits after a permanent error. A forced refresh or a swap ends the wait.
+const never = 100 * 365 * 24 * time.Hour
+
+// Method `permanent` classifies an authorization error.
+func (a *Token) permanent(err error) bool {
+	var p *PermanentError
+	var t *TransientError
+	var ae *AuthError
+	var fe *fallbackError
+	switch {
+	// The fallbacks failed along with the primary function. See `fallback.go`.
+	case errors.As(err, &fe):
+		return fe.permanent(a)
+	case errors.As(err, &p):
+		return true
+	case errors.As(err, &t):
+		return false
+	// An `AuthError` knows whether it is worth retrying. See `autherror.go`.
+	case errors.As(err, &ae):
+		return !ae.Retryable
+	case a.opts.isPermanent != nil:
+		return a.opts.isPermanent(err)
+	}
+	return false
+}
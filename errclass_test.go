@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/oauth2"
+)
+
+func TestPermanentErrorStopsRetries(t *testing.T) {
+	var calls atomic.Int32
+	errRevoked := errors.New("revoked")
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			return "", 0, Permanent(errRevoked)
+		}
+		return "tok", time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithLogger(NopLogger))
+	defer tok.Close()
+
+	_, err := tok.Get()
+	var p *PermanentError
+	if !errors.As(err, &p) || !errors.Is(err, errRevoked) {
+		t.Fatalf("want PermanentError wrapping %v, got %v", errRevoked, err)
+	}
+	var r *RetryError
+	if errors.As(err, &r) {
+		t.Fatalf("permanent error announces a retry: %v", err)
+	}
+	time.Sleep(5 * retryDelay)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want no retries, got %d calls", n)
+	}
+
+	// A forced refresh tries again.
+	if err := tok.ForceRefresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tok.Get(); got != "tok" {
+		t.Fatalf("want tok, got %q", got)
+	}
+}
+
+func TestErrorClassifier(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "", 0, &oauth2.ResponseError{StatusCode: 401, Code: "invalid_client"}
+	}
+	tok := NewToken(context.Background(), auth, WithErrorClassifier(ClassifyTemporary), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	time.Sleep(5 * retryDelay)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want no retries, got %d calls", n)
+	}
+
+	// Explicitly transient errors override the classifier.
+	if tok.permanent(Transient(&oauth2.ResponseError{StatusCode: 401})) {
+		t.Error("TransientError classified as permanent")
+	}
+	if ClassifyTemporary(&oauth2.ResponseError{StatusCode: 503}) {
+		t.Error("503 classified as permanent")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"github.com/appliedgo/refresh/oauth2"
+)
+
+// In a service mesh, each downstream service wants a token issued for itself. OAuth 2.0 token exchange (RFC 8693) mints such tokens from the service's own token, one per audience. Each audience token has its own lifespan and needs its own refresher. A `Manager` with a factory keyed by audience does exactly that.
+
+// `TokenExchangeFactory` returns a factory for `WithFactory` that creates a token for each audience. Each token exchanges the current token of `base` for a token for its audience at `tokenURL`. `xopts` and `opts` configure the exchange requests, and `tokenOpts` configure each token.
+// Use it with `Manager.ForAudience`.
+func TokenExchangeFactory(tokenURL, clientID, clientSecret string, base *Token, xopts []oauth2.ExchangeOption, opts []oauth2.Option, tokenOpts ...Option) func(ctx context.Context, audience string) (*Token, error) {
+	return func(ctx context.Context, audience string) (*Token, error) {
+		return NewTokenContext(ctx, oauth2.NewTokenExchange(tokenURL, clientID, clientSecret, base.GetContext, audience, xopts, opts...), tokenOpts...), nil
+	}
+}
+
+// An `AudienceToken` is the token of a `Manager` for one audience.
+type AudienceToken struct {
+	m        *Manager
+	audience string
+}
+
+// Method `ForAudience` returns the token for `audience`. The `Manager` needs a factory that takes audiences as keys, such as the one of `TokenExchangeFactory`. The token gets created on the first call to `Get`.
+func (m *Manager) ForAudience(audience string) AudienceToken {
+	return AudienceToken{m: m, audience: audience}
+}
+
+// Method `Get` returns the current token for the audience.
+func (t AudienceToken) Get(ctx context.Context) (string, error) {
+	return t.m.Get(ctx, t.audience)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Each audience gets its own token, exchanged from the base token.
+func TestForAudience(t *testing.T) {
+	var exchanges atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		exchanges.Add(1)
+		w.Write([]byte(`{"access_token":"` + r.PostForm.Get("subject_token") + `@` + r.PostForm.Get("audience") + `","expires_in":3600}`))
+	}))
+	defer srv.Close()
+
+	base := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "base", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer base.Close()
+	m := NewManager(WithFactory(TokenExchangeFactory(srv.URL, "svc", "secret", base, nil, nil, WithLogger(NopLogger))))
+	defer m.Close()
+
+	for _, aud := range []string{"orders", "billing", "orders"} {
+		got, err := m.ForAudience(aud).Get(context.Background())
+		if err != nil || got != "base@"+aud {
+			t.Fatalf("%s: want base@%s, got (%q, %v)", aud, aud, got, err)
+		}
+	}
+	if n := exchanges.Load(); n != 2 {
+		t.Fatalf("want 2 exchanges, got %d", n)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Some processes keep using an old credential until somebody tells them to reload: `nginx -s reload` after a certificate rotation, `systemctl reload` for a service that rereads its password file. An `ExecSink` runs such a command after each refresh. Used together with a `FileSink`, it writes the file first and then nudges the process, as sinks run in the order in which `WithSink` added them.
+
+// `DefaultExecTimeout` bounds the run time of an `ExecSink` command unless `ExecSink.Timeout` says otherwise.
+const DefaultExecTimeout = 30 * time.Second
+
+// An `ExecSink` runs a command after each successful refresh. The command gets the new token in its environment.
+type ExecSink struct {
+	// `Command` is the program to run and its arguments. No shell is involved; to use shell syntax, run `sh -c`.
+	Command []string
+	// `Timeout` bounds the command's run time. The command gets killed when the timeout passes. Zero means `DefaultExecTimeout`.
+	Timeout time.Duration
+	// `TokenEnv` is the name of the environment variable that holds the token. Empty means `REFRESH_TOKEN`. `REFRESH_EXPIRES_AT` holds the expiration time in RFC 3339 format.
+	TokenEnv string
+	// `Env` adds variables, in the form "key=value", to the environment of the current process.
+	Env []string
+	// `OnOutput` receives the combined standard output and error output of each run, for example, to log it.
+	OnOutput func(output []byte)
+}
+
+// Method `Write` runs the command and waits until it finishes. A command that fails or exits with a non-zero status returns an error that includes the end of its output.
+func (s ExecSink) Write(token string, expiresAt time.Time) error {
+	if len(s.Command) == 0 {
+		return errors.New("refresh: ExecSink without command")
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	name := s.TokenEnv
+	if name == "" {
+		name = "REFRESH_TOKEN"
+	}
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Env = append(os.Environ(), s.Env...)
+	cmd.Env = append(cmd.Env, name+"="+token, "REFRESH_EXPIRES_AT="+expiresAt.Format(time.RFC3339))
+	// Wait for the output only briefly once the command is killed, in case it started children that keep the output open.
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if s.OnOutput != nil {
+		s.OnOutput(out)
+	}
+	if err != nil && ctx.Err() != nil {
+		err = fmt.Errorf("%w after %v: %w", ctx.Err(), timeout, err)
+	}
+	if err != nil {
+		const maxOutput = 512
+		if len(out) > maxOutput {
+			out = out[len(out)-maxOutput:]
+		}
+		return fmt.Errorf("refresh: running %s: %w: %s", s.Command[0], err, out)
+	}
+	return nil
+}
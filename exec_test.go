@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecSink(t *testing.T) {
+	var output []byte
+	s := ExecSink{
+		Command:  []string{"sh", "-c", `echo "$MY_TOKEN $REFRESH_EXPIRES_AT $EXTRA"`},
+		TokenEnv: "MY_TOKEN",
+		Env:      []string{"EXTRA=x"},
+		OnOutput: func(out []byte) { output = out },
+	}
+	if err := s.Write("abc", time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "abc 2030-01-02T03:04:05Z x\n"; string(output) != want {
+		t.Fatalf("want %q, got %q", want, output)
+	}
+
+	s = ExecSink{Command: []string{"sh", "-c", "echo failing; exit 3"}}
+	if err := s.Write("abc", time.Now()); err == nil || !strings.Contains(err.Error(), "failing") {
+		t.Fatalf("want error with output, got %v", err)
+	}
+	s = ExecSink{Command: []string{"sleep", "10"}, Timeout: 50 * time.Millisecond}
+	if err := s.Write("abc", time.Now()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want timeout, got %v", err)
+	}
+	if err := (ExecSink{}).Write("abc", time.Now()); err == nil {
+		t.Fatal("want error without command")
+	}
+}
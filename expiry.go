@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// The article's `authorize()` returns the token's lifespan. A lifespan is relative to the moment the server issued the token, which lies somewhere within the authorization call. If the call takes long, the token has less time left than its lifespan says. Many authorization servers report an absolute expiry time instead, which does not suffer from this problem, but which depends on the server's and the client's clocks agreeing.
+
+// `NewTokenWithExpiry` works like `NewTokenContext` but receives an authorization function that returns the token's absolute expiry time instead of its lifespan. The refresh timer is computed from the expiry time when the call returns, so the duration of the call does not shift the refresh. Use `WithClockSkewTolerance` if the server's clock may run ahead of the client's.
+func NewTokenWithExpiry(ctx context.Context, auth func(ctx context.Context) (string, time.Time, error), opts ...Option) *Token {
+	a := newToken(opts)
+	a.authorize = a.untilExpiry(auth)
+	a.start(ctx)
+	return a
+}
+
+// `WithClockSkewTolerance` shortens the usable lifespan of tokens whose expiry time comes from the server, that is, tokens from `NewTokenWithExpiry` authorizers and, with `WithJWTExpiry`, JWTs. It treats them as expiring `d` earlier than reported, so that they get refreshed in time even if the server's clock runs up to `d` ahead of the client's. It never extends a lifespan. For JWTs, it also accepts an `nbf` claim up to `d` in the future. `d` must not be negative. The default is zero.
+func WithClockSkewTolerance(d time.Duration) Option {
+	if d < 0 {
+		panic(fmt.Sprintf("refresh: negative clock skew tolerance %v", d))
+	}
+	return func(o *options) {
+		o.clockSkew = d
+	}
+}
+
+// Method `untilExpiry` adapts an authorization function that returns an expiry time to one that returns a lifespan, measured from the moment the call returns. A token that is already expired by then gets a lifespan of zero.
+func (a *Token) untilExpiry(auth func(context.Context) (string, time.Time, error)) func(context.Context) (string, time.Duration, error) {
+	return func(ctx context.Context) (string, time.Duration, error) {
+		token, expiresAt, err := auth(ctx)
+		if expiresAt.IsZero() {
+			return token, 0, err
+		}
+		return token, max(expiresAt.Add(-a.opts.clockSkew).Sub(a.now()), 0), err
+	}
+}
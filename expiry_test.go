@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestNewTokenWithExpiry(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		latency time.Duration
+		skew    time.Duration
+		// `want` is the time from the end of the first call until the token expires.
+		want time.Duration
+	}{
+		{"instant", 0, 0, time.Hour},
+		{"slow call", 10 * time.Minute, 0, 50 * time.Minute},
+		{"skew", 0, 5 * time.Minute, 55 * time.Minute},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clock := refreshtest.NewClock(start)
+			var calls atomic.Int32
+			auth := func(context.Context) (string, time.Time, error) {
+				// The server issues the token at the start of the call.
+				expiresAt := clock.Now().Add(time.Hour)
+				if calls.Add(1) == 1 {
+					clock.Advance(tt.latency)
+				}
+				return "tok", expiresAt, nil
+			}
+			tok := NewTokenWithExpiry(context.Background(), auth, WithClock(clock), WithClockSkewTolerance(tt.skew))
+			defer tok.Close()
+			tok.Get()
+
+			clock.Advance(tt.want - lifeSpanSafetyMargin - time.Millisecond)
+			time.Sleep(10 * time.Millisecond)
+			if n := calls.Load(); n != 1 {
+				t.Fatalf("refreshed too early (%d calls)", n)
+			}
+			clock.Advance(time.Millisecond)
+			waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+		})
+	}
+}
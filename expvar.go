@@ -0,0 +1,51 @@
+package main
+
+import (
+	"expvar"
+	"time"
+)
+
+// `Stats()` gives programs a look at the refresh activity. Operators want the same look without writing code. Package `expvar` publishes variables as JSON on `/debug/vars`, and `PublishExpvar` adds a token's stats there.
+
+// `expvarStats` converts stats into a map with JSON-friendly values.
+func expvarStats(s Stats, ttl time.Duration) map[string]any {
+	m := map[string]any{
+		"refreshes":            s.Refreshes,
+		"failures":             s.Failures,
+		"gets":                 s.Gets,
+		"last_refresh":         s.LastRefresh,
+		"expires_at":           s.ExpiresAt,
+		"ttl_seconds":          ttl.Seconds(),
+		"max_get_wait_seconds": s.MaxGetWait.Seconds(),
+		"source":               s.Source,
+	}
+	if s.LastError != nil {
+		m["last_error"] = s.LastError.Error()
+		m["last_error_at"] = s.LastErrorAt
+	}
+	return m
+}
+
+// `PublishExpvar` publishes the stats of `t` under `name` in package `expvar`. The stats are read each time the variables get served. Like `expvar.Publish`, `PublishExpvar` panics if `name` is already in use.
+func PublishExpvar(name string, t *Token) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return expvarStats(t.Stats(), t.TTL())
+	}))
+}
+
+// Method `PublishExpvar` publishes the stats of all tokens of the `Manager` under `name` in package `expvar`, keyed by token name. Tokens that get added or evicted later appear or disappear accordingly.
+func (m *Manager) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		m.mu.Lock()
+		tokens := make(map[string]*Token, len(m.tokens))
+		for k, t := range m.tokens {
+			tokens[k] = t
+		}
+		m.mu.Unlock()
+		vars := make(map[string]any, len(tokens))
+		for k, t := range tokens {
+			vars[k] = expvarStats(t.Stats(), t.TTL())
+		}
+		return vars
+	}))
+}
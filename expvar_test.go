@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		if calls.Add(1) == 2 {
+			return "", 0, errors.New("down")
+		}
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	tok.ForceRefresh(context.Background())
+
+	// Names must be unique per process, also with -count.
+	name := "refresh_test_token_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	PublishExpvar(name, tok)
+	var got struct {
+		Refreshes int     `json:"refreshes"`
+		Failures  int     `json:"failures"`
+		LastError string  `json:"last_error"`
+		TTL       float64 `json:"ttl_seconds"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Refreshes != 1 || got.Failures != 1 || got.LastError != "down" || got.TTL <= 0 {
+		t.Fatalf("unexpected vars %+v", got)
+	}
+}
+
+func TestManagerPublishExpvar(t *testing.T) {
+	m := NewManager()
+	defer m.Close()
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	m.Register("api", tok)
+	tok.Get()
+
+	name := "refresh_test_manager_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	m.PublishExpvar(name)
+	var got map[string]struct {
+		Refreshes int `json:"refreshes"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["api"].Refreshes != 1 {
+		t.Fatalf("unexpected vars %+v", got)
+	}
+}
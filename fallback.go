@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Some services accept tokens from more than one source, for example, a regional and a global authorization endpoint, or a workload identity and a static service account as the last resort. When the primary source is down, the token should come from the next one rather than not at all. Once the primary source is back, the token should come from there again.
+
+// `defaultPrimaryRetry` is the time after a failure of the primary authorization function before the token tries it again.
+const defaultPrimaryRetry = time.Minute
+
+// `WithFallbackAuthorizers` adds authorization functions to try, in order, when the constructor's authorization function fails. The first one that delivers a token wins, and `Stats().Source` records which one it was. If all of them fail, the refresh fails with all errors joined.
+// After the primary function has failed, the following refreshes skip it until the retry interval has passed (see `WithPrimaryRetryInterval`). Then, the primary function gets its chance again.
+// Tokens with a signing key or a warm pool ignore this option.
+func WithFallbackAuthorizers(fallbacks ...func(ctx context.Context) (string, time.Duration, error)) Option {
+	return func(o *options) {
+		o.fallbacks = append(o.fallbacks, fallbacks...)
+	}
+}
+
+// `WithPrimaryRetryInterval` sets how long the token keeps using the fallback authorization functions after the primary one failed. The default is one minute.
+func WithPrimaryRetryInterval(d time.Duration) Option {
+	if d <= 0 {
+		panic("refresh: WithPrimaryRetryInterval interval must be positive")
+	}
+	return func(o *options) {
+		o.primaryRetry = d
+	}
+}
+
+// Method `fetchWithFallback` calls the primary authorization function and, if it fails, the fallback functions in order.
+func (a *Token) fetchWithFallback(ctx context.Context, primary func(context.Context) (string, []byte, time.Duration, error)) (tokenResponse, time.Duration) {
+	if len(a.opts.fallbacks) == 0 || a.authorizeWithKey != nil || a.opts.poolSize > 0 {
+		return a.fetchWith(ctx, primary)
+	}
+	first := 0
+	if failed := a.primaryFailed.Load(); failed != 0 && a.now().Sub(time.Unix(0, failed)) < a.primaryRetry() {
+		first = 1
+	}
+	var (
+		errs     []error
+		lifespan time.Duration
+	)
+	for i := first; i <= len(a.opts.fallbacks); i++ {
+		authorize := primary
+		if i > 0 {
+			authorize = withoutKey(a.opts.fallbacks[i-1])
+		}
+		var resp tokenResponse
+		resp, lifespan = a.fetchWith(ctx, authorize)
+		if resp.Err == nil {
+			if i == 0 {
+				a.primaryFailed.Store(0)
+			}
+			if from := a.stats.setSource(i); from != i {
+				a.logEvent(ctx, EventRefresh, "Authorization source changed", "from", from, "to", i)
+			}
+			return resp, lifespan
+		}
+		if i == 0 {
+			a.primaryFailed.Store(a.now().UnixNano())
+		}
+		errs = append(errs, resp.Err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return tokenResponse{Err: &fallbackError{errs: errs, primarySkipped: first > 0}}, lifespan
+}
+
+// `fallbackError` joins the errors of the authorization functions that the token tried, like `errors.Join` does.
+type fallbackError struct {
+	errs []error
+	// `primarySkipped` is true if the primary function was not tried, as it failed recently. It gets tried again after the retry interval.
+	primarySkipped bool
+}
+
+func (e *fallbackError) Error() string { return errors.Join(e.errs...).Error() }
+
+func (e *fallbackError) Unwrap() []error { return e.errs }
+
+// Method `permanent` reports whether all authorization functions failed permanently. As long as one of them may recover, including a skipped primary function, the token keeps retrying.
+func (e *fallbackError) permanent(a *Token) bool {
+	if e.primarySkipped {
+		return false
+	}
+	for _, err := range e.errs {
+		if !a.permanent(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// Method `primaryRetry` returns the configured retry interval for the primary authorization function, or the default.
+func (a *Token) primaryRetry() time.Duration {
+	if a.opts.primaryRetry > 0 {
+		return a.opts.primaryRetry
+	}
+	return defaultPrimaryRetry
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// When the primary authorization function fails, the fallback delivers the token. The primary function gets tried again after the retry interval.
+func TestFallbackAuthorizers(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var primaryDown atomic.Bool
+	var primaryCalls atomic.Int32
+	primaryDown.Store(true)
+	primary := func(context.Context) (string, time.Duration, error) {
+		primaryCalls.Add(1)
+		if primaryDown.Load() {
+			return "", 0, errors.New("primary down")
+		}
+		return "primary", time.Hour, nil
+	}
+	secondary := func(context.Context) (string, time.Duration, error) {
+		return "secondary", 10 * time.Minute, nil
+	}
+	tok := NewTokenContext(context.Background(), primary,
+		WithFallbackAuthorizers(secondary),
+		WithPrimaryRetryInterval(15*time.Minute),
+		WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+
+	if got, err := tok.Get(); err != nil || got != "secondary" {
+		t.Fatalf("want secondary, got (%q, %v)", got, err)
+	}
+	if s := tok.Stats().Source; s != 1 {
+		t.Fatalf("want source 1, got %d", s)
+	}
+
+	// The next refresh falls within the retry interval and skips the primary function.
+	primaryDown.Store(false)
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(10*time.Minute - lifeSpanSafetyMargin)
+	waitFor(t, time.Second, func() bool { return tok.Stats().Refreshes == 2 })
+	if n := primaryCalls.Load(); n != 1 {
+		t.Fatalf("want 1 primary call within the retry interval, got %d", n)
+	}
+
+	// The refresh after that tries the primary function again.
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(10*time.Minute - lifeSpanSafetyMargin)
+	waitFor(t, time.Second, func() bool { return tok.Stats().Source == 0 })
+	if got, err := tok.Get(); err != nil || got != "primary" {
+		t.Fatalf("want primary, got (%q, %v)", got, err)
+	}
+}
+
+// If all authorization functions fail, the error contains all their errors.
+func TestFallbackAllFail(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	tok := NewTokenContext(context.Background(), func(context.Context) (string, time.Duration, error) {
+		return "", 0, errA
+	}, WithFallbackAuthorizers(func(context.Context) (string, time.Duration, error) {
+		return "", 0, errB
+	}), WithLogger(NopLogger))
+	defer tok.Close()
+	_, err := tok.Get()
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("want both errors, got %v", err)
+	}
+}
+
+// The token stops retrying only if every authorization function failed permanently.
+func TestFallbackPermanent(t *testing.T) {
+	a := newToken(nil)
+	transient, permanent := errors.New("timeout"), Permanent(errors.New("revoked"))
+	tests := []struct {
+		name string
+		err  *fallbackError
+		want bool
+	}{
+		{"transient primary", &fallbackError{errs: []error{transient, permanent}}, false},
+		{"transient fallback", &fallbackError{errs: []error{permanent, transient}}, false},
+		{"all permanent", &fallbackError{errs: []error{permanent, permanent}}, true},
+		{"primary skipped", &fallbackError{errs: []error{permanent}, primarySkipped: true}, false},
+	}
+	for _, tt := range tests {
+		if got := a.permanent(tt.err); got != tt.want {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
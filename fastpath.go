@@ -0,0 +1,43 @@
+package main
+
+// The article's `Get()` receives each token from the refresh goroutine through an unbuffered channel. This is simple and correct, but every reader has to wait for its turn with the one goroutine that serves all readers. At very high read rates, this handoff becomes the bottleneck.
+// The fast path lets the refresh goroutine publish each new state in an `atomic.Pointer` instead. Readers load the pointer without waiting for anyone. The channel variant stays the default, as it is the subject of the article.
+
+// `WithFastPath` makes `Get()` and its variants read the current token from an atomic pointer rather than from the refresh goroutine. Reads become lock-free loads. Tokens with a warm pool ignore this option, as each pooled token must go to a single reader.
+func WithFastPath() Option {
+	return func(o *options) {
+		o.fastPath = true
+	}
+}
+
+// Method `fastPath` reports whether reads use the atomic pointer.
+func (a *Token) fastPath() bool {
+	return a.opts.fastPath && a.opts.poolSize == 0
+}
+
+// Method `publish` makes `resp` the response that fast-path readers receive. Only the refresh goroutine calls it. The first call releases readers that wait for the initial token.
+func (a *Token) publish(resp tokenResponse) {
+	if !a.fastPath() {
+		return
+	}
+	a.current.Store(&resp)
+	a.readyOnce.Do(func() { close(a.ready) })
+}
+
+// Method `load` returns the published response. It waits until the initial token is available, or until `cancel` is closed, in which case the boolean is false.
+func (a *Token) load(cancel <-chan struct{}) (tokenResponse, bool) {
+	select {
+	case <-a.ready:
+	case <-a.closing():
+		return tokenResponse{Err: ErrClosed}, true
+	case <-cancel:
+		return tokenResponse{}, false
+	}
+	// A token that has been closed must not serve its last state forever.
+	select {
+	case <-a.closing():
+		return tokenResponse{Err: ErrClosed}, true
+	default:
+	}
+	return *a.current.Load(), true
+}
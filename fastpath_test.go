@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFastPath(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), 30 * time.Millisecond, nil
+	}
+	tok := NewToken(context.Background(), auth, WithFastPath(), WithLogger(NopLogger))
+
+	if got, err := tok.Get(); got != "1" || err != nil {
+		t.Fatalf("want token 1, got (%q, %v)", got, err)
+	}
+	waitFor(t, time.Second, func() bool {
+		got, _ := tok.GetContext(context.Background())
+		return got == "2"
+	})
+	tok.Close()
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
+
+func TestFastPathGetContextTimeout(t *testing.T) {
+	auth := func(ctx context.Context) (string, time.Duration, error) {
+		<-ctx.Done()
+		return "", 0, ctx.Err()
+	}
+	tok := NewTokenContext(context.Background(), auth, WithFastPath(), WithLogger(NopLogger))
+	defer tok.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tok.GetContext(ctx); !errors.Is(err, ErrGetTimeout) {
+		t.Fatalf("want ErrGetTimeout, got %v", err)
+	}
+}
+
+func BenchmarkToken_GetParallel(b *testing.B) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil }, WithLogger(NopLogger))
+	defer tok.Close()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = tok.Get()
+		}
+	})
+}
+
+func BenchmarkToken_GetParallelFastPath(b *testing.B) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil }, WithFastPath(), WithLogger(NopLogger))
+	defer tok.Close()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = tok.Get()
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// When an API revokes a token early, clients get an authorization error although the token has not expired yet. `ForceRefresh()` lets them replace the token right away instead of waiting for the timer.
+
+// Method `ForceRefresh` makes the refresh goroutine fetch a new token immediately and waits for the result. It returns nil if the refresh succeeded, or the refresh error otherwise.
+// Many clients typically run into the same revoked token at about the same time. Therefore, all calls that arrive while a forced refresh is in progress are answered with the result of that refresh rather than triggering more authorization calls.
+// Tokens with a warm pool do not support forced refreshes. While refreshes are paused, `ForceRefresh` returns `ErrPaused`.
+func (a *Token) ForceRefresh(ctx context.Context) error {
+	if a.opts.poolSize > 0 {
+		return fmt.Errorf("refresh: ForceRefresh with warm pool: %w", errors.ErrUnsupported)
+	}
+	if a.paused.Load() {
+		return ErrPaused
+	}
+	if a.onDemand() {
+		return a.getOnDemand(ctx, true).Err
+	}
+	// The reply channel is buffered, so that the refresh goroutine never blocks on a caller that has given up.
+	req := forceRequest{reply: make(chan error, 1), gen: a.generation.Load()}
+	for sent := false; !sent; {
+		stopped := a.ensureStarted()
+		select {
+		case a.forces <- req:
+			sent = true
+		case <-a.closing():
+			return ErrClosed
+		case <-stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case err := <-req.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// A `forceRequest` asks the refresh goroutine for a refresh. `gen` is the generation of the token at the time of the request.
+type forceRequest struct {
+	reply chan error
+	gen   uint64
+}
+
+// Method `answerForces` answers all pending `ForceRefresh()` calls with the result of the refresh that just happened.
+func (a *Token) answerForces(err error) {
+	for {
+		select {
+		case req := <-a.forces:
+			req.reply <- err
+		default:
+			return
+		}
+	}
+}
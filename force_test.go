@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForceRefresh(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		time.Sleep(20 * time.Millisecond)
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth)
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want initial token 1, got %s", got)
+	}
+
+	// Many clients run into a revoked token at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tok.ForceRefresh(ctx); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("want a single coalesced refresh, got %d authorization calls", n-1)
+	}
+	if got, _ := tok.Get(); got != "2" {
+		t.Fatalf("want refreshed token 2, got %s", got)
+	}
+}
+
+func TestForceRefreshError(t *testing.T) {
+	var calls atomic.Int32
+	authErr := errors.New("invalid_client")
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) > 1 {
+			return "", time.Hour, authErr
+		}
+		return "tok", time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth)
+	if err := tok.ForceRefresh(ctx); !errors.Is(err, authErr) {
+		t.Fatalf("want %v, got %v", authErr, err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// `ErrGetTimeout` is returned by `GetContext` if the context is done before a token is available, for example, because the refresh goroutine is stuck in a slow authorization call. The error also wraps the context's error.
+var ErrGetTimeout = errors.New("refresh: timed out waiting for token")
+
+// Method `GetContext` works like `Get()` but stops waiting for the token when `ctx` is done, and returns `ErrGetTimeout`. If `ctx` is already done on entry, `GetContext` returns the context's error right away, without attempting to receive a token.
+func (a *Token) GetContext(ctx context.Context) (string, error) {
+	t := a.getContext(ctx)
+	return t.Token, t.Err
+}
+
+// Method `getContext` does the work of `GetContext()` and returns the whole response, including the signing key.
+func (a *Token) getContext(ctx context.Context) tokenResponse {
+	// A `select` with a ready token and a done context picks one of them at random. Checking the context first makes the outcome deterministic.
+	if err := ctx.Err(); err != nil {
+		return tokenResponse{Err: err}
+	}
+	a.stats.gets.Add(1)
+	// With `WithNotReadyPolicy`, a token that never had a value may answer without waiting. See `ready.go`.
+	if t, ok := a.notReady(ctx); ok {
+		return t
+	}
+	t := a.receiveContext(ctx)
+	// With `WithMaxServeAge`, a token that is too old gets replaced first. See `maxage.go`.
+	if a.tooOld(t) {
+		t = a.refreshTooOld(ctx, t, func() tokenResponse { return a.receiveContext(ctx) })
+	}
+	return a.checkExpiry(t)
+}
+
+// Method `receiveContext` does the work of `GetContext()`, without checking the token's age and expiry.
+func (a *Token) receiveContext(ctx context.Context) tokenResponse {
+	if a.onDemand() {
+		return a.getOnDemand(ctx, false)
+	}
+	if a.fastPath() {
+		a.ensureStarted()
+		if t, ok := a.load(ctx.Done()); ok {
+			return t
+		}
+		return tokenResponse{Err: fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())}
+	}
+	for {
+		stopped := a.ensureStarted()
+		result, started := a.waiters.join(a.now())
+		if result != nil {
+			select {
+			case t := <-result:
+				return t
+			case <-a.closing():
+				return tokenResponse{Err: ErrClosed}
+			case <-ctx.Done():
+				return tokenResponse{Err: fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())}
+			}
+		}
+		select {
+		case t := <-a.accessToken:
+			return t
+		case <-a.closing():
+			return tokenResponse{Err: ErrClosed}
+		case <-stopped:
+		case <-started:
+		case <-ctx.Done():
+			return tokenResponse{Err: fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())}
+		}
+	}
+}
+
+// A `cachedToken` is the last good token, along with its expiry time.
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// Method `cache` keeps a copy of a successfully fetched token for `TryGet()`. Failed fetches leave the previous copy in place.
+func (a *Token) cache(resp tokenResponse, lifespan time.Duration) {
+	if resp.Err == nil {
+		a.markReady()
+		a.cached.Store(&cachedToken{token: resp.Token, expiresAt: resp.expiry(a.now(), lifespan)})
+	}
+}
+
+// Method `expiry` returns the expiry time of the response. Measuring the lifespan from the time the authorization call returned would stretch it by the duration of the call, so the response's `ExpiresAt` wins. Only responses without one fall back to `now` plus `lifespan`.
+func (t tokenResponse) expiry(now time.Time, lifespan time.Duration) time.Time {
+	if !t.ExpiresAt.IsZero() {
+		return t.ExpiresAt
+	}
+	return now.Add(lifespan)
+}
+
+// Method `TryGet` returns the last good token without waiting for the refresh goroutine, for example, while a refresh is in progress. The token might be about to be replaced, but it is never expired. The boolean is false if there is no such token.
+// For tokens with a warm pool, `TryGet` takes a token from the pool if one is ready right away.
+func (a *Token) TryGet() (string, bool) {
+	a.stats.gets.Add(1)
+	select {
+	case <-a.closing():
+		return "", false
+	default:
+	}
+	if a.opts.poolSize > 0 {
+		select {
+		case t := <-a.accessToken:
+			return t.Token, t.Err == nil
+		default:
+			return "", false
+		}
+	}
+	c := a.cached.Load()
+	if c == nil || !a.now().Before(c.expiresAt) {
+		return "", false
+	}
+	return c.token, true
+}
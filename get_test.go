@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestGetContextExpired(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, func() (string, time.Duration, error) { return "tok", time.Hour, nil })
+	if got, err := tok.GetContext(ctx); err != nil || got != "tok" {
+		t.Fatalf("want tok, got (%q, %v)", got, err)
+	}
+
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	for i := 0; i < 1000; i++ {
+		got, err := tok.GetContext(expired)
+		if !errors.Is(err, context.Canceled) || got != "" {
+			t.Fatalf("call %d: want context error, got (%q, %v)", i, got, err)
+		}
+	}
+}
+
+func TestGetContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The authorization call hangs, so no token ever becomes available.
+	hang := make(chan struct{})
+	defer close(hang)
+	tok := NewToken(ctx, func() (string, time.Duration, error) {
+		<-hang
+		return "", time.Hour, nil
+	})
+
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+	_, err := tok.GetContext(timeout)
+	if !errors.Is(err, ErrGetTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want ErrGetTimeout wrapping the context error, got %v", err)
+	}
+}
+
+func TestTryGet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		switch calls.Add(1) {
+		case 1:
+			<-release
+			return "first", 50 * time.Millisecond, nil
+		case 2:
+			// The refresh hangs until the test is done.
+			<-ctx.Done()
+		}
+		return "", time.Hour, errors.New("late")
+	}
+	tok := NewToken(ctx, auth)
+
+	if got, ok := tok.TryGet(); ok {
+		t.Fatalf("want no token before the first fetch, got %q", got)
+	}
+	close(release)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+
+	// The refresh is in progress. `TryGet()` still returns the last good token without blocking.
+	if got, ok := tok.TryGet(); !ok || got != "first" {
+		t.Fatalf("want first, got (%q, %v)", got, ok)
+	}
+
+	// Once the token expired, `TryGet()` does not return it anymore.
+	time.Sleep(50 * time.Millisecond)
+	if got, ok := tok.TryGet(); ok {
+		t.Fatalf("want no expired token, got %q", got)
+	}
+}
+
+// A slow authorization call does not stretch the expiry of the cached token.
+func TestCacheSlowCall(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	clock := refreshtest.NewClock(start)
+	auth := func() (string, time.Duration, error) {
+		clock.Advance(time.Minute)
+		return "tok", time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tok.cached.Load().expiresAt, start.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("want expiry %v, got %v", want, got)
+	}
+}
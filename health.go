@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// A service that lost access to its API is not ready to serve traffic. A readiness probe that checks the token lets the platform route requests to other instances until the token is back.
+
+// `ErrUnhealthy` is wrapped by the errors of `Healthy()`.
+var ErrUnhealthy = errors.New("refresh: token unhealthy")
+
+// `HealthPolicy` sets the thresholds of `Healthy()`.
+type HealthPolicy struct {
+	// MaxConsecutiveFailures is the number of failed refreshes in a row that makes the token unhealthy, even if the last good token is still valid. Zero disables the check.
+	MaxConsecutiveFailures int
+	// MinTTL is the remaining lifespan below which the token counts as unhealthy. Zero means that any valid token is healthy.
+	MinTTL time.Duration
+}
+
+// `WithHealthPolicy` sets the thresholds of `Healthy()`. Without this option, a token is healthy as long as it has a valid token.
+func WithHealthPolicy(p HealthPolicy) Option {
+	return func(o *options) {
+		o.health = p
+	}
+}
+
+// Method `Healthy` returns nil if the token can serve a valid token, and an error that wraps `ErrUnhealthy` otherwise. The token is unhealthy if it has no valid token, if its remaining lifespan is below the `MinTTL` of the health policy, or if more than `MaxConsecutiveFailures` refreshes failed in a row.
+// A token with `WithLazyStart` or `WithOnDemandRefresh` that has not been used yet is healthy, as it fetches its first token on first use.
+func (a *Token) Healthy() error {
+	s := a.stats.snapshot()
+	if s.Refreshes == 0 && s.Failures == 0 && (a.opts.lazyStart || a.opts.onDemand) {
+		return nil
+	}
+	p := a.opts.health
+	if n := a.stats.consecutiveFailures(); p.MaxConsecutiveFailures > 0 && n > p.MaxConsecutiveFailures {
+		return fmt.Errorf("%w: %d consecutive refresh failures: %w", ErrUnhealthy, n, s.LastError)
+	}
+	ttl := a.TTL()
+	if ttl == 0 {
+		if s.LastError != nil {
+			return fmt.Errorf("%w: no valid token: %w", ErrUnhealthy, s.LastError)
+		}
+		return fmt.Errorf("%w: no valid token", ErrUnhealthy)
+	}
+	if ttl < p.MinTTL {
+		return fmt.Errorf("%w: token expires in %v", ErrUnhealthy, ttl)
+	}
+	return nil
+}
+
+// `HealthHandler` returns an `http.Handler` for readiness probes such as `/readyz`. It responds with 200 if all `tokens` are healthy, and with 503 and the first error otherwise.
+func HealthHandler(tokens ...*Token) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		for _, t := range tokens {
+			if err := t.Healthy(); err != nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintln(w, err)
+				return
+			}
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
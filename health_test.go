@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestHealthy(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var fail atomic.Bool
+	auth := func() (string, time.Duration, error) {
+		if fail.Load() {
+			return "", 0, errors.New("down")
+		}
+		return "tok", time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithClock(clock), WithLogger(NopLogger), WithStaleOnError(0),
+		WithHealthPolicy(HealthPolicy{MaxConsecutiveFailures: 2, MinTTL: 10 * time.Minute}))
+	defer tok.Close()
+	tok.Get()
+	if err := tok.Healthy(); err != nil {
+		t.Fatalf("want healthy, got %v", err)
+	}
+
+	// Refreshes fail, but the stale token is still good for a while.
+	fail.Store(true)
+	tok.ForceRefresh(context.Background())
+	if err := tok.Healthy(); err != nil {
+		t.Fatalf("want healthy after one failure, got %v", err)
+	}
+	tok.ForceRefresh(context.Background())
+	tok.ForceRefresh(context.Background())
+	if err := tok.Healthy(); !errors.Is(err, ErrUnhealthy) {
+		t.Fatalf("want unhealthy after three failures, got %v", err)
+	}
+	fail.Store(false)
+	tok.ForceRefresh(context.Background())
+	if err := tok.Healthy(); err != nil {
+		t.Fatalf("want healthy after recovery, got %v", err)
+	}
+
+	// The token gets close to its expiry.
+	clock.Advance(55 * time.Minute)
+	if err := tok.Healthy(); !errors.Is(err, ErrUnhealthy) {
+		t.Fatalf("want unhealthy below MinTTL, got %v", err)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	good := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer good.Close()
+	bad := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, errors.New("down")
+	}, WithLogger(NopLogger))
+	defer bad.Close()
+	lazy := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, errors.New("down")
+	}, WithLogger(NopLogger), WithLazyStart())
+	defer lazy.Close()
+	good.Get()
+	bad.Get()
+
+	for _, tt := range []struct {
+		tokens []*Token
+		status int
+	}{
+		{[]*Token{good, lazy}, http.StatusOK},
+		{[]*Token{good, bad}, http.StatusServiceUnavailable},
+	} {
+		rec := httptest.NewRecorder()
+		HealthHandler(tt.tokens...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != tt.status {
+			t.Errorf("want %d, got %d: %s", tt.status, rec.Code, rec.Body)
+		}
+	}
+}
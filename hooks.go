@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Some applications need to know about every token rotation, for example, to write an audit log. Callbacks are a simple way to tell them. However, a callback that blocks must not delay token delivery, so the refresh loop never calls a hook directly. Instead, it queues the call, and a separate goroutine runs the queued calls in order.
+
+// `WithOnRefresh` sets a function that is called after each successful refresh, with the new token and its expiration time.
+func WithOnRefresh(f func(token string, expiresAt time.Time)) Option {
+	return func(o *options) {
+		o.onRefresh = f
+	}
+}
+
+// `WithOnError` sets a function that is called after each failed refresh attempt.
+func WithOnError(f func(err error)) Option {
+	return func(o *options) {
+		o.onError = f
+	}
+}
+
+// `hookQueue` is an unbounded FIFO queue of hook calls. Pushing never blocks.
+// The hook goroutine outlives the token's context: a failed refresh that was interrupted by `Close()`, or a circuit breaker that changes its state on the way out, still queues a call after cancellation. Therefore, the queue is closed only after all other goroutines of the token have stopped (see `finish()` in `close.go`), and the hook goroutine runs the remaining calls before it exits.
+type hookQueue struct {
+	mu      sync.Mutex
+	pending []func()
+	wake    chan struct{}
+	stop    chan struct{}
+	// `stopped` is non-nil if a hook goroutine runs the queue. It gets closed when that goroutine has run the last call.
+	stopped chan struct{}
+}
+
+func newHookQueue() *hookQueue {
+	return &hookQueue{wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// Method `push` queues `f` and wakes up the hook goroutine.
+func (q *hookQueue) push(f func()) {
+	q.mu.Lock()
+	q.pending = append(q.pending, f)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Method `run` executes queued calls until `close` is called. Calls queued before `close` still run.
+func (q *hookQueue) run() {
+	defer close(q.stopped)
+	for {
+		select {
+		case <-q.wake:
+			q.drain()
+		case <-q.stop:
+			q.drain()
+			return
+		}
+	}
+}
+
+// Method `close` stops the hook goroutine and waits until it has run all queued calls. Nothing must push to the queue afterwards.
+func (q *hookQueue) close() {
+	close(q.stop)
+	if q.stopped != nil {
+		<-q.stopped
+	}
+}
+
+func (q *hookQueue) drain() {
+	q.mu.Lock()
+	calls := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+	for _, f := range calls {
+		f()
+	}
+}
+
+// Method `hasHooks` reports whether any hook is configured.
+func (o *options) hasHooks() bool {
+	return o.onRefresh != nil || o.onError != nil || len(o.sinks) > 0 || o.expiryImminent != nil || (o.circuit != nil && o.circuit.OnStateChange != nil)
+}
+
+// Method `notifyRefresh` queues a call to the `OnRefresh` hook and writes to the sinks, if set.
+func (a *Token) notifyRefresh(token string, expiresAt time.Time) {
+	if a.opts.onRefresh != nil {
+		a.hooks.push(func() { a.opts.onRefresh(token, expiresAt) })
+	}
+	if len(a.opts.sinks) > 0 {
+		a.hooks.push(func() { a.writeSinks(token, expiresAt) })
+	}
+}
+
+// Method `notifyError` queues a call to the `OnError` hook, if set.
+func (a *Token) notifyError(err error) {
+	if a.opts.onError == nil {
+		return
+	}
+	a.hooks.push(func() { a.opts.onError(err) })
+}
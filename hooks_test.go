@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		refreshed []string
+		failed    []error
+	)
+	errAuth := errors.New("auth failed")
+	calls := 0
+	auth := func() (string, time.Duration, error) {
+		calls++
+		if calls == 2 {
+			return "", 0, errAuth
+		}
+		return "tok", 30 * time.Millisecond, nil
+	}
+	// A blocking hook must not delay token delivery.
+	block := make(chan struct{})
+	tok := NewToken(context.Background(), auth,
+		WithOnRefresh(func(token string, expiresAt time.Time) {
+			<-block
+			mu.Lock()
+			refreshed = append(refreshed, token)
+			mu.Unlock()
+		}),
+		WithOnError(func(err error) {
+			mu.Lock()
+			failed = append(failed, err)
+			mu.Unlock()
+		}),
+	)
+	defer tok.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := tok.GetContext(ctx); err != nil {
+		t.Fatalf("Get blocked by hook: %v", err)
+	}
+	close(block)
+
+	waitFor(t, time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(refreshed) >= 2 && len(failed) >= 1
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if refreshed[0] != "tok" {
+		t.Errorf("want token tok, got %q", refreshed[0])
+	}
+	if !errors.Is(failed[0], errAuth) {
+		t.Errorf("want %v, got %v", errAuth, failed[0])
+	}
+}
+
+// An authorization call that fails because the token gets closed still reaches the `OnError` hook before `Close()` returns.
+func TestHooksAfterClose(t *testing.T) {
+	started := make(chan struct{})
+	auth := func(ctx context.Context) (string, time.Duration, error) {
+		close(started)
+		<-ctx.Done()
+		// Returning takes a moment, so that the error comes after the cancellation.
+		time.Sleep(20 * time.Millisecond)
+		return "", 0, ctx.Err()
+	}
+	var failed []error
+	tok := NewTokenContext(context.Background(), auth,
+		WithOnError(func(err error) { failed = append(failed, err) }),
+		WithLogger(NopLogger))
+	<-started
+	tok.Close()
+	if len(failed) != 1 || !errors.Is(failed[0], context.Canceled) {
+		t.Fatalf("want one canceled error, got %v", failed)
+	}
+}
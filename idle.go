@@ -0,0 +1,37 @@
+package main
+
+import (
+	"time"
+)
+
+// The article warns that a `Token` nobody reads keeps its goroutine alive until the context gets canceled. With an idle timeout, the goroutine stops by itself when the token has not been read for a while, and starts again on the next read. The next reader then waits for a new token, as the old one may have expired in the meantime.
+
+// `WithIdleTimeout` stops the refresh goroutine if no token has been read for `d`. The next read restarts the goroutine, which fetches a new token. Tokens with a warm pool or with `WithFastPath` ignore this option.
+// While the goroutine is stopped, it does not notice a canceled context. `Done()` then only gets closed by `Close()` or by the next read.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = d
+	}
+}
+
+// Method `idleTimeout` returns the effective idle timeout, or zero if the token never goes idle.
+func (a *Token) idleTimeout() time.Duration {
+	if a.opts.poolSize > 0 || a.opts.fastPath || a.opts.manualRun {
+		return 0
+	}
+	return a.opts.idleTimeout
+}
+
+// Method `idleTimer` returns a channel that fires when the token may have been idle for the idle timeout, or nil if the token never goes idle.
+func (a *Token) idleTimer() <-chan time.Time {
+	d := a.idleTimeout()
+	if d <= 0 {
+		return nil
+	}
+	return a.after(d - a.idleFor())
+}
+
+// Method `idleFor` returns the time since the last read.
+func (a *Token) idleFor() time.Duration {
+	return a.now().Sub(time.Unix(0, a.lastUsed.Load()))
+}
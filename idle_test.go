@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithIdleTimeout(30*time.Millisecond), WithLogger(NopLogger))
+	defer tok.Close()
+
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want token 1, got %q", got)
+	}
+	waitFor(t, time.Second, func() bool { return !tok.running.Load() })
+
+	// The next read restarts the goroutine, which fetches a new token.
+	if got, _ := tok.Get(); got != "2" {
+		t.Fatalf("want token 2 after idle restart, got %q", got)
+	}
+	if got, _ := tok.GetContext(context.Background()); got != "2" {
+		t.Fatalf("want token 2, got %q", got)
+	}
+}
+
+func TestIdleTimeoutClose(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil },
+		WithIdleTimeout(10*time.Millisecond), WithLogger(NopLogger))
+	waitFor(t, time.Second, func() bool { return !tok.running.Load() })
+	done := make(chan struct{})
+	go func() {
+		tok.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on an idle token")
+	}
+}
+
+// Readers that race with the idle stop must not hang.
+func TestIdleTimeoutConcurrentReads(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil },
+		WithIdleTimeout(time.Millisecond), WithLogger(NopLogger))
+	defer tok.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for i := 0; i < 200; i++ {
+		if _, err := tok.GetContext(ctx); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+		time.Sleep(time.Duration(i%3) * time.Millisecond)
+	}
+}
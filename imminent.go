@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A failing refresh is not a problem yet, as long as the current token is still valid. It becomes one when the token is about to expire and no new token is in sight. This is the moment to page someone, before clients start failing.
+
+// `WithOnExpiryImminent` calls `f` when the current token expires within `d`, no new token has been obtained, and the scheduled refresh has failed or is overdue. A healthy token that gets refreshed shortly before it expires raises no alarm. `f` receives the expiry time of the current token and the last refresh error, if the last attempt failed. The call is queued like the calls of `WithOnRefresh`, and the event is also logged as `EventExpiryImminent`.
+// While no new token arrives, `f` is called again every `d/2`, until the token has expired.
+func WithOnExpiryImminent(d time.Duration, f func(expiresAt time.Time, lastErr error)) Option {
+	if d <= 0 {
+		panic(fmt.Sprintf("refresh: WithOnExpiryImminent duration must be positive, got %v", d))
+	}
+	return func(o *options) {
+		o.expiryImminent = f
+		o.imminentWithin = d
+	}
+}
+
+// Method `watchExpiry` waits until the current token is about to expire, and raises the alarm if no new token has arrived by then. Without a token, or after the token has expired, it checks again every `d/2`.
+func (a *Token) watchExpiry(ctx context.Context) {
+	within := a.opts.imminentWithin
+	for {
+		wait := within / 2
+		if exp := a.stats.snapshot().ExpiresAt; !exp.IsZero() {
+			now := a.now()
+			switch at := exp.Add(-within); {
+			case now.Before(at):
+				wait = at.Sub(now)
+			case now.Before(exp) && a.refreshOverdue(now):
+				a.alarmExpiry(ctx, exp)
+			case now.Before(exp):
+				// Check again right after the scheduled refresh, in case it fails.
+				if due := time.Unix(0, a.refreshDue.Load()); due.After(now) {
+					wait = min(wait, due.Sub(now)+retryDelay)
+				}
+			}
+		}
+		select {
+		case <-a.after(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Method `refreshOverdue` reports whether the last refresh attempt failed, or the scheduled refresh should have happened by now. Tokens that refresh on demand have no schedule, so for them, only the expiry counts.
+func (a *Token) refreshOverdue(now time.Time) bool {
+	if a.stats.consecutiveFailures() > 0 {
+		return true
+	}
+	due := a.refreshDue.Load()
+	return due == 0 || !now.Before(time.Unix(0, due))
+}
+
+// Method `alarmExpiry` logs `EventExpiryImminent` and queues the call to the hook.
+func (a *Token) alarmExpiry(ctx context.Context, expiresAt time.Time) {
+	var lastErr error
+	if a.stats.consecutiveFailures() > 0 {
+		lastErr = a.stats.snapshot().LastError
+	}
+	a.logEvent(ctx, EventExpiryImminent, "Token about to expire without a replacement", "expires_at", expiresAt, "err", lastErr)
+	a.hooks.push(func() { a.opts.expiryImminent(expiresAt, lastErr) })
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// The alarm goes off when the token is about to expire after failed refreshes, and not while refreshes succeed.
+func TestOnExpiryImminent(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	errDown := errors.New("server down")
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) > 1 {
+			return "", 0, errDown
+		}
+		return "tok", time.Hour, nil
+	}
+	alarms := make(chan error, 10)
+	tok := NewToken(context.Background(), auth,
+		WithSafetyMargin(30*time.Minute),
+		WithBackoff(ExponentialBackoff{Initial: time.Minute, Max: time.Minute}),
+		WithOnExpiryImminent(10*time.Minute, func(expiresAt time.Time, lastErr error) {
+			alarms <- lastErr
+		}),
+		WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Refreshes start failing after 30 minutes. No alarm until 50 minutes.
+	for i := 0; i < 49; i++ {
+		clock.Advance(time.Minute)
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-alarms:
+		t.Fatalf("alarm too early: %v", err)
+	default:
+	}
+	clock.Advance(time.Minute)
+	select {
+	case err := <-alarms:
+		if !errors.Is(err, errDown) {
+			t.Fatalf("want last error %v, got %v", errDown, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no alarm before expiry")
+	}
+}
+
+// A healthy token that gets refreshed a short margin before it expires raises no alarm.
+func TestOnExpiryImminentHealthy(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "tok", time.Hour, nil
+	}
+	alarms := make(chan error, 10)
+	tok := NewToken(context.Background(), auth,
+		WithSafetyMargin(time.Second),
+		WithOnExpiryImminent(5*time.Minute, func(expiresAt time.Time, lastErr error) {
+			alarms <- lastErr
+		}),
+		WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three hours, three refreshes.
+	for i := 0; i < 3*60*6; i++ {
+		clock.Advance(10 * time.Second)
+		time.Sleep(100 * time.Microsecond)
+	}
+	waitFor(t, time.Second, func() bool { return calls.Load() >= 4 })
+	select {
+	case <-alarms:
+		t.Fatal("alarm for a healthy token")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Sometimes, the first token comes from somewhere else: an interactive login that just happened, or an orchestrator that passes a token to the process through an environment variable. Fetching another one right away wastes a call to the authorization server, or may not even be possible without the user's help.
+
+// `WithInitialValue` seeds the token with `token`, which expires at `expiresAt`. The first fetch returns it instead of calling the authorization function, and the first refresh is scheduled from `expiresAt`. If the token does not outlive the safety margin, the first fetch calls the authorization function as usual.
+// Tokens with a warm pool and tokens with a signing key ignore this option.
+func WithInitialValue(token string, expiresAt time.Time) Option {
+	return func(o *options) {
+		o.initial = &StoredToken{Token: token, ExpiresAt: expiresAt}
+	}
+}
+
+// Method `initialValue` returns the token of `WithInitialValue` if this is the first fetch and the token is still good.
+func (a *Token) initialValue(ctx context.Context) (tokenResponse, time.Duration, bool) {
+	if a.opts.initial == nil || a.opts.poolSize > 0 || a.authorizeWithKey != nil || !a.initialTried.CompareAndSwap(false, true) {
+		return tokenResponse{}, 0, false
+	}
+	now := a.now()
+	remaining := a.opts.initial.ExpiresAt.Sub(now)
+	if a.opts.initial.Token == "" || remaining <= a.storeMargin() {
+		a.logEvent(ctx, EventExpired, "Initial token expires too soon; fetching a new one", "expires_at", a.opts.initial.ExpiresAt)
+		return tokenResponse{}, 0, false
+	}
+	// The initial token takes the place of a stored one.
+	a.restoreTried.Store(true)
+	a.stats.success(now, a.opts.initial.ExpiresAt)
+	a.logEvent(ctx, EventRefresh, "Using initial token", "expires_at", a.opts.initial.ExpiresAt)
+	return tokenResponse{Token: a.opts.initial.Token, ExpiresAt: a.opts.initial.ExpiresAt, FetchedAt: now}, remaining, true
+}
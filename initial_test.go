@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// The initial value is served without an authorization call, and the first refresh happens before it expires.
+func TestInitialValue(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return "fetched" + strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithInitialValue("seed", clock.Now().Add(10*time.Minute)), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+
+	if got, err := tok.Get(); err != nil || got != "seed" {
+		t.Fatalf("want seed, got (%q, %v)", got, err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("want no authorization call, got %d", n)
+	}
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(10*time.Minute - lifeSpanSafetyMargin)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 1 })
+	if got, err := tok.Get(); err != nil || got != "fetched1" {
+		t.Fatalf("want fetched1, got (%q, %v)", got, err)
+	}
+}
+
+// An initial value that is about to expire is not used.
+func TestInitialValueExpired(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "fresh", time.Hour, nil
+	}, WithInitialValue("seed", time.Now().Add(lifeSpanSafetyMargin/2)), WithLogger(NopLogger))
+	defer tok.Close()
+	if got, err := tok.Get(); err != nil || got != "fresh" {
+		t.Fatalf("want fresh, got (%q, %v)", got, err)
+	}
+}
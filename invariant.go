@@ -0,0 +1,23 @@
+package main
+
+import "errors"
+
+// The loop refreshes a token well before it expires, so under normal conditions, readers never see an expired token. Abnormal conditions exist, though: a refresh that takes longer than the safety margin, or a token whose lifespan is shorter than the margin. Handing out an expired token lets the API call fail with a confusing "401 Unauthorized". A clear error at `Get()` is better, so each token response carries the token's expiry time, and the `Get` methods check it before returning the token.
+
+// `ErrExpired` is returned by `Get()` and its variants if the only token at hand has expired and no new one is available yet.
+var ErrExpired = errors.New("refresh: token expired")
+
+// Method `checkExpiry` replaces an expired token by `ErrExpired`. With `WithStaleOnError`, a token remains valid for the grace period beyond its expiry. While refreshes are paused, the token is served regardless (see `pause.go`). Responses without an expiry time pass unchecked.
+func (a *Token) checkExpiry(t tokenResponse) tokenResponse {
+	if t.Err != nil || t.ExpiresAt.IsZero() || a.paused.Load() {
+		return t
+	}
+	until := t.ExpiresAt
+	if a.opts.staleOnError {
+		until = until.Add(a.opts.staleGrace)
+	}
+	if !a.now().Before(until) {
+		return tokenResponse{Err: ErrExpired, ExpiresAt: t.ExpiresAt}
+	}
+	return t
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// `TestNeverServesExpiredToken` runs random sequences of refresh successes, refresh failures, `ForceRefresh()` calls, and clock advances against each mode of the token. Whatever the sequence, a successful `Get` must return a token that was issued and has not yet expired.
+func TestNeverServesExpiredToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		grace time.Duration
+	}{
+		{"channel", nil, 0},
+		{"fast path", []Option{WithFastPath()}, 0},
+		{"on demand", []Option{WithOnDemandRefresh()}, 0},
+		{"on demand serving stale", []Option{WithOnDemandRefresh(), WithServeStaleDuringRefresh()}, 0},
+		{"stale on error", []Option{WithStaleOnError(0)}, 0},
+		{"stale on error with grace", []Option{WithStaleOnError(5 * time.Millisecond)}, 5 * time.Millisecond},
+	}
+	seeds, steps := 20, 200
+	if testing.Short() {
+		seeds = 5
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			for seed := int64(0); seed < int64(seeds); seed++ {
+				checkExpiryInvariant(t, seed, steps, tt.grace, tt.opts)
+			}
+		})
+	}
+}
+
+// `checkExpiryInvariant` runs one random sequence of `steps` actions, derived from `seed`.
+func checkExpiryInvariant(t *testing.T, seed int64, steps int, grace time.Duration, opts []Option) {
+	t.Helper()
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	rnd := rand.New(rand.NewSource(seed))
+	var (
+		mu     sync.Mutex
+		issued = map[string]time.Time{}
+		// `failRate` changes during the run, to produce streaks of failures as well as of successes.
+		failRate = 0.2
+	)
+	auth := func() (string, time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if rnd.Float64() < failRate {
+			return "", 0, errors.New("down")
+		}
+		// Lifespans range from below the safety margin to many times of it.
+		lifespan := time.Duration(1+rnd.Intn(100)) * time.Millisecond
+		token := strconv.Itoa(len(issued) + 1)
+		issued[token] = clock.Now().Add(lifespan)
+		return token, lifespan, nil
+	}
+	tok := NewToken(context.Background(), auth, append([]Option{WithClock(clock), WithLogger(NopLogger)}, opts...)...)
+	defer tok.Close()
+
+	for i := 0; i < steps; i++ {
+		mu.Lock()
+		action := rnd.Intn(10)
+		advance := time.Duration(rnd.Intn(60)) * time.Millisecond
+		if rnd.Intn(20) == 0 {
+			failRate = rnd.Float64()
+		}
+		mu.Unlock()
+
+		switch {
+		case action < 3:
+			clock.Advance(advance)
+		case action < 4:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			tok.ForceRefresh(ctx)
+			cancel()
+		default:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			token, err := tok.GetContext(ctx)
+			cancel()
+			if err != nil {
+				continue
+			}
+			mu.Lock()
+			expiresAt, ok := issued[token]
+			mu.Unlock()
+			if !ok {
+				t.Fatalf("seed %d, step %d: got token %q that was never issued", seed, i, token)
+			}
+			if now := clock.Now(); !now.Before(expiresAt.Add(grace)) {
+				t.Fatalf("seed %d, step %d: got token %q that expired at %v, now is %v", seed, i, token, expiresAt, now)
+			}
+		}
+	}
+}
+
+// A token is expired from its expiry time on.
+func TestCheckExpiry(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+	resp := tok.checkExpiry(tokenResponse{Token: "tok", ExpiresAt: clock.Now()})
+	if !errors.Is(resp.Err, ErrExpired) || resp.Token != "" {
+		t.Fatalf("want ErrExpired, got %q, %v", resp.Token, resp.Err)
+	}
+	resp = tok.checkExpiry(tokenResponse{Token: "tok", ExpiresAt: clock.Now().Add(time.Millisecond)})
+	if resp.Err != nil || resp.Token != "tok" {
+		t.Fatalf("want tok, got %q, %v", resp.Token, resp.Err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	rnd "math/rand"
+	"time"
+)
+
+// Jitter spreads out timers that would otherwise fire at the same time. Refresh jitter keeps many tokens (or many app instances) from hitting the authorization endpoint all at once when their tokens were issued together. Backoff jitter spreads out retries after a failure. Both serve different purposes, hence they are configured independently.
+
+// `WithRefreshJitter` shortens each refresh delay by a random fraction of up to `fraction`. Refreshes only ever happen earlier, never later, so jitter cannot let a token expire. `fraction` must be between 0 and 1.
+func WithRefreshJitter(fraction float64) Option {
+	validateJitter("refresh", fraction)
+	return func(o *options) {
+		o.refreshJitter = fraction
+	}
+}
+
+// `WithBackoffJitter` shortens each retry delay by a random fraction of up to `fraction`. A fraction of 1 results in "full jitter". `fraction` must be between 0 and 1.
+func WithBackoffJitter(fraction float64) Option {
+	validateJitter("backoff", fraction)
+	return func(o *options) {
+		o.backoffJitter = fraction
+	}
+}
+
+// `WithRand` sets the random source for jitter, for example, a seeded one for reproducible tests. The source is used by the refresh goroutine only.
+func WithRand(r *rnd.Rand) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// An invalid jitter fraction is a programming error, hence `validateJitter` panics.
+func validateJitter(kind string, fraction float64) {
+	if fraction < 0 || fraction > 1 {
+		panic(fmt.Sprintf("refresh: %s jitter fraction must be between 0 and 1, got %v", kind, fraction))
+	}
+}
+
+// Method `jitter` shortens `d` by a random fraction of up to `fraction`.
+func (a *Token) jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction == 0 {
+		return d
+	}
+	f := rnd.Float64
+	if a.opts.rand != nil {
+		f = a.opts.rand.Float64
+	}
+	return time.Duration(float64(d) * (1 - fraction*f()))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	rnd "math/rand"
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	const seed = 42
+	lifespan := time.Second
+	authErr := errors.New("down")
+
+	newTok := func(opts ...Option) *Token {
+		a := &Token{}
+		for _, opt := range append(opts, WithRand(rnd.New(rnd.NewSource(seed)))) {
+			opt(&a.opts)
+		}
+		return a
+	}
+	jittered := func(d time.Duration, fraction float64) time.Duration {
+		r := rnd.New(rnd.NewSource(seed))
+		return time.Duration(float64(d) * (1 - fraction*r.Float64()))
+	}
+
+	a := newTok(WithRefreshJitter(0.5))
+	if got, _ := a.schedule(context.Background(), lifespan, nil); got != jittered(lifespan-lifeSpanSafetyMargin, 0.5) {
+		t.Errorf("refresh jitter: want %v, got %v", jittered(lifespan-lifeSpanSafetyMargin, 0.5), got)
+	}
+	if got, _ := a.schedule(context.Background(), lifespan, authErr); got != retryDelay {
+		t.Errorf("backoff without jitter: want %v, got %v", retryDelay, got)
+	}
+
+	a = newTok(WithBackoffJitter(1))
+	if got, _ := a.schedule(context.Background(), lifespan, authErr); got != jittered(retryDelay, 1) {
+		t.Errorf("backoff jitter: want %v, got %v", jittered(retryDelay, 1), got)
+	}
+	if got, _ := a.schedule(context.Background(), lifespan, nil); got != lifespan-lifeSpanSafetyMargin {
+		t.Errorf("refresh without jitter: want %v, got %v", lifespan-lifeSpanSafetyMargin, got)
+	}
+}
+
+func TestJitterValidation(t *testing.T) {
+	for _, f := range []func(){
+		func() { WithRefreshJitter(-0.1) },
+		func() { WithRefreshJitter(1.1) },
+		func() { WithBackoffJitter(-0.1) },
+		func() { WithBackoffJitter(1.1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("invalid fraction did not panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Verifying an ID token or an access token is the mirror image of fetching one. The verifier needs the public keys of the issuer, which publishes them as a JSON Web Key Set (JWKS). Issuers rotate their keys, so the key set is a value that expires, and a `Refresher` keeps it fresh. A token signed with a key that is newer than the cached key set triggers an early fetch, as the issuer may have rotated its keys before the cached set expired.
+
+// `DefaultJWKSLifespan` is how long a key set is used if the response does not say otherwise through `Cache-Control: max-age`.
+const DefaultJWKSLifespan = time.Hour
+
+// `minJWKSLifespan` keeps an issuer that forbids caching its key set from getting a request in each refresh loop iteration.
+const minJWKSLifespan = time.Minute
+
+// `jwksBackoff` spaces out the retries after failed fetches of a key set. The previous key set stays in use meanwhile.
+var jwksBackoff = ExponentialBackoff{Initial: time.Second, Max: minJWKSLifespan}
+
+// `minJWKSRefetch` limits how often tokens with unknown key IDs can trigger a fetch. Otherwise, forged tokens could make the verifier flood the issuer with requests.
+const minJWKSRefetch = time.Minute
+
+// `ErrUnknownKey` is returned if the key set has no key with the requested key ID.
+var ErrUnknownKey = errors.New("refresh: no key with this key ID")
+
+// `JWKS` maps key IDs to the public keys of a key set. Keys without a key ID have the empty key ID.
+type JWKS map[string]crypto.PublicKey
+
+// A `JWKSRefresher` is a `Refresher` for the signing keys of an issuer. If a fetch fails, it keeps serving the previous key set.
+type JWKSRefresher struct {
+	r     *Refresher[JWKS]
+	fetch func(ctx context.Context) (JWKS, time.Duration, error)
+
+	// `mu` guards `last`, the most recent key set from either the refresher or an early fetch, `fetchedAt`, the time of the last early fetch, and `refetching`, which is non-nil while an early fetch runs and gets closed when it is done. The fetch itself runs without the lock.
+	mu         sync.Mutex
+	last       JWKS
+	fetchedAt  time.Time
+	refetching chan struct{}
+}
+
+// `NewJWKSRefresher` spawns a goroutine that fetches the key set from `jwksURL` and fetches it again when the `max-age` of the response has passed, but not more often than once a minute, or after `DefaultJWKSLifespan` if the response has no `max-age`. After a failed fetch, it retries with exponential backoff from one second up to one minute, and keeps serving the previous key set. The `jwks_uri` of `OIDCConfig` is the URL of the issuer's key set. `client` makes the requests; nil means `http.DefaultClient`. The goroutine stops when `ctx` is canceled.
+func NewJWKSRefresher(ctx context.Context, jwksURL string, client *http.Client) *JWKSRefresher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	j := &JWKSRefresher{}
+	j.fetch = func(ctx context.Context) (JWKS, time.Duration, error) {
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		lifespan, err := getJSON(ctx, client, jwksURL, DefaultJWKSLifespan, &set)
+		if err != nil {
+			return nil, 0, fmt.Errorf("refresh: fetching JWKS: %w", err)
+		}
+		keys := make(JWKS, len(set.Keys))
+		for _, k := range set.Keys {
+			// Keys for encryption are of no use for verification. Keys of unknown types are skipped, so that one exotic key does not spoil the whole set.
+			if k.Use != "" && k.Use != "sig" {
+				continue
+			}
+			if pub, err := k.publicKey(); err == nil {
+				keys[k.Kid] = pub
+			}
+		}
+		return keys, max(lifespan, minJWKSLifespan), nil
+	}
+	j.r = NewRefresher(ctx, func(ctx context.Context) (JWKS, time.Duration, error) {
+		keys, lifespan, err := j.fetch(ctx)
+		if err == nil {
+			j.mu.Lock()
+			j.last = keys
+			j.mu.Unlock()
+		}
+		return keys, lifespan, err
+	}, WithRefresherBackoff(jwksBackoff))
+	return j
+}
+
+// Method `Keys` returns the current key set. After a failed fetch, it returns the previous key set.
+func (j *JWKSRefresher) Keys(ctx context.Context) (JWKS, error) {
+	_, err := j.r.GetContext(ctx)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// `last` is the newest key set, which may come from an early fetch.
+	if j.last == nil {
+		return nil, err
+	}
+	return j.last, nil
+}
+
+// Method `Key` returns the public key with the key ID `kid`. If the key set has no such key, `Key` fetches the key set once more, as the issuer may have rotated its keys, unless the last such fetch was less than a minute ago. Concurrent callers share the fetch, and callers that look up known keys do not wait for it.
+func (j *JWKSRefresher) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	keys, err := j.Keys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pub, ok := keys[kid]; ok {
+		return pub, nil
+	}
+	j.mu.Lock()
+	if wait := j.refetching; wait != nil {
+		j.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())
+		}
+		return j.lookup(kid)
+	}
+	if !j.fetchedAt.IsZero() && time.Since(j.fetchedAt) < minJWKSRefetch {
+		j.mu.Unlock()
+		return j.lookup(kid)
+	}
+	done := make(chan struct{})
+	j.fetchedAt, j.refetching = time.Now(), done
+	j.mu.Unlock()
+
+	keys, _, err = j.fetch(ctx)
+	j.mu.Lock()
+	if err == nil {
+		j.last = keys
+	}
+	j.refetching = nil
+	j.mu.Unlock()
+	close(done)
+	if err != nil {
+		return nil, err
+	}
+	return j.lookup(kid)
+}
+
+// Method `lookup` returns the key with the key ID `kid` from the newest key set.
+func (j *JWKSRefresher) lookup(kid string) (crypto.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if pub, ok := j.last[kid]; ok {
+		return pub, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// Method `Keyfunc` returns a function that looks up the verification key for the key ID of a JWT header. JWT libraries take such a function to find the key for a token. For example, with `github.com/golang-jwt/jwt/v5`:
+//
+//	keyfunc := jwks.Keyfunc()
+//	token, err := jwt.Parse(raw, func(t *jwt.Token) (any, error) {
+//		kid, _ := t.Header["kid"].(string)
+//		return keyfunc(kid)
+//	}, jwt.WithValidMethods([]string{"RS256", "ES256"}), jwt.WithIssuer(issuer), jwt.WithAudience(clientID))
+//
+// The function waits at most `callbackTimeout` for the key set.
+func (j *JWKSRefresher) Keyfunc() func(kid string) (crypto.PublicKey, error) {
+	return func(kid string) (crypto.PublicKey, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+		defer cancel()
+		return j.Key(ctx, kid)
+	}
+}
+
+// `jwk` holds the fields of a JSON Web Key (RFC 7517) that RSA and EC public keys need.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Method `publicKey` decodes the key (RFC 7518, section 6).
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("refresh: invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("refresh: unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("refresh: invalid EC key")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("refresh: unsupported key type %q", k.Kty)
+}
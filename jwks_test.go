@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func rsaJWK(kid string, pub *rsa.PublicKey) string {
+	return fmt.Sprintf(`{"kty":"RSA","kid":%q,"use":"sig","n":%q,"e":%q}`, kid,
+		base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()))
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) string {
+	return fmt.Sprintf(`{"kty":"EC","kid":%q,"crv":"P-256","x":%q,"y":%q}`, kid,
+		base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))))
+}
+
+func TestJWKSRefresher(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	// The second key appears after the first fetch, as if the issuer had rotated its keys.
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := rsaJWK("k1", &rsaKey.PublicKey) + `,{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},{"kty":"OKP","kid":"ed","crv":"Ed25519","x":"AA"}`
+		if fetches.Add(1) > 1 {
+			keys += "," + ecJWK("k2", &ecKey.PublicKey)
+		}
+		w.Header().Set("Cache-Control", "max-age=86400")
+		fmt.Fprintf(w, `{"keys":[%s]}`, keys)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jwks := NewJWKSRefresher(ctx, srv.URL, nil)
+	keyfunc := jwks.Keyfunc()
+
+	pub, err := keyfunc("k1")
+	if err != nil || !rsaKey.PublicKey.Equal(pub) {
+		t.Fatalf("want RSA key k1, got (%v, %v)", pub, err)
+	}
+	keys, _ := jwks.Keys(ctx)
+	if len(keys) != 1 {
+		t.Errorf("want only the signing key of a supported type, got %d keys", len(keys))
+	}
+
+	// An unknown key ID triggers an early fetch that finds the new key.
+	pub, err = keyfunc("k2")
+	if err != nil || !ecKey.PublicKey.Equal(pub) {
+		t.Fatalf("want EC key k2, got (%v, %v)", pub, err)
+	}
+	// Another unknown key ID right after does not trigger another fetch.
+	if _, err := keyfunc("k3"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("want ErrUnknownKey, got %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("want 2 fetches, got %d", n)
+	}
+	// Later calls use the newest key set.
+	if pub, err := keyfunc("k2"); err != nil || !ecKey.PublicKey.Equal(pub) {
+		t.Fatalf("want EC key k2, got (%v, %v)", pub, err)
+	}
+}
+
+func TestJWKSRefresherError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	jwks := NewJWKSRefresher(ctx, srv.URL, nil)
+	var ae *AuthError
+	if _, err := jwks.Key(ctx, "k1"); !errors.As(err, &ae) {
+		t.Fatalf("want AuthError, got %v", err)
+	}
+}
+
+// While an unknown key ID triggers a slow fetch, known keys remain available.
+func TestJWKSRefresherSlowRefetch(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		fmt.Fprintf(w, `{"keys":[%s]}`, rsaJWK("k1", &rsaKey.PublicKey))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	jwks := NewJWKSRefresher(ctx, srv.URL, nil)
+	if _, err := jwks.Key(ctx, "k1"); err != nil {
+		t.Fatal(err)
+	}
+	go jwks.Key(ctx, "k2")
+	waitFor(t, time.Second, func() bool { return fetches.Load() == 2 })
+	known, cancelKnown := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelKnown()
+	if _, err := jwks.Key(known, "k1"); err != nil {
+		t.Fatalf("known key blocked by refetch: %v", err)
+	}
+}
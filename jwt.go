@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Many authorization servers issue JWTs and do not report the lifespan separately. A JWT carries its expiry time in the `exp` claim, though. Reading the claim requires no signature verification, as the token comes straight from the authorization server and is not trusted for anything but its expiry time.
+
+// `ErrNotJWT` is returned by `JWTClaims` if the token is not a JWT.
+var ErrNotJWT = errors.New("refresh: not a JWT")
+
+// `ErrJWTNotYetValid` is returned if a JWT's `nbf` claim lies in the future.
+var ErrJWTNotYetValid = errors.New("refresh: JWT not yet valid")
+
+// `WithJWTExpiry` derives each token's lifespan from its `exp` claim, as the time left until then. `WithClockSkewTolerance` applies. A JWT whose `nbf` claim lies in the future counts as a failed refresh. Tokens that are not JWTs or have no `exp` claim keep the lifespan that the authorization function returned.
+func WithJWTExpiry() Option {
+	return func(o *options) {
+		o.jwtExpiry = true
+	}
+}
+
+// `JWTClaims` decodes the `exp` and `nbf` claims of a JWT without verifying its signature. Claims that are absent yield zero times.
+func JWTClaims(token string) (exp, nbf time.Time, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, time.Time{}, ErrNotJWT
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("refresh: decoding JWT payload: %w", err)
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+		Nbf *json.Number `json:"nbf"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("refresh: decoding JWT claims: %w", err)
+	}
+	if exp, err = numericDate(claims.Exp); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("refresh: JWT claim exp: %w", err)
+	}
+	if nbf, err = numericDate(claims.Nbf); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("refresh: JWT claim nbf: %w", err)
+	}
+	return exp, nbf, nil
+}
+
+// `numericDate` converts a JWT NumericDate, which are seconds since the epoch, possibly with a fraction.
+func numericDate(n *json.Number) (time.Time, error) {
+	if n == nil {
+		return time.Time{}, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	// A `time.Duration` overflows after the year 2262, so seconds and fraction go to `time.Unix` separately.
+	if math.IsNaN(f) || f < 0 || f >= maxNumericDate {
+		return time.Time{}, fmt.Errorf("refresh: NumericDate %s out of range", n)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
+
+// `maxNumericDate` is the start of the year 10000, beyond which no sane token expires.
+const maxNumericDate = 253402300800
+
+// Method `jwtLifespan` returns the lifespan derived from the token's claims, or `lifespan` if the token has no `exp` claim.
+func (a *Token) jwtLifespan(token string, lifespan time.Duration) (time.Duration, error) {
+	exp, nbf, err := JWTClaims(token)
+	if err != nil || exp.IsZero() {
+		return lifespan, nil
+	}
+	now := a.now()
+	if nbf.After(now.Add(a.opts.clockSkew)) {
+		return 0, fmt.Errorf("%w: nbf %v", ErrJWTNotYetValid, nbf)
+	}
+	return max(exp.Add(-a.opts.clockSkew).Sub(now), 0), nil
+}
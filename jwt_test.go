@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// `makeJWT` builds an unsigned JWT with the given claims.
+func makeJWT(claims string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(claims)) + ".sig"
+}
+
+func TestJWTExpiry(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	exp := start.Add(time.Hour).Unix()
+	tests := []struct {
+		name    string
+		token   string
+		skew    time.Duration
+		want    time.Duration
+		wantErr error
+	}{
+		{"exp", makeJWT(fmt.Sprintf(`{"exp":%d}`, exp)), 0, time.Hour, nil},
+		{"skew", makeJWT(fmt.Sprintf(`{"exp":%d}`, exp)), time.Minute, 59 * time.Minute, nil},
+		{"far future", makeJWT(`{"exp":9999999999}`), 0, time.Unix(9999999999, 0).Sub(start), nil},
+		{"no exp", makeJWT(`{"sub":"me"}`), 0, time.Minute, nil},
+		{"not a JWT", "opaque", 0, time.Minute, nil},
+		{"nbf in future", makeJWT(fmt.Sprintf(`{"exp":%d,"nbf":%d}`, exp, start.Add(time.Minute).Unix())), 0, 0, ErrJWTNotYetValid},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clock := refreshtest.NewClock(start)
+			// The authorization function reports a lifespan of one minute, which the claims override.
+			auth := func() (string, time.Duration, error) { return tt.token, time.Minute, nil }
+			tok := NewToken(context.Background(), auth, WithJWTExpiry(), WithClock(clock), WithClockSkewTolerance(tt.skew), WithLogger(NopLogger))
+			defer tok.Close()
+			_, err := tok.Get()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if ttl := tok.TTL(); ttl != tt.want {
+				t.Errorf("want TTL %v, got %v", tt.want, ttl)
+			}
+		})
+	}
+}
+
+func TestJWTClaimsRange(t *testing.T) {
+	exp, _, err := JWTClaims(makeJWT(`{"exp":9999999999.5}`))
+	if want := time.Unix(9999999999, 5e8); err != nil || !exp.Equal(want) {
+		t.Errorf("want %v, got (%v, %v)", want, exp, err)
+	}
+	for _, claims := range []string{`{"exp":1e300}`, `{"exp":-1}`, `{"exp":1e400}`} {
+		if _, _, err := JWTClaims(makeJWT(claims)); err == nil {
+			t.Errorf("%s: want error", claims)
+		}
+	}
+}
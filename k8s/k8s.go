@@ -0,0 +1,239 @@
+// Package k8s provides authorization functions for Kubernetes service account tokens, ready to be passed to `NewTokenWithExpiry` of the parent package.
+//
+// Kubelet mounts projected service account tokens into pods and rotates the token file before the token expires. `ProjectedToken` reads the file, takes the expiry time from the token's `exp` claim, and reads the file again before that time or after a poll interval, whatever comes first. If the file cannot be used, it can fall back to the TokenRequest API of the Kubernetes API server.
+//
+// The package uses only the standard library.
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTokenPath is where Kubernetes mounts the service account token of a pod.
+const DefaultTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// DefaultPollInterval is the maximum time between two reads of the token file.
+const DefaultPollInterval = time.Minute
+
+// Option configures an authorization function.
+type Option func(*config)
+
+type config struct {
+	poll     time.Duration
+	fallback *TokenRequest
+	now      func() time.Time
+}
+
+// WithPollInterval sets the maximum time between two reads of the token file. The default is `DefaultPollInterval`.
+func WithPollInterval(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.poll = d
+	}
+}
+
+// WithTokenRequest makes the authorization function request a token from the API server if the token file is missing, unreadable, or holds an expired token.
+func WithTokenRequest(req *TokenRequest) Option {
+	return func(cfg *config) {
+		cfg.fallback = req
+	}
+}
+
+// ErrExpired is returned if the token file holds an expired token and no fallback is configured.
+var ErrExpired = errors.New("k8s: service account token expired")
+
+// ProjectedToken returns an authorization function that reads the service account token from path. An empty path means `DefaultTokenPath`.
+func ProjectedToken(path string, opts ...Option) func(ctx context.Context) (string, time.Time, error) {
+	if path == "" {
+		path = DefaultTokenPath
+	}
+	cfg := &config{poll: DefaultPollInterval, now: time.Now}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(ctx context.Context) (string, time.Time, error) {
+		token, exp, err := cfg.readFile(path)
+		if err != nil && cfg.fallback != nil {
+			return cfg.fallback.Request(ctx)
+		}
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		// Read the file again after the poll interval, to pick up a rotated token early.
+		if next := cfg.now().Add(cfg.poll); next.Before(exp) {
+			exp = next
+		}
+		return token, exp, nil
+	}
+}
+
+// readFile reads the token file and decodes the token's expiry time.
+func (cfg *config) readFile(path string) (string, time.Time, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("k8s: reading service account token: %w", err)
+	}
+	token := strings.TrimSpace(string(b))
+	exp, err := expiry(token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !cfg.now().Before(exp) {
+		return "", time.Time{}, ErrExpired
+	}
+	return token, exp, nil
+}
+
+// expiry decodes the `exp` claim of a JWT without verifying its signature.
+func expiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("k8s: service account token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("k8s: decoding token payload: %w", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("k8s: decoding token claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, errors.New("k8s: service account token has no exp claim")
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// TokenRequest requests service account tokens from the TokenRequest API of the Kubernetes API server.
+type TokenRequest struct {
+	// APIServer is the base URL of the API server, for example, "https://kubernetes.default.svc".
+	APIServer string
+	// Namespace and ServiceAccount name the service account to request a token for.
+	Namespace      string
+	ServiceAccount string
+	// Audiences are the intended audiences of the token. Empty means the API server's default audience.
+	Audiences []string
+	// Expiration is the requested lifespan of the token. Zero means the API server's default.
+	Expiration time.Duration
+	// BearerToken returns the token that authenticates the request.
+	BearerToken func(ctx context.Context) (string, error)
+	// Client sends the request. Nil means `http.DefaultClient`.
+	Client *http.Client
+}
+
+// Paths of the service account files that Kubernetes mounts into each pod.
+const (
+	namespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	caPath        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// InClusterTokenRequest returns a TokenRequest for serviceAccount in the pod's namespace, sent to the API server that the pod's environment names, and authenticated with the pod's own service account token.
+func InClusterTokenRequest(serviceAccount string, audiences []string, expiration time.Duration) (*TokenRequest, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("k8s: not running in a cluster")
+	}
+	ns, err := os.ReadFile(namespacePath)
+	if err != nil {
+		return nil, fmt.Errorf("k8s: reading namespace: %w", err)
+	}
+	ca, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("k8s: reading CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("k8s: invalid CA certificate")
+	}
+	return &TokenRequest{
+		APIServer:      "https://" + net.JoinHostPort(host, port),
+		Namespace:      strings.TrimSpace(string(ns)),
+		ServiceAccount: serviceAccount,
+		Audiences:      audiences,
+		Expiration:     expiration,
+		BearerToken: func(ctx context.Context) (string, error) {
+			b, err := os.ReadFile(DefaultTokenPath)
+			return strings.TrimSpace(string(b)), err
+		},
+		Client: &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}},
+	}, nil
+}
+
+// maxResponseSize limits how much of a response gets read.
+const maxResponseSize = 1 << 20
+
+// Request requests a new token. It has the signature of an authorization function for `NewTokenWithExpiry`.
+func (r *TokenRequest) Request(ctx context.Context) (string, time.Time, error) {
+	spec := map[string]any{}
+	if len(r.Audiences) > 0 {
+		spec["audiences"] = r.Audiences
+	}
+	if r.Expiration > 0 {
+		spec["expirationSeconds"] = int64(r.Expiration / time.Second)
+	}
+	body, err := json.Marshal(map[string]any{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenRequest",
+		"spec":       spec,
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("k8s: %w", err)
+	}
+	u := fmt.Sprintf("%s/api/v1/namespaces/%s/serviceaccounts/%s/token", strings.TrimSuffix(r.APIServer, "/"), r.Namespace, r.ServiceAccount)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("k8s: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.BearerToken != nil {
+		bearer, err := r.BearerToken(ctx)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("k8s: reading bearer token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("k8s: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("k8s: reading token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", time.Time{}, fmt.Errorf("k8s: token request returned %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	var tr struct {
+		Status struct {
+			Token               string    `json:"token"`
+			ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(b, &tr); err != nil {
+		return "", time.Time{}, fmt.Errorf("k8s: decoding token response: %w", err)
+	}
+	if tr.Status.Token == "" || tr.Status.ExpirationTimestamp.IsZero() {
+		return "", time.Time{}, errors.New("k8s: incomplete token response")
+	}
+	return tr.Status.Token, tr.Status.ExpirationTimestamp, nil
+}
@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func jwt(exp time.Time) string {
+	payload, _ := json.Marshal(map[string]any{"sub": "system:serviceaccount:default:app", "exp": exp.Unix()})
+	return "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestProjectedToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	path := filepath.Join(t.TempDir(), "token")
+	token := jwt(now.Add(time.Hour))
+	os.WriteFile(path, []byte(token+"\n"), 0o600)
+
+	auth := ProjectedToken(path, WithPollInterval(5*time.Minute), func(cfg *config) { cfg.now = func() time.Time { return now } })
+	got, exp, err := auth(context.Background())
+	if err != nil || got != token || !exp.Equal(now.Add(5*time.Minute)) {
+		t.Fatalf("want token with expiry capped by poll interval, got (%q, %v, %v)", got, exp, err)
+	}
+
+	// A token that expires before the next poll keeps its own expiry.
+	token = jwt(now.Add(time.Minute))
+	os.WriteFile(path, []byte(token), 0o600)
+	got, exp, err = auth(context.Background())
+	if err != nil || got != token || !exp.Equal(now.Add(time.Minute)) {
+		t.Fatalf("want rotated token, got (%q, %v, %v)", got, exp, err)
+	}
+
+	os.WriteFile(path, []byte(jwt(now.Add(-time.Minute))), 0o600)
+	if _, _, err := auth(context.Background()); !errors.Is(err, ErrExpired) {
+		t.Fatalf("want ErrExpired, got %v", err)
+	}
+}
+
+func TestTokenRequestFallback(t *testing.T) {
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/serviceaccounts/app/token" || r.Header.Get("Authorization") != "Bearer own" {
+			t.Errorf("unexpected request %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var body struct {
+			Spec struct {
+				Audiences         []string `json:"audiences"`
+				ExpirationSeconds int64    `json:"expirationSeconds"`
+			} `json:"spec"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body.Spec.Audiences) != 1 || body.Spec.Audiences[0] != "vault" || body.Spec.ExpirationSeconds != 600 {
+			t.Errorf("unexpected spec %+v", body.Spec)
+		}
+		fmt.Fprintf(w, `{"status":{"token":"requested","expirationTimestamp":%q}}`, exp.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	req := &TokenRequest{
+		APIServer:      srv.URL,
+		Namespace:      "default",
+		ServiceAccount: "app",
+		Audiences:      []string{"vault"},
+		Expiration:     10 * time.Minute,
+		BearerToken:    func(ctx context.Context) (string, error) { return "own", nil },
+	}
+	token, got, err := ProjectedToken(filepath.Join(t.TempDir(), "missing"), WithTokenRequest(req))(context.Background())
+	if err != nil || token != "requested" || !got.Equal(exp) {
+		t.Fatalf("want requested token, got (%q, %v, %v)", token, got, err)
+	}
+}
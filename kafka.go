@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Kafka clients authenticate with SASL/OAUTHBEARER and ask a token provider for a token on each (re)authentication with a broker. Without a shared provider, each client implements its own refresh logic. A `KafkaTokenProvider` hands out the `Token`'s current value instead.
+//
+// The common clients define their own token types, and this package uses only the standard library, so a few lines of glue code connect the provider. For `github.com/IBM/sarama`, which expects an `AccessTokenProvider`:
+//
+//	type saramaProvider struct{ p *KafkaTokenProvider }
+//
+//	func (s saramaProvider) Token() (*sarama.AccessToken, error) {
+//		t, err := s.p.Token()
+//		return &sarama.AccessToken{Token: t.Token, Extensions: t.Extensions}, err
+//	}
+//
+// For `github.com/twmb/franz-go`, which expects a callback:
+//
+//	oauth.Oauth(func(ctx context.Context) (oauth.Auth, error) {
+//		t, err := p.TokenContext(ctx)
+//		return oauth.Auth{Token: t.Token, Extensions: t.Extensions}, err
+//	})
+
+// `DefaultKafkaTimeout` bounds the wait for a token in `KafkaTokenProvider.Token`, which has no context.
+const DefaultKafkaTimeout = 10 * time.Second
+
+// `KafkaToken` is what a Kafka client needs for SASL/OAUTHBEARER.
+type KafkaToken struct {
+	Token string
+	// `Extensions` are the SASL extensions of RFC 7628, which some brokers use, for example, for the logical cluster ID.
+	Extensions map[string]string
+	ExpiresAt  time.Time
+}
+
+// A `KafkaTokenProvider` serves a `Token` to Kafka clients.
+type KafkaTokenProvider struct {
+	t          *Token
+	extensions map[string]string
+	timeout    time.Duration
+}
+
+// `kafkaExtensionKey` is the key syntax of RFC 7628, section 3.1.
+var kafkaExtensionKey = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// `NewKafkaTokenProvider` returns a provider that serves the current value of `t` together with `extensions`, which may be nil. It panics if an extension key is invalid or the reserved key "auth".
+func NewKafkaTokenProvider(t *Token, extensions map[string]string) *KafkaTokenProvider {
+	for k := range extensions {
+		if k == "auth" || !kafkaExtensionKey.MatchString(k) {
+			panic(fmt.Sprintf("refresh: invalid SASL extension key %q", k))
+		}
+	}
+	return &KafkaTokenProvider{t: t, extensions: extensions, timeout: DefaultKafkaTimeout}
+}
+
+// Method `Token` returns the current token. It waits at most `DefaultKafkaTimeout` for a token.
+func (p *KafkaTokenProvider) Token() (KafkaToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.TokenContext(ctx)
+}
+
+// Method `TokenContext` returns the current token, or an error if no valid token is available before `ctx` is done.
+func (p *KafkaTokenProvider) TokenContext(ctx context.Context) (KafkaToken, error) {
+	token, err := p.t.GetContext(ctx)
+	if err != nil {
+		return KafkaToken{}, err
+	}
+	exp, _ := p.t.ExpiresAt()
+	return KafkaToken{Token: token, Extensions: p.extensions, ExpiresAt: exp}, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKafkaTokenProvider(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "kafka-token", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	p := NewKafkaTokenProvider(tok, map[string]string{"logicalCluster": "lkc-1"})
+	kt, err := p.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kt.Token != "kafka-token" || kt.Extensions["logicalCluster"] != "lkc-1" || time.Until(kt.ExpiresAt) < 59*time.Minute {
+		t.Fatalf("unexpected token %+v", kt)
+	}
+
+	failing := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, errors.New("idp down")
+	}, WithLogger(NopLogger))
+	defer failing.Close()
+	if _, err := NewKafkaTokenProvider(failing, nil).Token(); err == nil {
+		t.Fatal("want error")
+	}
+}
+
+func TestKafkaExtensionKeys(t *testing.T) {
+	for _, key := range []string{"auth", "with space", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%q: want panic", key)
+				}
+			}()
+			NewKafkaTokenProvider(nil, map[string]string{key: "x"})
+		}()
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Desktop tools and developer CLIs rather keep secrets in the operating system's keyring than in files. `KeyringStore` stores the token there.
+
+// A `Keyring` holds secrets by service and account name. `Get` returns `ErrNotInKeyring` if there is no secret.
+type Keyring interface {
+	Get(ctx context.Context, service, account string) (string, error)
+	Set(ctx context.Context, service, account, secret string) error
+}
+
+// `ErrNotInKeyring` is returned by a `Keyring` that holds no secret for the service and account.
+var ErrNotInKeyring = errors.New("refresh: secret not found in keyring")
+
+// `ErrKeyringUnsupported` is returned by `SystemKeyring` on operating systems without a supported keyring.
+var ErrKeyringUnsupported = errors.New("refresh: no supported keyring on this system")
+
+// `KeyringStore` keeps the token in a `Keyring` under `Service` and `Account`.
+type KeyringStore struct {
+	Keyring Keyring
+	Service string
+	Account string
+}
+
+// Method `Load` implements `Store`.
+func (s KeyringStore) Load(ctx context.Context) (StoredToken, bool, error) {
+	secret, err := s.Keyring.Get(ctx, s.Service, s.Account)
+	if errors.Is(err, ErrNotInKeyring) {
+		return StoredToken{}, false, nil
+	}
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	var t StoredToken
+	if err := json.Unmarshal([]byte(secret), &t); err != nil {
+		return StoredToken{}, false, err
+	}
+	return t, true, nil
+}
+
+// Method `Save` implements `Store`.
+func (s KeyringStore) Save(ctx context.Context, t StoredToken) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return s.Keyring.Set(ctx, s.Service, s.Account, string(b))
+}
+
+// `SystemKeyring` returns the keyring of the operating system: the login keychain on macOS, accessed through the `security` tool, or the Secret Service on Linux, accessed through `secret-tool` from libsecret. Secrets are passed through stdin, so that they never appear in the process list.
+func SystemKeyring() (Keyring, error) {
+	var k Keyring
+	var tool string
+	switch runtime.GOOS {
+	case "darwin":
+		k, tool = macKeychain{}, "security"
+	case "linux", "freebsd", "openbsd":
+		k, tool = secretService{}, "secret-tool"
+	default:
+		return nil, ErrKeyringUnsupported
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyringUnsupported, err)
+	}
+	return k, nil
+}
+
+// `runTool` runs a keyring tool with `stdin` and returns its trimmed output.
+func runTool(ctx context.Context, stdin string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("refresh: %s: %w: %s", name, err, strings.TrimSpace(errOut.String()))
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// `secretService` accesses the Secret Service API through `secret-tool`.
+type secretService struct{}
+
+func (secretService) Get(ctx context.Context, service, account string) (string, error) {
+	out, err := runTool(ctx, "", "secret-tool", "lookup", "service", service, "account", account)
+	// `secret-tool lookup` fails without output if there is no secret.
+	if err != nil || out == "" {
+		return "", ErrNotInKeyring
+	}
+	return out, nil
+}
+
+func (secretService) Set(ctx context.Context, service, account, secret string) error {
+	_, err := runTool(ctx, secret, "secret-tool", "store", "--label="+service, "service", service, "account", account)
+	return err
+}
+
+// `macKeychain` accesses the login keychain through the interactive mode of `security`, which reads commands from stdin. The secret is base64 encoded to avoid quoting issues.
+type macKeychain struct{}
+
+func (macKeychain) Get(ctx context.Context, service, account string) (string, error) {
+	out, err := runTool(ctx, "", "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	if err != nil {
+		return "", ErrNotInKeyring
+	}
+	b, err := base64.StdEncoding.DecodeString(out)
+	if err != nil {
+		return "", fmt.Errorf("refresh: decoding keychain item: %w", err)
+	}
+	return string(b), nil
+}
+
+func (macKeychain) Set(ctx context.Context, service, account, secret string) error {
+	cmd := fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n", service, account, base64.StdEncoding.EncodeToString([]byte(secret)))
+	_, err := runTool(ctx, cmd, "security", "-i")
+	return err
+}
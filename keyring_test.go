@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type mapKeyring map[string]string
+
+func (m mapKeyring) Get(_ context.Context, service, account string) (string, error) {
+	s, ok := m[service+"/"+account]
+	if !ok {
+		return "", ErrNotInKeyring
+	}
+	return s, nil
+}
+
+func (m mapKeyring) Set(_ context.Context, service, account, secret string) error {
+	m[service+"/"+account] = secret
+	return nil
+}
+
+func TestKeyringStore(t *testing.T) {
+	s := KeyringStore{Keyring: mapKeyring{}, Service: "refresh-test", Account: "api"}
+	ctx := context.Background()
+	if _, ok, err := s.Load(ctx); ok || err != nil {
+		t.Fatalf("want empty store, got (%v, %v)", ok, err)
+	}
+	want := StoredToken{Token: "tok", ExpiresAt: time.Now().Add(time.Hour).Truncate(time.Second)}
+	if err := s.Save(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := s.Load(ctx)
+	if err != nil || !ok || got.Token != want.Token || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Fatalf("want %+v, got (%+v, %v, %v)", want, got, ok, err)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+// A `Token` that is created at program start but may never be used should not call the authorization endpoint, nor keep a goroutine busy.
+
+// `WithLazyStart` defers the refresh goroutine, and with it the initial authorization call, until the first call to `Get()`, `GetContext()`, or any other method that needs a token.
+func WithLazyStart() Option {
+	return func(o *options) {
+		o.lazyStart = true
+	}
+}
+
+// Method `ensureStarted` starts the refresh goroutine if it is not running, and records the read for the idle timeout. It returns the channel that `run()` returned for the current goroutine. Readers select on it to notice when the goroutine stops for being idle.
+func (a *Token) ensureStarted() <-chan struct{} {
+	if a.idleTimeout() > 0 {
+		a.lastUsed.Store(a.now().UnixNano())
+		return a.run()
+	}
+	// Without idle timeout, a running goroutine only stops when the token gets closed, which the readers notice through `a.closing()`.
+	if a.running.Load() {
+		return nil
+	}
+	return a.run()
+}
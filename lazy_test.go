@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLazyStart(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "tok", time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithLazyStart())
+
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("lazy token authorized before first use (%d calls)", n)
+	}
+	if got, err := tok.GetContext(ctx); err != nil || got != "tok" {
+		t.Fatalf("want tok, got (%q, %v)", got, err)
+	}
+	tok.Get()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want exactly 1 authorization call, got %d", n)
+	}
+}
+
+func TestLazyStartCloseUnused(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "tok", time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithLazyStart())
+	if err := tok.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("closed lazy token authorized (%d calls)", n)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// `Logger` receives the log output of a `Token`. The method set matches `*slog.Logger`, so any `slog` logger can be used directly.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// `EventType` identifies the kind of event that a `Token` logs.
+type EventType int
+
+const (
+	// `EventExpired` is logged when the refresh timer fires.
+	EventExpired EventType = iota
+	// `EventForceRefresh` is logged when a client forces a refresh.
+	EventForceRefresh
+	// `EventRefresh` is logged after a successful refresh.
+	EventRefresh
+	// `EventRefreshError` is logged after a failed refresh.
+	EventRefreshError
+	// `EventBackoff` is logged when a retry gets scheduled.
+	EventBackoff
+	// `EventErrorWithToken` is logged when a token is served despite an authorization error.
+	EventErrorWithToken
+	// `EventClose` is logged when the refresh goroutine stops.
+	EventClose
+	// `EventUnused` is logged when nobody has read the token for a while after it was created.
+	EventUnused
+	// `EventIdle` is logged when the refresh goroutine stops because of the idle timeout.
+	EventIdle
+	// `EventCircuit` is logged when the circuit breaker changes its state.
+	EventCircuit
+	// `EventExpiryImminent` is logged when the token is about to expire and no new token has been obtained.
+	EventExpiryImminent
+	// `EventSinkError` is logged when a sink fails to take a new token.
+	EventSinkError
+)
+
+// `eventNames` are the values of the `event` field that every log entry carries.
+var eventNames = map[EventType]string{
+	EventExpired:        "expired",
+	EventForceRefresh:   "force_refresh",
+	EventRefresh:        "refresh",
+	EventRefreshError:   "refresh_error",
+	EventBackoff:        "backoff",
+	EventErrorWithToken: "error_with_token",
+	EventClose:          "close",
+	EventUnused:         "unused",
+	EventIdle:           "idle",
+	EventCircuit:        "circuit",
+	EventExpiryImminent: "expiry_imminent",
+	EventSinkError:      "sink_error",
+}
+
+// Method `String` returns the event name as it appears in the `event` field of log entries.
+func (e EventType) String() string {
+	if name, ok := eventNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(e))
+}
+
+// `defaultEventLevels` apply to all events that `WithEventLevels` does not configure.
+var defaultEventLevels = map[EventType]slog.Level{
+	EventExpired:        slog.LevelInfo,
+	EventForceRefresh:   slog.LevelInfo,
+	EventRefresh:        slog.LevelInfo,
+	EventRefreshError:   slog.LevelError,
+	EventBackoff:        slog.LevelWarn,
+	EventErrorWithToken: slog.LevelWarn,
+	EventClose:          slog.LevelInfo,
+	EventUnused:         slog.LevelWarn,
+	EventIdle:           slog.LevelInfo,
+	EventCircuit:        slog.LevelWarn,
+	EventExpiryImminent: slog.LevelError,
+	EventSinkError:      slog.LevelError,
+}
+
+// `WithLogger` sets the logger for the token's events. By default, events go to the standard logger of package `log`.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// `WithEventLevels` sets the log level per event type, for example, to log successful refreshes at debug level and failed ones at error level. Events that are not in the map keep their default level.
+func WithEventLevels(levels map[EventType]slog.Level) Option {
+	return func(o *options) {
+		o.eventLevels = levels
+	}
+}
+
+// `NewSlogLogger` returns a `Logger` that writes to the given `slog` handler. A nil handler means the handler of `slog.Default()`.
+func NewSlogLogger(h slog.Handler) Logger {
+	if h == nil {
+		return slog.Default()
+	}
+	return slog.New(h)
+}
+
+// `NopLogger` discards all events.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(context.Context, slog.Level, string, ...any) {}
+
+// Method `logEvent` logs an event at the level configured for its type. Each entry carries the event name in the `event` field, followed by the event's own fields.
+func (a *Token) logEvent(ctx context.Context, ev EventType, msg string, args ...any) {
+	level, ok := a.opts.eventLevels[ev]
+	if !ok {
+		level = defaultEventLevels[ev]
+	}
+	l := a.opts.logger
+	if l == nil {
+		l = stdLogger{}
+	}
+	l.Log(ctx, level, msg, append([]any{"event", ev.String()}, args...)...)
+}
+
+// `stdLogger` writes to the standard logger of package `log`, which is what the article's code did before loggers became configurable. Key-value pairs are appended to the message.
+type stdLogger struct{}
+
+func (stdLogger) Log(_ context.Context, _ slog.Level, msg string, args ...any) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	log.Println(b.String())
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type logEntry struct {
+	level slog.Level
+	msg   string
+}
+
+// `captureLogger` records all log calls.
+type captureLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (c *captureLogger) Log(_ context.Context, level slog.Level, msg string, _ ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, logEntry{level, msg})
+}
+
+func (c *captureLogger) levels() map[string]slog.Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := map[string]slog.Level{}
+	for _, e := range c.entries {
+		m[e.msg] = e.level
+	}
+	return m
+}
+
+func TestEventLevels(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) == 2 {
+			return "", time.Hour, errors.New("down")
+		}
+		return "tok", 20 * time.Millisecond, nil
+	}
+	levels := map[EventType]slog.Level{
+		EventExpired:      slog.LevelDebug - 1,
+		EventRefresh:      slog.LevelDebug,
+		EventRefreshError: slog.LevelError + 1,
+		EventBackoff:      slog.LevelWarn + 1,
+		EventClose:        slog.LevelInfo + 1,
+	}
+	logger := &captureLogger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	tok := NewToken(ctx, auth, WithLogger(logger), WithEventLevels(levels))
+
+	// Wait for expiry, failure, retry, and success.
+	for calls.Load() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	tok.Get()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	got := logger.levels()
+	for msg, want := range map[string]slog.Level{
+		"Token expired":           levels[EventExpired],
+		"Token refreshed":         levels[EventRefresh],
+		"Error refreshing token":  levels[EventRefreshError],
+		"Retrying refresh":        levels[EventBackoff],
+		"Token refresher stopped": levels[EventClose],
+	} {
+		if l, ok := got[msg]; !ok || l != want {
+			t.Errorf("%q: want level %v, got %v (logged: %v)", msg, want, l, ok)
+		}
+	}
+}
+
+func TestSlogLoggerStructuredFields(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+	h := slog.NewJSONHandler(&lockedWriter{mu: &mu, w: &buf}, &slog.HandlerOptions{Level: slog.LevelDebug})
+	auth := func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithLogger(NewSlogLogger(h)))
+	if err := tok.ForceRefresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	tok.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	events := map[string]bool{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var entry map[string]any
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		ev, _ := entry["event"].(string)
+		events[ev] = true
+		if ev == EventRefresh.String() {
+			if _, ok := entry["expires_at"]; !ok {
+				t.Errorf("refresh entry lacks expires_at: %v", entry)
+			}
+		}
+	}
+	for _, want := range []EventType{EventForceRefresh, EventRefresh, EventClose} {
+		if !events[want.String()] {
+			t.Errorf("no %q event logged (got %v)", want, events)
+		}
+	}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
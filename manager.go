@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A `Manager` keeps a set of named tokens, for example one per API that the app talks to.
+// With a factory, the `Manager` also creates tokens on demand, for example one per tenant and scope set, and stops those that nobody has used for a while.
+type Manager struct {
+	mu      sync.Mutex
+	tokens  map[string]*Token
+	factory func(ctx context.Context, key string) (*Token, error)
+	// `lastUsed` tracks the tokens created by the factory. Only these get evicted when idle.
+	lastUsed map[string]time.Time
+	// `building` holds a pending factory call per key, so that concurrent callers for the same key share one call, and callers for other keys do not wait for it.
+	building map[string]*pendingToken
+	idleTTL  time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closed   bool
+}
+
+// A `pendingToken` is a factory call in progress. `done` gets closed when `t` and `err` are set.
+type pendingToken struct {
+	done chan struct{}
+	t    *Token
+	err  error
+}
+
+// `ManagerOption` configures a `Manager`.
+type ManagerOption func(*Manager)
+
+// `WithFactory` lets `Manager.Get` create a token for each new key. The factory receives a context that lives until the `Manager` gets closed, and should pass it on to the token's constructor.
+func WithFactory(factory func(ctx context.Context, key string) (*Token, error)) ManagerOption {
+	return func(m *Manager) {
+		m.factory = factory
+	}
+}
+
+// `WithIdleEviction` closes and removes tokens created by the factory if `Manager.Get` has not been called for their key within `ttl`. The next `Get` for the key creates a new token. Registered tokens are never evicted. The `Manager` checks for idle tokens every `ttl/2`, but not more often than every `minEvictionInterval`. It panics if `ttl` is negative.
+func WithIdleEviction(ttl time.Duration) ManagerOption {
+	if ttl < 0 {
+		panic("refresh: WithIdleEviction ttl must not be negative")
+	}
+	return func(m *Manager) {
+		m.idleTTL = ttl
+	}
+}
+
+// `NewManager` returns an empty `Manager`.
+func NewManager(opts ...ManagerOption) *Manager {
+	m := &Manager{
+		tokens:   make(map[string]*Token),
+		lastUsed: make(map[string]time.Time),
+		building: make(map[string]*pendingToken),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	if m.idleTTL > 0 {
+		go m.evictIdle()
+	}
+	return m
+}
+
+// Method `Register` adds a token under the given name. A different token registered under the same name gets replaced and closed. After `Close()`, `Register` closes `t` and returns `ErrClosed`.
+func (m *Manager) Register(name string, t *Token) error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		t.Close()
+		return ErrClosed
+	}
+	old, ok := m.tokens[name]
+	m.tokens[name] = t
+	delete(m.lastUsed, name)
+	m.mu.Unlock()
+	if ok && old != t {
+		old.Close()
+	}
+	return nil
+}
+
+// Method `Token` returns the token registered under the given name.
+func (m *Manager) Token(name string) (*Token, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[name]
+	return t, ok
+}
+
+// Method `Remove` removes the token registered under the given name and closes it. It returns once the token's goroutines have stopped, and reports whether there was such a token.
+func (m *Manager) Remove(name string) bool {
+	m.mu.Lock()
+	t, ok := m.tokens[name]
+	delete(m.tokens, name)
+	delete(m.lastUsed, name)
+	m.mu.Unlock()
+	if ok {
+		t.Close()
+	}
+	return ok
+}
+
+// `ErrNoFactory` is returned by `Manager.Get` for an unknown key if the `Manager` has no factory.
+var ErrNoFactory = errors.New("refresh: no token for key and no factory")
+
+// `ErrNilToken` is returned by `Manager.Get` if the factory returned neither a token nor an error.
+var ErrNilToken = errors.New("refresh: factory returned a nil token")
+
+// Method `Get` returns the current token for `key`. If there is no token for `key` yet, `Get` creates one with the factory. All callers share the same token per key. The factory runs without blocking callers for other keys.
+func (m *Manager) Get(ctx context.Context, key string) (string, error) {
+	t, err := m.tokenFor(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return t.GetContext(ctx)
+}
+
+// Method `tokenFor` looks up or creates the token for `key` and marks it as used. Concurrent callers for a key that has no token yet wait for a single factory call. `ctx` bounds only the waiting.
+func (m *Manager) tokenFor(ctx context.Context, key string) (*Token, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if t, ok := m.tokens[key]; ok {
+		if _, managed := m.lastUsed[key]; managed {
+			m.lastUsed[key] = time.Now()
+		}
+		m.mu.Unlock()
+		return t, nil
+	}
+	if m.factory == nil {
+		m.mu.Unlock()
+		return nil, ErrNoFactory
+	}
+	if p, ok := m.building[key]; ok {
+		m.mu.Unlock()
+		select {
+		case <-p.done:
+			return p.t, p.err
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())
+		}
+	}
+	p := &pendingToken{done: make(chan struct{})}
+	m.building[key] = p
+	m.mu.Unlock()
+
+	m.build(key, p)
+	return p.t, p.err
+}
+
+// Method `build` calls the factory for `key` and hands the result to the callers that wait for `p`. If the factory panics, the waiters get an error, and the panic continues in the calling goroutine.
+func (m *Manager) build(key string, p *pendingToken) {
+	// This error remains only if the factory does not return.
+	p.err = fmt.Errorf("refresh: factory for key %q panicked", key)
+	defer func() {
+		var orphan *Token
+		m.mu.Lock()
+		delete(m.building, key)
+		if p.err == nil {
+			if m.closed {
+				// The `Manager` got closed while the factory ran. Nobody else will close the new token.
+				orphan = p.t
+				p.t, p.err = nil, ErrClosed
+			} else {
+				m.tokens[key] = p.t
+				m.lastUsed[key] = time.Now()
+			}
+		}
+		m.mu.Unlock()
+		close(p.done)
+		if orphan != nil {
+			orphan.Close()
+		}
+	}()
+	t, err := m.factory(m.ctx, key)
+	if err == nil && t == nil {
+		err = fmt.Errorf("%w for key %q", ErrNilToken, key)
+	}
+	p.t, p.err = t, err
+}
+
+// `minEvictionInterval` keeps a tiny idle TTL from making the eviction loop spin.
+const minEvictionInterval = time.Millisecond
+
+// Method `evictIdle` periodically closes tokens that have been idle for longer than the TTL.
+func (m *Manager) evictIdle() {
+	ticker := time.NewTicker(max(m.idleTTL/2, minEvictionInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			var idle []*Token
+			m.mu.Lock()
+			for key, used := range m.lastUsed {
+				if now.Sub(used) > m.idleTTL {
+					idle = append(idle, m.tokens[key])
+					delete(m.tokens, key)
+					delete(m.lastUsed, key)
+				}
+			}
+			m.mu.Unlock()
+			// Closing waits for the refresh goroutines. Do this without holding the lock.
+			for _, t := range idle {
+				t.Close()
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// Method `Close` closes all tokens, registered or created by the factory, and stops idle eviction. Afterwards, `Get` returns `ErrClosed`.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	tokens := m.tokens
+	m.tokens = make(map[string]*Token)
+	m.lastUsed = make(map[string]time.Time)
+	m.mu.Unlock()
+	m.cancel()
+	for _, t := range tokens {
+		t.Close()
+	}
+	return nil
+}
+
+// Method `Len` returns the number of tokens that the `Manager` currently holds.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.tokens)
+}
+
+// Method `StatsSnapshot` returns the stats of every registered token, keyed by name. The set of tokens cannot change while the snapshot is taken. Reading the stats does not block any refresh loop.
+func (m *Manager) StatsSnapshot() map[string]Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap := make(map[string]Stats, len(m.tokens))
+	for name, t := range m.tokens {
+		snap[name] = t.Stats()
+	}
+	return snap
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestManagerStatsSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	good := func() (string, time.Duration, error) { return "tok", time.Hour, nil }
+	bad := func() (string, time.Duration, error) { return "", time.Hour, errors.New("down") }
+
+	m := NewManager()
+	m.Register("good", NewToken(ctx, good))
+	m.Register("bad", NewToken(ctx, bad, WithRefreshDeadline(time.Nanosecond)))
+
+	for i := 0; i < 3; i++ {
+		tok, _ := m.Token("good")
+		tok.Get()
+	}
+	tok, _ := m.Token("bad")
+	tok.Get()
+
+	snap := m.StatsSnapshot()
+	if len(snap) != 2 {
+		t.Fatalf("want 2 entries, got %d", len(snap))
+	}
+	if s := snap["good"]; s.Refreshes != 1 || s.Failures != 0 || s.Gets != 3 || s.ExpiresAt.IsZero() {
+		t.Fatalf("good: unexpected stats %+v", s)
+	}
+	if s := snap["bad"]; s.Refreshes != 0 || s.Failures != 1 || s.Gets != 1 || s.LastError == nil {
+		t.Fatalf("bad: unexpected stats %+v", s)
+	}
+}
+
+func TestManagerFactory(t *testing.T) {
+	var created atomic.Int32
+	factory := func(ctx context.Context, key string) (*Token, error) {
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+		created.Add(1)
+		return NewToken(ctx, func() (string, time.Duration, error) { return "tok-" + key, time.Hour, nil }, WithLogger(NopLogger)), nil
+	}
+	m := NewManager(WithFactory(factory), WithIdleEviction(20*time.Millisecond))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := m.Get(context.Background(), "tenant-a"); got != "tok-tenant-a" || err != nil {
+				t.Errorf("want tok-tenant-a, got (%q, %v)", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := created.Load(); n != 1 {
+		t.Fatalf("want 1 token created, got %d", n)
+	}
+	if _, err := m.Get(context.Background(), ""); err == nil {
+		t.Fatal("want factory error")
+	}
+
+	// The idle token gets evicted and recreated on the next Get.
+	tok, _ := m.Token("tenant-a")
+	select {
+	case <-tok.Done():
+	case <-time.After(time.Second):
+		t.Fatal("idle token not evicted")
+	}
+	if n := m.Len(); n != 0 {
+		t.Fatalf("want no tokens after eviction, got %d", n)
+	}
+	m.Get(context.Background(), "tenant-a")
+	if n := created.Load(); n != 2 {
+		t.Fatalf("want 2 tokens created, got %d", n)
+	}
+
+	m.Close()
+	if _, err := m.Get(context.Background(), "tenant-a"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
+
+// A slow factory for one key does not block lookups of other keys.
+func TestManagerSlowFactory(t *testing.T) {
+	release := make(chan struct{})
+	var created atomic.Int32
+	factory := func(ctx context.Context, key string) (*Token, error) {
+		created.Add(1)
+		if key == "slow" {
+			<-release
+		}
+		return NewToken(ctx, func() (string, time.Duration, error) { return "tok-" + key, time.Hour, nil }, WithLogger(NopLogger)), nil
+	}
+	m := NewManager(WithFactory(factory))
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := m.Get(context.Background(), "slow"); got != "tok-slow" || err != nil {
+				t.Errorf("want tok-slow, got (%q, %v)", got, err)
+			}
+		}()
+	}
+	waitFor(t, time.Second, func() bool { return created.Load() == 1 })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if got, err := m.Get(ctx, "fast"); got != "tok-fast" || err != nil {
+		t.Fatalf("want tok-fast, got (%q, %v)", got, err)
+	}
+	close(release)
+	wg.Wait()
+	if n := created.Load(); n != 2 {
+		t.Fatalf("want 2 tokens created, got %d", n)
+	}
+}
+
+func TestManagerRegisterReplaces(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	auth := func() (string, time.Duration, error) { return "tok", time.Hour, nil }
+	m := NewManager()
+	defer m.Close()
+	old := NewToken(ctx, auth)
+	m.Register("api", old)
+	m.Register("api", NewToken(ctx, auth))
+	select {
+	case <-old.Done():
+	case <-time.After(time.Second):
+		t.Fatal("replaced token not closed")
+	}
+}
+
+func TestManagerTinyIdleTTL(t *testing.T) {
+	m := NewManager(WithIdleEviction(time.Nanosecond))
+	m.Close()
+}
+
+func TestManagerRegisterAfterClose(t *testing.T) {
+	m := NewManager()
+	m.Close()
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil })
+	if err := m.Register("api", tok); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+	select {
+	case <-tok.Done():
+	case <-time.After(time.Second):
+		t.Fatal("token registered after Close not closed")
+	}
+}
+
+// A factory that returns no token or panics does not break later calls for the key.
+func TestManagerFactoryFailures(t *testing.T) {
+	var calls atomic.Int32
+	m := NewManager(WithFactory(func(ctx context.Context, key string) (*Token, error) {
+		switch calls.Add(1) {
+		case 1:
+			return nil, nil
+		case 2:
+			panic("factory bug")
+		}
+		return NewTokenContext(ctx, func(context.Context) (string, time.Duration, error) { return "tok", time.Hour, nil }), nil
+	}))
+	defer m.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := m.Get(ctx, "k"); !errors.Is(err, ErrNilToken) {
+		t.Fatalf("want ErrNilToken, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("factory panic not propagated")
+			}
+		}()
+		m.Get(ctx, "k")
+	}()
+	if got, err := m.Get(ctx, "k"); got != "tok" || err != nil {
+		t.Fatalf("want tok, got (%q, %v)", got, err)
+	}
+	if n := len(m.StatsSnapshot()); n != 1 {
+		t.Fatalf("want 1 token, got %d", n)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// The loop refreshes a token `lifeSpanSafetyMargin` before it expires. That constant is tuned for the article's simulation. Real tokens need a margin that fits their lifespan and the latency of their authorization endpoint.
+
+// `WithSafetyMargin` makes the loop refresh the token `d` before it expires. Zero means that the token gets refreshed when it expires. `d` must not be negative.
+func WithSafetyMargin(d time.Duration) Option {
+	if d < 0 {
+		panic(fmt.Sprintf("refresh: negative safety margin %v", d))
+	}
+	return func(o *options) {
+		o.safetyMargin, o.safetyMarginSet = d, true
+		o.safetyFraction = 0
+	}
+}
+
+// `WithSafetyFraction` makes the loop refresh the token after the fraction `f` of its lifespan has passed. For example, 0.8 refreshes the token at 80% of its lifespan. `f` must be greater than 0 and at most 1.
+func WithSafetyFraction(f float64) Option {
+	if f <= 0 || f > 1 {
+		panic(fmt.Sprintf("refresh: safety fraction must be in (0, 1], got %v", f))
+	}
+	return func(o *options) {
+		o.safetyFraction = f
+		o.safetyMargin, o.safetyMarginSet = 0, false
+	}
+}
+
+// A fixed margin is a guess. If the authorization server slows down, or some calls fail and have to be retried, the refresh may not complete before the token expires. The adaptive margin measures instead of guessing.
+
+const (
+	// `adaptiveWindow` is the number of recent authorization calls that the adaptive margin considers.
+	adaptiveWindow = 50
+	// `adaptiveConfidence` is the probability with which the refresh shall complete before the token expires.
+	adaptiveConfidence = 0.99
+	// `adaptiveMaxAttempts` caps the number of attempts that the adaptive margin plans for.
+	adaptiveMaxAttempts = 10
+)
+
+// `WithAdaptiveMargin` widens the safety margin as needed, based on the recent authorization calls. The margin covers enough attempts to succeed with 99% probability at the observed failure rate, each taking as long as the 95th percentile of the observed latencies, plus the backoff delays between them. The configured margin (or fraction) remains the minimum. The margin never exceeds half of the lifespan.
+func WithAdaptiveMargin() Option {
+	return func(o *options) {
+		o.adaptiveMargin = true
+	}
+}
+
+// Method `adaptiveMargin` computes the margin for `WithAdaptiveMargin` from the recorded authorization calls. It returns zero if there are none.
+func (a *Token) adaptiveMargin() time.Duration {
+	records := a.timings.snapshot()
+	if len(records) > adaptiveWindow {
+		records = records[len(records)-adaptiveWindow:]
+	}
+	if len(records) == 0 {
+		return 0
+	}
+	durations := make([]time.Duration, len(records))
+	failures := 0
+	for i, r := range records {
+		durations[i] = r.Duration
+		if r.Err != nil {
+			failures++
+		}
+	}
+	slices.Sort(durations)
+	p95 := durations[(len(durations)*95+99)/100-1]
+
+	// With a failure rate of f, n attempts all fail with probability f^n.
+	attempts := 1
+	if f := float64(failures) / float64(len(records)); f > 0 {
+		attempts = adaptiveMaxAttempts
+		if f < 1 {
+			attempts = min(int(math.Ceil(math.Log(1-adaptiveConfidence)/math.Log(f))), adaptiveMaxAttempts)
+		}
+	}
+	var policy BackoffPolicy = constantBackoff{}
+	if a.opts.backoff != nil {
+		policy = a.opts.backoff
+	}
+	margin := time.Duration(attempts) * p95
+	for i := 1; i < attempts; i++ {
+		delay, ok := policy.NextDelay(i, margin)
+		if !ok {
+			break
+		}
+		margin += delay
+	}
+	return margin
+}
+
+// Method `refreshAfter` returns the time after which a token with the given lifespan shall be refreshed. A margin that is not smaller than the lifespan would push the refresh time into the past and make the loop refresh continuously. In this case, the token gets refreshed halfway through its lifespan instead.
+// With `WithAdaptiveMargin`, the token gets refreshed earlier if the adaptive margin demands it, but not before half of its lifespan.
+func (a *Token) refreshAfter(lifespan time.Duration) time.Duration {
+	after := a.fixedRefreshAfter(lifespan)
+	if a.opts.adaptiveMargin {
+		if m := a.adaptiveMargin(); lifespan-m < after {
+			after = max(lifespan-m, min(after, lifespan/2))
+		}
+	}
+	// With `WithMaxServeAge`, the token gets refreshed before it becomes too old to serve. See `maxage.go`.
+	if a.opts.maxServeAge > 0 {
+		after = min(after, a.opts.maxServeAge)
+	}
+	return after
+}
+
+// Method `safetyMargin` returns the configured safety margin, or `lifeSpanSafetyMargin` if none is set.
+func (a *Token) safetyMargin() time.Duration {
+	if a.opts.safetyMarginSet {
+		return a.opts.safetyMargin
+	}
+	return lifeSpanSafetyMargin
+}
+
+// Method `fixedRefreshAfter` implements `refreshAfter` for the configured margin or fraction.
+func (a *Token) fixedRefreshAfter(lifespan time.Duration) time.Duration {
+	if a.opts.safetyFraction > 0 {
+		return time.Duration(float64(lifespan) * a.opts.safetyFraction)
+	}
+	margin := a.safetyMargin()
+	if margin >= lifespan {
+		return lifespan / 2
+	}
+	return lifespan - margin
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		lifespan time.Duration
+		want     time.Duration
+	}{
+		{"default", nil, time.Second, time.Second - lifeSpanSafetyMargin},
+		{"margin", []Option{WithSafetyMargin(time.Minute)}, time.Hour, 59 * time.Minute},
+		{"fraction", []Option{WithSafetyFraction(0.8)}, time.Hour, 48 * time.Minute},
+		{"last option wins", []Option{WithSafetyFraction(0.8), WithSafetyMargin(time.Minute)}, time.Hour, 59 * time.Minute},
+		{"zero margin", []Option{WithSafetyMargin(0)}, time.Second, time.Second},
+		{"margin exceeds lifespan", []Option{WithSafetyMargin(time.Hour)}, time.Minute, 30 * time.Second},
+		{"default margin exceeds lifespan", nil, 4 * time.Millisecond, 2 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		a := newToken(tt.opts)
+		if got := a.refreshAfter(tt.lifespan); got != tt.want {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSafetyValidation(t *testing.T) {
+	for _, f := range []func(){
+		func() { WithSafetyMargin(-time.Second) },
+		func() { WithSafetyFraction(0) },
+		func() { WithSafetyFraction(1.5) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("invalid setting did not panic")
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestAdaptiveMargin(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name     string
+		latency  time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{"no history", 0, 0, 59 * time.Minute},
+		{"fast calls keep the fixed margin", time.Second, 0, 59 * time.Minute},
+		{"slow calls widen the margin", 2 * time.Minute, 0, 58 * time.Minute},
+		// At a failure rate of 50%, 7 attempts succeed with 99% probability.
+		{"failures widen the margin", time.Minute, 10, 53*time.Minute - 6*retryDelay},
+		{"at most half of the lifespan", 40 * time.Minute, 0, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		a := newToken([]Option{WithSafetyMargin(time.Minute), WithAdaptiveMargin()})
+		if tt.latency > 0 {
+			for i := 0; i < 20; i++ {
+				var err error
+				if i < tt.failures {
+					err = errFailed
+				}
+				a.timings.record(start, tt.latency, time.Hour, err)
+			}
+		}
+		if got := a.refreshAfter(time.Hour); got != tt.want {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Some APIs revoke tokens on the server side after a while, without telling anyone and without changing the lifespan that they advertise. A token that is nominally valid for a day may stop working after an hour. For such APIs, the age of a token matters more than its expiry time.
+
+// `ErrMaxServeAge` is returned by `Get()` and its variants if the token is older than the limit of `WithMaxServeAge`, and the refresh that was to replace it did not happen, for example, because of `WithMinRefreshInterval`.
+var ErrMaxServeAge = errors.New("refresh: token exceeds maximum serve age")
+
+// `WithMaxServeAge` limits the age of the tokens that `Get()` and its variants return. The token gets refreshed when it reaches age `d`, even if it expires much later. If a `Get` call finds the token older than `d` nevertheless, it forces a refresh and waits for the new token. If the refresh fails, the call returns the refresh error.
+// A token restored from a store, shared store, or `WithInitialValue` counts as fetched when it was loaded. While refreshes are paused, the limit does not apply.
+func WithMaxServeAge(d time.Duration) Option {
+	if d <= 0 {
+		panic("refresh: WithMaxServeAge age must be positive")
+	}
+	return func(o *options) {
+		o.maxServeAge = d
+	}
+}
+
+// Method `tooOld` reports whether `t` is a token that `WithMaxServeAge` forbids to serve.
+func (a *Token) tooOld(t tokenResponse) bool {
+	if a.opts.maxServeAge <= 0 || t.Err != nil || t.FetchedAt.IsZero() || a.paused.Load() {
+		return false
+	}
+	return a.now().Sub(t.FetchedAt) >= a.opts.maxServeAge
+}
+
+// Method `refreshTooOld` replaces the token `t` that is too old. It forces a refresh and then reads the token again with `receive`.
+func (a *Token) refreshTooOld(ctx context.Context, t tokenResponse, receive func() tokenResponse) tokenResponse {
+	a.logEvent(ctx, EventForceRefresh, "Token exceeds maximum serve age", "fetched_at", t.FetchedAt)
+	if err := a.ForceRefresh(ctx); err != nil {
+		return tokenResponse{Err: err}
+	}
+	t = receive()
+	if a.tooOld(t) {
+		return tokenResponse{Err: ErrMaxServeAge}
+	}
+	return t
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// The token gets refreshed at the maximum age, long before it expires.
+func TestMaxServeAgeSchedule(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}, WithMaxServeAge(10*time.Minute), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(10 * time.Minute)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+	if got, _ := tok.Get(); got != "2" {
+		t.Fatalf("want 2, got %q", got)
+	}
+}
+
+// A `Get` that finds the token too old waits for the new one, even with the fast path, which would otherwise serve the old token during the refresh.
+func TestMaxServeAgeWaitsForRefresh(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	release := make(chan struct{})
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			<-release
+		}
+		return strconv.Itoa(int(n)), time.Hour, nil
+	}, WithMaxServeAge(10*time.Minute), WithFastPath(), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(10 * time.Minute)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+
+	got := make(chan string, 1)
+	go func() {
+		token, _ := tok.Get()
+		got <- token
+	}()
+	select {
+	case token := <-got:
+		t.Fatalf("got %q during the refresh", token)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if token := <-got; token != "2" {
+		t.Fatalf("want 2, got %q", token)
+	}
+}
+
+// If the refresh is not allowed to happen yet, `Get` refuses to serve the old token.
+func TestMaxServeAgeRateLimited(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithMaxServeAge(10*time.Minute), WithMinRefreshInterval(30*time.Minute), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Minute)
+	if _, err := tok.GetContext(context.Background()); !errors.Is(err, ErrMaxServeAge) {
+		t.Fatalf("want ErrMaxServeAge, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Like Kafka clients (see `kafka.go`), NATS and AMQP clients can use the `Token` directly.
+//
+// The NATS client asks for credentials on each connect and reconnect through callbacks of plain function types, so `NATSTokenHandler` and `NATSUserJWT` plug in without glue code:
+//
+//	nc, err := nats.Connect(url, nats.TokenHandler(tok.NATSTokenHandler()))
+//
+// AMQP authenticates once per connection. RabbitMQ lets clients replace the secret of an open connection, which `AMQPReauth` does together with `KeepAuthenticated` (see `reauth.go`). When the connection breaks anyway, for example, because the broker closed it after the old token expired, `KeepConnected` dials again with the current token:
+//
+//	tok.KeepConnected(ctx, func(ctx context.Context, token string) error {
+//		conn, err := amqp.DialConfig(url, amqp.Config{SASL: []amqp.Authentication{&amqp.PlainAuth{Username: "app", Password: token}}})
+//		if err != nil {
+//			return err
+//		}
+//		defer conn.Close()
+//		ctx, cancel := context.WithCancel(ctx)
+//		defer cancel()
+//		go tok.KeepAuthenticated(ctx, token, AMQPReauth(conn), ReauthPolicy{})
+//		// Use the connection until it closes.
+//		<-conn.NotifyClose(make(chan *amqp.Error, 1))
+//		return nil
+//	}, ReauthPolicy{})
+
+// `callbackTimeout` bounds the wait for a token in callbacks that have no context.
+const callbackTimeout = 10 * time.Second
+
+// Method `getWithTimeout` returns the current token, waiting at most `callbackTimeout`.
+func (a *Token) getWithTimeout() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+	return a.GetContext(ctx)
+}
+
+// Method `NATSTokenHandler` returns a function for `nats.TokenHandler` of package `github.com/nats-io/nats.go`. The function returns the current token, or an empty string if no valid token is available, in which case the connection attempt fails and the client tries again later.
+func (a *Token) NATSTokenHandler() func() string {
+	return func() string {
+		token, err := a.getWithTimeout()
+		if err != nil {
+			return ""
+		}
+		return token
+	}
+}
+
+// Method `NATSUserJWT` returns a user JWT callback for `nats.UserJWT` of package `github.com/nats-io/nats.go`, for tokens that are NATS user JWTs. The signature callback that signs the server's nonce is separate, as it needs the user's private key.
+func (a *Token) NATSUserJWT() func() (string, error) {
+	return a.getWithTimeout
+}
+
+// An `AMQPSecretUpdater` can replace the secret of an open AMQP connection. `*amqp091.Connection` of package `github.com/rabbitmq/amqp091-go` implements it.
+type AMQPSecretUpdater interface {
+	UpdateSecret(newSecret, reason string) error
+}
+
+// `AMQPReauth` returns a re-authentication function for `KeepAuthenticated` that replaces the secret of `conn` with the new token.
+func AMQPReauth(conn AMQPSecretUpdater) func(ctx context.Context, token string) error {
+	return func(_ context.Context, token string) error {
+		return conn.UpdateSecret(token, "token refreshed")
+	}
+}
+
+// `minSessionUptime` is how long a connection must stay up before `KeepConnected` considers it healthy and starts a new series of attempts. A broker that accepts connections and closes them right away gets the same backoff as one that refuses them.
+const minSessionUptime = 30 * time.Second
+
+// Method `KeepConnected` calls `session` with the current token, and calls it again whenever it returns, until `ctx` is done or the token is closed. `session` dials a connection with the token, uses it until it closes, and returns nil. If it cannot connect, it returns an error. Either way, `KeepConnected` waits as the policy's backoff says before it dials again. The attempts count from the last connection that stayed up for at least 30 seconds. If the backoff gives up, `KeepConnected` returns the last error.
+// If `session` returns an `*AuthError`, the token gets refreshed before the next attempt, as the broker has rejected it.
+// `KeepConnected` returns `ctx.Err()` when `ctx` is done and `ErrClosed` when the token gets closed. The policy's `Timeout` is not used.
+func (a *Token) KeepConnected(ctx context.Context, session func(ctx context.Context, token string) error, p ReauthPolicy) error {
+	if p.Backoff == nil {
+		p.Backoff = ExponentialBackoff{Initial: time.Second, Max: 30 * time.Second}
+	}
+	var start time.Time
+	for attempt := 1; ; attempt++ {
+		token, err := a.GetContext(ctx)
+		dialed := a.now()
+		if err == nil {
+			err = session(ctx, token)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrClosed) {
+			return err
+		}
+		if err == nil {
+			up := a.now().Sub(dialed)
+			if up >= minSessionUptime {
+				// The connection was healthy. Start a new series of attempts.
+				attempt, start = 1, time.Time{}
+			}
+			err = fmt.Errorf("refresh: connection closed after %v", up)
+		}
+		if start.IsZero() {
+			start = a.now()
+		}
+		var authErr *AuthError
+		if errors.As(err, &authErr) {
+			a.ForceRefresh(ctx)
+		}
+		d, ok := p.Backoff.NextDelay(attempt, a.now().Sub(start))
+		if !ok {
+			return fmt.Errorf("refresh: giving up connecting after %d attempts: %w", attempt, err)
+		}
+		select {
+		case <-a.after(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.closing():
+			return ErrClosed
+		}
+	}
+}
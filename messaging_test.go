@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestNATSCallbacks(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "nats-jwt", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	if got := tok.NATSTokenHandler()(); got != "nats-jwt" {
+		t.Fatalf("want nats-jwt, got %q", got)
+	}
+	if got, err := tok.NATSUserJWT()(); got != "nats-jwt" || err != nil {
+		t.Fatalf("want nats-jwt, got (%q, %v)", got, err)
+	}
+
+	failing := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, errors.New("down")
+	}, WithLogger(NopLogger))
+	defer failing.Close()
+	if got := failing.NATSTokenHandler()(); got != "" {
+		t.Fatalf("want empty token, got %q", got)
+	}
+}
+
+type secretUpdater struct{ secret, reason string }
+
+func (u *secretUpdater) UpdateSecret(secret, reason string) error {
+	u.secret, u.reason = secret, reason
+	return nil
+}
+
+func TestAMQPReauth(t *testing.T) {
+	u := &secretUpdater{}
+	if err := AMQPReauth(u)(context.Background(), "new"); err != nil || u.secret != "new" || u.reason == "" {
+		t.Fatalf("secret not updated: %+v, %v", u, err)
+	}
+}
+
+// A rejected token gets refreshed before the next dial, and closed connections get dialed again.
+func TestKeepConnected(t *testing.T) {
+	var n atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return fmt.Sprintf("tok%d", n.Add(1)), time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var dialed []string
+	err := tok.KeepConnected(ctx, func(_ context.Context, token string) error {
+		dialed = append(dialed, token)
+		switch len(dialed) {
+		case 1:
+			return &AuthError{StatusCode: http.StatusUnauthorized}
+		case 2:
+			return nil
+		default:
+			cancel()
+			return nil
+		}
+	}, ReauthPolicy{Backoff: ExponentialBackoff{Initial: time.Millisecond}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+	if fmt.Sprint(dialed) != "[tok1 tok2 tok2]" {
+		t.Fatalf("unexpected dials %v", dialed)
+	}
+}
+
+func TestKeepConnectedGivesUp(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	var dials atomic.Int32
+	err := tok.KeepConnected(context.Background(), func(context.Context, string) error {
+		dials.Add(1)
+		return errors.New("connection refused")
+	}, ReauthPolicy{Backoff: ExponentialBackoff{Initial: time.Millisecond, MaxAttempts: 2}})
+	if err == nil || dials.Load() != 3 {
+		t.Fatalf("want error after 3 dials, got %v after %d", err, dials.Load())
+	}
+}
+
+// Connections that close right away count as failed attempts, and only a connection that stays up resets the backoff.
+func TestKeepConnectedShortSessions(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Microsecond):
+				clock.Advance(time.Millisecond)
+			}
+		}
+	}()
+
+	var dials atomic.Int32
+	err := tok.KeepConnected(context.Background(), func(context.Context, string) error {
+		if dials.Add(1) == 3 {
+			clock.Advance(minSessionUptime)
+		}
+		return nil
+	}, ReauthPolicy{Backoff: ExponentialBackoff{Initial: time.Millisecond, MaxAttempts: 2}})
+	// Two short sessions, a long one, and two more short ones, until the backoff gives up.
+	if err == nil || dials.Load() != 5 {
+		t.Fatalf("want error after 5 dials, got %v after %d", err, dials.Load())
+	}
+}
@@ -0,0 +1,202 @@
+// Package metadata provides authorization functions for the managed identities of cloud VMs: the metadata server of Google Compute Engine and the Instance Metadata Service (IMDS) of Azure. The functions are ready to be passed to `NewTokenContext` of the parent package.
+//
+// Both endpoints are local to the VM, but not always ready. Right after a VM starts, or while an identity gets assigned, they fail for a while. The functions therefore retry such failures a few times with exponential backoff before they report an error to the refresh loop.
+//
+// The package uses only the standard library.
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option configures an authorization function.
+type Option func(*config)
+
+type config struct {
+	client   *http.Client
+	endpoint string
+	attempts int
+	backoff  time.Duration
+	scopes   []string
+	clientID string
+}
+
+// WithHTTPClient sets the client for metadata requests. The default is a client with a timeout of 10 seconds, as the metadata endpoints answer quickly or not at all.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = c
+	}
+}
+
+// WithEndpoint overrides the token endpoint URL, for example, for tests or emulators.
+func WithEndpoint(u string) Option {
+	return func(cfg *config) {
+		cfg.endpoint = u
+	}
+}
+
+// WithRetry sets the number of attempts per call and the delay before the first retry, which doubles with each further retry. The default is 5 attempts, starting with one second. An attempts value of 1 disables retries.
+func WithRetry(attempts int, initial time.Duration) Option {
+	return func(cfg *config) {
+		cfg.attempts = attempts
+		cfg.backoff = initial
+	}
+}
+
+// WithScopes requests a GCE token for the given OAuth scopes instead of the scopes of the VM's service account.
+func WithScopes(scopes ...string) Option {
+	return func(cfg *config) {
+		cfg.scopes = scopes
+	}
+}
+
+// WithClientID selects one of several user-assigned Azure managed identities by its client ID.
+func WithClientID(id string) Option {
+	return func(cfg *config) {
+		cfg.clientID = id
+	}
+}
+
+func newConfig(endpoint string, opts []Option) *config {
+	cfg := &config{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		endpoint: endpoint,
+		attempts: 5,
+		backoff:  time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ErrInvalidResponse is returned if the endpoint responds with success but the response cannot be used.
+var ErrInvalidResponse = errors.New("metadata: invalid token response")
+
+// ResponseError is returned if the endpoint responds with an error status.
+type ResponseError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("metadata: endpoint returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// Temporary reports whether the error is likely to go away by retrying. Besides server errors (5xx) and rate limiting (429), this includes 404 and 410, which Azure IMDS returns while an identity is being assigned or the service is being updated.
+func (e *ResponseError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusNotFound, http.StatusGone, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+// GCE returns an authorization function that fetches access tokens of the default service account from the metadata server of Google Compute Engine, which also serves GKE and Cloud Run. The environment variable GCE_METADATA_HOST overrides the metadata server's host.
+func GCE(opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	cfg := newConfig("http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", opts)
+	return func(ctx context.Context) (string, time.Duration, error) {
+		u := cfg.endpoint
+		if len(cfg.scopes) > 0 {
+			u += "?scopes=" + url.QueryEscape(strings.Join(cfg.scopes, ","))
+		}
+		return cfg.fetch(ctx, u, "Metadata-Flavor", "Google")
+	}
+}
+
+// Azure returns an authorization function that fetches access tokens for resource, for example, "https://vault.azure.net", from the Azure Instance Metadata Service.
+func Azure(resource string, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	cfg := newConfig("http://169.254.169.254/metadata/identity/oauth2/token", opts)
+	return func(ctx context.Context) (string, time.Duration, error) {
+		q := url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}
+		if cfg.clientID != "" {
+			q.Set("client_id", cfg.clientID)
+		}
+		return cfg.fetch(ctx, cfg.endpoint+"?"+q.Encode(), "Metadata", "true")
+	}
+}
+
+// fetch requests a token and retries temporary failures.
+func (cfg *config) fetch(ctx context.Context, u, header, value string) (string, time.Duration, error) {
+	delay := cfg.backoff
+	for attempt := 1; ; attempt++ {
+		token, lifespan, err := cfg.request(ctx, u, header, value)
+		if err == nil || attempt >= cfg.attempts || !temporary(err) {
+			return token, lifespan, err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return "", 0, err
+		}
+		delay *= 2
+	}
+}
+
+// temporary reports whether err is worth retrying. Network errors are, as the endpoint may not be up yet.
+func temporary(err error) bool {
+	var re *ResponseError
+	if errors.As(err, &re) {
+		return re.Temporary()
+	}
+	return !errors.Is(err, ErrInvalidResponse)
+}
+
+// maxResponseSize limits how much of a response gets read.
+const maxResponseSize = 1 << 20
+
+// request sends one token request.
+func (cfg *config) request(ctx context.Context, u, header, value string) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("metadata: %w", err)
+	}
+	req.Header.Set(header, value)
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("metadata: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", 0, fmt.Errorf("metadata: reading token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", 0, &ResponseError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	// GCE sends expires_in as a number, Azure as a string.
+	var tr struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if tr.AccessToken == "" {
+		return "", 0, fmt.Errorf("%w: no access_token", ErrInvalidResponse)
+	}
+	secs, err := strconv.ParseInt(string(tr.ExpiresIn), 10, 64)
+	if err != nil || secs <= 0 {
+		return "", 0, fmt.Errorf("%w: expires_in %q", ErrInvalidResponse, tr.ExpiresIn)
+	}
+	return tr.AccessToken, time.Duration(secs) * time.Second, nil
+}
@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGCE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Query().Get("scopes") != "a,b" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.tok","expires_in":3599,"token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+
+	token, lifespan, err := GCE(WithEndpoint(srv.URL), WithScopes("a", "b"))(context.Background())
+	if err != nil || token != "ya29.tok" || lifespan != 3599*time.Second {
+		t.Fatalf("want (ya29.tok, 3599s, nil), got (%q, %v, %v)", token, lifespan, err)
+	}
+}
+
+// Azure IMDS answers 404 while the identity is being assigned. The call retries until it succeeds.
+func TestAzureRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		q := r.URL.Query()
+		if r.Header.Get("Metadata") != "true" || q.Get("resource") != "https://vault.azure.net" || q.Get("client_id") != "cid" {
+			t.Errorf("unexpected request %v", r.URL)
+		}
+		if calls < 3 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"access_token":"eyJ.tok","expires_in":"3599","token_type":"Bearer"}`))
+	}))
+	defer srv.Close()
+
+	auth := Azure("https://vault.azure.net", WithEndpoint(srv.URL), WithClientID("cid"), WithRetry(3, time.Millisecond))
+	token, lifespan, err := auth(context.Background())
+	if err != nil || token != "eyJ.tok" || lifespan != 3599*time.Second || calls != 3 {
+		t.Fatalf("want token after 3 calls, got (%q, %v, %v) after %d", token, lifespan, err, calls)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_resource"}`))
+	}))
+	defer srv.Close()
+
+	_, _, err := Azure("bogus", WithEndpoint(srv.URL), WithRetry(5, time.Millisecond))(context.Background())
+	var re *ResponseError
+	if !errors.As(err, &re) || re.StatusCode != http.StatusBadRequest || calls != 1 {
+		t.Fatalf("want a single 400 error, got %v after %d calls", err, calls)
+	}
+}
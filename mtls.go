@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/appliedgo/refresh/oauth2"
+)
+
+// With mutual TLS client authentication (RFC 8705), the client proves its identity with a TLS client certificate instead of a client secret, and the authorization server binds each token to that certificate. Now two things expire: the certificate and the token. A `CertRefresher` keeps the certificate fresh, and a `Token` keeps the token fresh, but they must not drift apart: a token that is bound to the old certificate fails with the new one. `NewMTLSToken` wires the two refreshers together, so that each new certificate gets a new token.
+
+// `MTLSClientCredentials` returns an authorization function that fetches tokens with the client credentials grant, authenticated by the current certificate of `certs`. `opts` configure the token requests as for `oauth2.NewClientCredentials`.
+func MTLSClientCredentials(tokenURL, clientID string, scopes []string, certs *CertRefresher, opts ...oauth2.Option) func(ctx context.Context) (string, time.Duration, error) {
+	opts = append(opts[:len(opts):len(opts)], oauth2.WithTLSClientAuth(certs.GetClientCertificate))
+	return oauth2.NewClientCredentials(tokenURL, clientID, "", scopes, opts...)
+}
+
+// `NewMTLSToken` works like `NewTokenContext`, and additionally refreshes the token whenever `certs` has a new certificate. `auth` would usually come from `MTLSClientCredentials` with the same `certs`.
+func NewMTLSToken(ctx context.Context, certs *CertRefresher, auth func(ctx context.Context) (string, time.Duration, error), opts ...Option) *Token {
+	a := NewTokenContext(ctx, auth, opts...)
+	if a.track() {
+		go func() {
+			defer a.cleanup.Done()
+			a.followCerts(certs)
+		}()
+	}
+	return a
+}
+
+// Method `followCerts` forces a refresh each time the certificate of `certs` changes, until the token gets closed.
+func (a *Token) followCerts(certs *CertRefresher) {
+	changed, _ := certs.r.watch()
+	last, _ := certs.r.GetContext(a.runCtx)
+	for {
+		select {
+		case <-changed:
+		case <-a.closing():
+			return
+		}
+		changed, _ = certs.r.watch()
+		// A failed renewal bumps the version, too, but leaves the previous certificate in place.
+		cert, err := certs.r.GetContext(a.runCtx)
+		if err != nil || sameCertificate(cert, last) {
+			continue
+		}
+		last = cert
+		a.ForceRefresh(a.runCtx)
+	}
+}
+
+// `sameCertificate` reports whether `a` and `b` hold the same leaf certificate. `CertFromFiles` returns a new `*tls.Certificate` on each reload, even if the files have not changed, so comparing pointers is not enough.
+func sameCertificate(a, b *tls.Certificate) bool {
+	if a == nil || b == nil || len(a.Certificate) == 0 || len(b.Certificate) == 0 {
+		return a == b
+	}
+	return bytes.Equal(a.Certificate[0], b.Certificate[0])
+}
+
+// Method `HTTPTransport` returns a copy of `http.DefaultTransport` that presents the current certificate to servers that ask for one. Resource requests with a certificate-bound token need it: `tok.Transport(certs.HTTPTransport())`. Connections that are kept alive stay with the certificate of their handshake, so call `CloseIdleConnections` on the transport when the token changes, for example, in a hook of `WithOnRefresh`.
+func (c *CertRefresher) HTTPTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = &tls.Config{GetClientCertificate: c.GetClientCertificate}
+	return tr
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/oauth2"
+)
+
+// Each new client certificate gets a new token, bound to the certificate's common name.
+func TestMTLSToken(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "app" {
+			t.Errorf("unexpected client_id %q", r.FormValue("client_id"))
+		}
+		fmt.Fprintf(w, `{"access_token":"bound-to-%s","expires_in":3600}`, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	var certs [2]tls.Certificate
+	for i := range certs {
+		certFile, keyFile := writeCert(t, t.TempDir(), fmt.Sprintf("cert%d", i+1), time.Now().Add(time.Hour))
+		var err error
+		if certs[i], err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first certificate lives briefly; the second one stays.
+	var loads atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cr := NewCertRefresher(ctx, func(context.Context) (*tls.Certificate, time.Duration, error) {
+		if loads.Add(1) == 1 {
+			return &certs[0], 100 * time.Millisecond, nil
+		}
+		return &certs[1], time.Hour, nil
+	})
+
+	auth := MTLSClientCredentials(srv.URL+"/token", "app", nil, cr, oauth2.WithHTTPClient(srv.Client()))
+	tok := NewMTLSToken(context.Background(), cr, auth, WithLogger(NopLogger))
+	defer tok.Close()
+	if got, err := tok.Get(); got != "bound-to-cert1" || err != nil {
+		t.Fatalf("want bound-to-cert1, got (%q, %v)", got, err)
+	}
+	waitFor(t, 2*time.Second, func() bool {
+		got, _ := tok.Get()
+		return got == "bound-to-cert2"
+	})
+}
+
+func TestCertRefresherHTTPTransport(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "client", time.Now().Add(time.Hour))
+	cr := NewCertRefresher(context.Background(), CertFromFiles(certFile, keyFile, time.Hour))
+	tr := cr.HTTPTransport()
+	cert, err := tr.TLSClientConfig.GetClientCertificate(nil)
+	if err != nil || commonName(t, cert) != "client" {
+		t.Fatalf("want client certificate, got %v", err)
+	}
+}
+
+// Reloading an unchanged certificate file does not force a token refresh.
+func TestMTLSTokenUnchangedCert(t *testing.T) {
+	certFile, keyFile := writeCert(t, t.TempDir(), "client", time.Now().Add(time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var loads atomic.Int32
+	load := CertFromFiles(certFile, keyFile, 15*time.Millisecond)
+	cr := NewCertRefresher(ctx, func(ctx context.Context) (*tls.Certificate, time.Duration, error) {
+		loads.Add(1)
+		return load(ctx)
+	})
+	var calls atomic.Int32
+	tok := NewMTLSToken(ctx, cr, func(context.Context) (string, time.Duration, error) {
+		return fmt.Sprintf("tok%d", calls.Add(1)), time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	if _, err := tok.Get(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, time.Second, func() bool { return loads.Load() >= 4 })
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 authorization call, got %d", n)
+	}
+}
@@ -0,0 +1,146 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// assertionType is the client_assertion_type of RFC 7523, section 2.2.
+const assertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ErrUnsupportedKey is returned if a client assertion key is neither an RSA key nor an ECDSA P-256 key.
+var ErrUnsupportedKey = errors.New("oauth2: client assertion key must be RSA or ECDSA P-256")
+
+// ClientAssertion configures client authentication with a signed JWT instead of a client secret, known as private_key_jwt (RFC 7523 and OpenID Connect Core, section 9). Each token request carries a freshly signed assertion.
+type ClientAssertion struct {
+	// Key returns the signing key and its key ID, which goes into the JWT's `kid` header if not empty. It gets called for each assertion, so it can read a rotated key. RSA keys sign with RS256, ECDSA P-256 keys with ES256. Keys in a KMS or HSM work through `crypto.Signer`. StaticKey returns a Key function for a fixed key.
+	Key func(ctx context.Context) (signer crypto.Signer, keyID string, err error)
+	// Audience is the `aud` claim. Empty means the token URL.
+	Audience string
+	// Lifetime is the time from issuance until the assertion expires. Zero means one minute.
+	Lifetime time.Duration
+	// ClockSkew backdates `iat` and `nbf`, so that an authorization server whose clock runs behind does not reject the assertion as issued in the future. Zero means 30 seconds; negative means no backdating.
+	ClockSkew time.Duration
+	// Claims are additional claims. They cannot override the standard claims.
+	Claims map[string]any
+
+	// now is the clock for tests. Nil means time.Now.
+	now func() time.Time
+}
+
+// StaticKey returns a Key function for ClientAssertion that always returns signer and keyID.
+func StaticKey(signer crypto.Signer, keyID string) func(context.Context) (crypto.Signer, string, error) {
+	return func(context.Context) (crypto.Signer, string, error) {
+		return signer, keyID, nil
+	}
+}
+
+// WithClientAssertion authenticates the client with a signed JWT instead of a client secret. The client secret passed to the constructor is ignored.
+func WithClientAssertion(a ClientAssertion) Option {
+	return func(cfg *config) {
+		cfg.assertion = &a
+	}
+}
+
+// sign returns a new assertion for clientID, signed with the current key.
+func (a *ClientAssertion) sign(ctx context.Context, clientID, tokenURL string) (string, error) {
+	signer, keyID, err := a.Key(ctx)
+	if err != nil {
+		return "", err
+	}
+	alg, err := algorithm(signer)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if a.now != nil {
+		now = a.now()
+	}
+	lifetime, skew, aud := a.Lifetime, a.ClockSkew, a.Audience
+	if lifetime <= 0 {
+		lifetime = time.Minute
+	}
+	if skew == 0 {
+		skew = 30 * time.Second
+	}
+	skew = max(skew, 0)
+	if aud == "" {
+		aud = tokenURL
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims := make(map[string]any, len(a.Claims)+7)
+	for k, v := range a.Claims {
+		claims[k] = v
+	}
+	claims["iss"] = clientID
+	claims["sub"] = clientID
+	claims["aud"] = aud
+	claims["jti"] = base64.RawURLEncoding.EncodeToString(jti)
+	claims["iat"] = now.Add(-skew).Unix()
+	claims["nbf"] = now.Add(-skew).Unix()
+	claims["exp"] = now.Add(lifetime).Unix()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	if alg == "ES256" {
+		if sig, err = rawECDSA(sig); err != nil {
+			return "", err
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// algorithm returns the JWS algorithm for the key of signer.
+func algorithm(signer crypto.Signer) (string, error) {
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		if pub.Curve == elliptic.P256() {
+			return "ES256", nil
+		}
+	}
+	return "", ErrUnsupportedKey
+}
+
+// rawECDSA converts an ASN.1 ECDSA signature, as crypto.Signer returns it, into the fixed-size R and S that JWS requires.
+func rawECDSA(der []byte) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 64)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
@@ -0,0 +1,98 @@
+package oauth2
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseAssertion verifies the signature of a client assertion and returns its header and claims.
+func parseAssertion(t *testing.T, jwt string, pub crypto.PublicKey) (header map[string]any, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT %q", jwt)
+	}
+	for i, v := range []*map[string]any{&header, &claims} {
+		b, _ := base64.RawURLEncoding.DecodeString(parts[i])
+		if err := json.Unmarshal(b, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	var err error
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+	case *ecdsa.PublicKey:
+		if len(sig) != 64 || !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			err = errors.New("invalid ES256 signature")
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header, claims
+}
+
+func TestClientAssertion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Unix(1_700_000_000, 0)
+	for alg, key := range map[string]crypto.Signer{"RS256": rsaKey, "ES256": ecKey} {
+		t.Run(alg, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, _, ok := r.BasicAuth(); ok || r.FormValue("client_secret") != "" {
+					t.Error("request carries a client secret")
+				}
+				if r.FormValue("client_id") != "app" || r.FormValue("client_assertion_type") != assertionType {
+					t.Errorf("unexpected form %v", r.PostForm)
+				}
+				header, claims := parseAssertion(t, r.FormValue("client_assertion"), key.Public())
+				if header["alg"] != alg || header["kid"] != "k1" {
+					t.Errorf("unexpected header %v", header)
+				}
+				if claims["iss"] != "app" || claims["sub"] != "app" || claims["aud"] != "https://issuer" || claims["tenant"] != "t1" || claims["jti"] == "" {
+					t.Errorf("unexpected claims %v", claims)
+				}
+				if claims["iat"] != float64(now.Unix()-30) || claims["exp"] != float64(now.Unix()+60) {
+					t.Errorf("unexpected iat/exp %v/%v", claims["iat"], claims["exp"])
+				}
+				w.Write([]byte(`{"access_token":"tok","expires_in":60}`))
+			}))
+			defer srv.Close()
+
+			auth := NewClientCredentials(srv.URL, "app", "ignored", nil, WithClientAssertion(ClientAssertion{
+				Key:      StaticKey(key, "k1"),
+				Audience: "https://issuer",
+				Claims:   map[string]any{"tenant": "t1", "iss": "spoofed"},
+				now:      func() time.Time { return now },
+			}))
+			if token, _, err := auth(context.Background()); token != "tok" || err != nil {
+				t.Fatalf("want tok, got (%q, %v)", token, err)
+			}
+		})
+	}
+}
+
+func TestClientAssertionUnsupportedKey(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	auth := NewClientCredentials("http://127.0.0.1:1/token", "app", "", nil, WithClientAssertion(ClientAssertion{Key: StaticKey(key, "")}))
+	if _, _, err := auth(context.Background()); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("want ErrUnsupportedKey, got %v", err)
+	}
+}
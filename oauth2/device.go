@@ -0,0 +1,170 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceCodeGrant is the grant type of RFC 8628, section 3.4.
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ErrDeviceCodeExpired is returned if the user did not authorize the device before the device code expired.
+var ErrDeviceCodeExpired = errors.New("oauth2: device code expired")
+
+// ErrAccessDenied is returned if the user denied the authorization request.
+var ErrAccessDenied = errors.New("oauth2: access denied")
+
+// DeviceCode is what the user needs to authorize the device: a code to enter at a verification URL.
+type DeviceCode struct {
+	UserCode        string
+	VerificationURI string
+	// VerificationURIComplete includes the user code, for example, for a QR code. It may be empty.
+	VerificationURIComplete string
+	ExpiresAt               time.Time
+}
+
+// NewDeviceAuthorization returns an authorization function for command-line tools and other devices without a browser, using the device authorization grant (RFC 8628).
+//
+// The first call, or any call after the refresh token has become invalid, starts the interactive flow: it requests a device code from deviceAuthURL, passes it to prompt, which tells the user where to go and which code to enter, and polls tokenURL until the user has authorized the device, denied the request, or the code has expired. Later calls use the refresh token, which is saved to store, so that the next start of the tool does not need the user again.
+//
+// The client is a public client: requests carry the client ID as a form parameter and no client secret. Calls are serialized.
+// As the interactive flow may take minutes, the token should not bound the authorization call by a short timeout.
+func NewDeviceAuthorization(deviceAuthURL, tokenURL, clientID string, scopes []string, store RefreshTokenStore, prompt func(ctx context.Context, code DeviceCode) error, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	c := &refreshChain{cfg: newConfig(opts), tokenURL: tokenURL, clientID: clientID, public: true, store: store}
+	return func(ctx context.Context) (string, time.Duration, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err := c.load(ctx); err != nil {
+			return "", 0, err
+		}
+		if c.current != "" {
+			token, lifespan, err := c.refresh(ctx)
+			var re *ResponseError
+			if !errors.As(err, &re) || re.Code != "invalid_grant" {
+				return token, lifespan, err
+			}
+			// The refresh token has expired or was revoked. Ask the user again.
+			c.current = ""
+		}
+		return c.deviceFlow(ctx, deviceAuthURL, scopes, prompt)
+	}
+}
+
+// deviceResponse is the device authorization response of RFC 8628, section 3.2.
+type deviceResponse struct {
+	DeviceCode              string       `json:"device_code"`
+	UserCode                string       `json:"user_code"`
+	VerificationURI         string       `json:"verification_uri"`
+	VerificationURIComplete string       `json:"verification_uri_complete"`
+	ExpiresIn               json.Number  `json:"expires_in"`
+	Interval                *json.Number `json:"interval"`
+}
+
+// deviceFlow runs the interactive part of the device authorization grant.
+func (c *refreshChain) deviceFlow(ctx context.Context, deviceAuthURL string, scopes []string, prompt func(context.Context, DeviceCode) error) (string, time.Duration, error) {
+	form := url.Values{"client_id": {c.clientID}}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	dr, err := c.cfg.deviceAuthorization(ctx, deviceAuthURL, form)
+	if err != nil {
+		return "", 0, err
+	}
+	expiresIn, err := strconv.ParseInt(string(dr.ExpiresIn), 10, 64)
+	if err != nil || dr.DeviceCode == "" || dr.UserCode == "" || dr.VerificationURI == "" {
+		return "", 0, fmt.Errorf("%w: incomplete device authorization response", ErrInvalidResponse)
+	}
+	// Without an interval, clients must wait 5 seconds between polls (section 3.2).
+	interval := 5 * time.Second
+	if dr.Interval != nil {
+		secs, err := dr.Interval.Int64()
+		if err != nil || secs < 0 {
+			return "", 0, fmt.Errorf("%w: interval %q", ErrInvalidResponse, *dr.Interval)
+		}
+		interval = time.Duration(secs) * time.Second
+	}
+	expiresAt := time.Now().Add(time.Duration(expiresIn) * time.Second)
+	err = prompt(ctx, DeviceCode{
+		UserCode:                dr.UserCode,
+		VerificationURI:         dr.VerificationURI,
+		VerificationURIComplete: dr.VerificationURIComplete,
+		ExpiresAt:               expiresAt,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, expiresAt)
+	defer cancel()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			if time.Now().After(expiresAt) {
+				return "", 0, ErrDeviceCodeExpired
+			}
+			return "", 0, ctx.Err()
+		}
+		tr, err := c.request(ctx, url.Values{"grant_type": {deviceCodeGrant}, "device_code": {dr.DeviceCode}})
+		if err == nil {
+			return c.adopt(ctx, tr)
+		}
+		if ctx.Err() != nil && time.Now().After(expiresAt) {
+			return "", 0, ErrDeviceCodeExpired
+		}
+		var re *ResponseError
+		if !errors.As(err, &re) {
+			return "", 0, err
+		}
+		// Section 3.5 defines the errors of a pending authorization.
+		switch re.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return "", 0, fmt.Errorf("%w: %w", ErrAccessDenied, err)
+		case "expired_token":
+			return "", 0, fmt.Errorf("%w: %w", ErrDeviceCodeExpired, err)
+		default:
+			return "", 0, err
+		}
+		timer.Reset(interval)
+	}
+}
+
+// deviceAuthorization posts form to the device authorization endpoint and parses the response.
+func (cfg *config) deviceAuthorization(ctx context.Context, deviceAuthURL string, form url.Values) (*deviceResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, deviceAuthURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: device authorization request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: reading device authorization response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(resp.StatusCode, body)
+	}
+	var dr deviceResponse
+	if err := json.Unmarshal(body, &dr); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return &dr, nil
+}
@@ -0,0 +1,88 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// deviceServer authorizes the device after `pending` polls, and issues rotating refresh tokens.
+func deviceServer(t *testing.T, pending int32, deny bool) (*httptest.Server, *atomic.Int32) {
+	var polls, flows atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		flows.Add(1)
+		if r.FormValue("client_id") != "cli" || r.FormValue("scope") != "offline_access" {
+			t.Errorf("unexpected device request %v", r.PostForm)
+		}
+		w.Write([]byte(`{"device_code":"dc","user_code":"ABCD-EFGH","verification_uri":"https://idp/device","expires_in":600,"interval":0}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok || r.FormValue("client_id") != "cli" {
+			t.Errorf("unexpected client authentication %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.FormValue("grant_type") {
+		case deviceCodeGrant:
+			switch {
+			case deny:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"access_denied"}`))
+			case polls.Add(1) <= pending:
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"authorization_pending"}`))
+			default:
+				w.Write([]byte(`{"access_token":"at1","refresh_token":"rt1","expires_in":60}`))
+			}
+		case "refresh_token":
+			if r.FormValue("refresh_token") != "rt1" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"at2","refresh_token":"rt2","expires_in":60}`))
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &flows
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	srv, flows := deviceServer(t, 2, false)
+	store := NewMemoryStore("")
+	var prompted DeviceCode
+	auth := NewDeviceAuthorization(srv.URL+"/device", srv.URL+"/token", "cli", []string{"offline_access"}, store, func(_ context.Context, code DeviceCode) error {
+		prompted = code
+		return nil
+	})
+
+	if token, _, err := auth(context.Background()); token != "at1" || err != nil {
+		t.Fatalf("want at1, got (%q, %v)", token, err)
+	}
+	if prompted.UserCode != "ABCD-EFGH" || prompted.VerificationURI != "https://idp/device" {
+		t.Fatalf("unexpected prompt %+v", prompted)
+	}
+	if rt, _ := store.Load(context.Background()); rt != "rt1" {
+		t.Fatalf("want rt1 saved, got %q", rt)
+	}
+	// The next call refreshes without the user.
+	if token, _, err := auth(context.Background()); token != "at2" || err != nil || flows.Load() != 1 {
+		t.Fatalf("want at2 without new flow, got (%q, %v) after %d flows", token, err, flows.Load())
+	}
+	// rt2 is unknown to the server, so the flow starts again.
+	if token, _, err := auth(context.Background()); token != "at1" || err != nil || flows.Load() != 2 {
+		t.Fatalf("want at1 from a new flow, got (%q, %v) after %d flows", token, err, flows.Load())
+	}
+}
+
+func TestDeviceAuthorizationDenied(t *testing.T) {
+	srv, _ := deviceServer(t, 0, true)
+	auth := NewDeviceAuthorization(srv.URL+"/device", srv.URL+"/token", "cli", []string{"offline_access"}, NewMemoryStore(""), func(context.Context, DeviceCode) error { return nil })
+	if _, _, err := auth(context.Background()); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("want ErrAccessDenied, got %v", err)
+	}
+}
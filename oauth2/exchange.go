@@ -0,0 +1,80 @@
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token type identifiers of RFC 8693, section 3.
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// ExchangeOption configures a token exchange.
+type ExchangeOption func(*exchangeConfig)
+
+type exchangeConfig struct {
+	subjectTokenType   string
+	requestedTokenType string
+	scopes             []string
+}
+
+// WithSubjectTokenType sets the type of the subject token. The default is `TokenTypeAccessToken`.
+func WithSubjectTokenType(t string) ExchangeOption {
+	return func(cfg *exchangeConfig) {
+		cfg.subjectTokenType = t
+	}
+}
+
+// WithRequestedTokenType asks the server for a specific type of token. By default, the server decides.
+func WithRequestedTokenType(t string) ExchangeOption {
+	return func(cfg *exchangeConfig) {
+		cfg.requestedTokenType = t
+	}
+}
+
+// WithExchangeScopes asks for a token with the given scopes.
+func WithExchangeScopes(scopes ...string) ExchangeOption {
+	return func(cfg *exchangeConfig) {
+		cfg.scopes = append(cfg.scopes, scopes...)
+	}
+}
+
+// NewTokenExchange returns an authorization function that exchanges a subject token for a token for audience, with the token exchange grant (RFC 8693). subject returns the current subject token for each exchange, typically the token of another refresher. clientID and clientSecret may be empty if the server does not require client authentication for token exchange.
+func NewTokenExchange(tokenURL, clientID, clientSecret string, subject func(ctx context.Context) (string, error), audience string, xopts []ExchangeOption, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	cfg := newConfig(opts)
+	xcfg := &exchangeConfig{subjectTokenType: TokenTypeAccessToken}
+	for _, opt := range xopts {
+		opt(xcfg)
+	}
+	return func(ctx context.Context) (string, time.Duration, error) {
+		st, err := subject(ctx)
+		if err != nil {
+			return "", 0, fmt.Errorf("oauth2: getting subject token: %w", err)
+		}
+		form := url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {st},
+			"subject_token_type": {xcfg.subjectTokenType},
+		}
+		if audience != "" {
+			form.Set("audience", audience)
+		}
+		if xcfg.requestedTokenType != "" {
+			form.Set("requested_token_type", xcfg.requestedTokenType)
+		}
+		if len(xcfg.scopes) > 0 {
+			form.Set("scope", strings.Join(xcfg.scopes, " "))
+		}
+		tr, err := cfg.request(ctx, tokenURL, clientID, clientSecret, form)
+		if err != nil {
+			return "", 0, err
+		}
+		return tr.AccessToken, tr.lifespan, nil
+	}
+}
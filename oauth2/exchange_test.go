@@ -0,0 +1,49 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenExchange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		want := map[string]string{
+			"grant_type":         "urn:ietf:params:oauth:grant-type:token-exchange",
+			"subject_token":      "base",
+			"subject_token_type": TokenTypeJWT,
+			"audience":           "orders",
+			"scope":              "read write",
+		}
+		for k, v := range want {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("%s: want %q, got %q", k, v, got)
+			}
+		}
+		if _, _, ok := r.BasicAuth(); ok {
+			t.Error("want no client authentication without client ID")
+		}
+		w.Write([]byte(`{"access_token":"orders-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":300}`))
+	}))
+	defer srv.Close()
+
+	subject := func(context.Context) (string, error) { return "base", nil }
+	auth := NewTokenExchange(srv.URL, "", "", subject, "orders", []ExchangeOption{WithSubjectTokenType(TokenTypeJWT), WithExchangeScopes("read", "write")})
+	tok, lifespan, err := auth(context.Background())
+	if err != nil || tok != "orders-token" || lifespan.Seconds() != 300 {
+		t.Fatalf("want orders-token for 300s, got (%q, %v, %v)", tok, lifespan, err)
+	}
+}
+
+func TestTokenExchangeSubjectError(t *testing.T) {
+	errSubject := errors.New("no base token")
+	auth := NewTokenExchange("http://invalid.example", "", "", func(context.Context) (string, error) {
+		return "", errSubject
+	}, "orders", nil)
+	if _, _, err := auth(context.Background()); !errors.Is(err, errSubject) {
+		t.Fatalf("want subject error, got %v", err)
+	}
+}
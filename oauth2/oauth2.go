@@ -0,0 +1,267 @@
+// Package oauth2 provides authorization functions for OAuth 2.0 token endpoints, ready to be passed to `NewTokenContext` of the parent package.
+//
+// The package uses only the standard library. It implements the parts of RFC 6749 that a refreshing token needs: the token request and the parsing of the token response. It also implements token exchange (RFC 8693), the device authorization grant (RFC 8628), and client authentication with TLS certificates (RFC 8705) and signed JWTs (RFC 7523).
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Option configures an authorization function.
+type Option func(*config)
+
+type config struct {
+	client        *http.Client
+	authInParams  bool
+	params        url.Values
+	defaultExpiry time.Duration
+	clientCert    func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	assertion     *ClientAssertion
+	// certMu guards lastCert, the certificate of the previous request. See rotateConns.
+	certMu   sync.Mutex
+	lastCert *tls.Certificate
+}
+
+// WithHTTPClient sets the client for token requests. The default is `http.DefaultClient`.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = c
+	}
+}
+
+// WithAuthInParams sends the client credentials as form parameters instead of HTTP Basic authentication. Some servers accept only this style.
+func WithAuthInParams() Option {
+	return func(cfg *config) {
+		cfg.authInParams = true
+	}
+}
+
+// WithParam adds a form parameter to each token request, for example, "audience" or "resource".
+func WithParam(key, value string) Option {
+	return func(cfg *config) {
+		cfg.params.Add(key, value)
+	}
+}
+
+// WithDefaultExpiry sets the lifespan of tokens whose response lacks `expires_in`. Without this option, such a response is an error, as the token could not be refreshed in time.
+func WithDefaultExpiry(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.defaultExpiry = d
+	}
+}
+
+// WithTLSClientAuth authenticates the client with a TLS client certificate instead of a client secret (RFC 8705, section 2). getCert gets called on each TLS handshake, so it can serve a renewed certificate. Requests carry the client ID as a form parameter and no client secret. The authorization server binds the tokens to the certificate, so resource requests need the same certificate.
+// The option replaces the transport of the HTTP client with a copy that presents the certificate. The copy starts from the client's transport if that is an `*http.Transport`, and from `http.DefaultTransport` otherwise.
+func WithTLSClientAuth(getCert func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) Option {
+	return func(cfg *config) {
+		cfg.clientCert = getCert
+	}
+}
+
+// rotateConns closes idle connections when the client certificate has changed since the previous request. A kept-alive connection would present the old certificate, and the new token would be bound to it.
+func (cfg *config) rotateConns() {
+	cert, err := cfg.clientCert(&tls.CertificateRequestInfo{})
+	if err != nil {
+		return
+	}
+	cfg.certMu.Lock()
+	defer cfg.certMu.Unlock()
+	if cfg.lastCert != nil && !sameCert(cert, cfg.lastCert) {
+		cfg.client.CloseIdleConnections()
+	}
+	cfg.lastCert = cert
+}
+
+// sameCert reports whether a and b hold the same leaf certificate. A reloaded certificate may be a new *tls.Certificate with the same content.
+func sameCert(a, b *tls.Certificate) bool {
+	if a == nil || b == nil || len(a.Certificate) == 0 || len(b.Certificate) == 0 {
+		return a == b
+	}
+	return bytes.Equal(a.Certificate[0], b.Certificate[0])
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{client: http.DefaultClient, params: url.Values{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.clientCert != nil {
+		base, ok := cfg.client.Transport.(*http.Transport)
+		if !ok {
+			base = http.DefaultTransport.(*http.Transport)
+		}
+		tr := base.Clone()
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		tr.TLSClientConfig.GetClientCertificate = cfg.clientCert
+		client := *cfg.client
+		client.Transport = tr
+		cfg.client = &client
+	}
+	return cfg
+}
+
+// ErrInvalidResponse is returned if the token endpoint responds with success but the response cannot be used.
+var ErrInvalidResponse = errors.New("oauth2: invalid token response")
+
+// maxExpiresIn is the largest expires_in, in seconds, that fits into a time.Duration. Larger values are clamped to it.
+const maxExpiresIn = int64(math.MaxInt64 / time.Second)
+
+// ResponseError is returned if the token endpoint responds with an error status. The Code, Description, and URI fields hold the error response defined in RFC 6749, section 5.2, if the server sent one.
+type ResponseError struct {
+	StatusCode  int
+	Code        string
+	Description string
+	URI         string
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("oauth2: token endpoint returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Temporary reports whether the error is likely to go away by retrying. This is the case for server errors (5xx) and rate limiting (429). Client errors (4xx), such as invalid credentials, are not temporary.
+func (e *ResponseError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// NewClientCredentials returns an authorization function that fetches tokens from tokenURL with the client credentials grant (RFC 6749, section 4.4).
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes []string, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	cfg := newConfig(opts)
+	return func(ctx context.Context) (string, time.Duration, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if len(scopes) > 0 {
+			form.Set("scope", strings.Join(scopes, " "))
+		}
+		tr, err := cfg.request(ctx, tokenURL, clientID, clientSecret, form)
+		if err != nil {
+			return "", 0, err
+		}
+		return tr.AccessToken, tr.lifespan, nil
+	}
+}
+
+// tokenResponse is the successful response of RFC 6749, section 5.1.
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+	Scope        string      `json:"scope"`
+
+	lifespan time.Duration
+}
+
+// maxResponseSize limits how much of a response gets read. Token responses are small. Anything larger is not a token response.
+const maxResponseSize = 1 << 20
+
+// responseError returns the error for an error response with the given status and body.
+func responseError(status int, body []byte) *ResponseError {
+	e := &ResponseError{StatusCode: status}
+	var er struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorURI         string `json:"error_uri"`
+	}
+	if json.Unmarshal(body, &er) == nil {
+		e.Code, e.Description, e.URI = er.Error, er.ErrorDescription, er.ErrorURI
+	}
+	return e
+}
+
+// request posts form to the token endpoint and parses the response.
+func (cfg *config) request(ctx context.Context, tokenURL, clientID, clientSecret string, form url.Values) (*tokenResponse, error) {
+	for k, vs := range cfg.params {
+		form[k] = append(form[k], vs...)
+	}
+	// Without a client ID, the request carries no client authentication. Token exchange may not need it.
+	basicAuth := false
+	switch {
+	case clientID == "":
+	case cfg.clientCert != nil:
+		// The TLS handshake authenticates the client, and the client ID goes into the form.
+		form.Set("client_id", clientID)
+		cfg.rotateConns()
+	case cfg.assertion != nil:
+		jwt, err := cfg.assertion.sign(ctx, clientID, tokenURL)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2: signing client assertion: %w", err)
+		}
+		form.Set("client_id", clientID)
+		form.Set("client_assertion_type", assertionType)
+		form.Set("client_assertion", jwt)
+	case cfg.authInParams:
+		form.Set("client_id", clientID)
+		if clientSecret != "" {
+			form.Set("client_secret", clientSecret)
+		}
+	default:
+		basicAuth = true
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		// RFC 6749, section 2.3.1, requires form-encoding the credentials before Basic authentication.
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: reading token response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(resp.StatusCode, body)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("%w: no access_token", ErrInvalidResponse)
+	}
+	switch {
+	case tr.ExpiresIn != "":
+		secs, err := strconv.ParseInt(string(tr.ExpiresIn), 10, 64)
+		if err != nil || secs < 0 {
+			return nil, fmt.Errorf("%w: expires_in %q", ErrInvalidResponse, tr.ExpiresIn)
+		}
+		// A huge expires_in would overflow the lifespan and make it negative.
+		tr.lifespan = time.Duration(min(secs, maxExpiresIn)) * time.Second
+	case cfg.defaultExpiry > 0:
+		tr.lifespan = cfg.defaultExpiry
+	default:
+		return nil, fmt.Errorf("%w: no expires_in", ErrInvalidResponse)
+	}
+	return &tr, nil
+}
@@ -0,0 +1,171 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "id" || secret != "s3cr%2Bt" {
+			t.Errorf("unexpected credentials %q %q", id, secret)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("scope") != "read write" || r.PostForm.Get("audience") != "api" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"tok","token_type":"Bearer","expires_in":"3600"}`))
+	}))
+	defer srv.Close()
+
+	auth := NewClientCredentials(srv.URL, "id", "s3cr+t", []string{"read", "write"}, WithParam("audience", "api"))
+	token, lifespan, err := auth(context.Background())
+	if err != nil || token != "tok" || lifespan != time.Hour {
+		t.Fatalf("want (tok, 1h, nil), got (%q, %v, %v)", token, lifespan, err)
+	}
+}
+
+func TestClientCredentialsErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		temporary bool
+		code      string
+		invalid   bool
+	}{
+		{"invalid client", http.StatusUnauthorized, `{"error":"invalid_client"}`, false, "invalid_client", false},
+		{"server error", http.StatusBadGateway, `<html>`, true, "", false},
+		{"rate limited", http.StatusTooManyRequests, ``, true, "", false},
+		{"no token", http.StatusOK, `{"expires_in":60}`, false, "", true},
+		{"no expiry", http.StatusOK, `{"access_token":"tok"}`, false, "", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			_, _, err := NewClientCredentials(srv.URL, "id", "secret", nil, WithAuthInParams())(context.Background())
+			if tt.invalid {
+				if !errors.Is(err, ErrInvalidResponse) {
+					t.Fatalf("want ErrInvalidResponse, got %v", err)
+				}
+				return
+			}
+			var re *ResponseError
+			if !errors.As(err, &re) {
+				t.Fatalf("want ResponseError, got %v", err)
+			}
+			if re.StatusCode != tt.status || re.Temporary() != tt.temporary || re.Code != tt.code {
+				t.Fatalf("unexpected error %+v", re)
+			}
+		})
+	}
+}
+
+func TestDefaultExpiry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"tok"}`))
+	}))
+	defer srv.Close()
+	_, lifespan, err := NewClientCredentials(srv.URL, "id", "secret", nil, WithDefaultExpiry(time.Minute))(context.Background())
+	if err != nil || lifespan != time.Minute {
+		t.Fatalf("want 1m, got (%v, %v)", lifespan, err)
+	}
+}
+
+// A huge expires_in is clamped instead of overflowing into a negative lifespan.
+func TestHugeExpiresIn(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"tok","expires_in":9300000000}`))
+	}))
+	defer srv.Close()
+	_, lifespan, err := NewClientCredentials(srv.URL, "id", "secret", nil)(context.Background())
+	if err != nil || lifespan < 100*365*24*time.Hour {
+		t.Fatalf("want a lifespan of centuries, got (%v, %v)", lifespan, err)
+	}
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "client"}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "client" {
+			t.Error("no client certificate")
+		}
+		if _, _, ok := r.BasicAuth(); ok || r.FormValue("client_id") != "id" || r.FormValue("client_secret") != "" {
+			t.Errorf("unexpected client authentication: %v", r.PostForm)
+		}
+		w.Write([]byte(`{"access_token":"bound","expires_in":60}`))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	auth := NewClientCredentials(srv.URL, "id", "", nil, WithHTTPClient(srv.Client()), WithTLSClientAuth(func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return cert, nil
+	}))
+	if token, _, err := auth(context.Background()); token != "bound" || err != nil {
+		t.Fatalf("want bound token, got (%q, %v)", token, err)
+	}
+}
+
+// A reloaded certificate with the same content keeps the connection alive.
+func TestTLSClientAuthSameCert(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "client"}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"bound","expires_in":60}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	auth := NewClientCredentials(srv.URL, "id", "", nil, WithHTTPClient(srv.Client()), WithTLSClientAuth(func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+	}))
+	for i := 0; i < 3; i++ {
+		if _, _, err := auth(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("want 1 connection, got %d", n)
+	}
+}
@@ -0,0 +1,166 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RefreshTokenStore persists the latest refresh token. Servers that rotate refresh tokens invalidate the old one with each use, so a restart must pick up the latest token, or the chain is broken and the user has to log in again.
+type RefreshTokenStore interface {
+	// Load returns the stored refresh token.
+	Load(ctx context.Context) (string, error)
+	// Save replaces the stored refresh token.
+	Save(ctx context.Context, refreshToken string) error
+}
+
+// ErrNoRefreshToken is returned if the store holds no refresh token.
+var ErrNoRefreshToken = errors.New("oauth2: no refresh token")
+
+// NewRefreshToken returns an authorization function that fetches access tokens with the refresh token grant (RFC 6749, section 6). The refresh token comes from store. If the server returns a new refresh token, the function saves it to store before returning the access token.
+// If saving fails, the function returns the access token along with the error. The token's `ErrorWithTokenPolicy` decides whether the access token gets served. Either way, the function keeps using the new refresh token in memory, so that the next attempt neither reuses an invalidated token nor loses the chain.
+// Calls are serialized, as concurrent calls would use the same refresh token twice.
+func NewRefreshToken(tokenURL, clientID, clientSecret string, store RefreshTokenStore, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	c := &refreshChain{cfg: newConfig(opts), tokenURL: tokenURL, clientID: clientID, clientSecret: clientSecret, store: store}
+	return func(ctx context.Context) (string, time.Duration, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if err := c.load(ctx); err != nil {
+			return "", 0, err
+		}
+		if c.current == "" {
+			return "", 0, ErrNoRefreshToken
+		}
+		return c.refresh(ctx)
+	}
+}
+
+// refreshChain holds the latest refresh token of a chain of rotating refresh tokens. Callers of its methods must hold mu.
+type refreshChain struct {
+	cfg                    *config
+	tokenURL               string
+	clientID, clientSecret string
+	// public clients send their client ID as a form parameter and authenticate otherwise.
+	public bool
+	store  RefreshTokenStore
+
+	mu      sync.Mutex
+	current string
+}
+
+// load reads the refresh token from the store unless the chain has one already.
+func (c *refreshChain) load(ctx context.Context) error {
+	if c.current != "" {
+		return nil
+	}
+	rt, err := c.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("oauth2: loading refresh token: %w", err)
+	}
+	c.current = rt
+	return nil
+}
+
+// refresh fetches an access token with the current refresh token.
+func (c *refreshChain) refresh(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {c.current}}
+	tr, err := c.request(ctx, form)
+	if err != nil {
+		return "", 0, err
+	}
+	return c.adopt(ctx, tr)
+}
+
+// request sends a token request with the chain's client authentication.
+func (c *refreshChain) request(ctx context.Context, form url.Values) (*tokenResponse, error) {
+	if c.public {
+		form.Set("client_id", c.clientID)
+		return c.cfg.request(ctx, c.tokenURL, "", "", form)
+	}
+	return c.cfg.request(ctx, c.tokenURL, c.clientID, c.clientSecret, form)
+}
+
+// adopt saves a new refresh token from tr, if any, and returns the access token. If saving fails, the access token comes with the error, and the chain keeps the new refresh token in memory.
+func (c *refreshChain) adopt(ctx context.Context, tr *tokenResponse) (string, time.Duration, error) {
+	if tr.RefreshToken != "" && tr.RefreshToken != c.current {
+		c.current = tr.RefreshToken
+		if err := c.store.Save(ctx, c.current); err != nil {
+			return tr.AccessToken, tr.lifespan, fmt.Errorf("oauth2: saving refresh token: %w", err)
+		}
+	}
+	return tr.AccessToken, tr.lifespan, nil
+}
+
+// MemoryStore keeps the refresh token in memory. It does not survive restarts but is useful for tests and short-lived processes.
+type MemoryStore struct {
+	mu    sync.Mutex
+	token string
+}
+
+// NewMemoryStore returns a MemoryStore that holds refreshToken.
+func NewMemoryStore(refreshToken string) *MemoryStore {
+	return &MemoryStore{token: refreshToken}
+}
+
+// Load returns the stored refresh token.
+func (s *MemoryStore) Load(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token, nil
+}
+
+// Save replaces the stored refresh token.
+func (s *MemoryStore) Save(_ context.Context, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = refreshToken
+	return nil
+}
+
+// FileStore keeps the refresh token in a file that only the owner can read. Save replaces the file atomically, so that a crash never leaves a truncated token behind.
+type FileStore struct {
+	Path string
+}
+
+// Load reads the refresh token from the file. A missing file yields an empty token.
+func (s FileStore) Load(context.Context) (string, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Save writes the refresh token to a temporary file and renames it to Path.
+func (s FileStore) Save(_ context.Context, refreshToken string) error {
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteString(refreshToken); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
@@ -0,0 +1,79 @@
+package oauth2
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// rotatingServer issues refresh tokens "rt1", "rt2", and so on, and accepts only the latest one.
+func rotatingServer(t *testing.T) *httptest.Server {
+	var (
+		mu     sync.Mutex
+		latest = 0
+	)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "rt"+strconv.Itoa(latest) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		latest++
+		w.Write([]byte(`{"access_token":"at` + strconv.Itoa(latest) + `","expires_in":60,"refresh_token":"rt` + strconv.Itoa(latest) + `"}`))
+	}))
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	srv := rotatingServer(t)
+	defer srv.Close()
+	store := FileStore{Path: filepath.Join(t.TempDir(), "refresh_token")}
+	if err := store.Save(context.Background(), "rt0"); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := NewRefreshToken(srv.URL, "id", "", store)
+	for i := 1; i <= 2; i++ {
+		at, _, err := auth(context.Background())
+		if err != nil || at != "at"+strconv.Itoa(i) {
+			t.Fatalf("call %d: want at%d, got (%q, %v)", i, i, at, err)
+		}
+	}
+
+	// A new authorizer, as after a restart, continues the chain from the store.
+	auth = NewRefreshToken(srv.URL, "id", "", store)
+	if at, _, err := auth(context.Background()); err != nil || at != "at3" {
+		t.Fatalf("after restart: want at3, got (%q, %v)", at, err)
+	}
+}
+
+type failingStore struct{ *MemoryStore }
+
+func (failingStore) Save(context.Context, string) error { return errors.New("disk full") }
+
+func TestRefreshTokenSaveFailure(t *testing.T) {
+	srv := rotatingServer(t)
+	defer srv.Close()
+	auth := NewRefreshToken(srv.URL, "id", "", failingStore{NewMemoryStore("rt0")})
+	if at, _, err := auth(context.Background()); at != "at1" || err == nil {
+		t.Fatalf("want at1 with save error, got (%q, %v)", at, err)
+	}
+	// The rotated refresh token is kept in memory.
+	if at, _, _ := auth(context.Background()); at != "at2" {
+		t.Fatalf("want at2, got %q", at)
+	}
+}
+
+func TestRefreshTokenEmptyStore(t *testing.T) {
+	auth := NewRefreshToken("http://invalid", "id", "", FileStore{Path: filepath.Join(t.TempDir(), "missing")})
+	if _, _, err := auth(context.Background()); !errors.Is(err, ErrNoRefreshToken) {
+		t.Fatalf("want ErrNoRefreshToken, got %v", err)
+	}
+}
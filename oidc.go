@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appliedgo/refresh/oauth2"
+)
+
+// An OpenID Connect provider publishes its endpoints in a discovery document at `<issuer>/.well-known/openid-configuration`. Hardcoding the token endpoint means that a change at the provider breaks the app. Reading the document once at startup means that the change breaks the app a little later. The discovery document is a value that expires, too, so a `Refresher` keeps it fresh, and the authorization function looks up the token endpoint on each call.
+
+// `DefaultDiscoveryLifespan` is how long a discovery document is used if the response does not say otherwise through `Cache-Control: max-age`.
+const DefaultDiscoveryLifespan = time.Hour
+
+// `minDiscoveryLifespan` keeps a provider that forbids caching from getting a request in each refresh loop iteration.
+const minDiscoveryLifespan = time.Minute
+
+// `discoveryBackoff` spaces out the retries after failed fetches of a discovery document.
+var discoveryBackoff = ExponentialBackoff{Initial: time.Second, Max: minDiscoveryLifespan}
+
+// `ErrIssuerMismatch` is returned if a discovery document names another issuer than the one it was fetched for.
+var ErrIssuerMismatch = errors.New("refresh: discovery document issuer mismatch")
+
+// `OIDCConfig` holds the fields of an OpenID Connect discovery document that token clients need.
+type OIDCConfig struct {
+	Issuer                      string   `json:"issuer"`
+	TokenEndpoint               string   `json:"token_endpoint"`
+	JWKSURI                     string   `json:"jwks_uri"`
+	DeviceAuthorizationEndpoint string   `json:"device_authorization_endpoint,omitempty"`
+	GrantTypesSupported         []string `json:"grant_types_supported,omitempty"`
+	ScopesSupported             []string `json:"scopes_supported,omitempty"`
+}
+
+// `NewOIDCDiscovery` returns a `Refresher` that keeps the discovery document of `issuer` fresh. It fetches the document again when the `max-age` of the response has passed, but not more often than once a minute, or after `DefaultDiscoveryLifespan` if the response has no `max-age`. After a failed fetch, it retries with exponential backoff from one second up to one minute. `client` makes the requests; nil means `http.DefaultClient`.
+func NewOIDCDiscovery(ctx context.Context, issuer string, client *http.Client) *Refresher[OIDCConfig] {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	issuer = strings.TrimSuffix(issuer, "/")
+	return NewRefresher(ctx, func(ctx context.Context) (OIDCConfig, time.Duration, error) {
+		var cfg OIDCConfig
+		lifespan, err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", DefaultDiscoveryLifespan, &cfg)
+		if err != nil {
+			return OIDCConfig{}, 0, fmt.Errorf("refresh: OIDC discovery: %w", err)
+		}
+		if strings.TrimSuffix(cfg.Issuer, "/") != issuer {
+			return OIDCConfig{}, 0, fmt.Errorf("%w: want %s, got %s", ErrIssuerMismatch, issuer, cfg.Issuer)
+		}
+		if cfg.TokenEndpoint == "" {
+			return OIDCConfig{}, 0, errors.New("refresh: OIDC discovery: no token_endpoint")
+		}
+		return cfg, max(lifespan, minDiscoveryLifespan), nil
+	}, WithRefresherBackoff(discoveryBackoff))
+}
+
+// `DiscoveredAuthorizer` returns an authorization function that builds its authorizer from the current discovery document of `disc`, and builds it anew when the document has changed. For example:
+//
+//	auth := DiscoveredAuthorizer(disc, func(cfg OIDCConfig) func(context.Context) (string, time.Duration, error) {
+//		return oauth2.NewClientCredentials(cfg.TokenEndpoint, clientID, clientSecret, scopes)
+//	})
+func DiscoveredAuthorizer(disc *Refresher[OIDCConfig], build func(cfg OIDCConfig) func(ctx context.Context) (string, time.Duration, error)) func(ctx context.Context) (string, time.Duration, error) {
+	var (
+		mu      sync.Mutex
+		current OIDCConfig
+		auth    func(ctx context.Context) (string, time.Duration, error)
+	)
+	return func(ctx context.Context) (string, time.Duration, error) {
+		cfg, err := disc.GetContext(ctx)
+		if err != nil {
+			return "", 0, err
+		}
+		mu.Lock()
+		if auth == nil || !sameOIDCConfig(cfg, current) {
+			auth, current = build(cfg), cfg
+		}
+		a := auth
+		mu.Unlock()
+		return a(ctx)
+	}
+}
+
+// `DiscoveredClientCredentials` returns an authorization function for the client credentials grant at the token endpoint that `disc` has discovered. See `DiscoveredAuthorizer`.
+func DiscoveredClientCredentials(disc *Refresher[OIDCConfig], clientID, clientSecret string, scopes []string, opts ...oauth2.Option) func(ctx context.Context) (string, time.Duration, error) {
+	return DiscoveredAuthorizer(disc, func(cfg OIDCConfig) func(context.Context) (string, time.Duration, error) {
+		return oauth2.NewClientCredentials(cfg.TokenEndpoint, clientID, clientSecret, scopes, opts...)
+	})
+}
+
+// `sameOIDCConfig` reports whether two discovery documents have the same endpoints.
+func sameOIDCConfig(a, b OIDCConfig) bool {
+	return a.Issuer == b.Issuer && a.TokenEndpoint == b.TokenEndpoint && a.JWKSURI == b.JWKSURI && a.DeviceAuthorizationEndpoint == b.DeviceAuthorizationEndpoint
+}
+
+// `maxResponseSize` limits how much of a discovery or key set response gets read.
+const maxResponseSize = 1 << 20
+
+// `getJSON` fetches `url` and decodes the JSON response into `v`. It returns the `max-age` of the response, or `fallback` if the response has none.
+func getJSON(ctx context.Context, client *http.Client, url string, fallback time.Duration, v any) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, NewAuthError(resp)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return 0, err
+	}
+	return maxAge(resp.Header, fallback), nil
+}
+
+// `maxAge` returns the `max-age` directive of the `Cache-Control` header, or `fallback` if there is none. Responses that must not be cached get no lifespan.
+func maxAge(h http.Header, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-store" || directive == "no-cache" {
+			return 0
+		}
+		if v, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The token comes from the discovered endpoint.
+func TestDiscoveredClientCredentials(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			fmt.Fprintf(w, `{"issuer":%q,"token_endpoint":"%s/token","jwks_uri":"%s/jwks"}`, srv.URL, srv.URL, srv.URL)
+		case "/token":
+			fmt.Fprint(w, `{"access_token":"tok","expires_in":3600}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	disc := NewOIDCDiscovery(ctx, srv.URL+"/", nil)
+	auth := DiscoveredClientCredentials(disc, "app", "secret", nil)
+	if token, _, err := auth(ctx); token != "tok" || err != nil {
+		t.Fatalf("want tok, got (%q, %v)", token, err)
+	}
+	if cfg, err := disc.Get(); err != nil || cfg.JWKSURI != srv.URL+"/jwks" {
+		t.Fatalf("unexpected config (%+v, %v)", cfg, err)
+	}
+}
+
+// A changed endpoint builds a new authorizer; an unchanged one reuses the authorizer.
+func TestDiscoveredAuthorizerRebuild(t *testing.T) {
+	var endpoint atomic.Int32
+	endpoint.Store(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	disc := NewRefresher(ctx, func(context.Context) (OIDCConfig, time.Duration, error) {
+		return OIDCConfig{Issuer: "https://issuer", TokenEndpoint: fmt.Sprintf("https://issuer/token%d", endpoint.Load())}, 30 * time.Millisecond, nil
+	})
+	var builds atomic.Int32
+	auth := DiscoveredAuthorizer(disc, func(cfg OIDCConfig) func(context.Context) (string, time.Duration, error) {
+		builds.Add(1)
+		return func(context.Context) (string, time.Duration, error) {
+			return cfg.TokenEndpoint, time.Hour, nil
+		}
+	})
+	for i := 0; i < 3; i++ {
+		if token, _, _ := auth(ctx); token != "https://issuer/token1" {
+			t.Fatalf("want token1, got %q", token)
+		}
+	}
+	endpoint.Store(2)
+	waitFor(t, time.Second, func() bool {
+		token, _, _ := auth(ctx)
+		return token == "https://issuer/token2"
+	})
+	if n := builds.Load(); n != 2 {
+		t.Errorf("want 2 builds, got %d", n)
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"issuer":"https://evil.example","token_endpoint":"https://evil.example/token"}`)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	disc := NewOIDCDiscovery(ctx, srv.URL, nil)
+	if _, err := disc.GetContext(ctx); !errors.Is(err, ErrIssuerMismatch) {
+		t.Fatalf("want ErrIssuerMismatch, got %v", err)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"":                      time.Hour,
+		"max-age=60":            time.Minute,
+		"public, Max-Age=120":   2 * time.Minute,
+		"max-age=abc":           time.Hour,
+		"no-store":              0,
+		"no-cache, max-age=600": 0,
+	} {
+		h := http.Header{}
+		if header != "" {
+			h.Set("Cache-Control", header)
+		}
+		if got := maxAge(h, time.Hour); got != want {
+			t.Errorf("%q: want %v, got %v", header, want, got)
+		}
+	}
+}
+
+// A provider that is down does not get a request every few milliseconds.
+func TestOIDCDiscoveryBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewOIDCDiscovery(ctx, srv.URL, nil)
+	time.Sleep(200 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 request, got %d", n)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The article's `Token` spends one goroutine per token. An app with thousands of tokens, most of them rarely read, may not want to pay for that. In on-demand mode, there is no goroutine at all. Instead, `Get()` checks whether the token is due for a refresh and, if so, refreshes it right away. Only one caller performs the refresh. The others wait for its result or, if configured, receive the previous token while it is still valid.
+
+// `WithOnDemandRefresh` refreshes the token inside `Get()` and its variants rather than in a background goroutine. Tokens with a warm pool ignore this option. On-demand tokens do not support `SwapAuthorizer()`, `WithStaleOnError`, or `WithIdleTimeout`.
+func WithOnDemandRefresh() Option {
+	return func(o *options) {
+		o.onDemand = true
+	}
+}
+
+// `WithServeStaleDuringRefresh` lets callers of an on-demand token receive the previous token while another caller refreshes it, as long as the previous token has not expired. By default, they wait for the refresh.
+func WithServeStaleDuringRefresh() Option {
+	return func(o *options) {
+		o.serveStaleDuringRefresh = true
+	}
+}
+
+// `onDemandState` is the state of an on-demand token. With no goroutine to own it, a mutex protects it.
+type onDemandState struct {
+	mu sync.Mutex
+	// `has` is true once the first fetch completed. `resp` is its result.
+	has  bool
+	resp tokenResponse
+	// `refreshAt` is when `resp` is due for a refresh, and `validUntil` is when a good token expires.
+	refreshAt  time.Time
+	validUntil time.Time
+	// `inflight` is non-nil while a caller refreshes the token. It gets closed when the refresh is done.
+	inflight chan struct{}
+}
+
+// Method `onDemand` reports whether the token refreshes on demand.
+func (a *Token) onDemand() bool {
+	return a.opts.onDemand && a.opts.poolSize == 0
+}
+
+// Method `getOnDemand` returns the current token, refreshing it first if it is due. `force` makes the call refresh the token unless another refresh is already in flight.
+func (a *Token) getOnDemand(ctx context.Context, force bool) tokenResponse {
+	s := &a.onDemandState
+	if force && a.minIntervalWait() > 0 {
+		force = false
+	}
+	for {
+		select {
+		case <-a.closing():
+			return tokenResponse{Err: ErrClosed}
+		default:
+		}
+		s.mu.Lock()
+		now := a.now()
+		// While refreshes are paused, the last result stays. See `pause.go`.
+		if s.has && !force && (now.Before(s.refreshAt) || a.paused.Load()) {
+			resp := s.resp
+			s.mu.Unlock()
+			return resp
+		}
+		if wait := s.inflight; wait != nil {
+			if a.opts.serveStaleDuringRefresh && s.has && s.resp.Err == nil && now.Before(s.validUntil) {
+				resp := s.resp
+				s.mu.Unlock()
+				return resp
+			}
+			s.mu.Unlock()
+			select {
+			case <-wait:
+				// A forced refresh is satisfied by the refresh that was in flight.
+				force = false
+				continue
+			case <-a.closing():
+				return tokenResponse{Err: ErrClosed}
+			case <-ctx.Done():
+				return tokenResponse{Err: fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())}
+			}
+		}
+		// `Close()` waits for the refresh to finish. A token that is closing starts no new refresh.
+		if !a.track() {
+			s.mu.Unlock()
+			return tokenResponse{Err: ErrClosed}
+		}
+		done := make(chan struct{})
+		s.inflight = done
+		s.mu.Unlock()
+		return a.refreshOnDemand(done)
+	}
+}
+
+// Method `refreshOnDemand` fetches a new token and publishes the result to all waiting callers. The fetch uses the token's context rather than the caller's, as all waiting callers depend on it.
+func (a *Token) refreshOnDemand(done chan struct{}) tokenResponse {
+	defer a.cleanup.Done()
+	s := &a.onDemandState
+	ctx := a.runCtx
+	a.logEvent(ctx, EventExpired, "Token due for refresh")
+	resp, lifespan := a.fetch(ctx)
+	a.generation.Add(1)
+	a.cache(resp, lifespan)
+	if resp.Err != nil {
+		a.logEvent(ctx, EventRefreshError, "Error refreshing token", "err", resp.Err)
+	} else {
+		a.logEvent(ctx, EventRefresh, "Token refreshed", "token", Secret(resp.Token), "expires_at", a.now().Add(lifespan))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// `schedule` keeps track of retries. Until the next attempt is due, callers receive the error.
+	var next time.Duration
+	next, resp.Err = a.schedule(ctx, lifespan, resp.Err)
+	now := a.now()
+	s.has = true
+	s.resp = resp
+	s.refreshAt = now.Add(next)
+	if resp.Err == nil {
+		s.validUntil = now.Add(lifespan)
+	}
+	s.inflight = nil
+	close(done)
+	a.broadcast(resp)
+	// The caller that performed the refresh may have outlived the token.
+	if ctx.Err() != nil {
+		return tokenResponse{Err: ErrClosed}
+	}
+	return resp
+}
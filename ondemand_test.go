@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestOnDemandRefresh(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		n := calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return strconv.Itoa(int(n)), time.Minute, nil
+	}
+	tok := NewToken(context.Background(), auth, WithOnDemandRefresh(), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("on-demand token authorized before first Get (%d calls)", n)
+	}
+
+	// Concurrent callers share a single refresh.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := tok.Get(); got != "1" || err != nil {
+				t.Errorf("want token 1, got (%q, %v)", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 authorization call, got %d", n)
+	}
+
+	// Once the token is due, the next Get refreshes it.
+	clock.Advance(time.Minute - lifeSpanSafetyMargin)
+	if got, _ := tok.GetContext(context.Background()); got != "2" {
+		t.Fatalf("want token 2, got %q", got)
+	}
+	if err := tok.ForceRefresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tok.Get(); got != "3" {
+		t.Fatalf("want token 3 after forced refresh, got %q", got)
+	}
+
+	tok.Close()
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
+
+func TestOnDemandServeStale(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	release := make(chan struct{})
+	auth := func() (string, time.Duration, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			<-release
+		}
+		return strconv.Itoa(int(n)), time.Minute, nil
+	}
+	tok := NewToken(context.Background(), auth, WithOnDemandRefresh(), WithServeStaleDuringRefresh(),
+		WithClock(clock), WithSafetyMargin(10*time.Second), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+
+	clock.Advance(50 * time.Second)
+	refreshed := make(chan string)
+	go func() {
+		got, _ := tok.Get()
+		refreshed <- got
+	}()
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+	// While the refresh hangs, other callers receive the still valid token 1.
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want stale token 1, got %q", got)
+	}
+	close(release)
+	if got := <-refreshed; got != "2" {
+		t.Fatalf("want token 2, got %q", got)
+	}
+}
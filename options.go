@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	rnd "math/rand"
+	"time"
+)
+
+// `Option` configures a `Token`. Options are passed to `NewToken` and applied in order.
+type Option func(*options)
+
+// `options` collects all optional settings of a `Token`. The zero value represents the default behavior described in the article.
+type options struct {
+	// `refreshDeadline` bounds the time spent on retrying a failed refresh. Zero means no deadline.
+	refreshDeadline time.Duration
+	// `validate` checks each new token before the warmup request. Nil means no validation. See `validator.go`.
+	validate func(token string) error
+	// `warmup` is called with each new token before the token gets served. Nil means no warmup.
+	warmup func(ctx context.Context, token string) error
+	// `errorWithToken` decides what happens if `authorize()` returns a token and an error at the same time.
+	errorWithToken ErrorWithTokenPolicy
+	// `refreshJitter` and `backoffJitter` are the fractions by which refresh and retry delays get randomly shortened. Zero means no jitter.
+	refreshJitter float64
+	backoffJitter float64
+	// `logger` receives log output. Nil means the standard logger of package `log`.
+	logger Logger
+	// `eventLevels` overrides the default log level per event type.
+	eventLevels map[EventType]slog.Level
+	// `poolSize` and `poolLow` configure the warm pool of single-use tokens. A `poolSize` of zero disables the pool.
+	poolSize int
+	poolLow  int
+	// `unusedAfter` is the time after which an unread token logs a warning. Zero disables the check.
+	unusedAfter time.Duration
+	// `backoff` computes retry delays. Nil means a constant delay of `retryDelay`.
+	backoff BackoffPolicy
+	// `giveUpInterval` is the least time the loop waits after giving up on a refresh window. Zero means `DefaultGiveUpInterval`.
+	giveUpInterval time.Duration
+	// `staleOnError` enables serving the previous token after a failed refresh, and `staleGrace` extends its validity.
+	staleOnError bool
+	staleGrace   time.Duration
+	// `safetyMargin` and `safetyFraction` override `lifeSpanSafetyMargin`. `safetyMarginSet` tells a margin of zero from no margin set. See `margin.go`.
+	safetyMargin    time.Duration
+	safetyMarginSet bool
+	safetyFraction  float64
+	// `adaptiveMargin` widens the margin based on recent authorization calls.
+	adaptiveMargin bool
+	// `onDemand` replaces the refresh goroutine by refreshes inside `Get()`, and `serveStaleDuringRefresh` lets callers skip waiting for them. See `ondemand.go`.
+	onDemand                bool
+	serveStaleDuringRefresh bool
+	// `lazyStart` defers the refresh goroutine until first use.
+	lazyStart bool
+	// `fallbacks` step in when the authorization function fails, and `primaryRetry` is the time before it gets tried again. See `fallback.go`.
+	fallbacks    []func(ctx context.Context) (string, time.Duration, error)
+	primaryRetry time.Duration
+	// `initial` is the token of `WithInitialValue`. See `initial.go`.
+	initial *StoredToken
+	// `manualRun` leaves running the refresh loop to `Run()`. See `runloop.go`.
+	manualRun bool
+	// `maxServeAge` is the age beyond which `Get()` refreshes the token before returning it. See `maxage.go`.
+	maxServeAge time.Duration
+	// `notReady` decides what `Get()` does before the first token arrived. See `ready.go`.
+	notReady NotReadyPolicy
+	// `blockUntilReady` makes the constructors wait for the first token. See `ready.go`.
+	blockUntilReady time.Duration
+	// `idleTimeout` stops the refresh goroutine after a period without reads. Zero disables it. See `idle.go`.
+	idleTimeout time.Duration
+	// `onRefresh` and `onError` are called after each refresh attempt. See `hooks.go`.
+	onRefresh func(token string, expiresAt time.Time)
+	onError   func(err error)
+	// `sinks` receive each new token, and `onSinkError` learns about their failures. See `sink.go`.
+	sinks       []Sink
+	onSinkError func(s Sink, err error)
+	// `expiryImminent` and `imminentWithin` implement `WithOnExpiryImminent`. See `imminent.go`.
+	expiryImminent func(expiresAt time.Time, lastErr error)
+	imminentWithin time.Duration
+	// `tracerProvider` creates a span for each refresh. Nil disables tracing. See `tracing.go`.
+	tracerProvider TracerProvider
+	// `authorizeTimeout` bounds each call to the authorization function. Zero means no timeout.
+	authorizeTimeout time.Duration
+	// `clockSkew` shortens absolute expiry times. See `expiry.go`.
+	clockSkew time.Duration
+	// `fastPath` serves reads from an atomic pointer. See `fastpath.go`.
+	fastPath bool
+	// `jwtExpiry` derives lifespans from JWT claims. See `jwt.go`.
+	jwtExpiry bool
+	// `circuit` configures the circuit breaker. Nil disables it.
+	circuit *CircuitBreaker
+	// `minRefreshInterval` is the minimum time between two authorization calls. See `ratelimit.go`.
+	minRefreshInterval time.Duration
+	// `isPermanent` classifies authorization errors. Nil means that all errors are transient. See `errclass.go`.
+	isPermanent func(error) bool
+	// `store` persists tokens across restarts. Nil disables persistence. See `store.go`.
+	store Store
+	// `shared` and `sharedLockTTL` coordinate refreshes between processes. See `shared.go`.
+	shared        SharedStore
+	sharedLockTTL time.Duration
+	// `coordinator` and `leaseTTL` elect a single replica that fetches tokens. See `coordinator.go`.
+	coordinator Coordinator
+	leaseTTL    time.Duration
+	// `health` sets the thresholds of `Healthy()`. See `health.go`.
+	health HealthPolicy
+	// `subscriptionBuffer` is the channel capacity per subscriber. Zero means 1.
+	subscriptionBuffer int
+	// `clock` tells the time. Nil means the system clock.
+	clock Clock
+	// `rand` is the random source for jitter. Nil means the global source of `math/rand`.
+	rand *rnd.Rand
+}
+
+// `ErrRefreshDeadline` is returned by `Get()` after the refresh loop gave up on the current refresh window.
+var ErrRefreshDeadline = errors.New("refresh: deadline exceeded")
+
+// `WithRefreshDeadline` bounds the total time that a single refresh window may take, including all retries. When the deadline passes, the loop stops retrying, serves the last error wrapped in `ErrRefreshDeadline`, and waits before it opens the next window, as described at `WithGiveUpInterval`.
+func WithRefreshDeadline(d time.Duration) Option {
+	return func(o *options) {
+		o.refreshDeadline = d
+	}
+}
+
+// `ErrWarmupFailed` is the error of a refresh whose warmup request failed. It wraps the error of the request.
+var ErrWarmupFailed = errors.New("refresh: warmup request failed")
+
+// `WithWarmupRequest` sets a function that performs a lightweight request with each new token before the token gets served. If the request fails, the token is discarded and the refresh counts as failed, hence it is retried. This catches tokens that authenticate but lack required permissions. By default, no warmup request is made.
+func WithWarmupRequest(warmup func(ctx context.Context, token string) error) Option {
+	return func(o *options) {
+		o.warmup = warmup
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshDeadline(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			return "initial", 20 * time.Millisecond, nil
+		}
+		return "", time.Hour, errors.New("auth server down")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithRefreshDeadline(30*time.Millisecond))
+
+	time.Sleep(150 * time.Millisecond)
+	_, err := tok.Get()
+	if !errors.Is(err, ErrRefreshDeadline) {
+		t.Fatalf("want ErrRefreshDeadline, got %v", err)
+	}
+
+	// The loop must have abandoned the window: no more attempts until the next window opens.
+	n := calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if m := calls.Load(); m != n {
+		t.Fatalf("loop kept retrying after the deadline: %d calls, then %d", n, m)
+	}
+}
+
+// Authorization functions report no lifespan along with an error. This must not make the loop open the next window right away.
+func TestRefreshDeadlineZeroLifespan(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "", 0, errors.New("auth server down")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithRefreshDeadline(30*time.Millisecond), WithLogger(NopLogger))
+
+	waitFor(t, time.Second, func() bool {
+		_, err := tok.Get()
+		return errors.Is(err, ErrRefreshDeadline)
+	})
+	n := calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if m := calls.Load(); m != n {
+		t.Fatalf("loop kept retrying after the deadline: %d calls, then %d", n, m)
+	}
+}
+
+func TestWarmupRequest(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return fmt.Sprintf("tok%d", calls.Add(1)), time.Hour, nil
+	}
+	var warmups atomic.Int32
+	warmup := func(ctx context.Context, token string) error {
+		warmups.Add(1)
+		if token == "tok1" {
+			return errors.New("403 forbidden")
+		}
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithWarmupRequest(warmup))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, err := tok.Get()
+		if err == nil {
+			if got != "tok2" {
+				t.Fatalf("want tok2, got %s", got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("warmup failure was not retried: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := warmups.Load(); n != 2 {
+		t.Fatalf("want 2 warmup requests, got %d", n)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "errors"
+
+// During a planned maintenance of the authorization server, every refresh attempt fails, fills the logs with errors, and may even trip alerts or lockouts. If the current token outlives the maintenance window, there is no need to try at all. `Pause()` stops the refreshes without closing the token, and `Resume()` starts them again.
+
+// `ErrPaused` is returned by `ForceRefresh()` while refreshes are paused.
+var ErrPaused = errors.New("refresh: refreshes paused")
+
+// Method `Pause` stops refreshing the token. `Get()` and its variants keep serving the last token, even after it has expired. A token that has no value yet still fetches its first one.
+// Tokens with a warm pool ignore `Pause`.
+func (a *Token) Pause() {
+	if !a.paused.Swap(true) {
+		a.logEvent(a.runCtx, EventRefresh, "Refreshes paused")
+	}
+}
+
+// Method `Resume` continues refreshing the token after `Pause()`. If a refresh fell due while paused, it happens right away. Otherwise, the refresh happens as scheduled.
+func (a *Token) Resume() {
+	if !a.paused.Swap(false) {
+		return
+	}
+	a.logEvent(a.runCtx, EventRefresh, "Refreshes resumed")
+	select {
+	case a.resumed <- struct{}{}:
+	default:
+	}
+}
+
+// Method `Paused` reports whether refreshes are paused.
+func (a *Token) Paused() bool {
+	return a.paused.Load()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// A paused token serves its last value past the refresh time and refreshes right after `Resume`.
+func TestPauseResume(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want 1, got %q", got)
+	}
+
+	tok.Pause()
+	if !tok.Paused() {
+		t.Fatal("want paused")
+	}
+	if err := tok.ForceRefresh(context.Background()); !errors.Is(err, ErrPaused) {
+		t.Fatalf("ForceRefresh: want ErrPaused, got %v", err)
+	}
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(2 * time.Hour)
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want 1 while paused, got %q", got)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want no refresh while paused, got %d calls", n)
+	}
+
+	tok.Resume()
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+	if got, _ := tok.Get(); got != "2" {
+		t.Fatalf("want 2 after Resume, got %q", got)
+	}
+}
+
+func TestPauseOnDemand(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}, WithOnDemandRefresh(), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	tok.Pause()
+	clock.Advance(2 * time.Hour)
+	if got, _ := tok.Get(); got != "1" {
+		t.Fatalf("want 1 while paused, got %q", got)
+	}
+	tok.Resume()
+	if got, _ := tok.Get(); got != "2" {
+		t.Fatalf("want 2 after Resume, got %q", got)
+	}
+}
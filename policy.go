@@ -0,0 +1,18 @@
+package main
+
+// `ErrorWithTokenPolicy` defines how a `Token` treats an authorization call that returns a non-empty token and a non-nil error at the same time.
+type ErrorWithTokenPolicy int
+
+const (
+	// `ErrorWithTokenFail` treats the call as failed. The token is discarded, `Get()` returns the error, and the refresh is retried. This is the default.
+	ErrorWithTokenFail ErrorWithTokenPolicy = iota
+	// `ErrorWithTokenServe` serves the token and only logs the error as a warning, unless the lifespan is not positive. Such a token is treated as with `ErrorWithTokenFail`.
+	ErrorWithTokenServe
+)
+
+// `WithErrorWithTokenPolicy` sets the policy for authorization calls that return a token along with an error. The default is `ErrorWithTokenFail`.
+func WithErrorWithTokenPolicy(p ErrorWithTokenPolicy) Option {
+	return func(o *options) {
+		o.errorWithToken = p
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorWithTokenPolicy(t *testing.T) {
+	authErr := errors.New("token issued with warnings")
+	auth := func() (string, time.Duration, error) {
+		return "tok", time.Hour, authErr
+	}
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantToken string
+		wantErr   error
+	}{
+		{"default", nil, "", authErr},
+		{"fail", []Option{WithErrorWithTokenPolicy(ErrorWithTokenFail)}, "", authErr},
+		{"serve", []Option{WithErrorWithTokenPolicy(ErrorWithTokenServe)}, "tok", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tok, err := NewToken(ctx, auth, tt.opts...).Get()
+			if tok != tt.wantToken || !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("want (%q, %v), got (%q, %v)", tt.wantToken, tt.wantErr, tok, err)
+			}
+		})
+	}
+}
+
+// A token that comes with an error and no lifespan is not served, and the refreshes back off.
+func TestErrorWithTokenServeExpired(t *testing.T) {
+	authErr := errors.New("token issued with warnings")
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "tok", 0, authErr
+	}, WithErrorWithTokenPolicy(ErrorWithTokenServe), WithBackoff(ExponentialBackoff{Initial: 50 * time.Millisecond}), WithLogger(NopLogger))
+	if got, err := tok.Get(); got != "" || !errors.Is(err, authErr) {
+		t.Fatalf("want (\"\", %v), got (%q, %v)", authErr, got, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n > 3 {
+		t.Fatalf("want at most 3 calls, got %d", n)
+	}
+}
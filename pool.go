@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Some APIs issue single-use tokens. A single token that gets refreshed before it expires does not help here; every request needs a fresh one. To serve bursts of requests without waiting for the authorization endpoint, a token can maintain a warm pool of pre-fetched tokens. Each `Get()` takes one token out of the pool, and the pool gets refilled in the background.
+
+// `WithWarmPool` turns the token into a pool of `size` pre-fetched single-use tokens. Each call to `Get()` removes one token from the pool. When the number of pooled tokens drops to `lowWatermark` or below, the pool gets refilled to capacity in the background. Tokens that are about to expire are dropped from the pool.
+// `size` must be positive and `lowWatermark` must be between 0 and `size`-1.
+func WithWarmPool(size, lowWatermark int) Option {
+	if size <= 0 || lowWatermark < 0 || lowWatermark >= size {
+		panic(fmt.Sprintf("refresh: invalid warm pool size %d or low watermark %d", size, lowWatermark))
+	}
+	return func(o *options) {
+		o.poolSize = size
+		o.poolLow = lowWatermark
+	}
+}
+
+// A `pooledToken` is a token in the warm pool, along with the time when it must be dropped.
+type pooledToken struct {
+	resp   tokenResponse
+	dropAt time.Time
+}
+
+// Method `poolLoop` replaces `refreshToken()` for tokens with a warm pool. It follows the same pattern: a `select` statement serializes all access to the pool. The authorization calls happen in a separate goroutine, so that clients can still take tokens from the pool while it is being refilled.
+func (a *Token) poolLoop(ctx context.Context) {
+	var pool []pooledToken
+	var lastErr error
+	refilled := make(chan pooledToken)
+	refilling := 0 // number of tokens that the refill goroutine still has to deliver
+	var retry <-chan time.Time
+
+	// `refill` starts a goroutine that fetches as many tokens as needed to fill the pool to capacity. Only one refill runs at a time.
+	refill := func() {
+		n := a.opts.poolSize - len(pool)
+		if refilling > 0 || retry != nil || n <= 0 {
+			return
+		}
+		refilling = n
+		go func() {
+			for i := 0; i < n; i++ {
+				if !a.rateWait(ctx) {
+					return
+				}
+				resp, lifespan := a.fetch(ctx)
+				if resp.Err == nil {
+					a.markReady()
+				}
+				select {
+				case refilled <- pooledToken{resp: resp, dropAt: a.now().Add(a.refreshAfter(lifespan))}:
+				case <-ctx.Done():
+					return
+				}
+				if resp.Err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	var headTimer <-chan time.Time
+	var headAt time.Time
+
+	for {
+		// Drop tokens that are about to expire, and refill the pool if it ran low.
+		now := a.now()
+		for len(pool) > 0 && !now.Before(pool[0].dropAt) {
+			pool = pool[1:]
+		}
+		if len(pool) <= a.opts.poolLow {
+			refill()
+		}
+
+		// Serve the oldest pooled token. With an empty pool, serve the last refill error, if any, or let clients wait for the refill.
+		var out chan tokenResponse
+		var next tokenResponse
+		var headExpired <-chan time.Time
+		switch {
+		case len(pool) > 0:
+			out, next = a.accessToken, pool[0].resp
+			if !pool[0].dropAt.Equal(headAt) {
+				headAt = pool[0].dropAt
+				headTimer = a.after(headAt.Sub(now))
+			}
+			headExpired = headTimer
+		case lastErr != nil:
+			out, next = a.accessToken, tokenResponse{Err: lastErr}
+		}
+
+		select {
+		case out <- next:
+			if len(pool) > 0 {
+				pool = pool[1:]
+			}
+
+		case p := <-refilled:
+			refilling--
+			if p.resp.Err != nil {
+				// The refill goroutine gives up after an error. Try again as the backoff policy says, unless the error is permanent.
+				refilling = 0
+				a.logEvent(ctx, EventRefreshError, "Error refilling token pool", "err", p.resp.Err)
+				var retryIn time.Duration
+				if a.permanent(p.resp.Err) {
+					retryIn, lastErr = never, p.resp.Err
+					a.windowStart = time.Time{}
+				} else {
+					retryIn, lastErr = a.retryAfterError(0, p.resp.Err)
+				}
+				retry = a.after(retryIn)
+				continue
+			}
+			lastErr = nil
+			a.windowStart = time.Time{}
+			pool = append(pool, p)
+			if refilling == 0 {
+				// Clients may have drained the pool during the refill. Keep going until the pool is full.
+				if len(pool) < a.opts.poolSize {
+					refill()
+					continue
+				}
+				a.logEvent(ctx, EventRefresh, "Token pool refilled", "size", len(pool))
+			}
+
+		case <-retry:
+			retry = nil
+
+		// The oldest token is about to expire. The next iteration drops it.
+		case <-headExpired:
+
+		case <-ctx.Done():
+			a.logEvent(ctx, EventClose, "Token refresher stopped")
+			return
+		}
+	}
+}
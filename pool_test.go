@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmPool(t *testing.T) {
+	const size = 5
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		n := calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return strconv.Itoa(int(n)), time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth, WithWarmPool(size, 2))
+
+	// Wait until the pool is full.
+	waitFor(t, time.Second, func() bool { return calls.Load() == size })
+	time.Sleep(25 * time.Millisecond)
+
+	// A burst of reads is served from the pool without waiting for the authorization endpoint.
+	start := time.Now()
+	seen := map[string]bool{}
+	for i := 0; i < size; i++ {
+		got, err := tok.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[got] {
+			t.Fatalf("single-use token %s served twice", got)
+		}
+		seen[got] = true
+	}
+	if d := time.Since(start); d >= 20*time.Millisecond {
+		t.Fatalf("burst took %v, pool did not serve it", d)
+	}
+
+	// Afterwards, the pool refills to capacity.
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2*size })
+	time.Sleep(50 * time.Millisecond)
+	if n := calls.Load(); n != 2*size {
+		t.Fatalf("pool overfilled: %d calls", n)
+	}
+}
+
+// `waitFor` polls `cond` until it returns true or the timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// A failing endpoint gets the configured backoff, as with a token without a pool.
+func TestWarmPoolBackoff(t *testing.T) {
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	NewToken(ctx, func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "", 0, errors.New("down")
+	}, WithWarmPool(3, 1), WithBackoff(ExponentialBackoff{Initial: 40 * time.Millisecond}), WithLogger(NopLogger))
+	time.Sleep(200 * time.Millisecond)
+	// Retries after 40, 120, and 280ms.
+	if n := calls.Load(); n > 3 {
+		t.Fatalf("want at most 3 calls, got %d", n)
+	}
+}
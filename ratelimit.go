@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The refresh timer depends on the lifespan that the authorization server reports. A server that reports a lifespan of zero or one second, by mistake or under load, would make the loop call it again and again. So would clients that force refreshes in a tight loop. A minimum interval between authorization calls prevents such refresh storms.
+
+// `WithMinRefreshInterval` makes sure that at least `d` passes between the starts of two authorization calls. Scheduled refreshes and retries are delayed accordingly. A `ForceRefresh()` within `d` after the last call does not call the authorization function but returns the current result. By default, there is no minimum interval.
+func WithMinRefreshInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.minRefreshInterval = d
+	}
+}
+
+// Method `minIntervalWait` returns the time until the next authorization call is allowed.
+func (a *Token) minIntervalWait() time.Duration {
+	last := a.lastAttempt.Load()
+	if a.opts.minRefreshInterval <= 0 || last == 0 {
+		return 0
+	}
+	return max(time.Unix(0, last).Add(a.opts.minRefreshInterval).Sub(a.now()), 0)
+}
+
+// Method `rateWait` blocks until the next authorization call is allowed. It returns false if `ctx` is done first.
+func (a *Token) rateWait(ctx context.Context) bool {
+	w := a.minIntervalWait()
+	if w <= 0 {
+		return true
+	}
+	select {
+	case <-a.after(w):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestMinRefreshInterval(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	// A broken server reports a lifespan of zero.
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "tok", 0, nil
+	}
+	tok := NewToken(context.Background(), auth, WithMinRefreshInterval(time.Second), WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+
+	// Forced refreshes within the interval do not reach the server.
+	for i := 0; i < 10; i++ {
+		if err := tok.ForceRefresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 call within the interval, got %d", n)
+	}
+
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(time.Second - time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 call before the interval has passed, got %d", n)
+	}
+	clock.Advance(time.Millisecond)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A `Token` starts fetching in the background, and the constructor returns right away. If the credentials are wrong, the app comes up anyway, and every request it serves fails on `Get()`. Many apps would rather fail at startup. `Start()` waits for the first token, and `WithBlockUntilReady` makes the constructor do so.
+
+// `ErrNotReady` is returned if no token has been obtained yet. Errors returned by `Start()` wrap it, along with the last authorization error, if any.
+var ErrNotReady = errors.New("refresh: no token obtained yet")
+
+// `WithBlockUntilReady` makes the constructor wait until the first token has been fetched successfully, or until `timeout` has passed. As the constructor returns no error, call `Start()` afterward to find out whether the token is ready; it returns immediately.
+// With `WithLazyStart`, the constructor starts the refresh goroutine right away.
+func WithBlockUntilReady(timeout time.Duration) Option {
+	if timeout <= 0 {
+		panic("refresh: WithBlockUntilReady timeout must be positive")
+	}
+	return func(o *options) {
+		o.blockUntilReady = timeout
+	}
+}
+
+// `readiness` records whether a token has ever been obtained.
+type readiness struct {
+	once sync.Once
+	ch   chan struct{}
+	// `fetching` is set once a `NotReadyFail` or `NotReadyFallback` policy has started the first fetch of an on-demand token.
+	fetching atomic.Bool
+}
+
+// A `NotReadyPolicy` defines what `Get()` and its variants do before the first token has been obtained.
+type NotReadyPolicy struct {
+	mode     notReadyMode
+	timeout  time.Duration
+	fallback string
+}
+
+type notReadyMode int
+
+const (
+	notReadyWait notReadyMode = iota
+	notReadyFail
+	notReadyBlock
+	notReadyFallback
+)
+
+// `NotReadyWait` makes `Get` wait for the first authorization attempt and return its result, be it a token or an error. This is the default.
+func NotReadyWait() NotReadyPolicy {
+	return NotReadyPolicy{mode: notReadyWait}
+}
+
+// `NotReadyFail` makes `Get` return `ErrNotReady` right away, wrapping the last authorization error, if any. The token keeps trying in the background.
+func NotReadyFail() NotReadyPolicy {
+	return NotReadyPolicy{mode: notReadyFail}
+}
+
+// `NotReadyBlock` makes `Get` wait up to `timeout` for the first token, through any number of retries. If the time runs out, `Get` returns the error of `Start()`, which wraps `ErrNotReady`.
+func NotReadyBlock(timeout time.Duration) NotReadyPolicy {
+	if timeout <= 0 {
+		panic("refresh: NotReadyBlock timeout must be positive")
+	}
+	return NotReadyPolicy{mode: notReadyBlock, timeout: timeout}
+}
+
+// `NotReadyFallback` makes `Get` return `token` without an error. Use this for APIs that serve anonymous requests with reduced rights, for example. The token keeps trying in the background.
+func NotReadyFallback(token string) NotReadyPolicy {
+	return NotReadyPolicy{mode: notReadyFallback, fallback: token}
+}
+
+// `WithNotReadyPolicy` sets what `Get()` and its variants do as long as no token has ever been obtained. Once the first token has arrived, the policy no longer applies, even if later refreshes fail. The default is `NotReadyWait()`.
+// Tokens with a warm pool ignore this option.
+func WithNotReadyPolicy(p NotReadyPolicy) Option {
+	return func(o *options) {
+		o.notReady = p
+	}
+}
+
+// Method `obtained` reports whether a token has ever been obtained.
+func (a *Token) obtained() bool {
+	select {
+	case <-a.readiness.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Method `notReady` applies the `NotReadyPolicy` before `Get` reads the token. If it returns true, `Get` returns the response instead of reading the token.
+func (a *Token) notReady(ctx context.Context) (tokenResponse, bool) {
+	p := a.opts.notReady
+	if p.mode == notReadyWait || a.opts.poolSize > 0 || a.obtained() {
+		return tokenResponse{}, false
+	}
+	switch p.mode {
+	case notReadyBlock:
+		ctx, cancel := context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+		if err := a.Start(ctx); err != nil {
+			return tokenResponse{Err: err}, true
+		}
+		return tokenResponse{}, false
+	case notReadyFallback:
+		a.startFirstFetch()
+		return tokenResponse{Token: p.fallback}, true
+	default:
+		a.startFirstFetch()
+		if err := a.stats.snapshot().LastError; err != nil {
+			return tokenResponse{Err: fmt.Errorf("%w: %w", ErrNotReady, err)}, true
+		}
+		return tokenResponse{Err: ErrNotReady}, true
+	}
+}
+
+// Method `startFirstFetch` makes sure that the first token is being fetched without blocking the caller. A token with a refresh goroutine fetches it there. An on-demand token, having no goroutine, gets one that calls `Start()` and ends with the first token.
+func (a *Token) startFirstFetch() {
+	if !a.onDemand() {
+		a.ensureStarted()
+		return
+	}
+	if a.readiness.fetching.Swap(true) || !a.track() {
+		return
+	}
+	go func() {
+		defer a.cleanup.Done()
+		a.Start(a.runCtx)
+	}()
+}
+
+// Method `markReady` records that a token has been obtained.
+func (a *Token) markReady() {
+	a.readiness.once.Do(func() { close(a.readiness.ch) })
+}
+
+// Method `Start` starts the token, if it has not been started yet, and waits until a token has been fetched successfully. It returns nil once a token was obtained, even if the token has expired since. If `ctx` is done first, `Start` returns an error that wraps `ErrNotReady` and the last authorization error, or the context's error if there was no attempt yet. The token keeps trying in the background either way.
+// For a token that refreshes on demand, `Start` fetches the token itself and retries as scheduled.
+func (a *Token) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-a.readiness.ch:
+			return nil
+		default:
+		}
+		wait := time.Duration(0)
+		if a.onDemand() {
+			if resp := a.getOnDemand(ctx, false); resp.Err == nil {
+				return nil
+			}
+			wait = a.onDemandRetry()
+		} else {
+			a.ensureStarted()
+		}
+		var retry <-chan time.Time
+		if wait > 0 {
+			retry = a.after(wait)
+		}
+		select {
+		case <-a.readiness.ch:
+			return nil
+		case <-retry:
+		case <-a.closing():
+			return ErrClosed
+		case <-ctx.Done():
+			if err := a.stats.snapshot().LastError; err != nil {
+				return fmt.Errorf("%w: %w", ErrNotReady, err)
+			}
+			return fmt.Errorf("%w: %w", ErrNotReady, ctx.Err())
+		}
+	}
+}
+
+// Method `onDemandRetry` returns the time until an on-demand token is due for another attempt.
+func (a *Token) onDemandRetry() time.Duration {
+	s := &a.onDemandState
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return max(s.refreshAt.Sub(a.now()), time.Millisecond)
+}
+
+// Method `blockUntilReady` implements `WithBlockUntilReady`. The constructors call it after starting the token.
+func (a *Token) blockUntilReady(ctx context.Context) {
+	if a.opts.blockUntilReady <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.opts.blockUntilReady)
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		a.logEvent(ctx, EventRefreshError, "Token not ready at startup", "err", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	for _, mode := range []struct {
+		name string
+		opt  Option
+	}{
+		{"lazy", WithLazyStart()},
+		{"on-demand", WithOnDemandRefresh()},
+		{"pool", WithWarmPool(2, 1)},
+	} {
+		t.Run(mode.name, func(t *testing.T) {
+			var calls atomic.Int32
+			tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+				calls.Add(1)
+				return "tok", time.Hour, nil
+			}, mode.opt, WithLogger(NopLogger))
+			defer tok.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := tok.Start(ctx); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if calls.Load() == 0 {
+				t.Fatal("Start did not fetch a token")
+			}
+		})
+	}
+}
+
+// `Start` gives up when its context is done and reports the last authorization error.
+func TestStartTimeout(t *testing.T) {
+	errAuth := errors.New("bad credentials")
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, errAuth
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := tok.Start(ctx)
+	if !errors.Is(err, ErrNotReady) || !errors.Is(err, errAuth) {
+		t.Fatalf("want ErrNotReady wrapping the authorization error, got %v", err)
+	}
+}
+
+// With `WithBlockUntilReady`, the constructor returns only after the first token has arrived.
+func TestBlockUntilReady(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		time.Sleep(20 * time.Millisecond)
+		return "tok", time.Hour, nil
+	}, WithBlockUntilReady(time.Second), WithLogger(NopLogger))
+	defer tok.Close()
+	if got, ok := tok.TryGet(); !ok || got != "tok" {
+		t.Fatalf("want tok right after the constructor returned, got %q, %v", got, ok)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tok.Start(ctx); err != nil {
+		t.Fatalf("Start after ready: %v", err)
+	}
+}
+
+// Before the first token arrives, each policy answers in its own way. Afterward, all of them serve the token.
+func TestNotReadyPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    NotReadyPolicy
+		opts      []Option
+		wantToken string
+		wantErr   error
+	}{
+		{"fail", NotReadyFail(), nil, "", ErrNotReady},
+		{"fail on demand", NotReadyFail(), []Option{WithOnDemandRefresh()}, "", ErrNotReady},
+		{"fallback", NotReadyFallback("anonymous"), nil, "anonymous", nil},
+		{"fallback on demand", NotReadyFallback("anonymous"), []Option{WithOnDemandRefresh()}, "anonymous", nil},
+		{"block", NotReadyBlock(20 * time.Millisecond), nil, "", ErrNotReady},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			tok := NewTokenContext(context.Background(), func(ctx context.Context) (string, time.Duration, error) {
+				select {
+				case <-release:
+					return "tok", time.Hour, nil
+				case <-ctx.Done():
+					return "", 0, ctx.Err()
+				}
+			}, append([]Option{WithNotReadyPolicy(tt.policy), WithLogger(NopLogger)}, tt.opts...)...)
+			defer tok.Close()
+			got, err := tok.Get()
+			if got != tt.wantToken || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want (%q, %v), got (%q, %v)", tt.wantToken, tt.wantErr, got, err)
+			}
+			close(release)
+			waitFor(t, time.Second, func() bool {
+				got, err := tok.GetContext(context.Background())
+				return got == "tok" && err == nil
+			})
+		})
+	}
+}
+
+// `NotReadyFail` reports the last authorization error, and `NotReadyBlock` waits through failed attempts.
+func TestNotReadyPolicyRetries(t *testing.T) {
+	errDown := errors.New("down")
+	newAuth := func(failures int32) func() (string, time.Duration, error) {
+		var calls atomic.Int32
+		return func() (string, time.Duration, error) {
+			if calls.Add(1) <= failures {
+				return "", 0, errDown
+			}
+			return "tok", time.Hour, nil
+		}
+	}
+
+	tok := NewToken(context.Background(), newAuth(1000), WithNotReadyPolicy(NotReadyFail()), WithLogger(NopLogger))
+	defer tok.Close()
+	waitFor(t, time.Second, func() bool { return tok.Stats().LastError != nil })
+	if _, err := tok.Get(); !errors.Is(err, ErrNotReady) || !errors.Is(err, errDown) {
+		t.Fatalf("want ErrNotReady wrapping the authorization error, got %v", err)
+	}
+
+	tok = NewToken(context.Background(), newAuth(2), WithNotReadyPolicy(NotReadyBlock(time.Second)), WithLogger(NopLogger))
+	defer tok.Close()
+	if got, err := tok.Get(); got != "tok" || err != nil {
+		t.Fatalf("want tok, got (%q, %v)", got, err)
+	}
+}
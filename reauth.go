@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MQTT sessions, WebSockets, and other long-lived connections authenticate once, when they connect. When the token rotates, some protocols let the client re-authenticate on the open connection (MQTT 5's AUTH packet, a "reauth" message on a WebSocket). Others have the server close the connection when the token expires. Either way, each connection owner would need the same plumbing: notice new tokens, send them, retry failed attempts, and give up before the old token expires. `KeepAuthenticated` is that plumbing. The connection owner only provides the function that sends a token over the connection.
+
+// `ErrReauthFailed` is wrapped by the error of `KeepAuthenticated` when a new token could not be sent in time.
+var ErrReauthFailed = errors.New("refresh: re-authentication failed")
+
+// `ReauthPolicy` tunes `KeepAuthenticated`.
+type ReauthPolicy struct {
+	// Timeout bounds each call of the re-authentication function. Zero means 10 seconds.
+	Timeout time.Duration
+	// Backoff decides how long to wait before retrying a failed re-authentication, and when to give up. Nil means exponential backoff from one second up to 30 seconds.
+	Backoff BackoffPolicy
+}
+
+// Method `KeepAuthenticated` calls `reauth` with each new token until `ctx` is done or the token is closed. `current` is the token that the connection is authenticated with. If the token has changed already, `reauth` gets called right away.
+// If `reauth` fails, `KeepAuthenticated` retries it as the policy's backoff says, but only as long as the token that the connection uses is valid. When retries run out, `KeepAuthenticated` returns an error that wraps `ErrReauthFailed` and the last error of `reauth`, and the connection owner should reconnect. Failed refreshes do not call `reauth`: the connection keeps its token until a new one arrives.
+// Run one `KeepAuthenticated` per connection, in its own goroutine, with a context that ends when the connection closes. It returns `ctx.Err()` when `ctx` is done and `ErrClosed` when the token gets closed.
+func (a *Token) KeepAuthenticated(ctx context.Context, current string, reauth func(ctx context.Context, token string) error, p ReauthPolicy) error {
+	if p.Timeout <= 0 {
+		p.Timeout = 10 * time.Second
+	}
+	if p.Backoff == nil {
+		p.Backoff = ExponentialBackoff{Initial: time.Second, Max: 30 * time.Second}
+	}
+	// `validUntil` is the expiration time of `current`, the token that the connection uses, as far as it is known. The token's own `exp` claim is the most reliable source. Otherwise, it is the expiry that the `Token` reports along with `current`. If `current` is older than the first token that `watch` returns, its expiry remains unknown, and only the backoff limits the retries.
+	var validUntil time.Time
+	if exp, _, err := JWTClaims(current); err == nil {
+		validUntil = exp
+	}
+	var version uint64
+	for {
+		resp, v, err := a.watch(ctx, version)
+		if v == 0 {
+			return err
+		}
+		version = v
+		if resp.Err != nil {
+			continue
+		}
+		if resp.Token == current {
+			if validUntil.IsZero() {
+				validUntil = resp.ExpiresAt
+			}
+			continue
+		}
+		if err := a.reauthenticate(ctx, resp.Token, reauth, p, validUntil); err != nil {
+			return err
+		}
+		current, validUntil = resp.Token, resp.ExpiresAt
+	}
+}
+
+// Method `reauthenticate` calls `reauth` with `token` until it succeeds, the backoff policy gives up, or the next attempt would come after `validUntil`.
+func (a *Token) reauthenticate(ctx context.Context, token string, reauth func(ctx context.Context, token string) error, p ReauthPolicy, validUntil time.Time) error {
+	start := a.now()
+	for attempt := 1; ; attempt++ {
+		actx, cancel := context.WithTimeout(ctx, p.Timeout)
+		err := reauth(actx, token)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		d, ok := p.Backoff.NextDelay(attempt, a.now().Sub(start))
+		if !ok || (!validUntil.IsZero() && a.now().Add(d).After(validUntil)) {
+			return fmt.Errorf("%w after %d attempts: %w", ErrReauthFailed, attempt, err)
+		}
+		select {
+		case <-a.after(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.closing():
+			return ErrClosed
+		}
+	}
+}
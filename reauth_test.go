@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepAuthenticated(t *testing.T) {
+	var n atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return fmt.Sprintf("tok%d", n.Add(1)), time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	current, _ := tok.Get()
+
+	sent := make(chan string, 10)
+	var failures atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tok.KeepAuthenticated(ctx, current, func(_ context.Context, token string) error {
+			// The first attempt for each token fails, the retry succeeds.
+			if failures.Add(1)%2 == 1 {
+				return errors.New("broker busy")
+			}
+			sent <- token
+			return nil
+		}, ReauthPolicy{Backoff: ExponentialBackoff{Initial: time.Millisecond}})
+	}()
+
+	for _, want := range []string{"tok2", "tok3"} {
+		tok.ForceRefresh(context.Background())
+		select {
+		case got := <-sent:
+			if got != want {
+				t.Fatalf("want %s, got %s", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s not sent", want)
+		}
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestKeepAuthenticatedGivesUp(t *testing.T) {
+	var n atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return fmt.Sprintf("tok%d", n.Add(1)), time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+
+	var attempts atomic.Int32
+	// The connection still uses an older token, so the first token triggers a re-authentication.
+	err := tok.KeepAuthenticated(context.Background(), "tok0", func(context.Context, string) error {
+		attempts.Add(1)
+		return errors.New("session gone")
+	}, ReauthPolicy{Backoff: ExponentialBackoff{Initial: time.Millisecond, MaxAttempts: 2}})
+	if !errors.Is(err, ErrReauthFailed) || attempts.Load() != 3 {
+		t.Fatalf("want ErrReauthFailed after 3 attempts, got %v after %d", err, attempts.Load())
+	}
+}
+
+// The retries stop when the token that the connection uses expires, not when the newest token expires.
+func TestKeepAuthenticatedCurrentExpiry(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "new", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+
+	current := makeJWT(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(time.Second).Unix()))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := tok.KeepAuthenticated(ctx, current, func(context.Context, string) error {
+		return errors.New("session gone")
+	}, ReauthPolicy{Backoff: ExponentialBackoff{Initial: 100 * time.Millisecond, Max: 100 * time.Millisecond}})
+	if !errors.Is(err, ErrReauthFailed) {
+		t.Fatalf("want ErrReauthFailed before the connection's token expires, got %v", err)
+	}
+}
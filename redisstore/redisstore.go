@@ -0,0 +1,216 @@
+// Package redisstore is a minimal Redis client for sharing tokens between instances of a service. It implements just the commands that a shared token store needs: GET, SET with NX and PX, and an atomic compare-and-delete for releasing locks.
+//
+// The package uses only the standard library and speaks the RESP2 protocol over a single connection. It is not a general-purpose Redis client.
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends commands to a Redis server. The zero value is not usable; set at least Addr. A Client is safe for concurrent use. Commands are serialized over one connection, which gets re-established after an error.
+type Client struct {
+	// Addr is the server's host:port.
+	Addr string
+	// Password, if not empty, authenticates the connection.
+	Password string
+	// DB selects a database other than 0.
+	DB int
+	// Dial opens the connection. Nil means a TCP connection to Addr. Use it for TLS.
+	Dial func(ctx context.Context) (net.Conn, error)
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// ErrorReply is an error reply of the server.
+type ErrorReply string
+
+func (e ErrorReply) Error() string { return "redisstore: " + string(e) }
+
+// ErrProtocol is returned if the server's reply cannot be parsed.
+var ErrProtocol = errors.New("redisstore: protocol error")
+
+// Do sends a command and returns its reply: a string for simple and bulk strings, an int64 for integers, nil for a null reply, or a []any for arrays.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(ctx, args)
+	var er ErrorReply
+	if err != nil && !errors.As(err, &er) {
+		// The connection is in an unknown state. Start over with the next command.
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// connect dials the server and sends AUTH and SELECT if needed.
+func (c *Client) connect(ctx context.Context) error {
+	dial := c.Dial
+	if dial == nil {
+		dial = func(ctx context.Context) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", c.Addr)
+		}
+	}
+	conn, err := dial(ctx)
+	if err != nil {
+		return fmt.Errorf("redisstore: %w", err)
+	}
+	c.conn, c.rd = conn, bufio.NewReader(conn)
+	var setup [][]string
+	if c.Password != "" {
+		setup = append(setup, []string{"AUTH", c.Password})
+	}
+	if c.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.DB)})
+	}
+	for _, cmd := range setup {
+		if _, err := c.roundTrip(ctx, cmd); err != nil {
+			conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a command and reads the reply, within the deadline of ctx.
+func (c *Client) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("redisstore: %w", err)
+	}
+	return readReply(c.rd)
+}
+
+// readReply parses one RESP2 reply.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redisstore: %w", err)
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, ErrProtocol
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, ErrorReply(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, fmt.Errorf("redisstore: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]any, n)
+		for i := range arr {
+			if arr[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, ErrProtocol
+}
+
+// Get returns the value of key, and false if key does not exist.
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	r, err := c.Do(ctx, "GET", key)
+	if err != nil || r == nil {
+		return "", false, err
+	}
+	s, ok := r.(string)
+	if !ok {
+		return "", false, ErrProtocol
+	}
+	return s, true, nil
+}
+
+// Set sets key to value with a time to live of ttl.
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "SET", key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// SetNX sets key to value with a time to live of ttl, but only if key does not exist. It reports whether key was set.
+func (c *Client) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	r, err := c.Do(ctx, "SET", key, value, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return r != nil, err
+}
+
+// compareAndDelete deletes KEYS[1] if its value equals ARGV[1].
+const compareAndDelete = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// compareAndExpire sets the TTL of KEYS[1] to ARGV[2] milliseconds if its value equals ARGV[1].
+const compareAndExpire = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+// ExpireIfEqual sets the time to live of key to ttl if its value is value, atomically. It reports whether the time to live was set. This extends a lock only if the caller still holds it.
+func (c *Client) ExpireIfEqual(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	r, err := c.Do(ctx, "EVAL", compareAndExpire, "1", key, value, strconv.FormatInt(ttl.Milliseconds(), 10))
+	n, _ := r.(int64)
+	return n == 1, err
+}
+
+// DeleteIfEqual deletes key if its value is value, atomically. It reports whether key was deleted. This releases a lock only if the caller still holds it.
+func (c *Client) DeleteIfEqual(ctx context.Context, key, value string) (bool, error) {
+	r, err := c.Do(ctx, "EVAL", compareAndDelete, "1", key, value)
+	n, _ := r.(int64)
+	return n == 1, err
+}
@@ -0,0 +1,79 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestClient(t *testing.T) {
+	srv, err := refreshtest.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	c := &Client{Addr: srv.Addr(), Password: "pw", DB: 1}
+	defer c.Close()
+	ctx := context.Background()
+
+	if _, ok, err := c.Get(ctx, "k"); ok || err != nil {
+		t.Fatalf("want missing key, got (%v, %v)", ok, err)
+	}
+	if err := c.Set(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := c.Get(ctx, "k"); v != "v" || !ok || err != nil {
+		t.Fatalf("want v, got (%q, %v, %v)", v, ok, err)
+	}
+
+	if ok, err := c.SetNX(ctx, "lock", "me", time.Minute); !ok || err != nil {
+		t.Fatalf("want lock, got (%v, %v)", ok, err)
+	}
+	if ok, _ := c.SetNX(ctx, "lock", "you", time.Minute); ok {
+		t.Fatal("lock acquired twice")
+	}
+	if ok, err := c.ExpireIfEqual(ctx, "lock", "me", time.Hour); !ok || err != nil {
+		t.Fatalf("want extended lock, got (%v, %v)", ok, err)
+	}
+	if ok, _ := c.ExpireIfEqual(ctx, "lock", "you", time.Hour); ok {
+		t.Fatal("lock extended by non-holder")
+	}
+	if ok, _ := c.DeleteIfEqual(ctx, "lock", "you"); ok {
+		t.Fatal("lock released by non-holder")
+	}
+	if ok, err := c.DeleteIfEqual(ctx, "lock", "me"); !ok || err != nil {
+		t.Fatalf("want release, got (%v, %v)", ok, err)
+	}
+
+	var er ErrorReply
+	if _, err := c.Do(ctx, "FLUSHALL"); !errors.As(err, &er) {
+		t.Fatalf("want error reply, got %v", err)
+	}
+	// An error reply leaves the connection usable.
+	if _, ok, err := c.Get(ctx, "k"); !ok || err != nil {
+		t.Fatalf("want k after error reply, got (%v, %v)", ok, err)
+	}
+}
+
+func TestClientExpiry(t *testing.T) {
+	srv, err := refreshtest.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	var offset atomic.Int64
+	srv.Now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+	c := &Client{Addr: srv.Addr()}
+	defer c.Close()
+	ctx := context.Background()
+
+	c.Set(ctx, "k", "v", time.Second)
+	offset.Store(int64(2 * time.Second))
+	if _, ok, _ := c.Get(ctx, "k"); ok {
+		t.Fatal("key did not expire")
+	}
+}
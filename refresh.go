@@ -173,14 +173,16 @@ package main
 // None of these packages (except `time`) are actually required for the token refreshing code. They are used by the code that simulates the token refresh API, the test code, and for printing out what's going on.
 import (
 	"context"
-	"crypto/rand"
+	"errors"
 	"fmt"
 	"log"
-	rnd "math/rand"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
 )
 
 const (
@@ -194,146 +196,551 @@ const (
 // The authorization API returns either a token or an error. We collect either of these in a `tokenResponse` and pass the result on to the client.
 type tokenResponse struct {
 	Token string
-	Err   error
+	// `Key` is the signing key that was issued along with `Token`, if any. See `signingkey.go`.
+	Key []byte
+	Err error
+	// `ExpiresAt` is when `Token` expires, or zero if unknown. See `invariant.go`.
+	ExpiresAt time.Time
+	// `FetchedAt` is when `Token` was fetched, or zero if unknown. See `maxage.go`.
+	FetchedAt time.Time
 }
 
 // `Token` represents an access token. It refreshes itself in the background by calling the API's authorization endpoint before the current token expires.
 type Token struct {
 	// The `accessToken` channel is used to send the new access token to the client.
 	accessToken chan tokenResponse
-	// The `authorize` field allows setting a custom authorization function that implements the call to the actual authorization endpoint.
-	authorize func() (string, time.Duration, error)
+	// The `authorize` field allows setting a custom authorization function that implements the call to the actual authorization endpoint. It receives the refresher's context, so that `Close()` can abort a hanging call (see `authctx.go`).
+	authorize func(ctx context.Context) (string, time.Duration, error)
+	// `authorizeWithKey` replaces `authorize` for tokens that come with a signing key. See `signingkey.go`.
+	authorizeWithKey func(ctx context.Context) (string, []byte, time.Duration, error)
+	// `cached` is a copy of the last good token for `TryGet()`. See `get.go`.
+	cached atomic.Pointer[cachedToken]
+	// `current`, `ready`, and `readyOnce` implement the lock-free read path. See `fastpath.go`.
+	current   atomic.Pointer[tokenResponse]
+	ready     chan struct{}
+	readyOnce sync.Once
+	// `forces` delivers requests for an immediate refresh to the refresh goroutine. See `force.go`.
+	forces chan forceRequest
+	// `generation` counts completed refresh attempts, and `swapSeq` numbers calls to `SwapAuthorizer()`. `lastSwap` is the number of the last swap that the refresh goroutine applied. See `coalesce.go`.
+	generation atomic.Uint64
+	swapSeq    atomic.Uint64
+	lastSwap   uint64
+	// `cancel` stops the refresh goroutine, and `done` is closed when it and every goroutine in `cleanup` have stopped. See `close.go`.
+	cancel  context.CancelFunc
+	done    chan struct{}
+	cleanup sync.WaitGroup
+	// `runCtx`, `runMu`, `running`, `closed`, and `stopped` track the refresh goroutine, which may start late and stop early. See `lazy.go` and `idle.go`.
+	runCtx  context.Context
+	runMu   sync.Mutex
+	running atomic.Bool
+	closed  bool
+	stopped chan struct{}
+	// `readiness` is closed when the first token has been obtained. See `ready.go`.
+	readiness readiness
+	// `fatal` ends `Run()` with a permanent error. See `runloop.go`.
+	fatal func(error)
+	// `paused` stops refreshes, and `resumed` wakes the refresh goroutine when they resume. See `pause.go`.
+	paused  atomic.Bool
+	resumed chan struct{}
+	// `waiters` queues the readers that arrive during a refresh. See `waiters.go`.
+	waiters waitQueue
+	// `onDemandState` replaces the refresh goroutine in on-demand mode. See `ondemand.go`.
+	onDemandState onDemandState
+	// `breaker` is the state of the circuit breaker. See `circuit.go`.
+	breaker circuitBreaker
+	// `lastAttempt` is the start time of the last authorization call, in Unix nanoseconds. See `ratelimit.go`.
+	lastAttempt atomic.Int64
+	// `subs` holds the subscribers to token updates. See `subscribe.go`.
+	subs subscribers
+	// `restoreTried` records whether the first fetch tried the store already. See `store.go`.
+	restoreTried atomic.Bool
+	// `initialTried` records whether the first fetch tried the initial value. See `initial.go`.
+	initialTried atomic.Bool
+	// `primaryFailed` is the time of the last failure of the primary authorization function, in Unix nanoseconds, or zero. See `fallback.go`.
+	primaryFailed atomic.Int64
+	// `sharedSeen` is the last token that this process fetched or loaded. See `shared.go`.
+	sharedSeen atomic.Pointer[string]
+	// `leader` is true while this token holds the lease of its coordinator. See `coordinator.go`.
+	leader atomic.Bool
+	// `lastUsed` is the time of the last read, in Unix nanoseconds. Only tokens with an idle timeout track it.
+	lastUsed atomic.Int64
+	// `refreshDue` is the time of the refresh scheduled after the last success, in Unix nanoseconds, or zero. See `imminent.go`.
+	refreshDue atomic.Int64
+	// `hooks` runs the `OnRefresh` and `OnError` callbacks outside the refresh loop. See `hooks.go`.
+	hooks *hookQueue
+	// `swaps` delivers replacement authorization functions to the refresh goroutine. See `swap.go`.
+	swaps chan swap
+	// `reconfigs` delivers new refresh settings to the refresh goroutine. See `tune.go`.
+	reconfigs chan reconfigRequest
+	// The `opts` field holds the settings passed to `NewToken` as `Option`s. See `options.go`.
+	opts options
+	// `timings` keeps a bounded history of authorization calls. See `timings.go`.
+	timings timingLog
+	// `stats` counts refreshes, failures, and reads. See `stats.go`.
+	stats statsRecorder
+	// `windowStart` records when the current series of failed refresh attempts began, and `attempts` counts the attempts. Only the `refreshToken` goroutine, or `poolLoop` in its place, touches them.
+	windowStart time.Time
+	attempts    int
+	// `lastGood`, `lastGoodUntil`, and `staleErr` support serving a stale token. Only the `refreshToken` goroutine touches them. See `stale.go`.
+	lastGood      tokenResponse
+	lastGoodUntil time.Time
+	staleErr      error
 }
 
 // Method `refreshToken` fetches a new access token from the authorization API if there is none yet or if the current one expires. It sends the results (a token or an error) to the `accessToken` channel.
 func (a *Token) refreshToken(ctx context.Context) {
-	var token string
-	var expiration time.Duration
-	var err error
+	var resp tokenResponse
+	var expiration, next time.Duration
 
 	// Set the initial token, before any client can request it.
-	// `authorize()` is defined below. Its purpose is to fetch a new token from an authorization endpoint, including the token's lifespan.
-	token, expiration, err = a.authorize()
+	// `fetch()` is defined below. It calls `authorize()`, whose purpose is to fetch a new token from an authorization endpoint, including the token's lifespan.
+	// With `WithTracerProvider`, each attempt gets its own span (see `tracing.go`).
+	// Readers that arrive during a refresh wait in a queue and receive the result all at once (see `waiters.go`).
+	a.beginRefresh()
+	sctx, span := a.startSpan(ctx)
+	resp, expiration = a.fetch(sctx)
+	a.generation.Add(1)
+	a.cache(resp, expiration)
+	if resp.Err != nil {
+		a.logEvent(ctx, EventRefreshError, "Error fetching initial token", "err", resp.Err)
+	}
 
-	// Set a new timer to fire when 90% of the expiration duration has passed. We want a new token *before* the current one expires.
-	expired := time.After(expiration - lifeSpanSafetyMargin)
+	// Set a new timer to fire shortly before the token expires. We want a new token *before* the current one expires.
+	// `schedule()` is defined below. It also takes care of retries if there is no token.
+	next, resp.Err = a.schedule(ctx, expiration, resp.Err)
+	a.endSpan(span, next, resp.Err)
+	expired := a.after(next)
+	// With `WithStaleOnError`, a failed refresh keeps serving the previous token until it expires, and `staleEnd` fires when it does. See `stale.go`.
+	var staleEnd <-chan time.Time
+	resp, staleEnd = a.stale(resp, expiration, staleEnd)
+	// Subscribers receive every new result. See `subscribe.go`.
+	a.broadcast(resp)
+	a.endRefresh(resp)
+	// With `WithIdleTimeout`, `idle` fires when nobody may have read the token for a while. See `idle.go`.
+	idle := a.idleTimer()
+
+	// The `refresh` closure runs when the timer has fired or when a client forces a refresh. It fetches a new token and sets a new timer.
+	refresh := func(ev EventType, msg string) {
+		a.logEvent(ctx, ev, msg)
+		a.beginRefresh()
+		sctx, span := a.startSpan(ctx)
+		resp, expiration = a.fetch(sctx)
+		a.generation.Add(1)
+		a.cache(resp, expiration)
+		if resp.Err != nil {
+			a.logEvent(ctx, EventRefreshError, "Error refreshing token", "err", resp.Err)
+		} else {
+			a.logEvent(ctx, EventRefresh, "Token refreshed", "token", Secret(resp.Token), "expires_at", a.now().Add(expiration))
+		}
+		// Set a new timer to fire shortly before the new token expires, or, if the token could not be refreshed, to fire when the retry delay has passed.
+		next, resp.Err = a.schedule(ctx, expiration, resp.Err)
+		a.endSpan(span, next, resp.Err)
+		expired = a.after(next)
+		resp, staleEnd = a.stale(resp, expiration, staleEnd)
+		a.broadcast(resp)
+		a.endRefresh(resp)
+	}
+
+	// While refreshes are paused, a timer that fires only marks the refresh as `deferred`. See `pause.go`.
+	deferred := false
+	due := func() {
+		if a.paused.Load() {
+			deferred = true
+			return
+		}
+		refresh(EventExpired, "Token expired")
+	}
 
 	for {
+		// With `WithFastPath`, readers do not use the `accessToken` channel but load the latest `resp` from an atomic pointer. See `fastpath.go`.
+		a.publish(resp)
+
+		// The `select` statement below picks a random case among all ready ones. Under extreme `Get()` load, the timer case competes with an endless stream of readers and may lose many times in a row. Therefore, a fired timer marks a refresh as pending, and a pending refresh is handled before any more reads are served.
+		// A token with a very short lifespan may keep the timer firing. Once the context is canceled, the main `select` must get its turn, or the loop would never stop.
+		select {
+		case <-expired:
+			if ctx.Err() == nil {
+				due()
+				continue
+			}
+		default:
+		}
+
 		select {
 		// When a client requests a token, this `case` condition writes one to the `accessToken` channel. It does nothing else, hence the body of the case is empty.
-		case a.accessToken <- tokenResponse{Token: token, Err: err}:
+		case a.accessToken <- resp:
 
 		// The expiration timer has fired and wrote the current time to `expired`.
 		case <-expired:
-			// Refresh the token.
-			log.Println("Token expired")
-			token, expiration, err = a.authorize()
-			if err != nil {
-				log.Println("Error refreshing token:", err)
-				// If the token cannot be fetched, retry frequently instead of waiting for the token's normal timeout (which could be minutes away).
-				expiration = retryDelay
-			} else {
-				log.Println("Token refreshed")
+			due()
+
+		// Refreshes have been resumed. A refresh that fell due while they were paused happens now.
+		case <-a.resumed:
+			if deferred && !a.paused.Load() {
+				deferred = false
+				refresh(EventExpired, "Refreshes resumed; token expired meanwhile")
 			}
-			// Set a new timer to fire when 90% of the expiration duration has passed, or, if the token could not be refreshed, to fire when the retry delay has passed.
-			expired = time.After(expiration - lifeSpanSafetyMargin)
+
+		// A client has asked for an immediate refresh. All clients that ask while the refresh is in progress share its result. See `force.go`.
+		// If a refresh has completed since the request was made, that refresh has already replaced the token that the client wanted to get rid of. A request made before the initial token arrived always refreshes. See `coalesce.go`. A request within the minimum refresh interval does not refresh either (see `ratelimit.go`).
+		case req := <-a.forces:
+			if a.paused.Load() {
+				req.reply <- ErrPaused
+				a.answerForces(ErrPaused)
+				continue
+			}
+			if (req.gen == 0 || req.gen == a.generation.Load()) && a.minIntervalWait() == 0 {
+				refresh(EventForceRefresh, "Token refresh forced")
+			}
+			req.reply <- resp.Err
+			a.answerForces(resp.Err)
+
+		// A new authorization function replaces the current one, along with a token that it already delivered. See `swap.go`.
+		case s := <-a.swaps:
+			// A swap that was overtaken by a later one must not undo it.
+			if s.seq < a.lastSwap {
+				s.applied <- false
+				continue
+			}
+			a.lastSwap = s.seq
+			s.applied <- true
+			a.generation.Add(1)
+			a.authorize = s.authorize
+			resp, expiration = s.resp, s.lifespan
+			a.cache(resp, expiration)
+			next, resp.Err = a.schedule(ctx, expiration, resp.Err)
+			expired = a.after(next)
+			resp, staleEnd = a.stale(resp, expiration, staleEnd)
+			a.broadcast(resp)
+			a.logEvent(ctx, EventRefresh, "Authorization function replaced")
+
+		// New refresh settings have arrived. A valid token gets its refresh rescheduled from the time it was fetched. A pending retry keeps its delay. See `tune.go`.
+		case r := <-a.reconfigs:
+			a.applyTuning(r.opts)
+			if resp.Err == nil && a.staleErr == nil && !resp.FetchedAt.IsZero() {
+				lifespan := resp.ExpiresAt.Sub(resp.FetchedAt)
+				next = a.jitter(a.refreshAfter(lifespan), a.opts.refreshJitter) - a.now().Sub(resp.FetchedAt)
+				expired = a.after(max(next, a.minIntervalWait(), 0))
+			}
+			close(r.done)
+			a.logEvent(ctx, EventRefresh, "Refresh settings changed")
+
+		// The stale token that was served after a failed refresh has expired. Now, clients get the error.
+		case <-staleEnd:
+			resp, staleEnd = tokenResponse{Err: a.staleErr}, nil
+			a.broadcast(resp)
+			a.logEvent(ctx, EventRefreshError, "Stale token expired", "err", a.staleErr)
+
+		// Readers may have come by since the timer was set. Stop only if they have not.
+		case <-idle:
+			if a.idleFor() >= a.idleTimeout() {
+				a.logEvent(ctx, EventIdle, "Token refresher idle; stopping", "idle", a.idleFor())
+				return
+			}
+			idle = a.idleTimer()
 
 		// The context has been canceled. Stop the goroutine.
 		case <-ctx.Done():
+			a.logEvent(ctx, EventClose, "Token refresher stopped")
 			return
 		}
 	}
 }
 
-// The Token constructor receives the authorization function to call. It takes care of spawning the goroutine that refreshes the token in the background.
-func NewToken(ctx context.Context, auth func() (string, time.Duration, error)) *Token {
+// Method `fetch` wraps the call to `authorize()`. It is the central place for the bookkeeping and checks around each call.
+func (a *Token) fetch(ctx context.Context) (tokenResponse, time.Duration) {
+	// With `WithCircuitBreaker`, an open circuit prevents the call. See `circuit.go`.
+	if err := a.circuitAllow(); err != nil {
+		return tokenResponse{Err: err}, 0
+	}
+	defer a.circuitEndProbe()
+	// With `WithInitialValue`, the first fetch returns the given token. See `initial.go`.
+	if resp, lifespan, ok := a.initialValue(ctx); ok {
+		return resp, lifespan
+	}
+	// With `WithStore`, the first fetch may restore the token of a previous run. See `store.go`.
+	if resp, lifespan, ok := a.restore(ctx); ok {
+		return resp, lifespan
+	}
+	// With `WithSharedStore`, another process may have fetched the token already. See `shared.go`.
+	if a.opts.shared != nil {
+		resp, lifespan, release, ok := a.coordinate(ctx)
+		if ok {
+			return resp, lifespan
+		}
+		defer release()
+	}
+	// With `WithCoordinator`, only the leader fetches. See `coordinator.go`.
+	if a.opts.coordinator != nil {
+		if resp, lifespan, ok := a.follow(ctx); ok {
+			return resp, lifespan
+		}
+	}
+	a.lastAttempt.Store(a.now().UnixNano())
+	authorize := withoutKey(a.authorize)
+	if a.authorizeWithKey != nil {
+		authorize = a.authorizeWithKey
+	}
+	// With `WithFallbackAuthorizers`, other authorization functions step in if this one fails. See `fallback.go`.
+	resp, lifespan := a.fetchWithFallback(ctx, authorize)
+	resp.Err = a.circuitRecord(resp.Err)
+	return resp, lifespan
+}
+
+// Method `fetchWith` does the work of `fetch()` for a given authorization function.
+func (a *Token) fetchWith(ctx context.Context, authorize func(context.Context) (string, []byte, time.Duration, error)) (tokenResponse, time.Duration) {
+	start := a.now()
+	token, key, lifespan, err := a.callAuthorize(ctx, authorize)
+	if err == nil && a.opts.jwtExpiry {
+		lifespan, err = a.jwtLifespan(token, lifespan)
+	}
+	a.timings.record(start, a.now().Sub(start), lifespan, err)
+	// `authorize()` might return a token along with an error. The configured policy decides which of the two wins. A token without a positive lifespan is expired already, so the call counts as failed regardless of the policy.
+	if err != nil && token != "" {
+		if a.opts.errorWithToken == ErrorWithTokenServe && lifespan > 0 {
+			a.logEvent(ctx, EventErrorWithToken, "Serving token despite authorization error", "err", err)
+			err = nil
+		} else {
+			token = ""
+		}
+	}
+	if err != nil {
+		a.stats.failure(a.now(), err)
+		a.notifyError(err)
+		return tokenResponse{Token: token, Err: err, ExpiresAt: start.Add(lifespan), FetchedAt: start}, lifespan
+	}
+	// If a validator is configured, a token that fails validation counts as a failed refresh and never reaches any client.
+	if a.opts.validate != nil {
+		if err := a.opts.validate(token); err != nil {
+			err = fmt.Errorf("%w: %w", ErrInvalidToken, err)
+			a.stats.failure(a.now(), err)
+			a.notifyError(err)
+			return tokenResponse{Err: err}, lifespan
+		}
+	}
+	// If a warmup request is configured, the token only counts as valid after the request succeeded.
+	if a.opts.warmup != nil {
+		if err := a.opts.warmup(ctx, token); err != nil {
+			err = fmt.Errorf("%w: %w", ErrWarmupFailed, err)
+			a.stats.failure(a.now(), err)
+			a.notifyError(err)
+			return tokenResponse{Err: err}, lifespan
+		}
+	}
+	a.stats.success(a.now(), start.Add(lifespan))
+	a.notifyRefresh(token, start.Add(lifespan))
+	a.persist(ctx, token, start.Add(lifespan))
+	return tokenResponse{Token: token, Key: key, ExpiresAt: start.Add(lifespan), FetchedAt: start}, lifespan
+}
+
+// Method `schedule` computes the delay until the next refresh. After a successful refresh, the timer shall fire a safety margin before the token expires. The margin is `lifeSpanSafetyMargin` unless configured otherwise (see `margin.go`).
+// If the token cannot be fetched, the loop retries frequently instead of waiting for the token's normal timeout (which could be minutes away). `retryAfterError()` takes care of this and also enforces the refresh deadline, if one is set.
+func (a *Token) schedule(ctx context.Context, lifespan time.Duration, err error) (time.Duration, error) {
+	// A permanent error stops the retries. See `errclass.go`.
+	if err != nil && a.permanent(err) {
+		a.windowStart = time.Time{}
+		a.logEvent(ctx, EventRefreshError, "Permanent authorization error; retries stopped", "err", err)
+		if a.fatal != nil {
+			a.fatal(err)
+		}
+		return never, err
+	}
+	if err != nil {
+		// The error served to clients tells them when the next attempt is due.
+		d, err := a.retryAfterError(lifespan, err)
+		// There is no point in retrying before an open circuit lets the call through (see `circuit.go`), or before the minimum refresh interval has passed (see `ratelimit.go`).
+		d = max(d, a.circuitWait(), a.minIntervalWait())
+		a.logEvent(ctx, EventBackoff, "Retrying refresh", "delay", d, "attempt", a.attempts, "err", err)
+		return d, &RetryError{Err: err, At: a.now().Add(d), now: a.now}
+	}
+	a.windowStart = time.Time{}
+	d := max(a.jitter(a.refreshAfter(lifespan), a.opts.refreshJitter), a.minIntervalWait())
+	a.refreshDue.Store(a.now().Add(d).UnixNano())
+	return d, nil
+}
+
+// The Token constructor receives the authorization function to call and optional settings. It takes care of spawning the goroutine that refreshes the token in the background.
+func NewToken(ctx context.Context, auth func() (string, time.Duration, error), opts ...Option) *Token {
+	a := newToken(opts)
+	a.authorize = ignoreContext(auth)
+	a.start(ctx)
+	return a
+}
+
+// Method `start` spawns the goroutine that keeps the token fresh. Usually, this is `refreshToken()`. A token with a warm pool runs `poolLoop()` instead (see `pool.go`).
+// The goroutine stops when either `ctx` is canceled or `Close()` is called. When it and the helper goroutines have stopped, `a.done` gets closed (see `close.go`).
+// With `WithLazyStart`, the goroutine starts on first use instead (see `lazy.go`).
+func (a *Token) start(ctx context.Context) {
+	ctx, a.cancel = context.WithCancel(ctx)
+	if a.opts.unusedAfter > 0 {
+		go a.watchUnused(ctx)
+	}
+	if a.opts.expiryImminent != nil {
+		a.cleanup.Add(1)
+		go func() {
+			defer a.cleanup.Done()
+			a.watchExpiry(ctx)
+		}()
+	}
+	if a.opts.hasHooks() {
+		a.hooks.stopped = make(chan struct{})
+		go a.hooks.run()
+	}
+	a.runCtx = ctx
+	a.lastUsed.Store(a.now().UnixNano())
+	if !a.opts.lazyStart && !a.onDemand() {
+		a.run()
+	}
+	// With `WithBlockUntilReady`, wait for the first token. See `ready.go`.
+	a.blockUntilReady(ctx)
+}
+
+// Method `run` spawns the refresh goroutine unless it is already running or the token is closed. It returns a channel that gets closed when this goroutine stops.
+// Usually, the goroutine runs until the token is closed. With `WithIdleTimeout`, it may also stop early, and `run` starts it again on the next read.
+func (a *Token) run() <-chan struct{} {
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+	// With `WithManualRun`, only `Run()` runs the loop (see `runloop.go`). On-demand tokens have no loop at all (see `ondemand.go`), so subscribing to one must not start it.
+	if a.running.Load() || a.closed || a.opts.manualRun || a.onDemand() {
+		return a.stopped
+	}
+	ctx := a.runCtx
+	if ctx.Err() != nil {
+		a.finish()
+		return nil
+	}
+	loop := a.refreshToken
+	if a.opts.poolSize > 0 {
+		loop = a.poolLoop
+	}
+	stopped := make(chan struct{})
+	a.stopped = stopped
+	a.running.Store(true)
+	go func() {
+		loop(ctx)
+		a.runMu.Lock()
+		defer a.runMu.Unlock()
+		a.running.Store(false)
+		close(stopped)
+		if ctx.Err() != nil {
+			a.finish()
+		}
+	}()
+	return stopped
+}
+
+// `newToken` creates a `Token` with the given options applied, but does not start the refresh goroutine yet.
+func newToken(opts []Option) *Token {
 	a := &Token{
 		accessToken: make(chan tokenResponse),
-		authorize:   auth,
+		swaps:       make(chan swap),
+		reconfigs:   make(chan reconfigRequest),
+		forces:      make(chan forceRequest),
+		done:        make(chan struct{}),
+		ready:       make(chan struct{}),
+		readiness:   readiness{ch: make(chan struct{})},
+		resumed:     make(chan struct{}, 1),
+		hooks:       newHookQueue(),
+	}
+	for _, opt := range opts {
+		opt(&a.opts)
 	}
-	go a.refreshToken(ctx) // This call sets a.token and a.apiErr.
 	return a
 }
 
 // Method `Get()` returns the current token or an error.
 func (a *Token) Get() (string, error) {
-	t := <-a.accessToken
+	t := a.receive()
 	return t.Token, t.Err
 }
 
+// Method `receive` reads the `accessToken` channel. Once the refresh goroutine has stopped, nobody writes to the channel anymore, and `receive` returns `ErrClosed` instead.
+func (a *Token) receive() tokenResponse {
+	a.stats.gets.Add(1)
+	// With `WithNotReadyPolicy`, a token that never had a value may answer without waiting. See `ready.go`.
+	if t, ok := a.notReady(context.Background()); ok {
+		return t
+	}
+	t := a.receiveUnchecked()
+	// With `WithMaxServeAge`, a token that is too old gets replaced first. See `maxage.go`.
+	if a.tooOld(t) {
+		t = a.refreshTooOld(context.Background(), t, a.receiveUnchecked)
+	}
+	return a.checkExpiry(t)
+}
+
+// Method `receiveUnchecked` does the work of `receive()`, without checking the token's age and expiry.
+func (a *Token) receiveUnchecked() tokenResponse {
+	if a.onDemand() {
+		return a.getOnDemand(context.Background(), false)
+	}
+	if a.fastPath() {
+		a.ensureStarted()
+		t, _ := a.load(nil)
+		return t
+	}
+	for {
+		stopped := a.ensureStarted()
+		// During a refresh, wait in the queue. See `waiters.go`.
+		result, started := a.waiters.join(a.now())
+		if result != nil {
+			select {
+			case t := <-result:
+				return t
+			case <-a.closing():
+				return tokenResponse{Err: ErrClosed}
+			}
+		}
+		select {
+		case t := <-a.accessToken:
+			return t
+		case <-a.closing():
+			return tokenResponse{Err: ErrClosed}
+		// The refresh goroutine has stopped for being idle. The next iteration restarts it.
+		case <-stopped:
+		// A refresh has begun. The next iteration queues up for its result.
+		case <-started:
+		}
+	}
+}
+
 /*
 
 ### Simulating an authorization endpoint
 
-Next, let me implement a flaky authorization function that we can pass to the `Token` constructor.
+Next, let me set up a flaky authorization function that we can pass to the `Token` constructor.
 
-The function `authFunc()` simulates fetching a new access token that expires after `lifespan` milliseconds.
+The function `authFunc()` simulates fetching a new access token that expires after `sim.LifeSpan`.
 
-But the simulated authorization endpoint is not very stable. With a probability of `apiFailureRate`, the call to the endpoint fails, and the failure then persists for `apiErrorDuration`.
+But the simulated authorization endpoint is not very stable. With a probability of `sim.FailureRate`, the call to the endpoint fails, and the failure then persists for `sim.OutageDuration`.
 
-When this happens, the function simulates a half-heartedly backoff strategy ("sleep, then try just once again") that fails half of the time. (Exponential backoff with jitter, anyone? Take it as a homework assignment.)
+When this happens, the simulator pretends to do a half-hearted backoff strategy ("sleep, then try just once again") that fails half of the time. (Exponential backoff with jitter, anyone? Take it as a homework assignment.)
 
-Feel free to skip reading through that part of the code, it is not relevant for the implementation. For any production purposes, you would insert a real API call here.
+The simulation lives in package `refreshtest` as a configurable `SimAuthorizer`, so that other tests (and you) can script a different behavior. Feel free to skip reading through that code, it is not relevant for the implementation. For any production purposes, you would insert a real API call here.
 
 */
 
-// These constants help simulate a not very reliable authorization endpoint.
+// These settings help simulate a not very reliable authorization endpoint.
 // To finish the tests quickly, the durations are set to absurdly short values.
 // (Typically, access tokens of web APIs have lifespans that are counted in
 // minutes, not milliseconds.)
+var sim = &refreshtest.SimAuthorizer{
+	LifeSpan:            100 * time.Millisecond,
+	Latency:             8 * time.Millisecond,
+	FailureRate:         0.2,
+	BackoffDelay:        100 * time.Millisecond,
+	BackoffRecoveryRate: 0.5,
+	OutageDuration:      150 * time.Millisecond,
+}
 
-const (
-	tokenLifeSpan       = 100 * time.Millisecond
-	averageCallDuration = 8 * time.Millisecond
-	apiFailureRate      = 0.2
-	apiErrorDuration    = tokenLifeSpan * 150 / 100
-)
-
-// `tempError` is set to `true` during a simulated transient API/network outage. To avoid races, it is an atomic value.
-var tempError atomic.Bool
-
-// `authFunc()` simulates fetching a new access token that expires after `lifespan` milliseconds.
+// `authFunc()` simulates fetching a new access token that expires after `sim.LifeSpan`.
+// A real endpoint would answer an outage with "503 Service Unavailable", so `authFunc()` reports the simulated outage as a retryable `AuthError`. Clients can then tell it apart from other errors with `errors.As`.
 func authFunc() (token string, lifespan time.Duration, err error) {
-	b := make([]byte, 8)
-
-	_, err = rand.Read(b)
-	if err != nil {
-		return "", tokenLifeSpan, err
-	}
-
-	// Simulate the delay of fetching a new token
-	time.Sleep(averageCallDuration)
-
-	// Simulate an API call error with a probability of `apiFailureRate`.
-	// The error lasts for `apiErrorDuration`, then disappears.
-	// The code pretends to do a backoff strategy that fails half of the time.
-	if !tempError.Load() && rnd.Float64() < apiFailureRate {
-		log.Println("API error")
-		log.Println("Backing off...")
-		time.Sleep(tokenLifeSpan)
-
-		if rnd.Float64() < 0.5 {
-			// Backoff strategy was not successful
-			log.Println("API is still not back, giving up")
-			tempError.Store(true)
-
-			// The API/network outage resolves itself after `apiErrorDuration`.
-			go func() {
-				<-time.After(apiErrorDuration)
-				log.Println("API error disappeared")
-				tempError.Store(false)
-			}()
-		} else {
-			log.Println("API error disappeared during backoff")
-		}
+	token, lifespan, err = sim.Authorize()
+	if errors.Is(err, refreshtest.ErrTemporaryAPI) {
+		err = &AuthError{StatusCode: http.StatusServiceUnavailable, Retryable: true, Err: err}
 	}
-
-	if tempError.Load() {
-		return "", tokenLifeSpan, fmt.Errorf("temporary API error")
-	}
-
-	return fmt.Sprintf("%x", b), tokenLifeSpan, nil
-
+	return token, lifespan, err
 }
 
 /*
@@ -363,8 +770,8 @@ func TestTokenGet(t *testing.T) {
 			// The clients shall request a token multiple times during the token's lifespan.
 			default:
 				t, err := token.Get()
-				log.Printf("Client %d token: %s, err: %v\n", n, t, err)
-				time.Sleep(tokenLifeSpan / 5)
+				log.Printf("Client %d token: %s, err: %v\n", n, Secret(t), err)
+				time.Sleep(sim.LifeSpan / 5)
 			}
 		}
 	}
@@ -498,8 +905,8 @@ func TestMTokenGet(t *testing.T) {
 			// The clients shall request a token multiple times during the token's lifespan.
 			default:
 				t, err := token.Get()
-				log.Printf("Mutex client %d token: %s, err: %v\n", n, t, err)
-				time.Sleep(tokenLifeSpan / 5)
+				log.Printf("Mutex client %d token: %s, err: %v\n", n, Secret(t), err)
+				time.Sleep(sim.LifeSpan / 5)
 			}
 		}
 	}
@@ -544,6 +951,8 @@ The above code's backoff "strategy" is to sleep once and try again.
 
 A tested-and-proven backup strategy for production code is "exponential backoff with jitter". Exponential means that the time between retries becomes exponentially longer (for example, the first delay is 1 second, the second is 2 seconds the third is 4, the fourth is 8, and so on). Jitter means adding a random amount of time to the delay, to avoid that clients that happen to go into backoff at the same time all retry the call at the same times.
 
+The repository contains such a strategy in `backoff.go`: pass `WithBackoff(ExponentialBackoff{...})` and `WithBackoffJitter(1)` to `NewToken`, and the refresh loop keeps retrying with exponentially growing, fully jittered delays.
+
 
 ### Which approach is faster, channels or mutexes?
 
@@ -580,6 +989,28 @@ ok      github.com/appliedgo/refresh    4.108s
 
 None of the two variants is considerably faster than the other. And by "considerably", I mean by at least an order of magnitude faster.
 
+A single goroutine reading a token that never changes is not much of a contest, though. `bench_test.go` contains a more thorough suite. It runs `Token` (channel), `MToken` (mutex), and `Token` with `WithFastPath` (atomic pointer) with 1 to 1024 reading goroutines, once with a token that stays valid and once with a token that is being refreshed all the time, and reports the latency of single `Get()` calls at the 50th, 99th, and 99.9th percentile:
+
+```sh
+> go test -run NONE -bench Get/ -benchtime 200000x
+```
+
+The absolute numbers depend on the machine, but the shape of the results does not:
+
+- **Throughput.** The bare mutex is the fastest. `Token` with the atomic pointer takes a few times longer, as each `Get()` also does some bookkeeping (statistics, lazy start, idle tracking). The channel is slower by more than an order of magnitude. This is the price of handing each token over through a goroutine switch. Still, all three serve millions of reads per second.
+- **Latency during refreshes.** Here, the picture turns around. `MToken` holds its lock while it calls `authorize()`, and the channel variant serves no reader while its loop waits for `fetch()`. Readers that arrive during a refresh wait as long as the authorization call takes, which shows up in the 99th percentile. The atomic pointer keeps serving the current token while the next one is being fetched, so its tail latency stays flat.
+- **Many goroutines.** With 1024 readers, the channel's tail latency grows with the number of readers queued up behind the loop, whereas the mutex and the atomic pointer hardly notice.
+
+Hence, a decision matrix:
+
+| Situation | Choose |
+|---|---|
+| Moderate read rates, the article's design | `Token` (channel), the default |
+| Very high read rates, or readers that must not stall during refreshes | `Token` with `WithFastPath` (atomic pointer) |
+| A minimal type without options, hooks, or `Close()` | a mutex, as in `MToken`, but without holding the lock during `authorize()` |
+
+And still: a single API call takes longer than a million `Get()` calls.
+
 
 
 ### Why do you use a timer-based token refresher?
@@ -602,6 +1033,8 @@ Remember:
 
 The current solution uses a cancelable context, but the app must ensure to eventually cancel the context. That's an additional burden for the `Token` consumer, unless the consumer already uses a cancelable context that the token refresher can be hooked into.
 
+To lift this burden, the repository version of `Token` has a `Close()` method that stops the goroutine and waits until it has exited. After that, `Get()` returns `ErrClosed` rather than blocking forever.
+
 
 ## Conclusion
 
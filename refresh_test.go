@@ -4,7 +4,11 @@ import (
 	"context"
 	"io"
 	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // This file is required for command-line testing because `go test` requires that tests be in a file that ends with `_test.go`.
@@ -38,3 +42,38 @@ func BenchmarkMToken_Get(b *testing.B) {
 		_, _ = t.Get()
 	}
 }
+
+// Under saturating `Get()` load, the token must still refresh in time.
+func TestTokenRefreshUnderLoad(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), 20 * time.Millisecond, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, auth)
+
+	for i := 0; i < 64; i++ {
+		go func() {
+			for ctx.Err() == nil {
+				tok.Get()
+			}
+		}()
+	}
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case <-deadline:
+			t.Fatalf("token refreshed only %d times within a second", calls.Load())
+		default:
+		}
+		if calls.Load() >= 10 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
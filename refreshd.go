@@ -0,0 +1,35 @@
+//go:build refreshd
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// This package is the article, and an article has no `main` function. With the `refreshd` build tag, the package builds into the token daemon instead:
+//
+//	go build -tags refreshd -o refreshd .
+//	./refreshd -config tokens.json -secret-file secret -socket /run/refreshd.sock
+//
+// A `cmd/refreshd` directory cannot hold the daemon, as a `main` package cannot be imported, and `RunDaemon` needs the whole package.
+
+// `main` runs `RunDaemon` until SIGINT or SIGTERM.
+func main() {
+	var c DaemonConfig
+	fs := flag.NewFlagSet("refreshd", flag.ExitOnError)
+	c.RegisterFlags(fs)
+	fs.Parse(os.Args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := RunDaemon(ctx, c)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "refreshd:", err)
+		os.Exit(1)
+	}
+}
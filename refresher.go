@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tokens are not the only values that need to be kept fresh. Config blobs, signed URLs, TLS certificates, or feature flags follow the same pattern: fetch a value that is valid for some time, serve it to many clients, and fetch a new one shortly before the current one expires.
+//
+// `Refresher` is the dynamic future from the article, generalized to values of any type.
+
+// `result` is the generic counterpart of `tokenResponse`.
+type result[T any] struct {
+	Value T
+	Err   error
+	// `version` counts the fetches that produced this result. See `derive.go`.
+	version uint64
+}
+
+// A `RefresherOption` configures a `Refresher`.
+type RefresherOption func(*refresherOptions)
+
+type refresherOptions struct {
+	store Store
+	codec Codec
+	// `margin` overrides `lifeSpanSafetyMargin` if `marginSet` is true, so that a margin of zero can be told from no margin set.
+	margin    time.Duration
+	marginSet bool
+	// `backoff` computes retry delays. Nil means `defaultRefresherBackoff`.
+	backoff BackoffPolicy
+	// `clock` runs the timers. Nil means the system clock.
+	clock Clock
+}
+
+// `defaultRefresherBackoff` spaces out the retries of a refresher whose source is down. Unlike tokens, which keep the article's constant `retryDelay`, refreshers back off by default, as a refresher for certificates or key sets can afford waiting a while.
+var defaultRefresherBackoff = ExponentialBackoff{Initial: retryDelay, Max: 30 * time.Second}
+
+// `WithRefresherMargin` sets the time before the end of a value's lifespan at which the refresher fetches a new value. The default is `lifeSpanSafetyMargin`. Zero means that the value gets refreshed when its lifespan ends. As with tokens, a margin that is not smaller than the lifespan makes the refresher fetch halfway through the lifespan. It panics if `d` is negative.
+func WithRefresherMargin(d time.Duration) RefresherOption {
+	if d < 0 {
+		panic("refresh: WithRefresherMargin margin must not be negative")
+	}
+	return func(o *refresherOptions) {
+		o.margin = d
+		o.marginSet = true
+	}
+}
+
+// `WithRefresherBackoff` sets the backoff policy for failed fetches. The default backs off exponentially from `retryDelay` up to 30 seconds. If the policy gives up, the refresher waits `DefaultGiveUpInterval` before it starts a new series of attempts.
+func WithRefresherBackoff(p BackoffPolicy) RefresherOption {
+	return func(o *refresherOptions) {
+		o.backoff = p
+	}
+}
+
+// `WithRefresherClock` sets the clock that the refresher uses for its timers, like `WithClock` does for tokens.
+func WithRefresherClock(c Clock) RefresherOption {
+	return func(o *refresherOptions) {
+		o.clock = c
+	}
+}
+
+// A `Refresher` holds a value of type `T` and refreshes it in the background before it expires.
+type Refresher[T any] struct {
+	value chan result[T]
+	fetch func(ctx context.Context) (T, time.Duration, error)
+	opts  refresherOptions
+	// `cancel` stops the goroutine, which closes `done` when it exits.
+	cancel context.CancelFunc
+	done   chan struct{}
+	// `parentChanged` returns a channel that fires when the parent of a derived refresher has a newer value. Nil for refreshers without a parent.
+	parentChanged func() <-chan struct{}
+
+	// `mu` guards `version` and `changed`. `changed` gets closed after each fetch and then replaced.
+	mu      sync.Mutex
+	version uint64
+	changed chan struct{}
+}
+
+// `NewRefresher` spawns a goroutine that calls `fetch` to get the initial value and then again each time the value is about to expire. `fetch` returns the value and its lifespan. A failed fetch, or a value whose lifespan is not positive, is retried with backoff. The goroutine stops when `ctx` is canceled or `Close` is called.
+func NewRefresher[T any](ctx context.Context, fetch func(ctx context.Context) (T, time.Duration, error), opts ...RefresherOption) *Refresher[T] {
+	var o refresherOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r := newRefresher[T](o)
+	// With `WithValueStore`, values go through the store. See `codec.go`.
+	if o.store != nil {
+		fetch = storedFetch(fetch, o.store, o.codec, r.opts.clock)
+	}
+	r.fetch = fetch
+	r.start(ctx)
+	return r
+}
+
+func newRefresher[T any](o refresherOptions) *Refresher[T] {
+	if o.backoff == nil {
+		o.backoff = defaultRefresherBackoff
+	}
+	if o.clock == nil {
+		o.clock = systemClock{}
+	}
+	return &Refresher[T]{
+		value:   make(chan result[T]),
+		opts:    o,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+}
+
+// Method `start` spawns the goroutine.
+func (r *Refresher[T]) start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.refresh(ctx)
+}
+
+// Method `Close` stops the goroutine and waits until it has exited. Afterwards, `Get` and `GetContext` return `ErrClosed`.
+func (r *Refresher[T]) Close() {
+	r.cancel()
+	<-r.done
+}
+
+// Method `refresh` is the generic version of `Token.refreshToken()`.
+// Unlike the article's loop, it backs off after failed fetches, and it treats a value that arrives expired like a failed fetch, as refreshing it right away would spin the loop.
+func (r *Refresher[T]) refresh(ctx context.Context) {
+	defer close(r.done)
+	var res result[T]
+	var lifespan time.Duration
+	var parentChanged <-chan struct{}
+	// `attempts` counts the failed fetches of the current series, which started at `failingSince`.
+	var attempts int
+	var failingSince time.Time
+
+	next := func() <-chan time.Time {
+		res.Value, lifespan, res.Err = r.fetch(ctx)
+		res.version = r.bump()
+		if r.parentChanged != nil {
+			parentChanged = r.parentChanged()
+		}
+		if res.Err == nil && lifespan > 0 {
+			attempts = 0
+			return r.opts.clock.After(r.refreshAfter(lifespan))
+		}
+		if attempts == 0 {
+			failingSince = r.opts.clock.Now()
+		}
+		attempts++
+		delay, ok := r.opts.backoff.NextDelay(attempts, r.opts.clock.Now().Sub(failingSince))
+		if !ok {
+			attempts = 0
+			delay = DefaultGiveUpInterval
+		}
+		return r.opts.clock.After(delay)
+	}
+	expired := next()
+
+	for {
+		select {
+		case r.value <- res:
+		case <-expired:
+			expired = next()
+		case <-parentChanged:
+			expired = next()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Method `refreshAfter` returns the time after which a value with the given lifespan shall be refreshed. Like `Token.refreshAfter`, it refreshes halfway through the lifespan if the margin is not smaller than the lifespan. A lifespan shorter than `retryDelay` still waits `retryDelay`, so that values with tiny lifespans cannot spin the loop.
+func (r *Refresher[T]) refreshAfter(lifespan time.Duration) time.Duration {
+	margin := lifeSpanSafetyMargin
+	if r.opts.marginSet {
+		margin = r.opts.margin
+	}
+	after := lifespan - margin
+	if margin >= lifespan {
+		after = lifespan / 2
+	}
+	return max(after, retryDelay)
+}
+
+// Method `bump` increments the version after a fetch and wakes up the watchers of the previous version.
+func (r *Refresher[T]) bump() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version++
+	close(r.changed)
+	r.changed = make(chan struct{})
+	return r.version
+}
+
+// Method `watch` returns the current version and a channel that gets closed when the version changes.
+func (r *Refresher[T]) watch() (<-chan struct{}, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.changed, r.version
+}
+
+// Method `Get` returns the current value or an error. After the refresher has stopped, it returns `ErrClosed`.
+func (r *Refresher[T]) Get() (T, error) {
+	select {
+	case v := <-r.value:
+		return v.Value, v.Err
+	case <-r.done:
+		var zero T
+		return zero, ErrClosed
+	}
+}
+
+// Method `GetContext` works like `Get()` but stops waiting when `ctx` is done, and returns `ErrGetTimeout`.
+func (r *Refresher[T]) GetContext(ctx context.Context) (T, error) {
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, err
+	}
+	select {
+	case v := <-r.value:
+		return v.Value, v.Err
+	case <-r.done:
+		var zero T
+		return zero, ErrClosed
+	case <-ctx.Done():
+		var zero T
+		return zero, fmt.Errorf("%w: %w", ErrGetTimeout, ctx.Err())
+	}
+}
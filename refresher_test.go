@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+type config struct {
+	Version int
+	Flags   map[string]bool
+}
+
+func TestRefresher(t *testing.T) {
+	var version atomic.Int32
+	fetch := func(ctx context.Context) (config, time.Duration, error) {
+		v := int(version.Add(1))
+		return config{Version: v, Flags: map[string]bool{"beta": v%2 == 0}}, 20 * time.Millisecond, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRefresher(ctx, fetch)
+
+	c, err := r.Get()
+	if err != nil || c.Version != 1 || c.Flags["beta"] {
+		t.Fatalf("unexpected initial value (%+v, %v)", c, err)
+	}
+	waitFor(t, time.Second, func() bool {
+		c, _ := r.Get()
+		return c.Version >= 3
+	})
+}
+
+func TestRefresherRetry(t *testing.T) {
+	var calls atomic.Int32
+	fetch := func(ctx context.Context) (string, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			return "", time.Hour, errors.New("down")
+		}
+		return "url", time.Hour, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRefresher(ctx, fetch)
+
+	waitFor(t, time.Second, func() bool {
+		v, err := r.GetContext(ctx)
+		return err == nil && v == "url"
+	})
+}
+
+// A failing source and a value that arrives expired must not make the refresher spin.
+func TestRefresherBackoff(t *testing.T) {
+	for name, fetchResult := range map[string]func() (string, time.Duration, error){
+		"error":         func() (string, time.Duration, error) { return "", 0, errors.New("down") },
+		"zero lifespan": func() (string, time.Duration, error) { return "v", 0, nil },
+		"negative":      func() (string, time.Duration, error) { return "v", -time.Hour, nil },
+	} {
+		fetchResult := fetchResult
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			NewRefresher(ctx, func(context.Context) (string, time.Duration, error) {
+				calls.Add(1)
+				return fetchResult()
+			}, WithRefresherBackoff(ExponentialBackoff{Initial: 20 * time.Millisecond, Max: time.Second}))
+			time.Sleep(100 * time.Millisecond)
+			// Retries after 20, 60, and 140ms.
+			if n := calls.Load(); n > 3 {
+				t.Fatalf("want at most 3 calls, got %d", n)
+			}
+		})
+	}
+}
+
+func TestRefresherMargin(t *testing.T) {
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRefresher(ctx, func(context.Context) (int32, time.Duration, error) {
+		return calls.Add(1), time.Second, nil
+	}, WithRefresherMargin(950*time.Millisecond))
+	// Without the margin, the second fetch would happen after 990ms.
+	waitFor(t, 500*time.Millisecond, func() bool {
+		v, _ := r.Get()
+		return v >= 2
+	})
+}
+
+func TestRefresherClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewRefresher(ctx, func(context.Context) (string, time.Duration, error) {
+		return "v", time.Hour, nil
+	})
+	if v, err := r.Get(); v != "v" || err != nil {
+		t.Fatalf("want v, got (%q, %v)", v, err)
+	}
+	// Canceling the constructor's context stops the refresher, and reads do not block.
+	cancel()
+	waitFor(t, time.Second, func() bool {
+		_, err := r.GetContext(context.Background())
+		return errors.Is(err, ErrClosed)
+	})
+	r.Close()
+	if _, err := r.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
+
+// The refresher's timers run on the configured clock.
+func TestRefresherClock(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRefresher(ctx, func(context.Context) (int32, time.Duration, error) {
+		return calls.Add(1), time.Hour, nil
+	}, WithRefresherMargin(10*time.Minute), WithRefresherClock(clock))
+	if v, _ := r.Get(); v != 1 {
+		t.Fatalf("want 1, got %d", v)
+	}
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(49 * time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if v, _ := r.Get(); v != 1 {
+		t.Fatalf("refreshed before the margin: got %d", v)
+	}
+	clock.Advance(time.Minute)
+	waitFor(t, time.Second, func() bool {
+		v, _ := r.Get()
+		return v == 2
+	})
+}
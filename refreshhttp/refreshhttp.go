@@ -0,0 +1,206 @@
+// Package refreshhttp serves a refreshing token over HTTP, so that sidecars and scripts in the same pod or on the same host can use the token that one process keeps fresh, instead of fetching their own.
+//
+// The handler requires a shared secret as a bearer token and is meant to listen on a loopback address only. `ListenAndServe` enforces this.
+//
+// The package uses only the standard library.
+package refreshhttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source is the part of a refreshing token that the handler needs. `*Token` from the parent package satisfies it.
+type Source interface {
+	Watch(ctx context.Context, lastVersion uint64) (string, uint64, error)
+	ExpiresAt() (time.Time, bool)
+}
+
+// Response is the JSON body of a successful response.
+type Response struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Version   uint64    `json:"version"`
+}
+
+// ErrorResponse is the JSON body of a failed response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// DefaultMaxWait limits how long a long-poll request waits for a new token.
+const DefaultMaxWait = 5 * time.Minute
+
+type handler struct {
+	src     Source
+	lookup  Lookup
+	secret  []byte
+	maxWait time.Duration
+}
+
+// Option configures the handler.
+type Option func(*handler)
+
+// WithMaxWait sets the maximum time that a long-poll request waits for a new token. The default is `DefaultMaxWait`.
+func WithMaxWait(d time.Duration) Option {
+	return func(h *handler) {
+		h.maxWait = d
+	}
+}
+
+// NewHandler returns a handler that serves the current token of src to clients that send `Authorization: Bearer <secret>`. secret must not be empty.
+//
+// A GET request returns the current token as a `Response`. With the query parameter `after=<version>`, the request waits until the token's version exceeds the given version, so that clients can long-poll for rotations: each response carries the version to pass in the next request. If no new token arrives within the maximum wait, the response is 304 Not Modified. If the token source has an error, the response is 503 with an `ErrorResponse`.
+func NewHandler(src Source, secret string, opts ...Option) http.Handler {
+	if secret == "" {
+		panic("refreshhttp: empty secret")
+	}
+	h := &handler{src: src, secret: []byte(secret), maxWait: DefaultMaxWait}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Lookup returns the token source registered under name. `Manager.Token` from the parent package, wrapped in a function, satisfies it.
+type Lookup func(name string) (Source, bool)
+
+// TokensPath is the path prefix under which a handler from NewMultiHandler serves tokens by name.
+const TokensPath = "/tokens/"
+
+// NewMultiHandler returns a handler that serves many tokens, one per path: a GET request for `/tokens/<name>` serves the token that lookup returns for name, as the handler from NewHandler does. Unknown names get a 404 response. lookup is called on every request, so tokens can come and go while the handler runs. secret must not be empty.
+func NewMultiHandler(lookup Lookup, secret string, opts ...Option) http.Handler {
+	h := NewHandler(nil, secret, opts...).(*handler)
+	h.lookup = lookup
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="refreshhttp"`)
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+	src := h.src
+	if h.lookup != nil {
+		name, ok := strings.CutPrefix(r.URL.Path, TokensPath)
+		if ok = ok && name != "" && !strings.Contains(name, "/"); ok {
+			src, ok = h.lookup(name)
+		}
+		if !ok {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "unknown token"})
+			return
+		}
+	}
+	var after uint64
+	if s := r.URL.Query().Get("after"); s != "" {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid after parameter"})
+			return
+		}
+		after = v
+	}
+	ctx := r.Context()
+	if after > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.maxWait)
+		defer cancel()
+	}
+	token, version, err := src.Watch(ctx, after)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && r.Context().Err() == nil:
+		w.WriteHeader(http.StatusNotModified)
+		return
+	case err != nil:
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: err.Error()})
+		return
+	}
+	resp := Response{Token: token, Version: version}
+	if exp, ok := src.ExpiresAt(); ok {
+		resp.ExpiresAt = exp
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// authorized compares the bearer token in constant time.
+func (h *handler) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), h.secret) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ErrNotLoopback is returned by ListenAndServe for addresses that other hosts can reach.
+var ErrNotLoopback = errors.New("refreshhttp: address is not a loopback address")
+
+// ListenAndServe serves h on addr until ctx is done. addr must be a loopback address, such as "127.0.0.1:8181" or "[::1]:8181", or "localhost" with a port.
+func ListenAndServe(ctx context.Context, addr string, h http.Handler) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("refreshhttp: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%w: %s", ErrNotLoopback, addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return serve(ctx, ln, h)
+}
+
+// ListenAndServeUnix serves h on a Unix domain socket at path until ctx is done. Only the owner of the process can connect: the socket file gets mode 0600. A stale socket file from an earlier run gets replaced, and the socket file is removed on return.
+func ListenAndServeUnix(ctx context.Context, path string, h http.Handler) error {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return fmt.Errorf("refreshhttp: %w", err)
+	}
+	return serve(ctx, ln, h)
+}
+
+// serve serves h on ln until ctx is done, then shuts down gracefully.
+func serve(ctx context.Context, ln net.Listener, h http.Handler) error {
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+		return ctx.Err()
+	}
+}
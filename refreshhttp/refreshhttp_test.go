@@ -0,0 +1,185 @@
+package refreshhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource serves versioned tokens. Set publishes a new one.
+type fakeSource struct {
+	mu      sync.Mutex
+	token   string
+	version uint64
+	err     error
+	changed chan struct{}
+}
+
+func newFakeSource(token string) *fakeSource {
+	return &fakeSource{token: token, version: 1, changed: make(chan struct{})}
+}
+
+func (f *fakeSource) Set(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.token = token
+	f.version++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeSource) Watch(ctx context.Context, last uint64) (string, uint64, error) {
+	for {
+		f.mu.Lock()
+		token, version, err, changed := f.token, f.version, f.err, f.changed
+		f.mu.Unlock()
+		if version > last {
+			return token, version, err
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", 0, ctx.Err()
+		}
+	}
+}
+
+func (f *fakeSource) ExpiresAt() (time.Time, bool) {
+	return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), true
+}
+
+func get(t *testing.T, url, secret string) (*http.Response, Response) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body Response
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body
+}
+
+func TestHandler(t *testing.T) {
+	src := newFakeSource("tok-1")
+	srv := httptest.NewServer(NewHandler(src, "s3cret", WithMaxWait(50*time.Millisecond)))
+	defer srv.Close()
+
+	if resp, _ := get(t, srv.URL, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("want 401, got %d", resp.StatusCode)
+	}
+	resp, body := get(t, srv.URL, "s3cret")
+	if resp.StatusCode != http.StatusOK || body.Token != "tok-1" || body.Version != 1 || body.ExpiresAt.IsZero() {
+		t.Fatalf("unexpected response %d %+v", resp.StatusCode, body)
+	}
+
+	// Long-poll: no rotation within the maximum wait.
+	if resp, _ := get(t, srv.URL+"?after=1", "s3cret"); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("want 304, got %d", resp.StatusCode)
+	}
+
+	// Long-poll: a rotation ends the wait.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		src.Set("tok-2")
+	}()
+	resp, body = get(t, srv.URL+"?after="+strconv.FormatUint(body.Version, 10), "s3cret")
+	if resp.StatusCode != http.StatusOK || body.Token != "tok-2" || body.Version != 2 {
+		t.Fatalf("unexpected response %d %+v", resp.StatusCode, body)
+	}
+
+	src.mu.Lock()
+	src.err = errors.New("authorization server down")
+	src.mu.Unlock()
+	src.Set("")
+	if resp, _ := get(t, srv.URL, "s3cret"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("want 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenAndServeLoopbackOnly(t *testing.T) {
+	h := NewHandler(newFakeSource("t"), "s")
+	if err := ListenAndServe(context.Background(), "0.0.0.0:0", h); !errors.Is(err, ErrNotLoopback) {
+		t.Fatalf("want ErrNotLoopback, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ListenAndServe(ctx, "127.0.0.1:0", h); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want clean shutdown, got %v", err)
+	}
+}
+
+func TestMultiHandler(t *testing.T) {
+	sources := map[string]Source{"api": newFakeSource("api-token")}
+	lookup := func(name string) (Source, bool) {
+		src, ok := sources[name]
+		return src, ok
+	}
+	srv := httptest.NewServer(NewMultiHandler(lookup, "s3cret"))
+	defer srv.Close()
+
+	if resp, body := get(t, srv.URL+"/tokens/api", "s3cret"); resp.StatusCode != http.StatusOK || body.Token != "api-token" {
+		t.Fatalf("want api-token, got %d %q", resp.StatusCode, body.Token)
+	}
+	for _, path := range []string{"/tokens/other", "/tokens/", "/tokens/api/x", "/"} {
+		if resp, _ := get(t, srv.URL+path, "s3cret"); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: want 404, got %d", path, resp.StatusCode)
+		}
+	}
+	if resp, _ := get(t, srv.URL+"/tokens/other", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want 401 before lookup, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenAndServeUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refresh.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- ListenAndServeUnix(ctx, path, NewHandler(newFakeSource("t"), "s")) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "http://unix/", nil)
+		req.Header.Set("Authorization", "Bearer s")
+		if resp, err = client.Do(req); err == nil {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want 200, got %d", resp.StatusCode)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("want socket with mode 0600, got (%v, %v)", fi, err)
+	}
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("want clean shutdown, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file not removed: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package refreshtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is a fake clock for tests. Time stands still until Advance moves it forward. Clock satisfies the Clock interface of the parent package.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewClock returns a fake clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the clock's time once the clock has been advanced by d or more. A non-positive d fires immediately.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires all timers that are due, in order.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+	n := 0
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			break
+		}
+		w.ch <- w.at
+		n++
+	}
+	c.waiters = c.waiters[n:]
+}
+
+// Timers returns the number of timers that have not fired yet. Tests can poll it to find out whether a goroutine is waiting on the clock.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
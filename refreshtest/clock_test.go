@@ -0,0 +1,42 @@
+package refreshtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	c := NewClock(start)
+	late := c.After(2 * time.Second)
+	early := c.After(time.Second)
+	if c.Timers() != 2 {
+		t.Fatalf("want 2 timers, got %d", c.Timers())
+	}
+
+	c.Advance(1500 * time.Millisecond)
+	select {
+	case at := <-early:
+		if !at.Equal(start.Add(time.Second)) {
+			t.Fatalf("early timer fired at %v", at)
+		}
+	default:
+		t.Fatal("early timer did not fire")
+	}
+	select {
+	case <-late:
+		t.Fatal("late timer fired too early")
+	default:
+	}
+
+	c.Advance(time.Second)
+	<-late
+	if !c.Now().Equal(start.Add(2500 * time.Millisecond)) {
+		t.Fatalf("unexpected time %v", c.Now())
+	}
+	select {
+	case <-c.After(0):
+	default:
+		t.Fatal("zero timer did not fire immediately")
+	}
+}
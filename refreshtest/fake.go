@@ -0,0 +1,159 @@
+package refreshtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Response is one scripted result of FakeAuthorizer.
+type Response struct {
+	// Token is the token to return. If empty and Err is nil, FakeAuthorizer generates "token-<n>", where n is the number of the call, starting at 1.
+	Token string
+	// LifeSpan is the lifespan to return.
+	LifeSpan time.Duration
+	// Err is the error to return.
+	Err error
+	// Latency is the real time the call takes. A call with a context returns early when the context is canceled.
+	Latency time.Duration
+}
+
+// FakeAuthorizer is an authorization function with scripted responses. Each call returns the next response from the script. Once the script is exhausted, the last response repeats. An empty script returns generated tokens with a lifespan of one hour.
+//
+// FakeAuthorizer records the time of each call, so that tests can assert how often and at which intervals a Token calls its authorizer. It is safe for concurrent use.
+type FakeAuthorizer struct {
+	// Now returns the time at which calls are recorded. If nil, time.Now is used. Set it to the Now method of a fake Clock to record fake time.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	script    []Response
+	calls     []time.Time
+	callAdded chan struct{}
+}
+
+// NewFakeAuthorizer returns a FakeAuthorizer with the given script.
+func NewFakeAuthorizer(script ...Response) *FakeAuthorizer {
+	return &FakeAuthorizer{script: script}
+}
+
+// Push appends responses to the script.
+func (f *FakeAuthorizer) Push(rs ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.script = append(f.script, rs...)
+}
+
+// Authorize returns the next scripted response. Its signature matches the authorization function expected by NewToken.
+func (f *FakeAuthorizer) Authorize() (string, time.Duration, error) {
+	return f.AuthorizeContext(context.Background())
+}
+
+// AuthorizeContext works like Authorize but stops waiting for the scripted latency when ctx is canceled. Its signature matches the authorization function expected by NewTokenContext.
+func (f *FakeAuthorizer) AuthorizeContext(ctx context.Context) (string, time.Duration, error) {
+	r, n := f.next()
+	if r.Latency > 0 {
+		t := time.NewTimer(r.Latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return "", 0, ctx.Err()
+		}
+	}
+	if r.Err == nil && r.Token == "" {
+		r.Token = fmt.Sprintf("token-%d", n)
+	}
+	return r.Token, r.LifeSpan, r.Err
+}
+
+// next records a call and picks its response. It returns the response and the number of the call.
+func (f *FakeAuthorizer) next() (Response, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now
+	if f.Now != nil {
+		now = f.Now
+	}
+	f.calls = append(f.calls, now())
+	if f.callAdded != nil {
+		close(f.callAdded)
+		f.callAdded = nil
+	}
+	n := len(f.calls)
+	switch {
+	case len(f.script) == 0:
+		return Response{LifeSpan: time.Hour}, n
+	case n <= len(f.script):
+		return f.script[n-1], n
+	default:
+		return f.script[len(f.script)-1], n
+	}
+}
+
+// Calls returns the number of calls so far.
+func (f *FakeAuthorizer) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// CallTimes returns the time of each call so far.
+func (f *FakeAuthorizer) CallTimes() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Time(nil), f.calls...)
+}
+
+// WaitForCalls blocks until at least n calls have been made or timeout has passed. It reports whether n calls have been made.
+func (f *FakeAuthorizer) WaitForCalls(n int, timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		f.mu.Lock()
+		if len(f.calls) >= n {
+			f.mu.Unlock()
+			return true
+		}
+		if f.callAdded == nil {
+			f.callAdded = make(chan struct{})
+		}
+		added := f.callAdded
+		f.mu.Unlock()
+		select {
+		case <-added:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// AssertCalls fails the test unless exactly n calls have been made.
+func (f *FakeAuthorizer) AssertCalls(t testing.TB, n int) {
+	t.Helper()
+	if got := f.Calls(); got != n {
+		t.Errorf("authorizer: want %d calls, got %d", n, got)
+	}
+}
+
+// AssertEventually fails the test unless at least n calls are made within timeout.
+func (f *FakeAuthorizer) AssertEventually(t testing.TB, n int, timeout time.Duration) {
+	t.Helper()
+	if !f.WaitForCalls(n, timeout) {
+		t.Errorf("authorizer: want %d calls within %v, got %d", n, timeout, f.Calls())
+	}
+}
+
+// AssertInterval fails the test unless call number i (starting at 1) followed call i-1 after at least min and at most max.
+func (f *FakeAuthorizer) AssertInterval(t testing.TB, i int, min, max time.Duration) {
+	t.Helper()
+	calls := f.CallTimes()
+	if i < 2 || i > len(calls) {
+		t.Errorf("authorizer: no interval before call %d (%d calls)", i, len(calls))
+		return
+	}
+	if d := calls[i-1].Sub(calls[i-2]); d < min || d > max {
+		t.Errorf("authorizer: call %d came %v after call %d, want between %v and %v", i, d, i-1, min, max)
+	}
+}
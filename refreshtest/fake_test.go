@@ -0,0 +1,56 @@
+package refreshtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeAuthorizerScript(t *testing.T) {
+	errDown := errors.New("down")
+	f := NewFakeAuthorizer(
+		Response{Token: "a", LifeSpan: time.Minute},
+		Response{Err: errDown},
+		Response{LifeSpan: time.Hour},
+	)
+	want := []struct {
+		token    string
+		lifespan time.Duration
+		err      error
+	}{
+		{"a", time.Minute, nil},
+		{"", 0, errDown},
+		{"token-3", time.Hour, nil},
+		{"token-4", time.Hour, nil}, // the last response repeats
+	}
+	for i, w := range want {
+		token, lifespan, err := f.Authorize()
+		if token != w.token || lifespan != w.lifespan || !errors.Is(err, w.err) {
+			t.Errorf("call %d: want (%q, %v, %v), got (%q, %v, %v)", i+1, w.token, w.lifespan, w.err, token, lifespan, err)
+		}
+	}
+	f.AssertCalls(t, 4)
+}
+
+func TestFakeAuthorizerTiming(t *testing.T) {
+	clock := NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	f := NewFakeAuthorizer()
+	f.Now = clock.Now
+	f.Authorize()
+	clock.Advance(time.Minute)
+	f.Authorize()
+	f.AssertInterval(t, 2, time.Minute, time.Minute)
+
+	go f.Authorize()
+	f.AssertEventually(t, 3, time.Second)
+}
+
+func TestFakeAuthorizerLatency(t *testing.T) {
+	f := NewFakeAuthorizer(Response{Latency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := f.AuthorizeContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package refreshtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redis is an in-process fake of a Redis server for tests of shared token stores. It understands AUTH, SELECT, GET, SET with NX and PX, DEL, and EVAL with the scripts that release and extend locks. EVAL does not run Lua. Scripts with one key and one argument are treated as compare-and-delete, scripts with one key and two arguments as compare-and-pexpire.
+type Redis struct {
+	ln net.Listener
+	// Now tells the time for key expiry. Nil means `time.Now`.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	data map[string]redisValue
+	cmds int
+}
+
+type redisValue struct {
+	v       string
+	expires time.Time
+}
+
+// NewRedis starts a fake Redis server on a local port. Call Close when done.
+func NewRedis() (*Redis, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r := &Redis{ln: ln, data: make(map[string]redisValue)}
+	go r.serve()
+	return r, nil
+}
+
+// Addr returns the server's address.
+func (r *Redis) Addr() string { return r.ln.Addr().String() }
+
+// Close stops the server.
+func (r *Redis) Close() error { return r.ln.Close() }
+
+// Commands returns the number of commands received so far.
+func (r *Redis) Commands() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cmds
+}
+
+func (r *Redis) serve() {
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		go r.handle(conn)
+	}
+}
+
+func (r *Redis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, r.exec(args))
+	}
+}
+
+// readCommand reads a RESP array of bulk strings.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (r *Redis) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// get returns the value of key if it has not expired. r.mu must be held.
+func (r *Redis) get(key string) (string, bool) {
+	v, ok := r.data[key]
+	if !ok || (!v.expires.IsZero() && !r.now().Before(v.expires)) {
+		delete(r.data, key)
+		return "", false
+	}
+	return v.v, true
+}
+
+func (r *Redis) exec(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmds++
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+	switch strings.ToUpper(args[0]) {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if len(args) != 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		if v, ok := r.get(args[1]); ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SET":
+		if len(args) < 3 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		val := redisValue{v: args[2]}
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				if i+1 >= len(args) {
+					return "-ERR syntax error\r\n"
+				}
+				ms, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil {
+					return "-ERR value is not an integer\r\n"
+				}
+				val.expires = r.now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			}
+		}
+		if _, exists := r.get(args[1]); nx && exists {
+			return "$-1\r\n"
+		}
+		r.data[args[1]] = val
+		return "+OK\r\n"
+	case "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := r.get(k); ok {
+				delete(r.data, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "EVAL":
+		// EVAL script 1 key value [ms]
+		if len(args) != 5 && len(args) != 6 {
+			return "-ERR unsupported script\r\n"
+		}
+		v, ok := r.get(args[3])
+		if !ok || v != args[4] {
+			return ":0\r\n"
+		}
+		if len(args) == 5 {
+			delete(r.data, args[3])
+			return ":1\r\n"
+		}
+		ms, err := strconv.ParseInt(args[5], 10, 64)
+		if err != nil {
+			return "-ERR value is not an integer\r\n"
+		}
+		r.data[args[3]] = redisValue{v: v, expires: r.now().Add(time.Duration(ms) * time.Millisecond)}
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
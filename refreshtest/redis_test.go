@@ -0,0 +1,28 @@
+package refreshtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedisExec(t *testing.T) {
+	now := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	r := &Redis{data: make(map[string]redisValue), Now: func() time.Time { return now }}
+
+	if got := r.exec([]string{"SET", "lock", "a", "NX", "PX", "1000"}); got != "+OK\r\n" {
+		t.Fatalf("first SET NX: %q", got)
+	}
+	if got := r.exec([]string{"SET", "lock", "b", "NX", "PX", "1000"}); got != "$-1\r\n" {
+		t.Fatalf("second SET NX: %q", got)
+	}
+	if got := r.exec([]string{"EVAL", "script", "1", "lock", "b"}); got != ":0\r\n" {
+		t.Fatalf("EVAL by non-holder: %q", got)
+	}
+	now = now.Add(time.Second)
+	if got := r.exec([]string{"GET", "lock"}); got != "$-1\r\n" {
+		t.Fatalf("GET after expiry: %q", got)
+	}
+	if r.Commands() != 4 {
+		t.Fatalf("want 4 commands, got %d", r.Commands())
+	}
+}
@@ -0,0 +1,102 @@
+// Package refreshtest provides helpers for testing code that uses refreshing tokens.
+package refreshtest
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	rnd "math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTemporaryAPI is returned by Authorize during a simulated outage.
+var ErrTemporaryAPI = errors.New("temporary API error")
+
+// SimAuthorizer simulates a not very reliable authorization endpoint.
+//
+// Each call takes Latency to complete. With a probability of FailureRate, the call runs into an API error. The simulator then pretends to back off for BackoffDelay and recovers with a probability of BackoffRecoveryRate. If it does not recover, an outage begins, and all calls fail until OutageDuration has passed.
+//
+// The zero value simulates an instant and perfectly reliable endpoint that issues tokens with a lifespan of zero. Set the fields before the first call to Authorize and do not change them afterwards.
+type SimAuthorizer struct {
+	// LifeSpan is the lifespan reported for each token.
+	LifeSpan time.Duration
+	// Latency is the duration of each call.
+	Latency time.Duration
+	// FailureRate is the probability of an API error, between 0 and 1.
+	FailureRate float64
+	// BackoffDelay is the time spent backing off after an API error.
+	BackoffDelay time.Duration
+	// BackoffRecoveryRate is the probability that the API error disappears during backoff, between 0 and 1.
+	BackoffRecoveryRate float64
+	// OutageDuration is the time it takes an outage to resolve itself.
+	OutageDuration time.Duration
+	// Rand is the random source. If nil, the global source of math/rand is used.
+	Rand *rnd.Rand
+	// Logger receives the simulator's log messages. If nil, the standard logger is used.
+	Logger *log.Logger
+
+	mu     sync.Mutex // guards Rand
+	outage atomic.Bool
+}
+
+// Authorize simulates fetching a new access token that expires after LifeSpan. Its signature matches the authorization function expected by NewToken.
+func (s *SimAuthorizer) Authorize() (token string, lifespan time.Duration, err error) {
+	b := make([]byte, 8)
+
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", s.LifeSpan, err
+	}
+
+	// Simulate the delay of fetching a new token
+	time.Sleep(s.Latency)
+
+	// Simulate an API call error with a probability of `FailureRate`.
+	// The error lasts for `OutageDuration`, then disappears.
+	// The code pretends to do a backoff strategy that fails some of the time.
+	if !s.outage.Load() && s.float64() < s.FailureRate {
+		s.logger().Println("API error")
+		s.logger().Println("Backing off...")
+		time.Sleep(s.BackoffDelay)
+
+		if s.float64() >= s.BackoffRecoveryRate {
+			// Backoff strategy was not successful
+			s.logger().Println("API is still not back, giving up")
+			s.outage.Store(true)
+
+			// The API/network outage resolves itself after `OutageDuration`.
+			go func() {
+				<-time.After(s.OutageDuration)
+				s.logger().Println("API error disappeared")
+				s.outage.Store(false)
+			}()
+		} else {
+			s.logger().Println("API error disappeared during backoff")
+		}
+	}
+
+	if s.outage.Load() {
+		return "", s.LifeSpan, ErrTemporaryAPI
+	}
+
+	return fmt.Sprintf("%x", b), s.LifeSpan, nil
+}
+
+func (s *SimAuthorizer) float64() float64 {
+	if s.Rand == nil {
+		return rnd.Float64()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Rand.Float64()
+}
+
+func (s *SimAuthorizer) logger() *log.Logger {
+	if s.Logger == nil {
+		return log.Default()
+	}
+	return s.Logger
+}
@@ -0,0 +1,35 @@
+package refreshtest
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestSimAuthorizerFailureRate(t *testing.T) {
+	quiet := log.New(io.Discard, "", 0)
+
+	always := &SimAuthorizer{LifeSpan: time.Second, FailureRate: 1, OutageDuration: time.Hour, Logger: quiet}
+	for i := 0; i < 20; i++ {
+		if tok, _, err := always.Authorize(); !errors.Is(err, ErrTemporaryAPI) {
+			t.Fatalf("call %d: want ErrTemporaryAPI, got (%q, %v)", i, tok, err)
+		}
+	}
+
+	never := &SimAuthorizer{LifeSpan: time.Second, FailureRate: 0, Logger: quiet}
+	for i := 0; i < 20; i++ {
+		tok, lifespan, err := never.Authorize()
+		if err != nil || tok == "" || lifespan != time.Second {
+			t.Fatalf("call %d: want token, got (%q, %v, %v)", i, tok, lifespan, err)
+		}
+	}
+}
+
+func TestSimAuthorizerRecovery(t *testing.T) {
+	s := &SimAuthorizer{FailureRate: 1, BackoffRecoveryRate: 1, Logger: log.New(io.Discard, "", 0)}
+	if _, _, err := s.Authorize(); err != nil {
+		t.Fatalf("want recovery during backoff, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+
+	"github.com/appliedgo/refresh/registry"
+)
+
+// Container registries issue tokens per repository and action. A client that pulls from several repositories needs several tokens, each of which has to be kept fresh. This is a job for a `Manager` with a factory: the key is the registry's challenge, and the factory creates one token per challenge.
+
+// `RegistryFactory` returns a factory for `WithFactory` that creates a token for each registry challenge. The key passed to `Manager.Get` should be the `Key()` of a `registry.Challenge`, so that equal challenges share a token. `opts` configure the token requests, for example, with `registry.WithBasicAuth`. `tokenOpts` configure each token.
+func RegistryFactory(opts []registry.Option, tokenOpts ...Option) func(ctx context.Context, key string) (*Token, error) {
+	return func(ctx context.Context, key string) (*Token, error) {
+		c, err := registry.ParseChallenge(key)
+		if err != nil {
+			return nil, err
+		}
+		return NewTokenContext(ctx, registry.NewAuthorizer(c, opts...), tokenOpts...), nil
+	}
+}
@@ -0,0 +1,186 @@
+// Package registry implements the token flow of container registries, as used by Docker Hub and other OCI registries: a registry rejects an anonymous request with a `WWW-Authenticate: Bearer realm=...,service=...,scope=...` challenge, and the client fetches a token for exactly that scope from the realm.
+//
+// Tokens are scoped to repositories and actions, so a client needs one token per registry and scope. A `Challenge` identifies such a token, and its `Key` can serve as the key of a token manager in the parent package.
+//
+// The package uses only the standard library.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultExpiry is the lifespan of tokens whose response lacks `expires_in`, as the token specification prescribes.
+const DefaultExpiry = 60 * time.Second
+
+// A Challenge is the content of a Bearer `WWW-Authenticate` header.
+type Challenge struct {
+	Realm   string
+	Service string
+	Scopes  []string
+}
+
+// ErrNoChallenge is returned by ParseChallenge if the header is not a Bearer challenge with a realm.
+var ErrNoChallenge = errors.New("registry: no bearer challenge")
+
+// ParseChallenge parses the value of a `WWW-Authenticate` header. Scopes are sorted, so that equal challenges have equal keys.
+func ParseChallenge(header string) (Challenge, error) {
+	scheme, params, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Challenge{}, ErrNoChallenge
+	}
+	var c Challenge
+	for params = strings.TrimSpace(params); params != ""; {
+		var key, value string
+		key, params, ok = strings.Cut(params, "=")
+		if !ok {
+			return Challenge{}, fmt.Errorf("%w: malformed parameters", ErrNoChallenge)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if strings.HasPrefix(params, `"`) {
+			// A quoted value may contain commas, as in scope="repository:app:pull,push".
+			end := strings.Index(params[1:], `"`)
+			if end < 0 {
+				return Challenge{}, fmt.Errorf("%w: unterminated quote", ErrNoChallenge)
+			}
+			value, params = params[1:end+1], params[end+2:]
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+		params = strings.TrimLeft(strings.TrimSpace(params), ", ")
+		switch key {
+		case "realm":
+			c.Realm = value
+		case "service":
+			c.Service = value
+		case "scope":
+			c.Scopes = append(c.Scopes, strings.Fields(value)...)
+		}
+	}
+	if c.Realm == "" {
+		return Challenge{}, fmt.Errorf("%w: no realm", ErrNoChallenge)
+	}
+	sort.Strings(c.Scopes)
+	return c, nil
+}
+
+// Key returns the challenge in header syntax. ParseChallenge turns the key back into the challenge.
+func (c Challenge) Key() string {
+	k := fmt.Sprintf("Bearer realm=%q,service=%q", c.Realm, c.Service)
+	if len(c.Scopes) > 0 {
+		k += fmt.Sprintf(",scope=%q", strings.Join(c.Scopes, " "))
+	}
+	return k
+}
+
+// Option configures an authorization function.
+type Option func(*config)
+
+type config struct {
+	client             *http.Client
+	username, password string
+}
+
+// WithHTTPClient sets the client for token requests. The default is `http.DefaultClient`.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = c
+	}
+}
+
+// WithBasicAuth authenticates token requests. Without it, tokens are requested anonymously, which public repositories allow for pulling.
+func WithBasicAuth(username, password string) Option {
+	return func(cfg *config) {
+		cfg.username, cfg.password = username, password
+	}
+}
+
+// ResponseError is returned if the token server responds with an error status.
+type ResponseError struct {
+	StatusCode int
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("registry: token server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary reports whether the error is likely to go away by retrying. This is the case for server errors (5xx) and rate limiting (429).
+func (e *ResponseError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// ErrInvalidResponse is returned if the token server responds with success but the response cannot be used.
+var ErrInvalidResponse = errors.New("registry: invalid token response")
+
+// maxResponseSize limits how much of a response gets read.
+const maxResponseSize = 1 << 20
+
+// NewAuthorizer returns an authorization function that fetches tokens for the challenge's service and scopes from its realm.
+func NewAuthorizer(c Challenge, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	cfg := &config{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return func(ctx context.Context) (string, time.Duration, error) {
+		q := url.Values{}
+		if c.Service != "" {
+			q.Set("service", c.Service)
+		}
+		for _, s := range c.Scopes {
+			q.Add("scope", s)
+		}
+		u := c.Realm
+		if len(q) > 0 {
+			u += "?" + q.Encode()
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return "", 0, fmt.Errorf("registry: %w", err)
+		}
+		if cfg.username != "" {
+			req.SetBasicAuth(cfg.username, cfg.password)
+		}
+		resp, err := cfg.client.Do(req)
+		if err != nil {
+			return "", 0, fmt.Errorf("registry: token request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		if err != nil {
+			return "", 0, fmt.Errorf("registry: reading token response: %w", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return "", 0, &ResponseError{StatusCode: resp.StatusCode}
+		}
+
+		// Servers send the token as "token", "access_token", or both.
+		var tr struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err := json.Unmarshal(body, &tr); err != nil {
+			return "", 0, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+		token := tr.Token
+		if token == "" {
+			token = tr.AccessToken
+		}
+		if token == "" {
+			return "", 0, fmt.Errorf("%w: no token", ErrInvalidResponse)
+		}
+		lifespan := DefaultExpiry
+		if tr.ExpiresIn > 0 {
+			lifespan = time.Duration(tr.ExpiresIn) * time.Second
+		}
+		return token, lifespan, nil
+	}
+}
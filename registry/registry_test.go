@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Challenge
+		err    bool
+	}{
+		{`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/alpine:pull"`,
+			Challenge{"https://auth.docker.io/token", "registry.docker.io", []string{"repository:library/alpine:pull"}}, false},
+		{`Bearer realm="https://r/token", scope="repository:b:pull,push repository:a:pull"`,
+			Challenge{"https://r/token", "", []string{"repository:a:pull", "repository:b:pull,push"}}, false},
+		{`Bearer realm=https://r/token,service=r`, Challenge{"https://r/token", "r", nil}, false},
+		{`Basic realm="registry"`, Challenge{}, true},
+		{`Bearer service="r"`, Challenge{}, true},
+	}
+	for _, tt := range tests {
+		c, err := ParseChallenge(tt.header)
+		if (err != nil) != tt.err || !reflect.DeepEqual(c, tt.want) {
+			t.Errorf("%s: want (%+v, err %v), got (%+v, %v)", tt.header, tt.want, tt.err, c, err)
+		}
+		if err == nil {
+			if back, _ := ParseChallenge(c.Key()); !reflect.DeepEqual(back, c) {
+				t.Errorf("key %s does not round-trip: %+v", c.Key(), back)
+			}
+		}
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		q := r.URL.Query()
+		if user != "u" || pass != "p" || q.Get("service") != "reg" || len(q["scope"]) != 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token":"tok"}`))
+	}))
+	defer srv.Close()
+
+	c := Challenge{Realm: srv.URL, Service: "reg", Scopes: []string{"repository:a:pull", "repository:b:pull"}}
+	token, lifespan, err := NewAuthorizer(c, WithBasicAuth("u", "p"))(context.Background())
+	if err != nil || token != "tok" || lifespan != DefaultExpiry {
+		t.Fatalf("want (tok, 60s, nil), got (%q, %v, %v)", token, lifespan, err)
+	}
+
+	_, _, err = NewAuthorizer(c)(context.Background())
+	var re *ResponseError
+	if !errors.As(err, &re) || re.StatusCode != http.StatusUnauthorized || re.Temporary() {
+		t.Fatalf("want permanent 401 error, got %v", err)
+	}
+}
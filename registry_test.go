@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/appliedgo/refresh/registry"
+)
+
+// The manager keeps one token per registry challenge, and equal challenges share a token.
+func TestRegistryFactory(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"token":"` + r.URL.Query().Get("scope") + `","expires_in":300}`))
+	}))
+	defer srv.Close()
+
+	m := NewManager(WithFactory(RegistryFactory([]registry.Option{registry.WithBasicAuth("u", "p")}, WithLogger(NopLogger))))
+	defer m.Close()
+
+	pull := `Bearer realm="` + srv.URL + `",service="reg",scope="repository:app:pull"`
+	push := `Bearer realm="` + srv.URL + `",service="reg",scope="repository:app:push"`
+	for _, key := range []string{pull, push, pull} {
+		c, err := registry.ParseChallenge(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := m.Get(context.Background(), c.Key())
+		if err != nil || token != c.Scopes[0] {
+			t.Fatalf("want token %q, got (%q, %v)", c.Scopes[0], token, err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("want 2 token requests, got %d", n)
+	}
+	if _, err := m.Get(context.Background(), "Basic realm=x"); err == nil {
+		t.Fatal("want error for non-bearer challenge")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A restart for each config change interrupts the service. A `ConfigReloader` applies a changed config file to the running `Manager` instead. It compares the new config with the current one, entry by entry:
+//   - A new entry gets a new token.
+//   - A removed entry's token gets closed, and the reload waits for its goroutines to stop.
+//   - An entry with new refresh settings (margins, backoff, jitter) keeps its token and its current value. `Reconfigure()` applies the settings.
+//   - An entry with a new source, such as rotated credentials or a new endpoint, keeps its token, too. `SwapAuthorizer()` switches it over once the new source has delivered a token. If the new source fails, the token keeps the old one.
+//   - Tokens that support neither, such as on-demand tokens, get replaced by new ones.
+
+// A `ConfigReloader` owns a `Manager` built from a config file and applies changes of the file to it.
+type ConfigReloader struct {
+	path   string
+	loader *configLoader
+	m      *Manager
+	// `mu` serializes reloads. `current` is the config that the tokens run with.
+	mu      sync.Mutex
+	current map[string]TokenConfig
+}
+
+// `WithConfigReloadHook` sets a function that `ConfigReloader.Watch` calls after each reload, with the reload's error or nil.
+func WithConfigReloadHook(f func(err error)) ConfigOption {
+	return func(l *configLoader) {
+		l.onReload = f
+	}
+}
+
+// `NewConfigReloader` reads the config file at `path` and builds a `Manager` from it, as `LoadManagerConfig` does.
+func NewConfigReloader(path string, opts ...ConfigOption) (*ConfigReloader, error) {
+	l := newConfigLoader(opts)
+	cfg, err := l.read(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := l.build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigReloader{path: path, loader: l, m: m, current: cfg.Tokens}, nil
+}
+
+// Method `Manager` returns the `Manager` whose tokens the reloader maintains. Look up tokens by name on each use, as a reload may replace them.
+func (r *ConfigReloader) Manager() *Manager {
+	return r.m
+}
+
+// Method `Close` closes the `Manager`.
+func (r *ConfigReloader) Close() error {
+	return r.m.Close()
+}
+
+// Method `Reload` reads the config file again and applies the changes. If the file cannot be read or any changed entry is invalid, `Reload` returns an error and changes nothing. If changes fail to apply to some tokens, for example, because a new source fails, these tokens keep their current config, the other changes take effect, and `Reload` returns the errors.
+func (r *ConfigReloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cfg, err := r.loader.read(r.path)
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		auth func(context.Context) (string, time.Duration, error)
+		opts []Option
+	}
+	changed := make(map[string]entry)
+	var errs []error
+	for name, tc := range cfg.Tokens {
+		if old, ok := r.current[name]; ok && reflect.DeepEqual(old, tc) {
+			continue
+		}
+		auth, opts, err := r.loader.prepare(r.m.ctx, name, tc)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changed[name] = entry{auth, opts}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	next := make(map[string]TokenConfig, len(cfg.Tokens))
+	for name, tc := range r.current {
+		if _, ok := cfg.Tokens[name]; !ok {
+			r.m.Remove(name)
+			continue
+		}
+		next[name] = tc
+	}
+	for name, e := range changed {
+		tc := cfg.Tokens[name]
+		old, existed := r.current[name]
+		t, ok := r.m.Token(name)
+		if !existed || !ok || old.JWTExpiry != tc.JWTExpiry {
+			r.replace(name, t, e.auth, e.opts)
+			next[name] = tc
+			continue
+		}
+		if err := r.update(ctx, t, old, tc, e.auth, e.opts); err != nil {
+			if !errors.Is(err, ErrSwapUnsupported) && !errors.Is(err, errors.ErrUnsupported) {
+				errs = append(errs, fmt.Errorf("refresh: reloading token %q: %w", name, err))
+				continue
+			}
+			r.replace(name, t, e.auth, e.opts)
+		}
+		next[name] = tc
+	}
+	r.current = next
+	return errors.Join(errs...)
+}
+
+// Method `update` applies a changed config to the token `t` in place.
+func (r *ConfigReloader) update(ctx context.Context, t *Token, old, tc TokenConfig, auth func(context.Context) (string, time.Duration, error), opts []Option) error {
+	if !reflect.DeepEqual(old.source(), tc.source()) {
+		if _, err := t.swapAuthorizer(ctx, auth); err != nil {
+			return err
+		}
+	}
+	if !reflect.DeepEqual(old.tuning(), tc.tuning()) {
+		return t.Reconfigure(ctx, opts...)
+	}
+	return nil
+}
+
+// Method `replace` registers a new token under `name` and closes the previous one, if any.
+func (r *ConfigReloader) replace(name string, prev *Token, auth func(context.Context) (string, time.Duration, error), opts []Option) {
+	r.m.Register(name, NewTokenContext(r.m.ctx, auth, opts...))
+	if prev != nil {
+		prev.Close()
+	}
+}
+
+// Method `tuning` returns the refresh settings of `tc`, which `Reconfigure()` can change.
+func (tc TokenConfig) tuning() TokenConfig {
+	return TokenConfig{
+		SafetyMargin:   tc.SafetyMargin,
+		SafetyFraction: tc.SafetyFraction,
+		AdaptiveMargin: tc.AdaptiveMargin,
+		Backoff:        tc.Backoff,
+		BackoffJitter:  tc.BackoffJitter,
+		RefreshJitter:  tc.RefreshJitter,
+	}
+}
+
+// Method `source` returns `tc` without its refresh settings.
+func (tc TokenConfig) source() TokenConfig {
+	tc.SafetyMargin, tc.SafetyFraction, tc.AdaptiveMargin = 0, 0, false
+	tc.Backoff, tc.BackoffJitter, tc.RefreshJitter = nil, 0, 0
+	return tc
+}
+
+// Method `Watch` reloads the config on SIGHUP and, if `interval` is positive, whenever the file's modification time or size has changed since the last check. It returns when `ctx` is done. The hook of `WithConfigReloadHook` receives the result of each reload.
+func (r *ConfigReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	last, _ := os.Stat(r.path)
+	for {
+		select {
+		case <-hup:
+		case <-tick:
+			fi, err := os.Stat(r.path)
+			if err != nil || (last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size()) {
+				continue
+			}
+			last = fi
+		case <-ctx.Done():
+			return
+		}
+		err := r.Reload(ctx)
+		if r.loader.onReload != nil {
+			r.loader.onReload(err)
+		}
+	}
+}
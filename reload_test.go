@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConfigReloader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	fileA, fileB := write("a", "token-a"), write("b", "token-b")
+	var created atomic.Int32
+	counting := func(_ context.Context, tc TokenConfig) (func(context.Context) (string, time.Duration, error), error) {
+		created.Add(1)
+		return func(context.Context) (string, time.Duration, error) {
+			return tc.Params["value"], time.Hour, nil
+		}, nil
+	}
+	config := func(tokens string) string {
+		return write("tokens.json", `{"tokens": {`+tokens+`}}`)
+	}
+	path := config(fmt.Sprintf(`
+		"tuned": {"type": "file", "path": %q, "lifespan": "1h"},
+		"moved": {"type": "file", "path": %q, "lifespan": "1h"},
+		"removed": {"type": "custom", "factory": "counting", "params": {"value": "x"}}`, fileA, fileA))
+
+	r, err := NewConfigReloader(path, WithConfigFactory("counting", counting), WithConfigTokenOptions(WithLogger(NopLogger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	m := r.Manager()
+	tuned, _ := m.Token("tuned")
+	moved, _ := m.Token("moved")
+	removed, _ := m.Token("removed")
+	if got, _ := moved.Get(); got != "token-a" {
+		t.Fatalf("want token-a, got %q", got)
+	}
+
+	config(fmt.Sprintf(`
+		"tuned": {"type": "file", "path": %q, "lifespan": "1h", "safety_margin": "10m"},
+		"moved": {"type": "file", "path": %q, "lifespan": "1h"},
+		"added": {"type": "custom", "factory": "counting", "params": {"value": "y"}}`, fileA, fileB))
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tok, _ := m.Token("tuned"); tok != tuned {
+		t.Error("tuned token was replaced")
+	}
+	if tok, _ := m.Token("moved"); tok != moved {
+		t.Error("moved token was replaced")
+	}
+	if got, _ := moved.Get(); got != "token-b" {
+		t.Errorf("want token-b from the new source, got %q", got)
+	}
+	if _, ok := m.Token("removed"); ok {
+		t.Error("removed token still registered")
+	}
+	select {
+	case <-removed.Done():
+	default:
+		t.Error("removed token not closed")
+	}
+	if got, err := m.Get(context.Background(), "added"); got != "y" || err != nil {
+		t.Errorf("want y, got (%q, %v)", got, err)
+	}
+
+	// A source that fails keeps the token on the old one. An invalid entry rejects the whole reload.
+	config(fmt.Sprintf(`
+		"tuned": {"type": "file", "path": %q, "lifespan": "1h", "safety_margin": "10m"},
+		"moved": {"type": "file", "path": %q, "lifespan": "1h"},
+		"added": {"type": "custom", "factory": "counting", "params": {"value": "y"}}`, fileA, filepath.Join(dir, "missing")))
+	if err := r.Reload(context.Background()); err == nil {
+		t.Fatal("want error for failing source")
+	}
+	if got, _ := moved.Get(); got != "token-b" {
+		t.Errorf("want token-b kept, got %q", got)
+	}
+	config(`"broken": {"type": "oauth2"}`)
+	if err := r.Reload(context.Background()); err == nil {
+		t.Fatal("want error for invalid entry")
+	}
+	if m.Len() != 3 {
+		t.Errorf("want 3 tokens after rejected reload, got %d", m.Len())
+	}
+}
+
+func TestConfigReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokens.json")
+	write := func(value string) {
+		content := fmt.Sprintf(`{"tokens": {"t": {"type": "custom", "factory": "static", "params": {"value": %q}}}}`, value)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("v1")
+	static := func(_ context.Context, tc TokenConfig) (func(context.Context) (string, time.Duration, error), error) {
+		return func(context.Context) (string, time.Duration, error) {
+			return tc.Params["value"], time.Hour, nil
+		}, nil
+	}
+	reloads := make(chan error, 10)
+	r, err := NewConfigReloader(path, WithConfigFactory("static", static), WithConfigReloadHook(func(err error) { reloads <- err }), WithConfigTokenOptions(WithLogger(NopLogger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx, 5*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	write("v2-changed")
+	select {
+	case err := <-reloads:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no reload after file change")
+	}
+	if got, _ := r.Manager().Get(context.Background(), "t"); got != "v2-changed" {
+		t.Fatalf("want v2-changed, got %q", got)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// A `RetryError` is returned by `Get()` while the refresh loop waits for its next attempt after a failed refresh. Callers can use `RetryAfter()` to tell their users when to try again.
+type RetryError struct {
+	// Err is the error of the failed refresh.
+	Err error
+	// At is the time of the next refresh attempt.
+	At time.Time
+	// now tells the current time of the token's clock.
+	now func() time.Time
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (retrying in %v)", e.Err, e.RetryAfter().Round(time.Millisecond))
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Method `RetryAfter` returns the time left until the next refresh attempt, or zero if the attempt is due.
+func (e *RetryError) RetryAfter() time.Duration {
+	now := time.Now
+	if e.now != nil {
+		now = e.now
+	}
+	return max(e.At.Sub(now()), 0)
+}
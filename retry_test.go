@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	authErr := errors.New("down")
+	auth := func() (string, time.Duration, error) { return "", time.Hour, authErr }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Give up on the first window right away, so that the next attempt is an hour away.
+	_, err := NewToken(ctx, auth, WithRefreshDeadline(time.Nanosecond)).Get()
+	var re *RetryError
+	if !errors.As(err, &re) {
+		t.Fatalf("want a RetryError, got %v", err)
+	}
+	if !errors.Is(err, authErr) || !errors.Is(err, ErrRefreshDeadline) {
+		t.Fatalf("RetryError does not wrap the refresh error: %v", err)
+	}
+	first := re.RetryAfter()
+	if first <= 0 || first > time.Hour {
+		t.Fatalf("want retry-after within the next hour, got %v", first)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if second := re.RetryAfter(); second >= first {
+		t.Fatalf("retry-after did not decrease: %v, then %v", first, second)
+	}
+}
+
+func TestRetryAfterBackoff(t *testing.T) {
+	auth := func() (string, time.Duration, error) { return "", time.Hour, errors.New("down") }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := NewToken(ctx, auth).Get()
+	var re *RetryError
+	if !errors.As(err, &re) {
+		t.Fatalf("want a RetryError, got %v", err)
+	}
+	if d := re.RetryAfter(); d > retryDelay {
+		t.Fatalf("want retry-after of at most %v, got %v", retryDelay, d)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Some apps manage all their long-running loops in one place, for example, with an `errgroup.Group`, and want each loop to report failure as an error. A goroutine that the constructor spawns does not fit into this pattern. With `WithManualRun`, the app runs the refresh loop itself by calling `Run()`.
+
+// `ErrNotManualRun` is returned by `Run()` for tokens created without `WithManualRun`.
+var ErrNotManualRun = errors.New("refresh: Run requires WithManualRun")
+
+// `WithManualRun` leaves running the refresh loop to the caller. The constructor does not start a goroutine, and readers wait until `Run()` is called. `WithLazyStart` and `WithIdleTimeout` have no effect.
+func WithManualRun() Option {
+	return func(o *options) {
+		o.manualRun = true
+	}
+}
+
+// Method `Run` runs the refresh loop in the calling goroutine, until `ctx` is canceled, the token is closed, or a permanent authorization error occurs (see `errclass.go`). In the latter case, `Run` returns the error. Otherwise, it returns nil.
+// When `Run` returns, the token is closed. `Run` can only be called once. On-demand tokens do not support `Run`.
+func (a *Token) Run(ctx context.Context) error {
+	if !a.opts.manualRun {
+		return ErrNotManualRun
+	}
+	if a.onDemand() {
+		return fmt.Errorf("refresh: Run with on-demand refresh: %w", errors.ErrUnsupported)
+	}
+	a.runMu.Lock()
+	if a.running.Load() || a.closed {
+		a.runMu.Unlock()
+		return ErrClosed
+	}
+	a.running.Store(true)
+	a.runMu.Unlock()
+
+	loopCtx, cancel := context.WithCancel(a.runCtx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	var runErr error
+	// `fatal` is called by `schedule()` in this goroutine, so that `runErr` needs no lock.
+	a.fatal = func(err error) {
+		runErr = err
+		cancel()
+	}
+
+	loop := a.refreshToken
+	if a.opts.poolSize > 0 {
+		loop = a.poolLoop
+	}
+	loop(loopCtx)
+
+	a.runMu.Lock()
+	a.running.Store(false)
+	a.runMu.Unlock()
+	a.Close()
+	return runErr
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Readers wait for `Run`, and `Run` returns nil when its context is canceled.
+func TestRun(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithManualRun(), WithLogger(NopLogger))
+
+	got := make(chan string)
+	go func() {
+		token, _ := tok.Get()
+		got <- token
+	}()
+	select {
+	case token := <-got:
+		t.Fatalf("Get returned %q before Run", token)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- tok.Run(ctx) }()
+	if token := <-got; token != "tok" {
+		t.Fatalf("want tok, got %q", token)
+	}
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("want nil after cancel, got %v", err)
+	}
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed after Run returned, got %v", err)
+	}
+	if err := tok.Run(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("second Run: want ErrClosed, got %v", err)
+	}
+}
+
+// A permanent error ends `Run` with that error.
+func TestRunPermanentError(t *testing.T) {
+	errRevoked := errors.New("client revoked")
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "", 0, Permanent(errRevoked)
+	}, WithManualRun(), WithLogger(NopLogger))
+	if err := tok.Run(context.Background()); !errors.Is(err, errRevoked) {
+		t.Fatalf("want permanent error, got %v", err)
+	}
+}
+
+func TestRunWithoutManualRun(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	if err := tok.Run(context.Background()); !errors.Is(err, ErrNotManualRun) {
+		t.Fatalf("want ErrNotManualRun, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Service wiring libraries such as `github.com/oklog/run` and `golang.org/x/sync/errgroup` start a set of actors together and stop all of them when the first one returns. The adapters below let a `Token` take part without glue code. They work with and without `WithManualRun`.
+// Shutdown order matters: an HTTP server that drains its in-flight requests still needs tokens for them. `HTTPServerRunner` therefore closes the tokens only after the server has shut down.
+
+// Method `Runner` returns an actor for `run.Group.Add` of package `github.com/oklog/run`. `execute` runs the token until it is closed, and returns the error of `Run()` with `WithManualRun`, or nil otherwise. `interrupt` closes the token.
+func (a *Token) Runner() (execute func() error, interrupt func(error)) {
+	execute = func() error {
+		return a.RunFunc(context.Background())()
+	}
+	interrupt = func(error) {
+		a.Close()
+	}
+	return execute, interrupt
+}
+
+// Method `RunFunc` returns a function for `errgroup.Group.Go`. The function runs the token until `ctx` is canceled or the token is closed, and closes the token before it returns. With `WithManualRun`, it calls `Run()` and returns its error. Otherwise, it returns nil.
+// Pass the context of `errgroup.WithContext`, so that the token stops when another function of the group fails.
+func (a *Token) RunFunc(ctx context.Context) func() error {
+	return func() error {
+		if a.opts.manualRun {
+			return a.Run(ctx)
+		}
+		select {
+		case <-ctx.Done():
+		case <-a.closing():
+		}
+		return a.Close()
+	}
+}
+
+// `HTTPServerRunner` returns an actor for `run.Group.Add` that runs `srv` and stops it gracefully along with `tokens`. On interrupt, the server gets up to `shutdownTimeout` to finish its in-flight requests, which may still read the tokens. Only then are the tokens closed.
+// The actor does not start the tokens. They may run on their own, or be added to the group with `Runner()`. In the latter case, add the server first. `run.Group` interrupts its actors in the order in which they were added, so the server shuts down before the tokens get closed.
+func HTTPServerRunner(srv *http.Server, shutdownTimeout time.Duration, tokens ...*Token) (execute func() error, interrupt func(error)) {
+	execute = func() error {
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+	interrupt = func(error) {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+		for _, t := range tokens {
+			t.Close()
+		}
+	}
+	return execute, interrupt
+}
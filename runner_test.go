@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	for _, manual := range []bool{false, true} {
+		opts := []Option{WithLogger(NopLogger)}
+		if manual {
+			opts = append(opts, WithManualRun())
+		}
+		tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+			return "tok", time.Hour, nil
+		}, opts...)
+		execute, interrupt := tok.Runner()
+		errc := make(chan error)
+		go func() { errc <- execute() }()
+		if got, err := tok.Get(); err != nil || got != "tok" {
+			t.Fatalf("manual=%v: want tok, got (%q, %v)", manual, got, err)
+		}
+		interrupt(errors.New("another actor stopped"))
+		if err := <-errc; err != nil {
+			t.Fatalf("manual=%v: want nil, got %v", manual, err)
+		}
+	}
+}
+
+func TestRunFunc(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error)
+	go func() { errc <- tok.RunFunc(ctx)() }()
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}
+
+// A request in flight during shutdown still gets a token.
+func TestHTTPServerRunner(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	entered, release := make(chan struct{}), make(chan struct{})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		token, err := tok.Get()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, token)
+	})}
+	execute, interrupt := HTTPServerRunner(srv, time.Second, tok)
+	errc := make(chan error)
+	go func() { errc <- execute() }()
+
+	body := make(chan string)
+	go func() {
+		for {
+			resp, err := http.Get("http://" + addr)
+			if err != nil {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body <- string(b)
+			return
+		}
+	}()
+	<-entered
+	interrupted := make(chan struct{})
+	go func() {
+		interrupt(nil)
+		close(interrupted)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if got := <-body; got != "tok" {
+		t.Fatalf("want tok during shutdown, got %q", got)
+	}
+	<-interrupted
+	if err := <-errc; err != nil {
+		t.Fatalf("want nil, got %v", err)
+	}
+	if _, err := tok.Get(); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want token closed after shutdown, got %v", err)
+	}
+}
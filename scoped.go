@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 tokens carry scopes, and different parts of an app need different ones. One token per scope set works but may fetch many tokens that overlap. Authorization servers that issue a token for several scopes at once allow for fewer tokens: a token for "read write" also serves requests that only need "read", and a single token for the union of all scopes that the app ever asked for serves all requests.
+// A `ScopedToken` manages the tokens per scope set. Callers ask for the scopes that they need, and the `ScopedToken` decides which token serves them.
+
+// `defaultMaxScopeSets` is the number of tokens that a `ScopedToken` keeps by default.
+const defaultMaxScopeSets = 8
+
+// A `ScopedToken` keeps a small set of tokens, each for a set of scopes.
+type ScopedToken struct {
+	mu      sync.Mutex
+	auth    func(ctx context.Context, scopes []string) (string, time.Duration, error)
+	opts    []Option
+	entries []*scopedEntry
+	// `tick` orders the uses of the entries for evicting the least recently used one.
+	tick      uint64
+	reuse     bool
+	union     bool
+	maxTokens int
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closed    bool
+}
+
+// A `scopedEntry` is a token along with its scopes, sorted and without duplicates.
+type scopedEntry struct {
+	scopes []string
+	t      *Token
+	used   uint64
+}
+
+// `ScopedOption` configures a `ScopedToken`.
+type ScopedOption func(*ScopedToken)
+
+// `WithScopedTokenOptions` sets the options for each token that the `ScopedToken` creates.
+func WithScopedTokenOptions(opts ...Option) ScopedOption {
+	return func(s *ScopedToken) {
+		s.opts = append(s.opts, opts...)
+	}
+}
+
+// `WithBroaderTokenReuse` lets a token serve any request for a subset of its scopes. Only use this if the APIs accept tokens with more scopes than needed. If several tokens qualify, the one with the fewest scopes serves the request.
+func WithBroaderTokenReuse() ScopedOption {
+	return func(s *ScopedToken) {
+		s.reuse = true
+	}
+}
+
+// `WithScopeUnion` keeps a single token for the union of all scopes requested so far. A request for a scope that the token lacks replaces the token by one for the larger union. `WithScopeUnion` implies `WithBroaderTokenReuse`.
+func WithScopeUnion() ScopedOption {
+	return func(s *ScopedToken) {
+		s.union = true
+		s.reuse = true
+	}
+}
+
+// `WithMaxScopeSets` limits the number of tokens. If a request needs a new token beyond the limit, the least recently used token gets closed. The default is 8.
+func WithMaxScopeSets(n int) ScopedOption {
+	if n <= 0 {
+		panic("refresh: WithMaxScopeSets limit must be positive")
+	}
+	return func(s *ScopedToken) {
+		s.maxTokens = n
+	}
+}
+
+// `NewScopedToken` returns a `ScopedToken` that fetches tokens with `auth`. `auth` receives the scopes of the token to fetch, sorted and without duplicates. The tokens live until `ctx` is canceled or `Close()` is called.
+func NewScopedToken(ctx context.Context, auth func(ctx context.Context, scopes []string) (string, time.Duration, error), opts ...ScopedOption) *ScopedToken {
+	s := &ScopedToken{
+		auth:      auth,
+		maxTokens: defaultMaxScopeSets,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	return s
+}
+
+// Method `Get` returns a token that carries at least `scopes`. The first request for a scope set creates a token for it, unless an existing token can serve it.
+func (s *ScopedToken) Get(ctx context.Context, scopes ...string) (string, error) {
+	for {
+		t, err := s.tokenFor(scopes)
+		if err != nil {
+			return "", err
+		}
+		token, err := t.GetContext(ctx)
+		// The token may have been replaced or evicted since the lookup. The next lookup finds its successor.
+		if errors.Is(err, ErrClosed) && s.ctx.Err() == nil {
+			continue
+		}
+		return token, err
+	}
+}
+
+// Method `tokenFor` finds or creates the token that serves `scopes`.
+func (s *ScopedToken) tokenFor(scopes []string) (*Token, error) {
+	set := normalizeScopes(scopes)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.ctx.Err() != nil {
+		return nil, ErrClosed
+	}
+	s.tick++
+	if e := s.find(set); e != nil {
+		e.used = s.tick
+		return e.t, nil
+	}
+	var retired []*scopedEntry
+	if s.union && len(s.entries) > 0 {
+		for _, e := range s.entries {
+			set = normalizeScopes(append(set, e.scopes...))
+		}
+		retired, s.entries = s.entries, nil
+	}
+	if len(s.entries) >= s.maxTokens {
+		lru := 0
+		for i, e := range s.entries {
+			if e.used < s.entries[lru].used {
+				lru = i
+			}
+		}
+		retired = append(retired, s.entries[lru])
+		s.entries = slices.Delete(s.entries, lru, lru+1)
+	}
+	// Closing waits for the refresh goroutine. Do this without holding the lock.
+	for _, e := range retired {
+		go e.t.Close()
+	}
+	e := &scopedEntry{scopes: set, used: s.tick}
+	e.t = NewTokenContext(s.ctx, func(ctx context.Context) (string, time.Duration, error) {
+		return s.auth(ctx, set)
+	}, s.opts...)
+	s.entries = append(s.entries, e)
+	return e.t, nil
+}
+
+// Method `find` returns the entry that serves `set`: the one with exactly these scopes or, with reuse enabled, the one with the fewest scopes that include them.
+func (s *ScopedToken) find(set []string) *scopedEntry {
+	var best *scopedEntry
+	for _, e := range s.entries {
+		if slices.Equal(e.scopes, set) {
+			return e
+		}
+		if s.reuse && includesScopes(e.scopes, set) && (best == nil || len(e.scopes) < len(best.scopes)) {
+			best = e
+		}
+	}
+	return best
+}
+
+// Method `Scopes` returns the scope sets of the current tokens.
+func (s *ScopedToken) Scopes() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sets := make([][]string, len(s.entries))
+	for i, e := range s.entries {
+		sets[i] = slices.Clone(e.scopes)
+	}
+	return sets
+}
+
+// Method `Close` closes all tokens. Afterwards, `Get` returns `ErrClosed`.
+func (s *ScopedToken) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	entries := s.entries
+	s.entries = nil
+	s.mu.Unlock()
+	s.cancel()
+	for _, e := range entries {
+		e.t.Close()
+	}
+	return nil
+}
+
+// `normalizeScopes` sorts the scopes and removes duplicates and empty strings.
+func normalizeScopes(scopes []string) []string {
+	set := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		if sc = strings.TrimSpace(sc); sc != "" {
+			set = append(set, sc)
+		}
+	}
+	slices.Sort(set)
+	return slices.Compact(set)
+}
+
+// `includesScopes` reports whether the sorted scope set `have` contains all of the sorted scope set `want`.
+func includesScopes(have, want []string) bool {
+	for _, w := range want {
+		if _, ok := slices.BinarySearch(have, w); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// `scopeAuth` returns an authorization function that issues tokens named after their scopes, and a function that returns the scope sets requested so far.
+func scopeAuth() (func(context.Context, []string) (string, time.Duration, error), func() []string) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	auth := func(_ context.Context, scopes []string) (string, time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		token := strings.Join(scopes, "+")
+		calls = append(calls, token)
+		return token, time.Hour, nil
+	}
+	return auth, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(calls)
+	}
+}
+
+func TestScopedToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []ScopedOption
+		gets  [][]string
+		want  []string
+		calls []string
+	}{
+		{
+			name:  "per scope set",
+			gets:  [][]string{{"write", "read"}, {"read"}, {"read", "write", "read"}},
+			want:  []string{"read+write", "read", "read+write"},
+			calls: []string{"read+write", "read"},
+		},
+		{
+			name:  "broader token reuse",
+			opts:  []ScopedOption{WithBroaderTokenReuse()},
+			gets:  [][]string{{"read", "write"}, {"read"}, {"admin"}},
+			want:  []string{"read+write", "read+write", "admin"},
+			calls: []string{"read+write", "admin"},
+		},
+		{
+			name:  "union",
+			opts:  []ScopedOption{WithScopeUnion()},
+			gets:  [][]string{{"read"}, {"write"}, {"read"}},
+			want:  []string{"read", "read+write", "read+write"},
+			calls: []string{"read", "read+write"},
+		},
+		{
+			name:  "eviction",
+			opts:  []ScopedOption{WithMaxScopeSets(1)},
+			gets:  [][]string{{"read"}, {"write"}, {"read"}},
+			want:  []string{"read", "write", "read"},
+			calls: []string{"read", "write", "read"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			auth, calls := scopeAuth()
+			opts := append(tt.opts, WithScopedTokenOptions(WithLogger(NopLogger)))
+			s := NewScopedToken(context.Background(), auth, opts...)
+			defer s.Close()
+			for i, scopes := range tt.gets {
+				got, err := s.Get(context.Background(), scopes...)
+				if err != nil || got != tt.want[i] {
+					t.Fatalf("Get(%v): want %q, got (%q, %v)", scopes, tt.want[i], got, err)
+				}
+			}
+			if got := calls(); !slices.Equal(got, tt.calls) {
+				t.Fatalf("want authorization calls %v, got %v", tt.calls, got)
+			}
+		})
+	}
+}
+
+func TestScopedTokenClose(t *testing.T) {
+	auth, _ := scopeAuth()
+	s := NewScopedToken(context.Background(), auth)
+	if _, err := s.Get(context.Background(), "read"); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, err := s.Get(context.Background(), "read"); err != ErrClosed {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+	if n := len(s.Scopes()); n != 0 {
+		t.Fatalf("want no tokens after Close, got %d", n)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+)
+
+// A token in a log file is as good as a token in the hands of whoever reads the log. Still, logs need to tell tokens apart: Did the client use the token that was just refreshed, or the previous one? A short hash answers that question without revealing the token.
+
+// A `Secret` is a string that never shows up in logs or formatted output. `fmt`, `log/slog`, and `encoding/json` print only a short hash prefix, such as "[redacted sha256:1a2b3c4d]", which identifies the value without revealing it. Convert a `Secret` to `string` to get the value.
+type Secret string
+
+// Method `String` returns the redacted form of the secret.
+func (s Secret) String() string {
+	if s == "" {
+		return "[empty]"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return "[redacted sha256:" + hex.EncodeToString(sum[:4]) + "]"
+}
+
+// Method `Format` prints the redacted form for every verb, including `%x` and `%#v`, which would otherwise bypass `String`.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", s.String())
+		return
+	}
+	fmt.Fprint(f, s.String())
+}
+
+// Method `LogValue` makes `log/slog` print the redacted form.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// Method `MarshalText` makes encoders such as `encoding/json` write the redacted form.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Method `String` returns the stored token in redacted form, along with its expiry time, so that a `StoredToken` can be logged safely.
+func (st StoredToken) String() string {
+	return fmt.Sprintf("{%v expires %v}", Secret(st.Token), st.ExpiresAt)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretRedacted(t *testing.T) {
+	const token = "eyJhbGciOiJIUzI1NiJ9.c2VjcmV0.c2lnbmF0dXJl"
+	s := Secret(token)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("refreshed", "token", s)
+	js, _ := json.Marshal(struct{ Token Secret }{s})
+	outputs := []string{
+		fmt.Sprint(s),
+		fmt.Sprintf("%s %v %+v %#v %q %x %X", s, s, s, s, s, s, s),
+		fmt.Sprintf("%v", StoredToken{Token: token, ExpiresAt: time.Now()}),
+		buf.String(),
+		string(js),
+	}
+	for _, out := range outputs {
+		if strings.Contains(out, token) || strings.Contains(out, fmt.Sprintf("%x", token)) {
+			t.Errorf("token revealed: %s", out)
+		}
+		if !strings.Contains(out, "[redacted sha256:") {
+			t.Errorf("no redacted form: %s", out)
+		}
+	}
+	if string(s) != token {
+		t.Fatal("conversion to string must reveal the value")
+	}
+}
+
+// The hash prefix tells different secrets apart and is stable for the same secret.
+func TestSecretHash(t *testing.T) {
+	a, b := Secret("token-a").String(), Secret("token-b").String()
+	if a == b || a != Secret("token-a").String() {
+		t.Fatalf("want distinct stable hashes, got %s and %s", a, b)
+	}
+	if got := Secret("").String(); got != "[empty]" {
+		t.Fatalf("want [empty], got %s", got)
+	}
+}
+
+// The token's own log entries carry the redacted token only.
+func TestSecretInLogs(t *testing.T) {
+	const token = "s3cr3t-t0k3n"
+	var buf bytes.Buffer
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return token, time.Hour, nil
+	}, WithOnDemandRefresh(), WithLogger(NewSlogLogger(slog.NewTextHandler(&buf, nil))))
+	defer tok.Close()
+	tok.Get()
+	if out := buf.String(); strings.Contains(out, token) || !strings.Contains(out, Secret(token).String()) {
+		t.Fatalf("want redacted token in log, got %s", out)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/appliedgo/refresh/redisstore"
+)
+
+// A service with many replicas runs one refresh loop per replica. Each loop fetches its own token, although one token would do for all of them. This wastes quota and, with enough replicas, trips the rate limits of the authorization server. A `SharedStore` lets the replicas share one token: before a replica calls the authorization function, it checks the store for a token that another replica fetched. If there is none, the replica takes a lock, so that only one replica fetches at a time, while the others poll the store until the new token arrives.
+
+// A `SharedStore` is a `Store` that is shared between processes and provides a lock. `TryLock` acquires the lock for at most `ttl` and reports whether it succeeded. `unlock` releases the lock, if the caller still holds it.
+type SharedStore interface {
+	Store
+	TryLock(ctx context.Context, ttl time.Duration) (unlock func(ctx context.Context) error, ok bool, err error)
+}
+
+// `defaultLockTTL` is the lock duration if `WithSharedStore` gets none.
+const defaultLockTTL = 10 * time.Second
+
+// `WithSharedStore` shares the token with other processes through `s`. A replica that does not get the lock waits for at most `lockTTL` for the lock holder to deliver a token, then fetches a token itself. `lockTTL` should exceed the time that the authorization function takes. Zero means 10 seconds. Errors of the store are logged, and the replica falls back to fetching on its own.
+// `WithSharedStore` implies `WithStore(s)`.
+func WithSharedStore(s SharedStore, lockTTL time.Duration) Option {
+	return func(o *options) {
+		o.store = s
+		o.shared = s
+		o.sharedLockTTL = lockTTL
+		if o.sharedLockTTL <= 0 {
+			o.sharedLockTTL = defaultLockTTL
+		}
+	}
+}
+
+// Method `coordinate` returns a token from the shared store if there is a usable one, or waits for the replica that holds the lock to deliver one. If this replica has to fetch the token itself, `coordinate` returns false along with a function that releases the lock after the fetch.
+func (a *Token) coordinate(ctx context.Context) (tokenResponse, time.Duration, func(), bool) {
+	noop := func() {}
+	if resp, lifespan, ok := a.loadShared(ctx); ok {
+		return resp, lifespan, noop, true
+	}
+	ttl := a.opts.sharedLockTTL
+	poll := max(ttl/20, 10*time.Millisecond)
+	deadline := a.now().Add(ttl)
+	for {
+		unlock, locked, err := a.opts.shared.TryLock(ctx, ttl)
+		if err != nil {
+			a.logEvent(ctx, EventRefreshError, "Cannot lock shared store", "err", err)
+			return tokenResponse{}, 0, noop, false
+		}
+		if locked {
+			release := func() {
+				if err := unlock(ctx); err != nil {
+					a.logEvent(ctx, EventRefreshError, "Cannot unlock shared store", "err", err)
+				}
+			}
+			// Another replica may have stored a token between the load and the lock.
+			if resp, lifespan, ok := a.loadShared(ctx); ok {
+				release()
+				return resp, lifespan, noop, true
+			}
+			return tokenResponse{}, 0, release, false
+		}
+		if !a.now().Before(deadline) {
+			a.logEvent(ctx, EventRefreshError, "Lock holder did not deliver a token; fetching")
+			return tokenResponse{}, 0, noop, false
+		}
+		select {
+		case <-a.after(poll):
+		case <-ctx.Done():
+			return tokenResponse{Err: ctx.Err()}, 0, noop, true
+		}
+		if resp, lifespan, ok := a.loadShared(ctx); ok {
+			return resp, lifespan, noop, true
+		}
+	}
+}
+
+// Method `loadShared` returns the token in the shared store if it is not the token that this replica has already seen, and if its remaining lifespan exceeds the safety margin.
+func (a *Token) loadShared(ctx context.Context) (tokenResponse, time.Duration, bool) {
+	st, ok, err := a.opts.store.Load(ctx)
+	if err != nil {
+		a.logEvent(ctx, EventRefreshError, "Cannot load token from shared store", "err", err)
+		return tokenResponse{}, 0, false
+	}
+	now := a.now()
+	remaining := st.ExpiresAt.Sub(now)
+	if seen := a.sharedSeen.Load(); !ok || st.Token == "" || remaining <= a.storeMargin() || (seen != nil && *seen == st.Token) {
+		return tokenResponse{}, 0, false
+	}
+	a.sharedSeen.Store(&st.Token)
+	a.stats.success(now, st.ExpiresAt)
+	a.logEvent(ctx, EventRefresh, "Token loaded from shared store", "expiresAt", st.ExpiresAt)
+	return tokenResponse{Token: st.Token, ExpiresAt: st.ExpiresAt, FetchedAt: now}, remaining, true
+}
+
+// `RedisStore` is a `SharedStore` on a Redis server. The token is stored as JSON under `Key`, and expires along with the token. The lock is the key `Key` + ":lock", set with NX and a TTL, and released only by its holder.
+type RedisStore struct {
+	Client *redisstore.Client
+	Key    string
+}
+
+// Method `Load` implements `Store`.
+func (s RedisStore) Load(ctx context.Context) (StoredToken, bool, error) {
+	v, ok, err := s.Client.Get(ctx, s.Key)
+	if err != nil || !ok {
+		return StoredToken{}, false, err
+	}
+	var t StoredToken
+	if err := json.Unmarshal([]byte(v), &t); err != nil {
+		return StoredToken{}, false, err
+	}
+	return t, true, nil
+}
+
+// Method `Save` implements `Store`. A token that has expired already is not saved.
+func (s RedisStore) Save(ctx context.Context, t StoredToken) error {
+	ttl := time.Until(t.ExpiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, s.Key, string(b), ttl)
+}
+
+// Method `TryLock` implements `SharedStore`. Each lock gets a random value, so that a replica whose lock has expired cannot release the lock of another replica.
+func (s RedisStore) TryLock(ctx context.Context, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, false, err
+	}
+	owner := hex.EncodeToString(b)
+	ok, err := s.Client.SetNX(ctx, s.Key+":lock", owner, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return func(ctx context.Context) error {
+		_, err := s.Client.DeleteIfEqual(ctx, s.Key+":lock", owner)
+		return err
+	}, true, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/redisstore"
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// Replicas that share a store fetch only one token between them.
+func TestSharedStore(t *testing.T) {
+	srv, err := refreshtest.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		n := calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return "token-" + strconv.Itoa(int(n)), time.Hour, nil
+	}
+
+	const replicas = 5
+	var wg sync.WaitGroup
+	tokens := make([]string, replicas)
+	for i := 0; i < replicas; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := RedisStore{Client: &redisstore.Client{Addr: srv.Addr()}, Key: "api-token"}
+			defer store.Client.Close()
+			tok := NewToken(context.Background(), auth, WithSharedStore(store, time.Second), WithLogger(NopLogger))
+			defer tok.Close()
+			tokens[i], _ = tok.Get()
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 authorization call, got %d", n)
+	}
+	for i, tk := range tokens {
+		if tk != "token-1" {
+			t.Fatalf("replica %d: want token-1, got %q", i, tk)
+		}
+	}
+}
+
+// A replica whose token was rejected fetches a new one, rather than loading the rejected token again.
+func TestSharedStoreForceRefresh(t *testing.T) {
+	srv, err := refreshtest.NewRedis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	store := RedisStore{Client: &redisstore.Client{Addr: srv.Addr()}, Key: "api-token"}
+	defer store.Client.Close()
+
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "token-" + strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}, WithSharedStore(store, time.Second), WithLogger(NopLogger))
+	defer tok.Close()
+
+	tok.Get()
+	if err := tok.ForceRefresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tok.Get(); got != "token-2" {
+		t.Fatalf("want token-2, got %q", got)
+	}
+	if st, _, _ := store.Load(context.Background()); st.Token != "token-2" {
+		t.Fatalf("want token-2 in store, got %q", st.Token)
+	}
+}
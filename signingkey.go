@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Some APIs pair an access token with a request-signing key and rotate both together. A token and its key must never get mixed up with a token or key of another generation. Both therefore travel through the `accessToken` channel in the same `tokenResponse`.
+
+// `NewSigningToken` works like `NewToken` but receives an authorization function that returns a signing key along with each token.
+func NewSigningToken(ctx context.Context, auth func() (token string, key []byte, lifespan time.Duration, err error), opts ...Option) *Token {
+	a := newToken(opts)
+	a.authorizeWithKey = func(context.Context) (string, []byte, time.Duration, error) {
+		return auth()
+	}
+	a.start(ctx)
+	return a
+}
+
+// Method `GetSigningKey` returns the signing key of the current token or an error. For tokens created by `NewToken`, the key is nil.
+func (a *Token) GetSigningKey() ([]byte, error) {
+	t := a.receive()
+	return t.Key, t.Err
+}
+
+// Method `GetSigned` returns the current token along with its signing key. Unlike separate calls to `Get()` and `GetSigningKey()`, which might straddle a refresh, both values are guaranteed to belong to the same generation.
+func (a *Token) GetSigned() (string, []byte, error) {
+	t := a.receive()
+	return t.Token, t.Key, t.Err
+}
+
+// Method `GetSignedContext` works like `GetSigned()` but stops waiting when `ctx` is done, as `GetContext()` does.
+func (a *Token) GetSignedContext(ctx context.Context) (string, []byte, error) {
+	t := a.getContext(ctx)
+	return t.Token, t.Key, t.Err
+}
+
+// `withoutKey` adapts an authorization function without signing key to the signature of one with a key.
+func withoutKey(auth func(context.Context) (string, time.Duration, error)) func(context.Context) (string, []byte, time.Duration, error) {
+	return func(ctx context.Context) (string, []byte, time.Duration, error) {
+		token, lifespan, err := auth(ctx)
+		return token, nil, lifespan, err
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSigningKeyGenerations(t *testing.T) {
+	var gen atomic.Int32
+	auth := func() (string, []byte, time.Duration, error) {
+		n := strconv.Itoa(int(gen.Add(1)))
+		return "tok" + n, []byte("key" + n), 15 * time.Millisecond, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewSigningToken(ctx, auth)
+
+	seen := map[string]bool{}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		token, key, err := tok.GetSigned()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimPrefix(token, "tok") != strings.TrimPrefix(string(key), "key") {
+			t.Fatalf("mismatched pair: %s, %s", token, key)
+		}
+		seen[token] = true
+
+		key, err = tok.GetSigningKey()
+		if err != nil || !strings.HasPrefix(string(key), "key") {
+			t.Fatalf("GetSigningKey: (%s, %v)", key, err)
+		}
+	}
+	if len(seen) < 3 {
+		t.Fatalf("want pairs from several generations, got %d", len(seen))
+	}
+}
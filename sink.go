@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+)
+
+// Some processes read their credentials only from files: a database client that reads a password file, a proxy that includes a header file, `kubectl` with its kubeconfig. A sidecar that keeps such a file up to date lets these processes use refreshed tokens without knowing anything about refreshing. A `Sink` receives each new token, and a `FileSink` writes it to a file, in any format that a template describes (see `FormatTemplate`).
+//
+// A reader must never see a half-written file, so `FileSink` writes to a temporary file in the same directory and renames it over the target. The rename is atomic: readers see either the old or the new content. Processes that read the file only at startup or on a signal can get a SIGHUP after each write.
+
+// A `Sink` receives each new token after a successful refresh.
+type Sink interface {
+	Write(token string, expiresAt time.Time) error
+}
+
+// `WithSink` sends each new token to `s`. Use the option once per sink to send tokens to several sinks. Like the hooks of `WithOnRefresh`, the sinks run in a separate goroutine and never delay token delivery.
+func WithSink(s Sink) Option {
+	return func(o *options) {
+		o.sinks = append(o.sinks, s)
+	}
+}
+
+// `WithOnSinkError` sets a function that is called when a sink fails, with the failing sink and its error. Sink errors are logged as `EventSinkError` in any case.
+func WithOnSinkError(f func(s Sink, err error)) Option {
+	return func(o *options) {
+		o.onSinkError = f
+	}
+}
+
+// Method `writeSinks` passes a new token to each sink. A failing sink does not keep the others from getting the token.
+func (a *Token) writeSinks(token string, expiresAt time.Time) {
+	for i, s := range a.opts.sinks {
+		if err := s.Write(token, expiresAt); err != nil {
+			a.logEvent(context.Background(), EventSinkError, "Sink failed", "sink", i, "err", err)
+			if a.opts.onSinkError != nil {
+				a.opts.onSinkError(s, err)
+			}
+		}
+	}
+}
+
+// A `FileSink` writes each new token atomically to the file at `Path`.
+type FileSink struct {
+	// `Path` is the target file.
+	Path string
+	// `Mode` is the file mode of the target file. Zero means 0600.
+	Mode os.FileMode
+	// `Format` renders the file content from the token, for example, a kubeconfig or an environment file with `export TOKEN=...`. `FormatTemplate` returns a `Format` function for a Go template. Nil means the token followed by a newline.
+	Format func(token string, expiresAt time.Time) ([]byte, error)
+	// `PID` or `PIDFile` name a process that gets `Signal` after each write. `PIDFile` is read before each signal, so it may change when the process restarts.
+	PID     int
+	PIDFile string
+	// `Signal` is the signal to send. Zero means SIGHUP.
+	Signal syscall.Signal
+}
+
+// Method `Write` renders the token, writes it to the target file, and signals the process, if configured.
+func (s FileSink) Write(token string, expiresAt time.Time) error {
+	content := []byte(token + "\n")
+	if s.Format != nil {
+		var err error
+		if content, err = s.Format(token, expiresAt); err != nil {
+			return fmt.Errorf("refresh: formatting %s: %w", s.Path, err)
+		}
+	}
+	mode := s.Mode
+	if mode == 0 {
+		mode = 0o600
+	}
+	if err := writeFileAtomic(s.Path, content, mode); err != nil {
+		return fmt.Errorf("refresh: writing %s: %w", s.Path, err)
+	}
+	return s.signal()
+}
+
+// Method `signal` sends the signal to the configured process, if any.
+func (s FileSink) signal() error {
+	pid := s.PID
+	if s.PIDFile != "" {
+		data, err := os.ReadFile(s.PIDFile)
+		if err != nil {
+			return fmt.Errorf("refresh: reading PID file: %w", err)
+		}
+		if pid, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			return fmt.Errorf("refresh: invalid PID file %s: %w", s.PIDFile, err)
+		}
+	}
+	if pid == 0 {
+		return nil
+	}
+	sig := s.Signal
+	if sig == 0 {
+		sig = syscall.SIGHUP
+	}
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		return fmt.Errorf("refresh: signaling process %d: %w", pid, err)
+	}
+	return nil
+}
+
+// `TemplateData` is the data that templates of `FormatTemplate` receive.
+type TemplateData struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// `templateFuncs` help render common credential formats. `json` quotes a string for JSON files, and `shell` quotes it for shell scripts and environment files.
+var templateFuncs = template.FuncMap{
+	"json": func(s string) (string, error) {
+		b, err := json.Marshal(s)
+		return string(b), err
+	},
+	"shell": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+}
+
+// `FormatTemplate` returns a `Format` function for `FileSink` that renders the Go template `text` (see package `text/template`) with `TemplateData`. For example, a `.pgpass` line:
+//
+//	db.example.com:5432:app:app:{{.Token}}
+//
+// or a JSON credentials file:
+//
+//	{"token": {{json .Token}}, "expires_at": "{{.ExpiresAt.Format "2006-01-02T15:04:05Z07:00"}}"}
+//
+// The functions `json` and `shell` quote the token for JSON and shell files. `FormatTemplate` panics if `text` is not a valid template.
+func FormatTemplate(text string) func(token string, expiresAt time.Time) ([]byte, error) {
+	tmpl, err := template.New("sink").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		panic(fmt.Sprintf("refresh: invalid sink template: %v", err))
+	}
+	return func(token string, expiresAt time.Time) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, TemplateData{Token: token, ExpiresAt: expiresAt}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	pidFile := filepath.Join(dir, "pid")
+	os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o600)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1)
+	defer signal.Stop(sigs)
+
+	sink := FileSink{
+		Path: path,
+		Format: func(token string, _ time.Time) ([]byte, error) {
+			return []byte("export TOKEN=" + token + "\n"), nil
+		},
+		PIDFile: pidFile,
+		Signal:  syscall.SIGUSR1,
+	}
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "abc", time.Hour, nil
+	}, WithSink(sink), WithLogger(NopLogger))
+	defer tok.Close()
+
+	select {
+	case <-sigs:
+	case <-time.After(time.Second):
+		t.Fatal("no signal after write")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "export TOKEN=abc\n" {
+		t.Fatalf("want export line, got (%q, %v)", data, err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("want mode 0600, got %v", fi.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("want no temporary files left, got %d entries", len(entries))
+	}
+}
+
+func TestFileSinkError(t *testing.T) {
+	logger := &captureLogger{}
+	sink := FileSink{
+		Path: filepath.Join(t.TempDir(), "token"),
+		Format: func(string, time.Time) ([]byte, error) {
+			return nil, fmt.Errorf("broken template")
+		},
+	}
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "abc", time.Hour, nil
+	}, WithSink(sink), WithLogger(logger))
+	defer tok.Close()
+
+	waitFor(t, time.Second, func() bool {
+		_, ok := logger.levels()["Sink failed"]
+		return ok
+	})
+	if _, err := os.Stat(sink.Path); !os.IsNotExist(err) {
+		t.Fatalf("want no file after failed format, got %v", err)
+	}
+}
+
+func TestFormatTemplate(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	for text, want := range map[string]string{
+		"db:5432:app:app:{{.Token}}\n":                           "db:5432:app:app:a'b\"c\n",
+		`{"token": {{json .Token}}, "exp": {{.ExpiresAt.Unix}}}`: `{"token": "a'b\"c", "exp": 1893553445}`,
+		"TOKEN={{shell .Token}}":                                 `TOKEN='a'\''b"c'`,
+	} {
+		got, err := FormatTemplate(text)(`a'b"c`, exp)
+		if err != nil || string(got) != want {
+			t.Errorf("%s: want %q, got (%q, %v)", text, want, got, err)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("want panic for invalid template")
+		}
+	}()
+	FormatTemplate("{{.Token")
+}
+
+// Each sink gets the token, and failures are reported per sink.
+func TestMultipleSinks(t *testing.T) {
+	dir := t.TempDir()
+	good := FileSink{Path: filepath.Join(dir, "pgpass"), Format: FormatTemplate("*:*:*:app:{{.Token}}\n")}
+	bad := FileSink{Path: filepath.Join(dir, "missing", "token")}
+	failed := make(chan Sink, 1)
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "abc", time.Hour, nil
+	}, WithSink(good), WithSink(bad), WithOnSinkError(func(s Sink, err error) { failed <- s }), WithLogger(NopLogger))
+	defer tok.Close()
+
+	select {
+	case s := <-failed:
+		if s.(FileSink).Path != bad.Path {
+			t.Fatalf("wrong sink reported: %v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sink error not reported")
+	}
+	if data, err := os.ReadFile(good.Path); string(data) != "*:*:*:app:abc\n" {
+		t.Fatalf("want pgpass line, got (%q, %v)", data, err)
+	}
+}
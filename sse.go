@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dashboards and internal tools like to show what the refreshers are doing: when the token rotated, which certificate is live, which config version is active. Server-Sent Events push such updates to browsers and `curl` alike. An `SSEBroadcaster` collects events from any number of refreshers and streams them to all connected clients. Each event has an ID, and a client that reconnects with the standard `Last-Event-ID` header receives the events it missed, as long as they are still in the broadcaster's history.
+
+// `sseKeepAlive` is the interval of comment lines that keep idle connections open through proxies.
+const sseKeepAlive = 15 * time.Second
+
+// `sseClientBuffer` is the number of events that a slow client can lag behind before it gets disconnected. It then reconnects and catches up from the history.
+const sseClientBuffer = 16
+
+// An `SSEEvent` is an event as sent to clients. `Data` is JSON.
+type SSEEvent struct {
+	ID    uint64
+	Event string
+	Data  []byte
+}
+
+// An `SSEBroadcaster` streams events to clients over Server-Sent Events. It is an `http.Handler`. The handler does no authentication; wrap it if the events carry secrets.
+type SSEBroadcaster struct {
+	mu      sync.Mutex
+	history []SSEEvent
+	size    int
+	nextID  uint64
+	clients map[chan SSEEvent]struct{}
+}
+
+// `NewSSEBroadcaster` returns a broadcaster that keeps the last `historySize` events for reconnecting clients. `historySize` must be positive.
+func NewSSEBroadcaster(historySize int) *SSEBroadcaster {
+	if historySize <= 0 {
+		panic(fmt.Sprintf("refresh: invalid SSE history size %d", historySize))
+	}
+	return &SSEBroadcaster{size: historySize, nextID: 1, clients: make(map[chan SSEEvent]struct{})}
+}
+
+// Method `Publish` sends an event with the JSON encoding of `data` to all clients.
+func (b *SSEBroadcaster) Publish(event string, data any) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ev := SSEEvent{ID: b.nextID, Event: event, Data: d}
+	b.nextID++
+	b.history = append(b.history, ev)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+	for ch := range b.clients {
+		select {
+		case ch <- ev:
+		default:
+			// The client is too slow. Disconnect it, so that it reconnects and catches up from the history.
+			delete(b.clients, ch)
+			close(ch)
+		}
+	}
+	return nil
+}
+
+// Method `ServeHTTP` streams events to the client until the client disconnects. It first replays the events of the history that are newer than the `Last-Event-ID` header. Without the header, it replays the whole history, so that a new client learns the current state.
+func (b *SSEBroadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	last, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	ch := make(chan SSEEvent, sseClientBuffer)
+	b.mu.Lock()
+	var replay []SSEEvent
+	for _, ev := range b.history {
+		if ev.ID > last {
+			replay = append(replay, ev)
+		}
+	}
+	b.clients[ch] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		if _, ok := b.clients[ch]; ok {
+			delete(b.clients, ch)
+			close(ch)
+		}
+		b.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	for _, ev := range replay {
+		writeSSE(w, ev)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSSE(w, ev)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// `writeSSE` writes one event in the wire format of Server-Sent Events. JSON data never contains newlines, so it fits into a single data line.
+func writeSSE(w http.ResponseWriter, ev SSEEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Event, ev.Data)
+}
+
+// `tokenEvent` is the data of a token event.
+type tokenEvent struct {
+	Token     string    `json:"token,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Method `PublishToken` publishes each new token or refresh error of `t` as `event`, until `ctx` is done or `t` is closed. It is built on `Token.Subscribe()`. `redact` maps the token before publishing. Nil means the redacted form of `Secret`, a short hash that identifies the token without revealing it. The handler does no authentication, so publishing the token itself takes an explicit `RawToken`.
+func (b *SSEBroadcaster) PublishToken(ctx context.Context, event string, t *Token, redact func(token string) string) {
+	if redact == nil {
+		redact = func(token string) string { return Secret(token).String() }
+	}
+	go func() {
+		for resp := range t.Subscribe(ctx) {
+			data := tokenEvent{Token: resp.Token}
+			if resp.Err != nil {
+				data.Error = resp.Err.Error()
+			}
+			if data.Token != "" {
+				data.Token = redact(data.Token)
+			}
+			if exp, ok := t.ExpiresAt(); ok && resp.Err == nil {
+				data.ExpiresAt = exp
+			}
+			b.Publish(event, data)
+		}
+	}()
+}
+
+// `RawToken` returns `token` unchanged. Pass it to `PublishToken` to publish the tokens themselves, for example, to a debugging tool behind an authenticating proxy.
+func RawToken(token string) string {
+	return token
+}
+
+// `PublishRefresher` publishes `data(v)` as `event` for each new value `v` of `r`, until `ctx` is done. If a fetch fails, the event carries the error instead.
+func PublishRefresher[T any](ctx context.Context, b *SSEBroadcaster, event string, r *Refresher[T], data func(T) any) {
+	go func() {
+		var seen uint64
+		for {
+			changed, version := r.watch()
+			if version > seen {
+				seen = version
+				v, err := r.GetContext(ctx)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					b.Publish(event, tokenEvent{Error: err.Error()})
+				} else {
+					b.Publish(event, data(v))
+				}
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// `certEvent` is the data of a certificate event.
+type certEvent struct {
+	Fingerprint string    `json:"fingerprint"`
+	NotAfter    time.Time `json:"not_after"`
+}
+
+// Method `PublishCert` publishes the SHA-256 fingerprint and expiry of each new certificate of `c` as `event`, until `ctx` is done.
+func (b *SSEBroadcaster) PublishCert(ctx context.Context, event string, c *CertRefresher) {
+	PublishRefresher(ctx, b, event, c.r, func(cert *tls.Certificate) any {
+		var ev certEvent
+		if len(cert.Certificate) > 0 {
+			sum := sha256.Sum256(cert.Certificate[0])
+			ev.Fingerprint = hex.EncodeToString(sum[:])
+		}
+		if cert.Leaf != nil {
+			ev.NotAfter = cert.Leaf.NotAfter
+		}
+		return ev
+	})
+}
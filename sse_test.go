@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// `readEvents` reads n events from an SSE stream and returns their "id event data" lines.
+func readEvents(t *testing.T, url, lastID string, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("want event stream, got %q", ct)
+	}
+	var events []string
+	var cur []string
+	sc := bufio.NewScanner(resp.Body)
+	for len(events) < n && sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			events = append(events, strings.Join(cur, " "))
+			cur = nil
+			continue
+		}
+		_, v, _ := strings.Cut(line, ": ")
+		cur = append(cur, v)
+	}
+	if len(events) < n {
+		t.Fatalf("want %d events, got %v (%v)", n, events, sc.Err())
+	}
+	return events
+}
+
+// A reconnecting client receives only the events it missed.
+func TestSSEBroadcasterReplay(t *testing.T) {
+	b := NewSSEBroadcaster(10)
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	b.Publish("config", map[string]int{"version": 1})
+	b.Publish("config", map[string]int{"version": 2})
+	got := readEvents(t, srv.URL, "", 2)
+	if got[1] != `2 config {"version":2}` {
+		t.Fatalf("unexpected events %q", got)
+	}
+
+	b.Publish("config", map[string]int{"version": 3})
+	got = readEvents(t, srv.URL, "2", 1)
+	if got[0] != `3 config {"version":3}` {
+		t.Fatalf("want only the missed event, got %q", got)
+	}
+}
+
+// Token refreshes become events; live clients receive them as they happen.
+func TestSSEPublishToken(t *testing.T) {
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "token-" + strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+
+	b := NewSSEBroadcaster(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.PublishToken(ctx, "token", tok, func(token string) string { return "redacted:" + token })
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	done := make(chan []string)
+	go func() { done <- readEvents(t, srv.URL, "", 2) }()
+	waitFor(t, time.Second, func() bool {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return len(b.clients) == 1 && len(b.history) == 1
+	})
+	tok.ForceRefresh(context.Background())
+
+	got := <-done
+	if !strings.Contains(got[0], `"token":"redacted:token-1"`) || !strings.Contains(got[1], `"token":"redacted:token-2"`) {
+		t.Fatalf("unexpected events %q", got)
+	}
+}
+
+// Refresher values become events.
+func TestSSEPublishRefresher(t *testing.T) {
+	var version atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewRefresher(ctx, func(ctx context.Context) (int, time.Duration, error) {
+		return int(version.Add(1)), 20*time.Millisecond + lifeSpanSafetyMargin, nil
+	})
+	b := NewSSEBroadcaster(100)
+	PublishRefresher(ctx, b, "config", r, func(v int) any { return map[string]int{"version": v} })
+
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+	waitFor(t, time.Second, func() bool { return version.Load() >= 3 })
+	got := readEvents(t, srv.URL, "", 2)
+	if !strings.HasPrefix(got[0], "1 config ") || !strings.HasPrefix(got[1], "2 config ") {
+		t.Fatalf("unexpected events %q", got)
+	}
+}
+
+// Without a redaction function, the tokens do not leave the process; `RawToken` publishes them.
+func TestSSEPublishTokenRedactsByDefault(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "secret-token", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for redact, want := range map[string]string{"default": Secret("secret-token").String(), "raw": "secret-token"} {
+		b := NewSSEBroadcaster(10)
+		if redact == "raw" {
+			b.PublishToken(ctx, "token", tok, RawToken)
+		} else {
+			b.PublishToken(ctx, "token", tok, nil)
+		}
+		srv := httptest.NewServer(b)
+		got := readEvents(t, srv.URL, "", 1)
+		srv.Close()
+		if !strings.Contains(got[0], `"token":"`+want+`"`) {
+			t.Errorf("%s: want token %q, got %q", redact, want, got[0])
+		}
+	}
+}
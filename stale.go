@@ -0,0 +1,35 @@
+package main
+
+import "time"
+
+// A failed refresh does not mean that the current token is useless. The loop refreshes the token a safety margin before it expires, so the token usually remains valid for a while. Rather than replacing it with an error right away, the loop can keep serving it until it expires, while retrying the refresh in the background.
+
+// `WithStaleOnError` keeps serving the previous token after a failed refresh until the token expires, plus `grace`. Only then do clients receive the refresh error. A `grace` of zero serves the token until its real expiry.
+func WithStaleOnError(grace time.Duration) Option {
+	return func(o *options) {
+		o.staleOnError = true
+		o.staleGrace = grace
+	}
+}
+
+// Method `stale` decides what to serve after a fetch. A successful response is remembered as the last good token and served as is. For a failed one, if stale serving is enabled and the last good token is still valid, `stale` returns that token instead, along with a channel that fires when the token expires. `staleEnd` is the channel from the previous call.
+func (a *Token) stale(resp tokenResponse, lifespan time.Duration, staleEnd <-chan time.Time) (tokenResponse, <-chan time.Time) {
+	if !a.opts.staleOnError {
+		return resp, nil
+	}
+	now := a.now()
+	if resp.Err == nil {
+		a.lastGood = resp
+		a.lastGoodUntil = resp.expiry(now, lifespan).Add(a.opts.staleGrace)
+		a.staleErr = nil
+		return resp, nil
+	}
+	if !now.Before(a.lastGoodUntil) {
+		return resp, nil
+	}
+	a.staleErr = resp.Err
+	if staleEnd == nil {
+		staleEnd = a.after(a.lastGoodUntil.Sub(now))
+	}
+	return a.lastGood, staleEnd
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+func TestStaleOnError(t *testing.T) {
+	const lifespan = 100 * time.Millisecond
+	newAuth := func() func() (string, time.Duration, error) {
+		var calls atomic.Int32
+		return func() (string, time.Duration, error) {
+			if calls.Add(1) == 1 {
+				return "tok", lifespan, nil
+			}
+			return "", lifespan, errors.New("down")
+		}
+	}
+	// Refreshing starts failing at about 90ms, the token expires at 100ms.
+	tests := []struct {
+		name      string
+		opts      []Option
+		at        time.Duration
+		wantToken bool
+	}{
+		{"without stale serving", nil, lifespan - lifeSpanSafetyMargin/2, false},
+		{"before expiry", []Option{WithStaleOnError(0)}, lifespan - lifeSpanSafetyMargin/2, true},
+		{"after expiry", []Option{WithStaleOnError(0)}, lifespan + 20*time.Millisecond, false},
+		{"within grace", []Option{WithStaleOnError(50 * time.Millisecond)}, lifespan + 20*time.Millisecond, true},
+		{"after grace", []Option{WithStaleOnError(50 * time.Millisecond)}, lifespan + 70*time.Millisecond, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tok := NewToken(ctx, newAuth(), tt.opts...)
+			tok.Get()
+			time.Sleep(tt.at)
+			got, err := tok.Get()
+			if tt.wantToken && (err != nil || got != "tok") {
+				t.Fatalf("want stale token, got (%q, %v)", got, err)
+			}
+			if !tt.wantToken && err == nil {
+				t.Fatalf("want error, got %q", got)
+			}
+		})
+	}
+}
+
+// A slow authorization call does not stretch the time that a stale token is served.
+func TestStaleSlowCall(t *testing.T) {
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	clock := refreshtest.NewClock(start)
+	a := newToken([]Option{WithStaleOnError(0), WithClock(clock)})
+	// The call started at `start` and returned a minute later.
+	clock.Advance(time.Minute)
+	a.stale(tokenResponse{Token: "tok", ExpiresAt: start.Add(time.Hour), FetchedAt: start}, time.Hour, nil)
+	if got, want := a.lastGoodUntil, start.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("want stale until %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// `Stats` is a point-in-time view of a token's refresh activity.
+type Stats struct {
+	// Refreshes counts successful authorization calls, including the initial one.
+	Refreshes int
+	// Failures counts failed authorization calls.
+	Failures int
+	// Gets counts calls to `Get()`.
+	Gets int64
+	// LastRefresh is the time of the last successful authorization call.
+	LastRefresh time.Time
+	// LastError is the error of the last failed authorization call, and LastErrorAt is its time.
+	LastError   error
+	LastErrorAt time.Time
+	// ExpiresAt is the expiry time of the last token obtained.
+	ExpiresAt time.Time
+	// Source is the index of the authorization function that delivered the last token: 0 for the constructor's function, 1 for the first fallback, and so on. See `WithFallbackAuthorizers`.
+	Source int
+	// MaxGetWait is the longest time that a call to `Get()` has waited for a refresh to complete.
+	MaxGetWait time.Duration
+}
+
+// `statsRecorder` collects the numbers for `Stats`. Reading stats must never wait for the refresh loop, so the recorder has its own lock that is held only for a few assignments. The read counter is atomic to keep `Get()` cheap.
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats Stats
+	gets  atomic.Int64
+	// `consecutive` counts the failures since the last success.
+	consecutive int
+}
+
+func (r *statsRecorder) success(now, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Refreshes++
+	r.consecutive = 0
+	r.stats.LastRefresh = now
+	r.stats.ExpiresAt = expiresAt
+}
+
+func (r *statsRecorder) failure(now time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Failures++
+	r.consecutive++
+	r.stats.LastError = err
+	r.stats.LastErrorAt = now
+}
+
+func (r *statsRecorder) setSource(i int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	from := r.stats.Source
+	r.stats.Source = i
+	return from
+}
+
+func (r *statsRecorder) waited(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.MaxGetWait = max(r.stats.MaxGetWait, d)
+}
+
+func (r *statsRecorder) consecutiveFailures() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.consecutive
+}
+
+func (r *statsRecorder) snapshot() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.Gets = r.gets.Load()
+	return s
+}
+
+// Method `Stats` returns a snapshot of the token's refresh activity.
+func (a *Token) Stats() Stats {
+	return a.stats.snapshot()
+}
+
+// Method `LastError` returns the error of the last failed authorization call and the time of the failure. A later success does not clear it; see `ConsecutiveFailures()` for whether the token is currently failing. The error is nil if no call has failed yet.
+func (a *Token) LastError() (error, time.Time) {
+	s := a.stats.snapshot()
+	return s.LastError, s.LastErrorAt
+}
+
+// Method `ConsecutiveFailures` returns the number of authorization calls that have failed since the last success.
+func (a *Token) ConsecutiveFailures() int {
+	return a.stats.consecutiveFailures()
+}
+
+// Method `ExpiresAt` returns the expiry time of the most recently obtained token. The boolean is false if no token has been obtained yet.
+func (a *Token) ExpiresAt() (time.Time, bool) {
+	s := a.stats.snapshot()
+	return s.ExpiresAt, !s.ExpiresAt.IsZero()
+}
+
+// Method `TTL` returns how long the most recently obtained token remains valid. It returns zero if no token has been obtained yet or if the token has expired.
+func (a *Token) TTL() time.Duration {
+	exp, ok := a.ExpiresAt()
+	if !ok {
+		return 0
+	}
+	return max(exp.Sub(a.now()), 0)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/awscreds"
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// `*Token` must plug into the adapter subpackages.
+var _ awscreds.TokenSource = (*Token)(nil)
+
+func TestExpiresAt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	before := time.Now()
+	tok := NewToken(ctx, func() (string, time.Duration, error) { return "tok", time.Hour, nil })
+	tok.Get()
+
+	exp, ok := tok.ExpiresAt()
+	if !ok {
+		t.Fatal("no expiry after first token")
+	}
+	if exp.Before(before.Add(time.Hour)) || exp.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("unexpected expiry %v", exp)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, func() (string, time.Duration, error) { return "", 0, errors.New("down") }, WithClock(clock))
+	if ttl := tok.TTL(); ttl != 0 {
+		t.Fatalf("want zero TTL without token, got %v", ttl)
+	}
+
+	tok = NewToken(ctx, func() (string, time.Duration, error) { return "tok", time.Hour, nil }, WithClock(clock))
+	tok.Get()
+	if ttl := tok.TTL(); ttl != time.Hour {
+		t.Fatalf("want TTL 1h, got %v", ttl)
+	}
+	clock.Advance(20 * time.Minute)
+	if ttl := tok.TTL(); ttl != 40*time.Minute {
+		t.Fatalf("want TTL 40m, got %v", ttl)
+	}
+}
+
+func TestLastErrorAndConsecutiveFailures(t *testing.T) {
+	errDown := errors.New("down")
+	var down atomic.Bool
+	down.Store(true)
+	before := time.Now()
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		if down.Load() {
+			return "", 0, errDown
+		}
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+	if err, at := tok.LastError(); err != nil || !at.IsZero() {
+		t.Fatalf("want no error before the first call, got (%v, %v)", err, at)
+	}
+
+	waitFor(t, time.Second, func() bool { return tok.ConsecutiveFailures() >= 2 })
+	err, at := tok.LastError()
+	if !errors.Is(err, errDown) || at.Before(before) || at.After(time.Now()) {
+		t.Fatalf("want %v with a recent time, got (%v, %v)", errDown, err, at)
+	}
+
+	down.Store(false)
+	waitFor(t, time.Second, func() bool { return tok.ConsecutiveFailures() == 0 })
+	if err, _ := tok.LastError(); !errors.Is(err, errDown) {
+		t.Fatalf("want last error kept after success, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Each process start fetches a new token. For a service that gets deployed many times a day, or a CLI tool that runs for a second, this hammers the authorization server with requests for tokens that the previous run already had. A `Store` keeps the token across restarts. The first fetch after a start tries the store before it calls the authorization function.
+
+// A `StoredToken` is a token along with its absolute expiry time.
+type StoredToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// A `Store` persists the current token. `Load` returns false if the store holds no token.
+type Store interface {
+	Load(ctx context.Context) (StoredToken, bool, error)
+	Save(ctx context.Context, t StoredToken) error
+}
+
+// `WithStore` saves each new token to `s`, and restores the token from `s` on the first fetch. A restored token is only used if its remaining lifespan exceeds the safety margin; otherwise, the token is fetched as usual. Errors of the store are logged but do not fail a refresh.
+// Tokens with a warm pool are single-use and never stored. Signing keys (see `signingkey.go`) are not stored either, so a token with a signing key always fetches on start.
+func WithStore(s Store) Option {
+	return func(o *options) {
+		o.store = s
+	}
+}
+
+// Method `restore` returns the stored token if this is the first fetch and the stored token is still good.
+func (a *Token) restore(ctx context.Context) (tokenResponse, time.Duration, bool) {
+	if a.opts.store == nil || a.opts.poolSize > 0 || a.authorizeWithKey != nil || !a.restoreTried.CompareAndSwap(false, true) {
+		return tokenResponse{}, 0, false
+	}
+	st, ok, err := a.opts.store.Load(ctx)
+	if err != nil {
+		a.logEvent(ctx, EventRefreshError, "Cannot load token from store", "err", err)
+		return tokenResponse{}, 0, false
+	}
+	now := a.now()
+	remaining := st.ExpiresAt.Sub(now)
+	if !ok || st.Token == "" || remaining <= a.storeMargin() {
+		return tokenResponse{}, 0, false
+	}
+	a.sharedSeen.Store(&st.Token)
+	a.stats.success(now, st.ExpiresAt)
+	a.logEvent(ctx, EventRefresh, "Token restored from store", "expiresAt", st.ExpiresAt)
+	return tokenResponse{Token: st.Token, ExpiresAt: st.ExpiresAt, FetchedAt: now}, remaining, true
+}
+
+// Method `storeMargin` is the remaining lifespan that a stored token must exceed to be used.
+func (a *Token) storeMargin() time.Duration {
+	return a.safetyMargin()
+}
+
+// Method `persist` saves a new token to the store, if any.
+func (a *Token) persist(ctx context.Context, token string, expiresAt time.Time) {
+	if a.opts.store == nil || a.opts.poolSize > 0 || a.authorizeWithKey != nil {
+		return
+	}
+	a.sharedSeen.Store(&token)
+	if err := a.opts.store.Save(ctx, StoredToken{Token: token, ExpiresAt: expiresAt}); err != nil {
+		a.logEvent(ctx, EventRefreshError, "Cannot save token to store", "err", err)
+	}
+}
+
+// `MemoryStore` keeps the token in memory. It does not survive restarts, but it can carry a token from one `Token` to the next within a process, and is useful for tests.
+type MemoryStore struct {
+	mu sync.Mutex
+	t  StoredToken
+	ok bool
+}
+
+// Method `Load` implements `Store`.
+func (s *MemoryStore) Load(context.Context) (StoredToken, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.t, s.ok, nil
+}
+
+// Method `Save` implements `Store`.
+func (s *MemoryStore) Save(_ context.Context, t StoredToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.t, s.ok = t, true
+	return nil
+}
+
+// `FileStore` keeps the token as JSON in a file that only the owner can read. `Save` replaces the file atomically, so that a crash never leaves a truncated token behind.
+type FileStore struct {
+	Path string
+}
+
+// Method `Load` implements `Store`. A missing file means that there is no token.
+func (s FileStore) Load(context.Context) (StoredToken, bool, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return StoredToken{}, false, nil
+	}
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	var t StoredToken
+	if err := json.Unmarshal(b, &t); err != nil {
+		return StoredToken{}, false, err
+	}
+	return t, true, nil
+}
+
+// Method `Save` implements `Store`. It writes the token to a temporary file and renames it to `Path`.
+func (s FileStore) Save(_ context.Context, t StoredToken) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, b, 0o600)
+}
+
+// `writeFileAtomic` writes `data` to a temporary file with the given mode and renames it to `path`.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
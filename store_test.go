@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A new token reuses a stored token that is still valid instead of calling the authorization function.
+func TestStoreRestore(t *testing.T) {
+	store := FileStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "fresh", time.Hour, nil
+	}
+
+	tok := NewToken(context.Background(), auth, WithStore(store), WithLogger(NopLogger))
+	if got, err := tok.Get(); err != nil || got != "fresh" {
+		t.Fatalf("want fresh token, got (%q, %v)", got, err)
+	}
+	tok.Close()
+
+	tok = NewToken(context.Background(), auth, WithStore(store), WithLogger(NopLogger))
+	defer tok.Close()
+	if got, err := tok.Get(); err != nil || got != "fresh" {
+		t.Fatalf("want restored token, got (%q, %v)", got, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 authorization call, got %d", n)
+	}
+	if ttl := tok.TTL(); ttl <= 59*time.Minute {
+		t.Fatalf("want TTL of about an hour, got %v", ttl)
+	}
+}
+
+// A stored token that is about to expire is not trusted.
+func TestStoreExpired(t *testing.T) {
+	store := &MemoryStore{}
+	store.Save(context.Background(), StoredToken{Token: "old", ExpiresAt: time.Now().Add(lifeSpanSafetyMargin / 2)})
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "fresh", time.Hour, nil
+	}, WithStore(store), WithLogger(NopLogger))
+	defer tok.Close()
+
+	if got, err := tok.Get(); err != nil || got != "fresh" {
+		t.Fatalf("want fresh token, got (%q, %v)", got, err)
+	}
+	if st, ok, _ := store.Load(context.Background()); !ok || st.Token != "fresh" {
+		t.Fatalf("want fresh token in store, got %+v", st)
+	}
+}
@@ -0,0 +1,216 @@
+//go:build stress
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The stress test hammers every variant of `Token` with concurrent reads, forced refreshes, and closes, while the authorization function takes random time and fails at random. It checks the guarantees that the article and the package documentation make:
+//
+//   - no data races (run with `-race`),
+//   - `Get()` returns either a token that the authorization function issued, or an error, and never a token after `Close()` has returned,
+//   - `Close()` returns, and `Done()` gets closed,
+//   - no goroutines are left behind.
+//
+// The test is behind the build tag `stress`, as it takes a while:
+//
+//	go test -race -tags stress -run Stress -stress.duration 30s
+
+var stressDuration = flag.Duration("stress.duration", 5*time.Second, "duration of each stress test run")
+
+// `stressVariants` are the token variants under stress.
+var stressVariants = []struct {
+	name string
+	opts []Option
+}{
+	{"channel", nil},
+	{"fastpath", []Option{WithFastPath()}},
+	{"ondemand", []Option{WithOnDemandRefresh()}},
+	{"ondemand-stale", []Option{WithOnDemandRefresh(), WithServeStaleDuringRefresh()}},
+	{"pool", []Option{WithWarmPool(4, 2)}},
+	{"lazy-idle", []Option{WithLazyStart(), WithIdleTimeout(time.Millisecond)}},
+	{"stale-on-error", []Option{WithStaleOnError(time.Hour)}},
+	{"hooks", []Option{WithOnRefresh(func(string, time.Time) {}), WithOnError(func(error) {})}},
+}
+
+// `stressAuth` issues numbered tokens with random latency and lifespan, and fails at random. `issued` reports whether a token was issued.
+type stressAuth struct {
+	mu     sync.Mutex
+	rnd    *rand.Rand
+	n      int
+	issued sync.Map
+}
+
+func newStressAuth(seed int64) *stressAuth {
+	return &stressAuth{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (s *stressAuth) authorize(ctx context.Context) (string, time.Duration, error) {
+	s.mu.Lock()
+	latency := time.Duration(s.rnd.Int63n(int64(2 * time.Millisecond)))
+	lifespan := lifeSpanSafetyMargin + time.Duration(s.rnd.Int63n(int64(20*time.Millisecond)))
+	fail := s.rnd.Float64() < 0.2
+	s.n++
+	token := "tok" + strconv.Itoa(s.n)
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(latency):
+	case <-ctx.Done():
+		return "", 0, ctx.Err()
+	}
+	if fail {
+		return "", 0, errors.New("random failure")
+	}
+	s.issued.Store(token, true)
+	return token, lifespan, nil
+}
+
+func TestStress(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	before := runtime.NumGoroutine()
+
+	for i, v := range stressVariants {
+		v := v
+		seed := time.Now().UnixNano() + int64(i)
+		t.Run(v.name, func(t *testing.T) {
+			t.Logf("seed %d", seed)
+			deadline := time.Now().Add(*stressDuration)
+			for round := 0; time.Now().Before(deadline); round++ {
+				stressRound(t, seed+int64(round), v.opts)
+			}
+		})
+	}
+
+	// Give the goroutines of the last closed tokens a moment to exit.
+	waitFor(t, 5*time.Second, func() bool { return runtime.NumGoroutine() <= before+2 })
+}
+
+func TestStressMToken(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	auth := newStressAuth(time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), *stressDuration)
+	defer cancel()
+	m := NewMToken(ctx, func() (string, time.Duration, error) { return auth.authorize(ctx) })
+	var wg sync.WaitGroup
+	for g := 0; g < 64; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if token, err := m.Get(); err == nil && token != "" {
+					if _, ok := auth.issued.Load(token); !ok {
+						t.Errorf("Get returned a token that was never issued: %q", token)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// `stressRound` creates a token, runs random operations on it from many goroutines, and closes it while they are still running.
+func stressRound(t *testing.T, seed int64, opts []Option) {
+	auth := newStressAuth(seed)
+	opts = append(append([]Option(nil), opts...), WithLogger(NopLogger))
+	tok := NewTokenContext(context.Background(), auth.authorize, opts...)
+
+	var (
+		wg       sync.WaitGroup
+		closed   atomic.Bool
+		closeAt  = time.Duration(rand.New(rand.NewSource(seed)).Int63n(int64(50 * time.Millisecond)))
+		stop     = make(chan struct{})
+		subCtx   context.Context
+		subClose context.CancelFunc
+	)
+	subCtx, subClose = context.WithCancel(context.Background())
+	defer subClose()
+
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed + int64(g)))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// `wasClosed` must be read before the operation: only a `Close()` that returned before the operation started guarantees `ErrClosed`.
+				wasClosed := closed.Load()
+				var (
+					token string
+					err   error
+				)
+				switch op := rnd.Intn(100); {
+				case op < 60:
+					token, err = tok.Get()
+				case op < 80:
+					ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rnd.Intn(3))*time.Millisecond)
+					token, err = tok.GetContext(ctx)
+					cancel()
+				case op < 90:
+					token, _ = tok.TryGet()
+				case op < 97:
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+					err = tok.ForceRefresh(ctx)
+					cancel()
+				default:
+					for range tok.Subscribe(subCtx) {
+						break
+					}
+				}
+				if err == nil && token != "" {
+					if _, ok := auth.issued.Load(token); !ok {
+						t.Errorf("token %q was never issued", token)
+					}
+				}
+				if wasClosed && token != "" && !strings.HasPrefix(token, "tok") {
+					t.Errorf("unexpected token %q", token)
+				}
+				if wasClosed && err == nil && token != "" {
+					t.Errorf("got token %q after Close returned", token)
+				}
+			}
+		}(g)
+	}
+
+	time.Sleep(closeAt)
+	// Several goroutines close the token at once.
+	var closers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		closers.Add(1)
+		go func() {
+			defer closers.Done()
+			tok.Close()
+		}()
+	}
+	closers.Wait()
+	closed.Store(true)
+	select {
+	case <-tok.Done():
+	default:
+		t.Error("Done not closed after Close returned")
+	}
+	time.Sleep(time.Millisecond)
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// `Get()` is a pull model: clients ask for the token whenever they need it. Some clients rather want to be told when the token changes, for example, a long-lived websocket writer that has to re-authenticate its connection, or a sidecar that writes the token into a config file. `Subscribe()` offers a push model alongside `Get()`.
+
+// `WithSubscriptionBuffer` sets the number of updates that each subscriber's channel can hold. When a slow subscriber's channel is full, the oldest update is dropped in favor of the new one, so that a subscriber never blocks the refresh loop and always ends up with the latest token. The default is 1.
+func WithSubscriptionBuffer(n int) Option {
+	return func(o *options) {
+		o.subscriptionBuffer = n
+	}
+}
+
+// A `TokenUpdate` is what subscribers receive after each refresh: either a new token with its expiry time, or the error of a failed refresh.
+type TokenUpdate struct {
+	Token string
+	// `ExpiresAt` is when `Token` expires, or zero if unknown.
+	ExpiresAt time.Time
+	Err       error
+}
+
+// `subscribers` is the set of subscription channels, together with the last update for new subscribers. `version` and `changed` serve `Watch()`. See `watch.go`.
+type subscribers struct {
+	mu      sync.Mutex
+	chs     map[chan TokenUpdate]struct{}
+	last    *tokenResponse
+	version uint64
+	changed chan struct{}
+}
+
+// Method `Subscribe` returns a channel that receives each new token or refresh error. If a token or error is available already, the channel receives it right away. The subscription ends, and the channel gets closed, when `ctx` is done or the token is closed.
+// Tokens with a warm pool hand out each token only once, so subscribing to them makes no sense. Their channel gets closed right away.
+func (a *Token) Subscribe(ctx context.Context) <-chan TokenUpdate {
+	ch := make(chan TokenUpdate, max(a.opts.subscriptionBuffer, 1))
+	if a.opts.poolSize > 0 {
+		close(ch)
+		return ch
+	}
+	s := &a.subs
+	s.mu.Lock()
+	if s.chs == nil {
+		s.chs = make(map[chan TokenUpdate]struct{})
+	}
+	s.chs[ch] = struct{}{}
+	if s.last != nil {
+		ch <- s.last.update()
+	}
+	s.mu.Unlock()
+
+	a.ensureStarted()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-a.closing():
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.chs, ch)
+		close(ch)
+	}()
+	return ch
+}
+
+// Method `broadcast` sends `resp` to all subscribers without blocking.
+func (a *Token) broadcast(resp tokenResponse) {
+	s := &a.subs
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = &resp
+	s.version = a.generation.Load()
+	if s.changed != nil {
+		close(s.changed)
+		s.changed = nil
+	}
+	update := resp.update()
+	for ch := range s.chs {
+		for {
+			select {
+			case ch <- update:
+			default:
+				// The channel is full. Drop the oldest update and try again. Only `broadcast` sends to the channel, and it holds the lock, so the loop ends after the next attempt at the latest.
+				select {
+				case <-ch:
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// Method `update` returns the fields of `r` that subscribers see.
+func (r tokenResponse) update() TokenUpdate {
+	return TokenUpdate{Token: r.Token, ExpiresAt: r.ExpiresAt, Err: r.Err}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// Subscribers receive the current token right away and each new token after a refresh.
+func TestSubscribe(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Minute, nil
+	}
+	tok := NewToken(context.Background(), auth, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := tok.Subscribe(ctx)
+	if resp := recv(t, ch); resp.Token != "1" || !resp.ExpiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("want token 1 expiring in a minute, got %+v", resp)
+	}
+
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(time.Minute)
+	if resp := recv(t, ch); resp.Token != "2" {
+		t.Fatalf("want token 2, got %q", resp.Token)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("want closed channel after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
+
+// A slow subscriber does not block the refresh loop and ends up with the latest token.
+func TestSubscribeDropsOldest(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithLogger(NopLogger))
+	defer tok.Close()
+	ch := tok.Subscribe(context.Background())
+	for i := 0; i < 3; i++ {
+		if err := tok.ForceRefresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resp := recv(t, ch); resp.Token != "4" {
+		t.Fatalf("want token 4, got %q", resp.Token)
+	}
+}
+
+// Closing the token ends all subscriptions.
+func TestSubscribeClose(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "t", time.Hour, nil
+	}, WithLogger(NopLogger))
+	ch := tok.Subscribe(context.Background())
+	recv(t, ch)
+	tok.Close()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("want closed channel after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after Close")
+	}
+}
+
+func recv(t *testing.T, ch <-chan TokenUpdate) TokenUpdate {
+	t.Helper()
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+		return resp
+	case <-time.After(time.Second):
+		t.Fatal("no update received")
+	}
+	return TokenUpdate{}
+}
+
+// Subscribing to an on-demand token must not start a refresh goroutine.
+func TestSubscribeOnDemand(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithOnDemandRefresh(), WithLogger(NopLogger))
+	defer tok.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok.Subscribe(ctx)
+	if tok.running.Load() {
+		t.Fatal("Subscribe started a refresh goroutine for an on-demand token")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// A `swap` carries a replacement authorization function to the refresh goroutine, together with the first token that it delivered.
+type swap struct {
+	authorize func(context.Context) (string, time.Duration, error)
+	resp      tokenResponse
+	lifespan  time.Duration
+	// `seq` orders concurrent swaps. `applied` receives whether the refresh goroutine accepted the swap.
+	seq     uint64
+	applied chan bool
+}
+
+// `ErrSwapUnsupported` is returned by `SwapAuthorizer` for tokens with signing keys, a warm pool, or on-demand refresh.
+var ErrSwapUnsupported = errors.New("refresh: authorizer swap not supported for this token")
+
+// Method `SwapAuthorizer` replaces the token's authorization function without downtime. It first fetches a token from `newAuth`, applying all configured checks. If this fails, the token keeps using the current authorization function and `SwapAuthorizer` returns the error. Otherwise, the refresh goroutine switches over to `newAuth` and its token in one step, and `SwapAuthorizer` returns the new token. Clients calling `Get()` meanwhile receive either the old token or the new one, but never an error caused by the swap.
+func (a *Token) SwapAuthorizer(ctx context.Context, newAuth func() (string, time.Duration, error)) (string, error) {
+	return a.swapAuthorizer(ctx, ignoreContext(newAuth))
+}
+
+// Method `swapAuthorizer` implements `SwapAuthorizer` for an authorization function that takes a context.
+func (a *Token) swapAuthorizer(ctx context.Context, auth func(context.Context) (string, time.Duration, error)) (string, error) {
+	if a.authorizeWithKey != nil || a.opts.poolSize > 0 || a.onDemand() {
+		return "", ErrSwapUnsupported
+	}
+	seq := a.swapSeq.Add(1)
+	resp, lifespan := a.fetchWith(ctx, withoutKey(auth))
+	if resp.Err != nil {
+		return "", resp.Err
+	}
+	applied := make(chan bool, 1)
+	for {
+		stopped := a.ensureStarted()
+		select {
+		case a.swaps <- swap{authorize: auth, resp: resp, lifespan: lifespan, seq: seq, applied: applied}:
+			if !<-applied {
+				return "", ErrSwapSuperseded
+			}
+			return resp.Token, nil
+		case <-a.closing():
+			return "", ErrClosed
+		case <-stopped:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
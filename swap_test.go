@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSwapAuthorizer(t *testing.T) {
+	counter := func(prefix string) func() (string, time.Duration, error) {
+		var n atomic.Int32
+		return func() (string, time.Duration, error) {
+			// The call takes a moment, so that readers overlap with the swap. The lifespan is long enough that no token expires during the test, so any error comes from the swap.
+			time.Sleep(5 * time.Millisecond)
+			return fmt.Sprintf("%s-%d", prefix, n.Add(1)), time.Hour, nil
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, counter("old"))
+
+	// Readers hammer the token throughout the swap. None of them may see an error.
+	var swapped atomic.Bool
+	errs := make(chan error, 1)
+	for i := 0; i < 8; i++ {
+		go func() {
+			for ctx.Err() == nil {
+				before := swapped.Load()
+				got, err := tok.Get()
+				switch {
+				case err != nil:
+					errs <- err
+				case before && !strings.HasPrefix(got, "new-"):
+					errs <- fmt.Errorf("got %s after the swap", got)
+				}
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	got, err := tok.SwapAuthorizer(ctx, counter("new"))
+	if err != nil || got != "new-1" {
+		t.Fatalf("want new-1, got (%q, %v)", got, err)
+	}
+	swapped.Store(true)
+	time.Sleep(100 * time.Millisecond)
+
+	select {
+	case err := <-errs:
+		t.Fatal(err)
+	default:
+	}
+}
+
+func TestSwapAuthorizerFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tok := NewToken(ctx, func() (string, time.Duration, error) { return "old", time.Hour, nil })
+
+	failing := func() (string, time.Duration, error) { return "", time.Hour, errors.New("bad credentials") }
+	if _, err := tok.SwapAuthorizer(ctx, failing); err == nil {
+		t.Fatal("want error from failing authorizer")
+	}
+	if got, err := tok.Get(); err != nil || got != "old" {
+		t.Fatalf("failed swap changed the token: (%q, %v)", got, err)
+	}
+}
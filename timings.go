@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// `maxTimings` bounds the number of authorization calls that a `Token` remembers. Older records are dropped first.
+const maxTimings = 1000
+
+// A `refreshTiming` records a single call to the authorization function.
+type refreshTiming struct {
+	Start    time.Time
+	Duration time.Duration
+	Lifespan time.Duration
+	Err      error
+}
+
+// `timingLog` is a bounded history of `refreshTiming`s. The refresh goroutine writes to it while clients may dump it at any time, hence the mutex.
+type timingLog struct {
+	mu      sync.Mutex
+	records []refreshTiming
+}
+
+func (l *timingLog) record(start time.Time, d, lifespan time.Duration, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.records) == maxTimings {
+		copy(l.records, l.records[1:])
+		l.records = l.records[:maxTimings-1]
+	}
+	l.records = append(l.records, refreshTiming{Start: start, Duration: d, Lifespan: lifespan, Err: err})
+}
+
+func (l *timingLog) snapshot() []refreshTiming {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]refreshTiming(nil), l.records...)
+}
+
+// Method `DumpTimings` writes the recorded authorization calls to `w` as CSV, oldest first. Columns are the start time (RFC 3339), the call duration and the reported lifespan (both in seconds), and the outcome (`ok` or `error`).
+func (a *Token) DumpTimings(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"start", "duration", "outcome", "lifespan"}); err != nil {
+		return err
+	}
+	for _, r := range a.timings.snapshot() {
+		outcome := "ok"
+		if r.Err != nil {
+			outcome = "error"
+		}
+		err := cw.Write([]string{
+			r.Start.Format(time.RFC3339Nano),
+			strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64),
+			outcome,
+			strconv.FormatFloat(r.Lifespan.Seconds(), 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDumpTimings(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1)%2 == 0 {
+			return "", 20 * time.Millisecond, errors.New("flaky")
+		}
+		return "tok", 20 * time.Millisecond, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tok := NewToken(ctx, auth)
+	time.Sleep(80 * time.Millisecond)
+	// Stop the loop and let an in-flight call finish before counting.
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := tok.DumpTimings(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start", "duration", "outcome", "lifespan"}
+	if len(rows) == 0 || len(rows[0]) != len(want) {
+		t.Fatalf("bad header: %v", rows)
+	}
+	for i := range want {
+		if rows[0][i] != want[i] {
+			t.Fatalf("header: want %v, got %v", want, rows[0])
+		}
+	}
+	if got, want := len(rows)-1, int(calls.Load()); got != want {
+		t.Fatalf("want %d rows, got %d", want, got)
+	}
+	if rows[1][2] != "ok" || rows[2][2] != "error" {
+		t.Fatalf("unexpected outcomes: %v", rows[1:3])
+	}
+}
+
+func TestTimingLogBounded(t *testing.T) {
+	var l timingLog
+	for i := 0; i < maxTimings+10; i++ {
+		l.record(time.Unix(int64(i), 0), 0, 0, nil)
+	}
+	recs := l.snapshot()
+	if len(recs) != maxTimings {
+		t.Fatalf("want %d records, got %d", maxTimings, len(recs))
+	}
+	if recs[0].Start.Unix() != 10 {
+		t.Fatalf("oldest records not dropped first: %v", recs[0].Start)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// When a refresh adds latency to a request, it should show up in the distributed trace of that request. This package does not depend on any tracing library. Instead, it defines the few methods it needs, and a small adapter connects them to OpenTelemetry or any other tracer.
+
+// OpenTelemetry's `trace.Tracer` takes start options and returns a `trace.Span` with many more methods, so it cannot satisfy `Tracer` directly. This adapter connects the two, using `go.opentelemetry.io/otel`, its `attribute`, `codes`, and `trace` packages:
+//
+//	type otelProvider struct{ tp trace.TracerProvider }
+//
+//	func (p otelProvider) Tracer(name string) Tracer { return otelTracer{p.tp.Tracer(name)} }
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+//		ctx, span := t.t.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ s trace.Span }
+//
+//	func (s otelSpan) SetAttributes(attrs ...slog.Attr) {
+//		kvs := make([]attribute.KeyValue, 0, len(attrs))
+//		for _, a := range attrs {
+//			switch a.Value.Kind() {
+//			case slog.KindInt64:
+//				kvs = append(kvs, attribute.Int64(a.Key, a.Value.Int64()))
+//			case slog.KindBool:
+//				kvs = append(kvs, attribute.Bool(a.Key, a.Value.Bool()))
+//			case slog.KindDuration:
+//				kvs = append(kvs, attribute.Int64(a.Key+"_ms", a.Value.Duration().Milliseconds()))
+//			default:
+//				kvs = append(kvs, attribute.String(a.Key, a.Value.String()))
+//			}
+//		}
+//		s.s.SetAttributes(kvs...)
+//	}
+//
+//	func (s otelSpan) RecordError(err error) {
+//		s.s.RecordError(err)
+//		s.s.SetStatus(codes.Error, err.Error())
+//	}
+//
+//	func (s otelSpan) End() { s.s.End() }
+//
+// With the adapter, the token traces into the global tracer provider:
+//
+//	tok := NewToken(ctx, auth, WithTracerProvider(otelProvider{otel.GetTracerProvider()}))
+
+// `TracerProvider` hands out a `Tracer`. It mirrors the method of the same name in OpenTelemetry's `trace.TracerProvider`.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// `Tracer` starts spans.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// `Span` is a traced operation. Attributes use `slog.Attr`, which maps directly to OpenTelemetry's `attribute.KeyValue`.
+type Span interface {
+	SetAttributes(attrs ...slog.Attr)
+	RecordError(err error)
+	End()
+}
+
+// `tracerName` identifies this package to the `TracerProvider`.
+const tracerName = "github.com/appliedgo/refresh"
+
+// Span attribute keys.
+const (
+	AttrAttempt      = "refresh.attempt"
+	AttrSuccess      = "refresh.success"
+	AttrBackoffDelay = "refresh.backoff_delay"
+	AttrNextRefresh  = "refresh.next_refresh"
+)
+
+// `WithTracerProvider` wraps each refresh in a span named "refresh.authorize". The span carries the attempt number within the current retry window, whether the attempt succeeded, and the time of the next refresh or, after a failure, the backoff delay. By default, nothing is traced.
+func WithTracerProvider(tp TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+// Method `startSpan` starts the span for one refresh attempt. Without a tracer provider, it returns `ctx` and a nil span.
+func (a *Token) startSpan(ctx context.Context) (context.Context, Span) {
+	if a.opts.tracerProvider == nil {
+		return ctx, nil
+	}
+	attempt := 1
+	if !a.windowStart.IsZero() {
+		attempt = a.attempts + 1
+	}
+	ctx, span := a.opts.tracerProvider.Tracer(tracerName).Start(ctx, "refresh.authorize")
+	span.SetAttributes(slog.Int(AttrAttempt, attempt))
+	return ctx, span
+}
+
+// Method `endSpan` records the outcome of a refresh attempt and ends the span. `next` is the delay until the next attempt.
+func (a *Token) endSpan(span Span, next time.Duration, err error) {
+	if span == nil {
+		return
+	}
+	span.SetAttributes(slog.Bool(AttrSuccess, err == nil))
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(slog.Duration(AttrBackoffDelay, next))
+	}
+	span.SetAttributes(slog.Time(AttrNextRefresh, a.now().Add(next)))
+	span.End()
+}
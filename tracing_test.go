@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSpan struct {
+	mu    *sync.Mutex
+	name  string
+	attrs map[string]slog.Value
+	err   error
+	ended bool
+}
+
+func (s *fakeSpan) SetAttributes(attrs ...slog.Attr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+func (s *fakeSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *fakeSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) Tracer(string) Tracer { return t }
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &fakeSpan{mu: &t.mu, name: name, attrs: map[string]slog.Value{}}
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func (t *fakeTracer) ended() []fakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []fakeSpan
+	for _, s := range t.spans {
+		if s.ended {
+			out = append(out, *s)
+		}
+	}
+	return out
+}
+
+func TestTracerProvider(t *testing.T) {
+	calls := 0
+	auth := func() (string, time.Duration, error) {
+		calls++
+		if calls <= 2 {
+			return "", 0, errors.New("down")
+		}
+		return "tok", time.Hour, nil
+	}
+	tracer := &fakeTracer{}
+	tok := NewToken(context.Background(), auth, WithTracerProvider(tracer))
+	defer tok.Close()
+
+	waitFor(t, time.Second, func() bool { return len(tracer.ended()) >= 3 })
+	tok.Close()
+	spans := tracer.ended()
+	for i, s := range spans[:3] {
+		if s.name != "refresh.authorize" {
+			t.Errorf("span %d: want name refresh.authorize, got %q", i, s.name)
+		}
+		if got := s.attrs[AttrAttempt].Int64(); got != int64(i+1) {
+			t.Errorf("span %d: want attempt %d, got %d", i, i+1, got)
+		}
+		success := i == 2
+		if got := s.attrs[AttrSuccess].Bool(); got != success {
+			t.Errorf("span %d: want success %v, got %v", i, success, got)
+		}
+		if _, ok := s.attrs[AttrBackoffDelay]; ok == success {
+			t.Errorf("span %d: backoff delay present: %v, want %v", i, ok, !success)
+		}
+		if (s.err != nil) == success {
+			t.Errorf("span %d: recorded error %v", i, s.err)
+		}
+		if _, ok := s.attrs[AttrNextRefresh]; !ok {
+			t.Errorf("span %d: no next refresh time", i)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Most tokens end up in the `Authorization` header of HTTP requests. `Transport()` does this for every request of an `http.Client`, so that the client code never sees a token.
+
+// Method `Transport` returns an `http.RoundTripper` that adds the current token to each request as `Authorization: Bearer <token>` and sends the request through `base`. If `base` is nil, `http.DefaultTransport` is used.
+// If the server responds with 401 Unauthorized, the token has probably been revoked. The transport then forces a refresh and retries the request once with the new token. Requests with a body are only retried if the body can be recreated through `Request.GetBody`, which `http.NewRequest` sets for common body types.
+func (a *Token) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{token: a, base: base}
+}
+
+type transport struct {
+	token *Token
+	base  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.send(req, false)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	// Pooled tokens cannot be force-refreshed, but each request gets a fresh token from the pool anyway.
+	if err := t.token.ForceRefresh(req.Context()); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return t.send(req, true)
+}
+
+// Method `send` sends a copy of `req` with the current token. A `RoundTripper` must not modify the original request. A retry gets a fresh copy of the body, as the first attempt has consumed the original one.
+func (t *transport) send(req *http.Request, retry bool) (*http.Response, error) {
+	token, err := t.token.GetContext(req.Context())
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	if retry && req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(r)
+}
+
+// `closeBody` closes the request body, as a `RoundTripper` must do even on errors.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
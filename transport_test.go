@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	var issued atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(issued.Add(1))), time.Hour, nil
+	}
+	tok := NewToken(context.Background(), auth, WithLogger(NopLogger))
+	defer tok.Close()
+
+	// The server revokes token 1 and accepts later tokens.
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") == "Bearer 1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: tok.Transport(nil)}
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "payload" {
+		t.Fatalf("want 200 with the original body, got %d %q", resp.StatusCode, body)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("want 2 requests, got %d", n)
+	}
+}
+
+func TestTransportRetriesOnce(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) { return "tok", time.Hour, nil }, WithLogger(NopLogger))
+	defer tok.Close()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	resp, err := (&http.Client{Transport: tok.Transport(nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || requests.Load() != 2 {
+		t.Fatalf("want 401 after 2 requests, got %d after %d", resp.StatusCode, requests.Load())
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Margins and backoff settings are a matter of tuning, and tuning happens while the app runs: the authorization server has become slower, or a rate limit demands longer backoff delays. Closing the token and creating a new one would throw away a perfectly good token. `Reconfigure` changes the settings of the running token instead.
+
+// A `reconfigRequest` carries new refresh settings to the refresh goroutine.
+type reconfigRequest struct {
+	opts []Option
+	done chan struct{}
+}
+
+// Method `Reconfigure` replaces the token's refresh settings: `WithSafetyMargin`, `WithSafetyFraction`, `WithAdaptiveMargin`, `WithBackoff`, `WithBackoffJitter`, and `WithRefreshJitter`. Settings that `opts` does not include revert to their defaults. Other options have no effect.
+// The refresh goroutine applies the settings between two refreshes and reschedules the pending refresh of a valid token accordingly.
+// Tokens with a warm pool or on-demand refresh do not support `Reconfigure`.
+func (a *Token) Reconfigure(ctx context.Context, opts ...Option) error {
+	if a.opts.poolSize > 0 || a.onDemand() {
+		return fmt.Errorf("refresh: Reconfigure with warm pool or on-demand refresh: %w", errors.ErrUnsupported)
+	}
+	req := reconfigRequest{opts: opts, done: make(chan struct{})}
+	for {
+		stopped := a.ensureStarted()
+		select {
+		case a.reconfigs <- req:
+			<-req.done
+			return nil
+		case <-a.closing():
+			return ErrClosed
+		case <-stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Method `applyTuning` applies the settings of `opts` to the token. Only the refresh goroutine calls it, and only the refresh goroutine reads these settings, so they need no lock.
+func (a *Token) applyTuning(opts []Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	a.opts.safetyMargin, a.opts.safetyMarginSet = o.safetyMargin, o.safetyMarginSet
+	a.opts.safetyFraction = o.safetyFraction
+	a.opts.adaptiveMargin = o.adaptiveMargin
+	a.opts.backoff = o.backoff
+	a.opts.backoffJitter = o.backoffJitter
+	a.opts.refreshJitter = o.refreshJitter
+}
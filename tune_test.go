@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// A new margin reschedules the pending refresh, counted from the time the token was fetched.
+func TestReconfigure(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		calls.Add(1)
+		return "tok", time.Hour, nil
+	}, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+	tok.Get()
+	clock.Advance(10 * time.Minute)
+
+	if err := tok.Reconfigure(context.Background(), WithSafetyMargin(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(20*time.Minute - time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("refreshed too early (%d calls)", n)
+	}
+	clock.Advance(time.Millisecond)
+	waitFor(t, time.Second, func() bool { return calls.Load() == 2 })
+
+	// Without a margin, the default applies again.
+	if err := tok.Reconfigure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tok.opts.safetyMargin != 0 {
+		t.Fatalf("want default margin, got %v", tok.opts.safetyMargin)
+	}
+}
+
+func TestReconfigureUnsupported(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "tok", time.Hour, nil
+	}, WithOnDemandRefresh(), WithLogger(NopLogger))
+	defer tok.Close()
+	if err := tok.Reconfigure(context.Background(), WithSafetyMargin(time.Minute)); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("want ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// A `Token` that nobody reads still refreshes itself forever, unless its context gets canceled. Such a token is most likely a leak.
+
+// `WithUnusedWarning` logs a warning (as `EventUnused`) if `Get()` or one of its variants has not been called once within `d` after the token was created. This helps spot tokens that were created but forgotten, and whose goroutine should be stopped.
+func WithUnusedWarning(d time.Duration) Option {
+	return func(o *options) {
+		o.unusedAfter = d
+	}
+}
+
+// Method `watchUnused` checks once, after the configured duration, whether the token has ever been read.
+func (a *Token) watchUnused(ctx context.Context) {
+	select {
+	case <-a.after(a.opts.unusedAfter):
+		if a.stats.gets.Load() == 0 {
+			a.logEvent(ctx, EventUnused, "Token was never read; consider stopping it", "after", a.opts.unusedAfter)
+		}
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUnusedWarning(t *testing.T) {
+	auth := func() (string, time.Duration, error) { return "tok", time.Hour, nil }
+	const msg = "Token was never read; consider stopping it"
+
+	for _, read := range []bool{false, true} {
+		logger := &captureLogger{}
+		ctx, cancel := context.WithCancel(context.Background())
+		tok := NewToken(ctx, auth, WithLogger(logger), WithUnusedWarning(20*time.Millisecond))
+		if read {
+			tok.Get()
+		}
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		_, warned := logger.levels()[msg]
+		if warned == read {
+			t.Errorf("read: %v, warned: %v", read, warned)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "errors"
+
+// An authorization server might return a broken token, for example, an empty string or a JWT for the wrong audience. Once served, such a token spreads to every client session. A validator catches it first.
+
+// `ErrInvalidToken` wraps the error of a failed validation.
+var ErrInvalidToken = errors.New("refresh: token validation failed")
+
+// `WithValidator` sets a function that checks each new token before it gets served. If the function returns an error, the refresh counts as failed and gets retried like any other failed refresh. Validation runs before the warmup request, if one is configured.
+func WithValidator(validate func(token string) error) Option {
+	return func(o *options) {
+		o.validate = validate
+	}
+}
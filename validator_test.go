@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidator(t *testing.T) {
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		if calls.Add(1) == 1 {
+			return "", time.Hour, nil
+		}
+		return "tok", time.Hour, nil
+	}
+	errEmpty := errors.New("empty token")
+	validate := func(token string) error {
+		if token == "" {
+			return errEmpty
+		}
+		return nil
+	}
+	tok := NewToken(context.Background(), auth, WithValidator(validate), WithLogger(NopLogger))
+	defer tok.Close()
+
+	if _, err := tok.Get(); !errors.Is(err, ErrInvalidToken) || !errors.Is(err, errEmpty) {
+		t.Fatalf("want ErrInvalidToken wrapping %v, got %v", errEmpty, err)
+	}
+	waitFor(t, time.Second, func() bool {
+		got, _ := tok.Get()
+		return got == "tok"
+	})
+	if s := tok.Stats(); s.Failures != 1 || s.Refreshes != 1 {
+		t.Fatalf("want 1 failure and 1 refresh, got %+v", s)
+	}
+}
@@ -0,0 +1,259 @@
+// Package vault provides authorization functions for HashiCorp Vault, ready to be passed to `NewTokenContext` and `NewRefresher` of the parent package.
+//
+// The package uses only the standard library and talks to Vault's HTTP API directly. Lifespans come from Vault's `lease_duration`, so the parent package refreshes each token or secret shortly before its lease ends.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Option configures an authorization function.
+type Option func(*config)
+
+type config struct {
+	client        *http.Client
+	namespace     string
+	defaultExpiry time.Duration
+}
+
+// WithHTTPClient sets the client for Vault requests. The default is `http.DefaultClient`.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = c
+	}
+}
+
+// WithNamespace sends each request to the given Vault Enterprise namespace.
+func WithNamespace(ns string) Option {
+	return func(cfg *config) {
+		cfg.namespace = ns
+	}
+}
+
+// WithDefaultExpiry sets the lifespan of tokens and secrets whose lease duration is zero, which Vault uses for leases that never expire. Without this option, such a response is an error.
+func WithDefaultExpiry(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.defaultExpiry = d
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ErrInvalidResponse is returned if Vault responds with success but the response cannot be used.
+var ErrInvalidResponse = errors.New("vault: invalid response")
+
+// ResponseError is returned if Vault responds with an error status. Errors holds the messages of Vault's error response.
+type ResponseError struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *ResponseError) Error() string {
+	msg := fmt.Sprintf("vault: server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if len(e.Errors) > 0 {
+		msg += ": " + strings.Join(e.Errors, "; ")
+	}
+	return msg
+}
+
+// Temporary reports whether the error is likely to go away by retrying. This is the case for server errors (5xx), rate limiting (429), and a sealed or standby server (503). Client errors (4xx), such as a denied login, are not temporary.
+func (e *ResponseError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// A LoginMethod describes how to log in to Vault.
+type LoginMethod struct {
+	// Path is the login endpoint relative to "/v1/", for example, "auth/approle/login".
+	Path string
+	// Body returns the request body. It is called for each login, so that it can read credentials that rotate, like a Kubernetes service account token.
+	Body func() (map[string]any, error)
+}
+
+// AppRole logs in with the AppRole auth method mounted at "approle".
+func AppRole(roleID, secretID string) LoginMethod {
+	return LoginMethod{
+		Path: "auth/approle/login",
+		Body: func() (map[string]any, error) {
+			return map[string]any{"role_id": roleID, "secret_id": secretID}, nil
+		},
+	}
+}
+
+// DefaultServiceAccountTokenPath is where Kubernetes mounts the service account token of a pod.
+const DefaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Kubernetes logs in with the Kubernetes auth method mounted at "kubernetes". The service account token is read from jwtPath on each login. An empty jwtPath means `DefaultServiceAccountTokenPath`.
+func Kubernetes(role, jwtPath string) LoginMethod {
+	if jwtPath == "" {
+		jwtPath = DefaultServiceAccountTokenPath
+	}
+	return LoginMethod{
+		Path: "auth/kubernetes/login",
+		Body: func() (map[string]any, error) {
+			jwt, err := os.ReadFile(jwtPath)
+			if err != nil {
+				return nil, fmt.Errorf("vault: reading service account token: %w", err)
+			}
+			return map[string]any{"role": role, "jwt": strings.TrimSpace(string(jwt))}, nil
+		},
+	}
+}
+
+// NewLogin returns an authorization function that logs in to the Vault server at addr and returns the client token. On subsequent calls, it renews the current token instead of logging in again, as long as the token is renewable. When a renewal fails, or when the renewed lease is shorter than half of the original lease because the token approaches its maximum TTL, the function logs in again.
+func NewLogin(addr string, method LoginMethod, opts ...Option) func(ctx context.Context) (string, time.Duration, error) {
+	cfg := newConfig(opts)
+	var mu sync.Mutex
+	var token string
+	var renewable bool
+	var lease time.Duration
+	return func(ctx context.Context) (string, time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if token != "" && renewable {
+			r, err := cfg.do(ctx, addr, http.MethodPost, "auth/token/renew-self", token, nil)
+			if err == nil && r.Auth != nil {
+				if d, err := cfg.lifespan(r.Auth.LeaseDuration); err == nil && d >= lease/2 {
+					return token, d, nil
+				}
+			}
+		}
+		body, err := method.Body()
+		if err != nil {
+			return "", 0, err
+		}
+		r, err := cfg.do(ctx, addr, http.MethodPost, method.Path, "", body)
+		if err != nil {
+			return "", 0, err
+		}
+		if r.Auth == nil || r.Auth.ClientToken == "" {
+			return "", 0, fmt.Errorf("%w: no client token", ErrInvalidResponse)
+		}
+		d, err := cfg.lifespan(r.Auth.LeaseDuration)
+		if err != nil {
+			return "", 0, err
+		}
+		token, renewable, lease = r.Auth.ClientToken, r.Auth.Renewable, d
+		return token, d, nil
+	}
+}
+
+// A Secret is a dynamic secret, such as database credentials.
+type Secret struct {
+	LeaseID string
+	Data    map[string]any
+}
+
+// NewSecret returns a fetch function for `NewRefresher` that reads the secret at path, for example, "database/creds/readonly". Each call reads a new secret, and the lifespan is the secret's lease duration. vaultToken returns the client token for the request; `GetContext` of a token created with `NewLogin` fits.
+func NewSecret(addr, path string, vaultToken func(ctx context.Context) (string, error), opts ...Option) func(ctx context.Context) (Secret, time.Duration, error) {
+	cfg := newConfig(opts)
+	return func(ctx context.Context) (Secret, time.Duration, error) {
+		token, err := vaultToken(ctx)
+		if err != nil {
+			return Secret{}, 0, err
+		}
+		r, err := cfg.do(ctx, addr, http.MethodGet, path, token, nil)
+		if err != nil {
+			return Secret{}, 0, err
+		}
+		d, err := cfg.lifespan(r.LeaseDuration)
+		if err != nil {
+			return Secret{}, 0, err
+		}
+		return Secret{LeaseID: r.LeaseID, Data: r.Data}, d, nil
+	}
+}
+
+// response is Vault's response to a login, renewal, or secret read.
+type response struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int64          `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// maxResponseSize limits how much of a response gets read.
+const maxResponseSize = 1 << 20
+
+// lifespan converts a lease duration in seconds.
+func (cfg *config) lifespan(secs int64) (time.Duration, error) {
+	switch {
+	case secs > 0:
+		return time.Duration(secs) * time.Second, nil
+	case secs == 0 && cfg.defaultExpiry > 0:
+		return cfg.defaultExpiry, nil
+	}
+	return 0, fmt.Errorf("%w: lease_duration %d", ErrInvalidResponse, secs)
+}
+
+// do sends a request to path below "/v1/" and parses the response.
+func (cfg *config) do(ctx context.Context, addr, method, path, token string, body map[string]any) (*response, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("vault: %w", err)
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(addr, "/")+"/v1/"+path, rd)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if cfg.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", cfg.namespace)
+	}
+
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("vault: reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		e := &ResponseError{StatusCode: resp.StatusCode}
+		var er struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(b, &er) == nil {
+			e.Errors = er.Errors
+		}
+		return nil, e
+	}
+
+	var r response
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return &r, nil
+}
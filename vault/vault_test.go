@@ -0,0 +1,120 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeVault serves AppRole logins and token renewals. renewLease is the lease duration that renewals return.
+func fakeVault(t *testing.T, renewLease *int) (*httptest.Server, *int) {
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Namespace") != "ns" {
+			t.Errorf("missing namespace header")
+		}
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["role_id"] != "role" || body["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+				return
+			}
+			logins++
+			fmt.Fprintf(w, `{"auth":{"client_token":"s.%d","lease_duration":3600,"renewable":true}}`, logins)
+		case "/v1/auth/token/renew-self":
+			if r.Header.Get("X-Vault-Token") == "" {
+				t.Errorf("renewal without token")
+			}
+			fmt.Fprintf(w, `{"auth":{"client_token":%q,"lease_duration":%d,"renewable":true}}`, r.Header.Get("X-Vault-Token"), *renewLease)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return srv, &logins
+}
+
+func TestLoginRenews(t *testing.T) {
+	renewLease := 3600
+	srv, logins := fakeVault(t, &renewLease)
+	defer srv.Close()
+	auth := NewLogin(srv.URL, AppRole("role", "secret"), WithNamespace("ns"))
+
+	for i := 0; i < 3; i++ {
+		token, lifespan, err := auth(context.Background())
+		if err != nil || token != "s.1" || lifespan != time.Hour {
+			t.Fatalf("call %d: want (s.1, 1h, nil), got (%q, %v, %v)", i, token, lifespan, err)
+		}
+	}
+	if *logins != 1 {
+		t.Fatalf("want 1 login, got %d", *logins)
+	}
+
+	// The token approaches its maximum TTL. Log in again.
+	renewLease = 60
+	token, _, err := auth(context.Background())
+	if err != nil || token != "s.2" {
+		t.Fatalf("want new token s.2, got (%q, %v)", token, err)
+	}
+}
+
+func TestLoginError(t *testing.T) {
+	renewLease := 0
+	srv, _ := fakeVault(t, &renewLease)
+	defer srv.Close()
+	_, _, err := NewLogin(srv.URL, AppRole("role", "wrong"), WithNamespace("ns"))(context.Background())
+	var re *ResponseError
+	if !errors.As(err, &re) || re.StatusCode != http.StatusBadRequest || re.Temporary() || len(re.Errors) != 1 {
+		t.Fatalf("want permanent ResponseError, got %v", err)
+	}
+}
+
+func TestKubernetesLogin(t *testing.T) {
+	jwtPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwtPath, []byte("sa-jwt\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/v1/auth/kubernetes/login" || body["role"] != "app" || body["jwt"] != "sa-jwt" {
+			t.Errorf("unexpected login %s %v", r.URL.Path, body)
+		}
+		w.Write([]byte(`{"auth":{"client_token":"s.k8s","lease_duration":0,"renewable":false}}`))
+	}))
+	defer srv.Close()
+
+	if _, _, err := NewLogin(srv.URL, Kubernetes("app", jwtPath))(context.Background()); !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("want ErrInvalidResponse for zero lease, got %v", err)
+	}
+	token, lifespan, err := NewLogin(srv.URL, Kubernetes("app", jwtPath), WithDefaultExpiry(time.Hour))(context.Background())
+	if err != nil || token != "s.k8s" || lifespan != time.Hour {
+		t.Fatalf("want (s.k8s, 1h, nil), got (%q, %v, %v)", token, lifespan, err)
+	}
+}
+
+func TestSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/database/creds/readonly" || r.Header.Get("X-Vault-Token") != "s.1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"lease_id":"database/creds/readonly/abc","lease_duration":300,"renewable":true,"data":{"username":"u","password":"p"}}`))
+	}))
+	defer srv.Close()
+
+	fetch := NewSecret(srv.URL, "database/creds/readonly", func(ctx context.Context) (string, error) { return "s.1", nil })
+	s, lifespan, err := fetch(context.Background())
+	if err != nil || lifespan != 5*time.Minute || s.LeaseID != "database/creds/readonly/abc" || s.Data["username"] != "u" {
+		t.Fatalf("unexpected secret (%+v, %v, %v)", s, lifespan, err)
+	}
+}
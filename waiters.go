@@ -0,0 +1,76 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// While the refresh goroutine waits for the authorization server, it serves no reader. Readers that arrive in the meantime block on the `accessToken` channel, and once the refresh is done, the goroutine hands the token to them one at a time, competing with timers and forced refreshes in a `select` that picks at random. Under heavy load, a reader can lose that race many times in a row.
+// The wait queue avoids this. A reader that finds a refresh in progress queues up, and the refresh goroutine hands the result to all queued readers at once, in the order in which they arrived.
+
+// `waitQueue` holds the readers that wait for a refresh in progress.
+type waitQueue struct {
+	mu sync.Mutex
+	// `refreshing` is true while the refresh goroutine fetches a token.
+	refreshing bool
+	// `started` is closed when a refresh begins, to wake readers that blocked on the `accessToken` channel before.
+	started chan struct{}
+	queue   []waiter
+}
+
+// A `waiter` is a queued reader. `ch` is buffered, so that handing it the result never blocks on a reader that has given up.
+type waiter struct {
+	ch    chan tokenResponse
+	since time.Time
+}
+
+// Method `join` queues a reader if a refresh is in progress, and returns the channel on which it receives the result. Otherwise, it returns nil along with a channel that is closed when the next refresh begins.
+func (q *waitQueue) join(now time.Time) (result <-chan tokenResponse, started <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.refreshing {
+		ch := make(chan tokenResponse, 1)
+		q.queue = append(q.queue, waiter{ch: ch, since: now})
+		return ch, nil
+	}
+	if q.started == nil {
+		q.started = make(chan struct{})
+	}
+	return nil, q.started
+}
+
+// Method `begin` marks a refresh as in progress and wakes the readers that wait on the `accessToken` channel, so that they queue up.
+func (q *waitQueue) begin() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.refreshing = true
+	if q.started != nil {
+		close(q.started)
+		q.started = nil
+	}
+}
+
+// Method `release` ends the refresh and hands `resp` to all queued readers in FIFO order. It returns the longest time that any of them has waited.
+func (q *waitQueue) release(resp tokenResponse, now time.Time) time.Duration {
+	q.mu.Lock()
+	queue := q.queue
+	q.queue = nil
+	q.refreshing = false
+	q.mu.Unlock()
+	var longest time.Duration
+	for _, w := range queue {
+		w.ch <- resp
+		longest = max(longest, now.Sub(w.since))
+	}
+	return longest
+}
+
+// Method `beginRefresh` is called by the refresh goroutine before it fetches a token.
+func (a *Token) beginRefresh() {
+	a.waiters.begin()
+}
+
+// Method `endRefresh` is called by the refresh goroutine with the result of a refresh. It releases the queued readers and records how long they waited.
+func (a *Token) endRefresh(resp tokenResponse) {
+	a.stats.waited(a.waiters.release(resp, a.now()))
+}
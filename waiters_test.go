@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Readers that arrive during a slow refresh all receive its result once it is done.
+func TestWaitQueue(t *testing.T) {
+	release := make(chan struct{})
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		<-release
+		return "tok", time.Hour, nil
+	}, WithLogger(NopLogger))
+	defer tok.Close()
+
+	const readers = 50
+	results := make(chan string, readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			got, _ := tok.Get()
+			results <- got
+		}()
+	}
+	waitFor(t, time.Second, func() bool {
+		tok.waiters.mu.Lock()
+		defer tok.waiters.mu.Unlock()
+		return len(tok.waiters.queue) == readers
+	})
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < readers; i++ {
+		select {
+		case got := <-results:
+			if got != "tok" {
+				t.Fatalf("want tok, got %q", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%d readers still waiting", readers-i)
+		}
+	}
+	if w := tok.Stats().MaxGetWait; w < 10*time.Millisecond {
+		t.Fatalf("want MaxGetWait >= 10ms, got %v", w)
+	}
+}
+
+// `release` hands the result to every waiter, including those that gave up, and reports the longest wait.
+func TestWaitQueueRelease(t *testing.T) {
+	var q waitQueue
+	start := time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC)
+	if result, started := q.join(start); result != nil || started == nil {
+		t.Fatal("want no queueing outside a refresh")
+	}
+	_, started := q.join(start)
+	q.begin()
+	select {
+	case <-started:
+	default:
+		t.Fatal("begin did not wake the readers")
+	}
+	var chans []<-chan tokenResponse
+	for i := 0; i < 3; i++ {
+		ch, _ := q.join(start.Add(time.Duration(i) * time.Second))
+		chans = append(chans, ch)
+	}
+	if longest := q.release(tokenResponse{Token: "tok"}, start.Add(5*time.Second)); longest != 5*time.Second {
+		t.Fatalf("want longest wait 5s, got %v", longest)
+	}
+	for i, ch := range chans {
+		if r := <-ch; r.Token != "tok" {
+			t.Fatalf("waiter %d: want tok, got %+v", i, r)
+		}
+	}
+	if result, _ := q.join(start); result != nil {
+		t.Fatal("want no queueing after release")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// A client that keeps derived state, such as an authenticated connection, needs to know whether the token changed since it last looked. Comparing token strings works, but `Watch()` makes this cheaper: every refresh attempt bumps a version number, and clients just compare numbers.
+
+// `ErrWatchUnsupported` is returned by `Watch()` for tokens with a warm pool, which have no single current token.
+var ErrWatchUnsupported = errors.New("refresh: watch not supported for this token")
+
+// Method `Watch` blocks until the token's version exceeds `lastVersion`, then returns the current token, its version, and the refresh error, if any. Pass 0 to get the first available token. The version is the same as `Generation()`: it increases with each refresh attempt, including failed ones and authorizer swaps, so that a version can occur with an unchanged token when stale serving is enabled.
+// `Watch` returns `ctx.Err()` if `ctx` is done first, and `ErrClosed` after the token has been closed.
+// With `WithOnDemandRefresh`, only `Get()` triggers refreshes, and `Watch` sees the versions that these refreshes produce.
+func (a *Token) Watch(ctx context.Context, lastVersion uint64) (string, uint64, error) {
+	resp, version, err := a.watch(ctx, lastVersion)
+	if err == nil {
+		err = resp.Err
+	}
+	return resp.Token, version, err
+}
+
+// Method `watch` works like `Watch`, but returns the whole response, so that callers get the expiry time that belongs to the token.
+func (a *Token) watch(ctx context.Context, lastVersion uint64) (tokenResponse, uint64, error) {
+	if a.opts.poolSize > 0 {
+		return tokenResponse{}, 0, ErrWatchUnsupported
+	}
+	a.ensureStarted()
+	s := &a.subs
+	for {
+		s.mu.Lock()
+		if s.last != nil && s.version > lastVersion {
+			resp, version := *s.last, s.version
+			s.mu.Unlock()
+			return resp, version, nil
+		}
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return tokenResponse{}, 0, ctx.Err()
+		case <-a.closing():
+			return tokenResponse{}, 0, ErrClosed
+		}
+	}
+}
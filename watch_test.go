@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appliedgo/refresh/refreshhttp"
+	"github.com/appliedgo/refresh/refreshtest"
+)
+
+// `*Token` can be served by `refreshhttp`.
+var _ refreshhttp.Source = (*Token)(nil)
+
+// `Watch` returns immediately for an old version and blocks until the next refresh for the current one.
+func TestWatch(t *testing.T) {
+	clock := refreshtest.NewClock(time.Date(2023, 10, 18, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	auth := func() (string, time.Duration, error) {
+		return strconv.Itoa(int(calls.Add(1))), time.Minute, nil
+	}
+	tok := NewToken(context.Background(), auth, WithClock(clock), WithLogger(NopLogger))
+	defer tok.Close()
+
+	token, v1, err := tok.Watch(context.Background(), 0)
+	if err != nil || token != "1" {
+		t.Fatalf("want token 1, got %q, %v", token, err)
+	}
+
+	type result struct {
+		token   string
+		version uint64
+		err     error
+	}
+	next := make(chan result)
+	go func() {
+		token, v, err := tok.Watch(context.Background(), v1)
+		next <- result{token, v, err}
+	}()
+	select {
+	case r := <-next:
+		t.Fatalf("Watch returned before refresh: %+v", r)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	waitFor(t, time.Second, func() bool { return clock.Timers() > 0 })
+	clock.Advance(time.Minute)
+	select {
+	case r := <-next:
+		if r.err != nil || r.token != "2" || r.version <= v1 {
+			t.Fatalf("want token 2 with version > %d, got %+v", v1, r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Watch did not return after refresh")
+	}
+}
+
+// `Watch` gives up when its context is done or the token is closed.
+func TestWatchCancel(t *testing.T) {
+	tok := NewToken(context.Background(), func() (string, time.Duration, error) {
+		return "t", time.Hour, nil
+	}, WithLogger(NopLogger))
+	_, v, _ := tok.Watch(context.Background(), 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := tok.Watch(ctx, v); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tok.Close()
+	}()
+	if _, _, err := tok.Watch(context.Background(), v); !errors.Is(err, ErrClosed) {
+		t.Fatalf("want ErrClosed, got %v", err)
+	}
+}